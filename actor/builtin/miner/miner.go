@@ -51,6 +51,10 @@ const LargestSectorGenerationAttackThresholdBlocks = 100
 // MinimumCollateralPerSector is the minimum amount of collateral required per sector
 var MinimumCollateralPerSector, _ = types.NewAttoFILFromFILString("0.001")
 
+// ConsensusFaultReporterRewardDivisor is the fraction of a slashed miner's
+// collateral paid to the reporter of its consensus fault.  The rest is burnt.
+const ConsensusFaultReporterRewardDivisor = 10
+
 // ClientProofOfStorageTimeoutBlocks is the number of blocks between LastPoSt and the current block height
 // after which the miner is no longer considered to be storing the client's piece and they are entitled to
 // a refund.
@@ -269,6 +273,10 @@ var minerExports = exec.Exports{
 		Params: nil,
 		Return: []abi.Type{abi.BytesAmount},
 	},
+	"slashConsensusFault": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Address},
+		Return: []abi.Type{abi.BytesAmount},
+	},
 }
 
 // Exports returns the miner actors exported functions.
@@ -840,6 +848,56 @@ func (ma *Actor) GetProvingPeriodStart(ctx exec.VMContext) (*types.BlockHeight,
 	return state.ProvingPeriodStart, 0, nil
 }
 
+// SlashConsensusFault penalizes the miner for a consensus fault reported by
+// reporter.  The miner loses all its power, sectors and pledged collateral: a
+// share of the collateral is paid to the reporter and the rest is burnt.  The
+// rest of its balance is left to the owner.  It returns the power removed so
+// that the storage market can update the total power.  Only the storage
+// market, which verifies the fault, may call it.
+func (ma *Actor) SlashConsensusFault(ctx exec.VMContext, reporter address.Address) (*types.BytesAmount, uint8, error) {
+	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	ret, err := actor.WithState(ctx, &state, func() (interface{}, error) {
+		if ctx.Message().From != address.StorageMarketAddress {
+			return nil, Errors[ErrCallerUnauthorized]
+		}
+
+		power := state.Power
+		collateral := state.ActiveCollateral
+		if collateral.GreaterThan(ctx.MyBalance()) {
+			collateral = ctx.MyBalance()
+		}
+		state.Power = types.NewBytesAmount(0)
+		state.ActiveCollateral = types.ZeroAttoFIL
+		state.SectorCommitments = make(map[string]types.Commitments)
+
+		reward := types.NewAttoFIL(big.NewInt(0).Div(collateral.AsBigInt(), big.NewInt(ConsensusFaultReporterRewardDivisor)))
+		if reward.IsPositive() {
+			if _, _, err := ctx.Send(reporter, "", reward, nil); err != nil {
+				return nil, errors.RevertErrorWrap(err, "Failed to pay consensus fault reporter")
+			}
+		}
+		if err := ma.burnFunds(ctx, collateral.Sub(reward)); err != nil {
+			return nil, errors.RevertErrorWrap(err, "Failed to burn collateral")
+		}
+
+		return power, nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	power, ok := ret.(*types.BytesAmount)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected *types.BytesAmount to be returned, but got %T instead", ret)
+	}
+
+	return power, 0, nil
+}
+
 //
 // Un-exported methods
 //
@@ -23,12 +23,21 @@ const (
 	ErrUnknownMiner = 34
 	// ErrUnsupportedSectorSize indicates that the sector size is incompatible with the proofs mode.
 	ErrUnsupportedSectorSize = 44
+	// ErrInvalidConsensusFault indicates that the reported blocks do not prove a consensus fault.
+	ErrInvalidConsensusFault = 45
+	// ErrMinerCallFailed indicates the call to the miner failed.
+	ErrMinerCallFailed = 46
+	// ErrConsensusFaultSlashed indicates that the miner was already slashed for a fault at the same height.
+	ErrConsensusFaultSlashed = 47
 )
 
 // Errors map error codes to revert errors this actor may return.
 var Errors = map[uint8]error{
 	ErrUnknownMiner:          errors.NewCodedRevertErrorf(ErrUnknownMiner, "unknown miner"),
 	ErrUnsupportedSectorSize: errors.NewCodedRevertErrorf(ErrUnsupportedSectorSize, "sector size is not supported"),
+	ErrInvalidConsensusFault: errors.NewCodedRevertErrorf(ErrInvalidConsensusFault, "blocks do not prove a consensus fault"),
+	ErrMinerCallFailed:       errors.NewCodedRevertErrorf(ErrMinerCallFailed, "call to miner failed"),
+	ErrConsensusFaultSlashed: errors.NewCodedRevertErrorf(ErrConsensusFaultSlashed, "consensus fault was already slashed"),
 }
 
 func init() {
@@ -44,6 +53,10 @@ type Actor struct{}
 type State struct {
 	Miners cid.Cid `refmt:",omitempty"`
 
+	// ConsensusFaults holds the consensus faults already slashed, keyed by
+	// the miner and height of the blocks proving them.
+	ConsensusFaults cid.Cid `refmt:",omitempty"`
+
 	// TODO: Determine correct unit of measure. Could be denominated in the
 	// smallest sector size supported by the network.
 	//
@@ -104,6 +117,10 @@ var storageMarketExports = exec.Exports{
 		Params: []abi.Type{},
 		Return: []abi.Type{abi.ProofsMode},
 	},
+	"slashConsensusFault": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Bytes, abi.Bytes},
+		Return: nil,
+	},
 }
 
 // CreateStorageMiner creates a new miner which will commit sectors of the
@@ -193,6 +210,101 @@ func (sma *Actor) UpdatePower(vmctx exec.VMContext, delta *types.BytesAmount) (u
 	return 0, nil
 }
 
+// SlashConsensusFault slashes the miner of two blocks that prove it
+// equivocated: both are signed by its worker key, at the same height, and
+// differ.  The miner loses its power and collateral, and the sender of the
+// report receives a share of the collateral.  A miner is slashed once for the
+// blocks it mined at a height, however many pairs of them are reported.
+//
+// The signatures are checked against the miner's current worker key, as the
+// miner actor keeps no history of its keys.  Worker keys can't be changed
+// yet; once they can, the key valid at the blocks' height must be checked
+// instead, or a miner could escape slashing by changing its key.
+func (sma *Actor) SlashConsensusFault(vmctx exec.VMContext, blockA, blockB []byte) (uint8, error) {
+	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	a, err := types.DecodeBlock(blockA)
+	if err != nil {
+		return errors.CodeError(Errors[ErrInvalidConsensusFault]), Errors[ErrInvalidConsensusFault]
+	}
+	b, err := types.DecodeBlock(blockB)
+	if err != nil {
+		return errors.CodeError(Errors[ErrInvalidConsensusFault]), Errors[ErrInvalidConsensusFault]
+	}
+	if a.Miner != b.Miner || a.Height != b.Height || a.Cid().Equals(b.Cid()) {
+		return errors.CodeError(Errors[ErrInvalidConsensusFault]), Errors[ErrInvalidConsensusFault]
+	}
+
+	var state State
+	_, err = actor.WithState(vmctx, &state, func() (interface{}, error) {
+		ctx := context.Background()
+
+		miners, err := actor.LoadLookup(ctx, vmctx.Storage(), state.Miners)
+		if err != nil {
+			return nil, errors.FaultErrorWrapf(err, "could not load lookup for miner with CID: %s", state.Miners)
+		}
+		_, err = miners.Find(ctx, a.Miner.String())
+		if err != nil {
+			if err == hamt.ErrNotFound {
+				return nil, Errors[ErrUnknownMiner]
+			}
+			return nil, errors.FaultErrorWrapf(err, "could not load lookup for miner with address: %s", a.Miner)
+		}
+
+		rets, code, err := vmctx.Send(a.Miner, "getKey", types.ZeroAttoFIL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if code != 0 {
+			return nil, Errors[ErrMinerCallFailed]
+		}
+		workerAddr, err := types.PublicKeyAddress(rets[0])
+		if err != nil {
+			return nil, Errors[ErrInvalidConsensusFault]
+		}
+		if !types.IsValidSignature(a.SignatureData(), workerAddr, a.BlockSig) || !types.IsValidSignature(b.SignatureData(), workerAddr, b.BlockSig) {
+			return nil, Errors[ErrInvalidConsensusFault]
+		}
+
+		faultKey := fmt.Sprintf("%s/%d", a.Miner, a.Height)
+		faults, err := actor.LoadLookup(ctx, vmctx.Storage(), state.ConsensusFaults)
+		if err != nil {
+			return nil, errors.FaultErrorWrapf(err, "could not load lookup for consensus faults with CID: %s", state.ConsensusFaults)
+		}
+		_, err = faults.Find(ctx, faultKey)
+		if err == nil {
+			return nil, Errors[ErrConsensusFaultSlashed]
+		}
+		if err != hamt.ErrNotFound {
+			return nil, errors.FaultErrorWrapf(err, "could not look up consensus fault %s", faultKey)
+		}
+
+		rets, code, err = vmctx.Send(a.Miner, "slashConsensusFault", types.ZeroAttoFIL, []interface{}{vmctx.Message().From})
+		if err != nil {
+			return nil, err
+		}
+		if code != 0 {
+			return nil, Errors[ErrMinerCallFailed]
+		}
+
+		state.TotalCommittedStorage = state.TotalCommittedStorage.Sub(types.NewBytesAmountFromBytes(rets[0]))
+
+		state.ConsensusFaults, err = actor.SetKeyValue(ctx, vmctx.Storage(), state.ConsensusFaults, faultKey, true)
+		if err != nil {
+			return nil, errors.FaultErrorWrapf(err, "could not record consensus fault %s", faultKey)
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
 // GetTotalStorage returns the total amount of proven storage in the system.
 func (sma *Actor) GetTotalStorage(vmctx exec.VMContext) (*types.BytesAmount, uint8, error) {
 	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
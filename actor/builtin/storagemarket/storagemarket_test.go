@@ -4,17 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"math/big"
 	"testing"
 
 	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/state"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -125,6 +130,153 @@ func TestProofsMode(t *testing.T) {
 	assert.Equal(t, types.TestProofsMode, proofsMode)
 }
 
+func TestStorageMarketSlashConsensusFault(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signer, kis := types.NewMockSignersAndKeyInfo(2)
+	workerKey := kis[0].PublicKey()
+
+	setup := func(t *testing.T) (state.Tree, vm.StorageMap, address.Address) {
+		st, vms := core.CreateStorages(ctx, t)
+
+		pdata := actor.MustConvertParams(workerKey, types.OneKiBSectorSize, th.RequireRandomPeerID(t))
+		msg := types.NewMessage(address.TestAddress, address.StorageMarketAddress, 0, types.NewAttoFILFromFIL(100), "createStorageMiner", pdata)
+		result, err := th.ApplyTestMessage(st, vms, msg, types.NewBlockHeight(0))
+		require.NoError(t, err)
+		require.NoError(t, result.ExecutionError)
+		minerAddr, err := address.NewFromBytes(result.Receipt.Return[0])
+		require.NoError(t, err)
+
+		result, err = th.CreateAndApplyTestMessage(t, st, vms, minerAddr, 0, 1, "commitSector", nil, uint64(1), th.MakeCommitment(), th.MakeCommitment(), th.MakeCommitment(), th.MakeRandomBytes(types.TwoPoRepProofPartitions.ProofLen()))
+		require.NoError(t, err)
+		require.NoError(t, result.ExecutionError)
+		return st, vms, minerAddr
+	}
+
+	report := func(t *testing.T, st state.Tree, vms vm.StorageMap, a, b *types.Block) *consensus.ApplicationResult {
+		pdata := actor.MustConvertParams(a.ToNode().RawData(), b.ToNode().RawData())
+		msg := types.NewMessage(address.TestAddress2, address.StorageMarketAddress, 0, types.ZeroAttoFIL, "slashConsensusFault", pdata)
+		result, err := th.ApplyTestMessage(st, vms, msg, types.NewBlockHeight(5))
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("slashes a miner that signed two blocks at the same height", func(t *testing.T) {
+		st, vms, minerAddr := setup(t)
+
+		blockA := &types.Block{Miner: minerAddr, Height: 4, Nonce: 1}
+		blockB := &types.Block{Miner: minerAddr, Height: 4, Nonce: 2}
+		th.RequireSignBlock(t, blockA, workerKey, signer)
+		th.RequireSignBlock(t, blockB, workerKey, signer)
+
+		reporter, err := st.GetActor(ctx, address.TestAddress2)
+		require.NoError(t, err)
+		reporterBalance := reporter.Balance
+
+		collateral := miner.CollateralForSector(types.OneKiBSectorSize)
+		reward := types.NewAttoFIL(big.NewInt(0).Div(collateral.AsBigInt(), big.NewInt(miner.ConsensusFaultReporterRewardDivisor)))
+		require.True(t, reward.IsPositive())
+
+		result := report(t, st, vms, blockA, blockB)
+		require.NoError(t, result.ExecutionError)
+
+		// Only the pledged collateral is taken from the miner.
+		minerActor, err := st.GetActor(ctx, minerAddr)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewAttoFILFromFIL(100).Sub(collateral), minerActor.Balance)
+
+		var mstor miner.State
+		builtin.RequireReadState(t, vms, minerAddr, minerActor, &mstor)
+		assert.Equal(t, types.NewBytesAmount(0), mstor.Power)
+		assert.Equal(t, types.ZeroAttoFIL, mstor.ActiveCollateral)
+		assert.Empty(t, mstor.SectorCommitments)
+
+		reporter, err = st.GetActor(ctx, address.TestAddress2)
+		require.NoError(t, err)
+		assert.Equal(t, reporterBalance.Add(reward), reporter.Balance)
+
+		burnt, err := st.GetActor(ctx, address.BurntFundsAddress)
+		require.NoError(t, err)
+		assert.Equal(t, collateral.Sub(reward), burnt.Balance)
+
+		var mktStor storagemarket.State
+		mkt, err := st.GetActor(ctx, address.StorageMarketAddress)
+		require.NoError(t, err)
+		builtin.RequireReadState(t, vms, address.StorageMarketAddress, mkt, &mktStor)
+		assert.Equal(t, types.NewBytesAmount(0), mktStor.TotalCommittedStorage)
+	})
+
+	t.Run("slashes a miner once per height", func(t *testing.T) {
+		st, vms, minerAddr := setup(t)
+
+		blockA := &types.Block{Miner: minerAddr, Height: 4, Nonce: 1}
+		blockB := &types.Block{Miner: minerAddr, Height: 4, Nonce: 2}
+		blockC := &types.Block{Miner: minerAddr, Height: 4, Nonce: 3}
+		for _, blk := range []*types.Block{blockA, blockB, blockC} {
+			th.RequireSignBlock(t, blk, workerKey, signer)
+		}
+
+		result := report(t, st, vms, blockA, blockB)
+		require.NoError(t, result.ExecutionError)
+
+		result = report(t, st, vms, blockA, blockB)
+		assert.Equal(t, storagemarket.Errors[storagemarket.ErrConsensusFaultSlashed], result.ExecutionError)
+		result = report(t, st, vms, blockB, blockC)
+		assert.Equal(t, storagemarket.Errors[storagemarket.ErrConsensusFaultSlashed], result.ExecutionError)
+	})
+
+	t.Run("rejects the same block reported twice", func(t *testing.T) {
+		st, vms, minerAddr := setup(t)
+
+		blockA := &types.Block{Miner: minerAddr, Height: 4}
+		th.RequireSignBlock(t, blockA, workerKey, signer)
+
+		result := report(t, st, vms, blockA, blockA)
+		assert.Equal(t, storagemarket.Errors[storagemarket.ErrInvalidConsensusFault], result.ExecutionError)
+	})
+
+	t.Run("rejects blocks not signed by the miner's worker key", func(t *testing.T) {
+		st, vms, minerAddr := setup(t)
+
+		blockA := &types.Block{Miner: minerAddr, Height: 4, Nonce: 1}
+		blockB := &types.Block{Miner: minerAddr, Height: 4, Nonce: 2}
+		th.RequireSignBlock(t, blockA, workerKey, signer)
+		th.RequireSignBlock(t, blockB, kis[1].PublicKey(), signer)
+
+		result := report(t, st, vms, blockA, blockB)
+		assert.Equal(t, storagemarket.Errors[storagemarket.ErrInvalidConsensusFault], result.ExecutionError)
+	})
+
+	// Signatures are checked against the current worker key, so blocks
+	// signed with a key the miner no longer has can't be reported.
+	t.Run("rejects blocks signed with a previous worker key", func(t *testing.T) {
+		st, vms, minerAddr := setup(t)
+
+		blockA := &types.Block{Miner: minerAddr, Height: 4, Nonce: 1}
+		blockB := &types.Block{Miner: minerAddr, Height: 4, Nonce: 2}
+		th.RequireSignBlock(t, blockA, workerKey, signer)
+		th.RequireSignBlock(t, blockB, workerKey, signer)
+
+		// Replace the worker key in the miner's state.
+		minerActor, err := st.GetActor(ctx, minerAddr)
+		require.NoError(t, err)
+		var mstor miner.State
+		builtin.RequireReadState(t, vms, minerAddr, minerActor, &mstor)
+		mstor.PublicKey = kis[1].PublicKey()
+		storage := vms.NewStorage(minerAddr, minerActor)
+		head, err := storage.Put(&mstor)
+		require.NoError(t, err)
+		require.NoError(t, storage.Commit(head, minerActor.Head))
+		require.NoError(t, st.SetActor(ctx, minerAddr, minerActor))
+
+		result := report(t, st, vms, blockA, blockB)
+		assert.Equal(t, storagemarket.Errors[storagemarket.ErrInvalidConsensusFault], result.ExecutionError)
+	})
+}
+
 // this is used to simulate an attack where someone derives the likely address of another miner's
 // minerActor and sends some FIL. If that FIL creates an actor tha cannot be upgraded to a miner
 // actor, this action will block the other user. Another possibility is that the miner actor will
@@ -149,6 +149,7 @@ func build() {
 	buildGengen()
 	buildFaucet()
 	buildGenesisFileServer()
+	buildRemoteSigner()
 	generateGenesis()
 	buildMigrations()
 	buildPrereleaseTool()
@@ -159,6 +160,7 @@ func forcebuild() {
 	buildGengen()
 	buildFaucet()
 	buildGenesisFileServer()
+	buildRemoteSigner()
 	generateGenesis()
 	buildMigrations()
 	buildPrereleaseTool()
@@ -272,6 +274,12 @@ func buildGenesisFileServer() {
 	runCmd(cmd([]string{"go", "build", "-o", "./tools/genesis-file-server/genesis-file-server", "./tools/genesis-file-server/"}...))
 }
 
+func buildRemoteSigner() {
+	log.Println("Building remote signer...")
+
+	runCmd(cmd([]string{"go", "build", "-o", "./tools/remote-signer/remote-signer", "./tools/remote-signer/"}...))
+}
+
 func buildMigrations() {
 	log.Println("Building migrations...")
 	runCmd(cmd([]string{
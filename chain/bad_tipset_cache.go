@@ -1,42 +1,249 @@
 package chain
 
 import (
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
-// badTipSetCache keeps track of bad tipsets that the syncer should not try to
-// download. Readers and writers grab a lock. The purpose of this cache is to
-// prevent a node from having to repeatedly invalidate a block (and its children)
-// in the event that the tipset does not conform to the rules of consensus. Note
-// that the cache is only in-memory, so it is reset whenever the node is restarted.
-// TODO: this needs to be limited.
-type badTipSetCache struct {
-	mu  sync.Mutex
-	bad map[string]struct{}
+func init() {
+	cbor.RegisterCborType(BadTipSet{})
+}
+
+// badTipSetPrefix is the datastore prefix under which bad tipsets are stored.
+const badTipSetPrefix = "/chain/bad"
+
+// MaxBadTipSets is the default bound on the number of entries of the bad
+// tipset cache.
+const MaxBadTipSets = 2048
+
+// ErrBadTipSetNotFound is returned when looking up a tipset that is not in
+// the bad tipset cache.
+var ErrBadTipSetNotFound = errors.New("tipset is not in the bad tipset cache")
+
+// BadTipSet is an entry of the bad tipset cache, recording why a tipset was
+// refused and which peer sent it.
+type BadTipSet struct {
+	Key    types.SortedCidSet
+	Height uint64
+	Reason string
+	// Peer is the peer the tipset was received from, empty if it was
+	// produced locally.
+	Peer string
+}
+
+// BadTipSetCache keeps track of bad tipsets that the syncer should not try to
+// download. The purpose of this cache is to prevent a node from having to
+// repeatedly invalidate a block (and its children) in the event that the
+// tipset does not conform to the rules of consensus. The cache is persisted in
+// the chain datastore so that it survives restarts, and entries can be
+// removed to override a false positive.  Once it holds limit entries, adding
+// one evicts the lowest quarter of them, which the chain has most likely
+// moved past.
+type BadTipSetCache struct {
+	ds    repo.Datastore
+	limit int
+
+	// mu protects size and the consistency of size with ds.
+	mu sync.Mutex
+	// size is the number of entries, counted on first use, or -1.
+	size int
+}
+
+// NewBadTipSetCache returns a bad tipset cache persisting up to limit entries
+// in ds.
+func NewBadTipSetCache(ds repo.Datastore, limit int) *BadTipSetCache {
+	return &BadTipSetCache{ds: ds, limit: limit, size: -1}
 }
 
-// AddChain adds the chain of tipsets to the badTipSetCache.  For now it just
-// does the simplest thing and adds all blocks of the chain to the cache.
-// TODO: might want to cache a random subset once cache size is limited.
-func (cache *badTipSetCache) AddChain(chain []types.TipSet) {
-	for _, ts := range chain {
-		cache.Add(ts.String())
+// AddChain adds the chain of tipsets to the BadTipSetCache.  The first tipset
+// of the chain failed validation with the given reason, the others descend
+// from it.  For now it just does the simplest thing and adds all tipsets of
+// the chain to the cache.
+func (cache *BadTipSetCache) AddChain(chain []types.TipSet, reason error, from peer.ID) error {
+	if len(chain) == 0 {
+		return nil
 	}
+	failed := chain[0].String()
+	for i, ts := range chain {
+		why := reason.Error()
+		if i > 0 {
+			why = "descends from bad tipset " + failed + ": " + why
+		}
+		if err := cache.Add(ts, why, from); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Add adds a single tipset key to the badTipSetCache.
-func (cache *badTipSetCache) Add(tsKey string) {
+// Add adds a single tipset to the BadTipSetCache.
+func (cache *BadTipSetCache) Add(ts types.TipSet, reason string, from peer.ID) error {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	cache.bad[tsKey] = struct{}{}
+
+	h, err := ts.Height()
+	if err != nil {
+		return err
+	}
+	entry := &BadTipSet{
+		Key:    ts.ToSortedCidSet(),
+		Height: h,
+		Reason: reason,
+	}
+	if from != "" {
+		entry.Peer = from.Pretty()
+	}
+	val, err := cbor.DumpObject(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := cache.countEntries(); err != nil {
+		return err
+	}
+	has, err := cache.ds.Has(badTipSetKey(entry.Key))
+	if err != nil {
+		return errors.Wrap(err, "failed to read bad tipset cache")
+	}
+	if !has && cache.size >= cache.limit {
+		if err := cache.evict(); err != nil {
+			return err
+		}
+	}
+
+	if err := cache.ds.Put(badTipSetKey(entry.Key), val); err != nil {
+		return errors.Wrap(err, "failed to write bad tipset")
+	}
+	if !has {
+		cache.size++
+	}
+	return nil
 }
 
-// Has checks for membership in the badTipSetCache.
-func (cache *badTipSetCache) Has(tsKey string) bool {
+// countEntries counts the entries of the cache if not done yet.
+func (cache *BadTipSetCache) countEntries() error {
+	if cache.size >= 0 {
+		return nil
+	}
+	results, err := cache.ds.Query(query.Query{Prefix: badTipSetPrefix, KeysOnly: true})
+	if err != nil {
+		return errors.Wrap(err, "failed to query bad tipset cache")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to query bad tipset cache")
+	}
+	cache.size = len(entries)
+	return nil
+}
+
+// evict removes the lowest quarter of the entries, and at least one.
+func (cache *BadTipSetCache) evict() error {
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Height < entries[j].Height
+	})
+	n := len(entries) / 4
+	if n == 0 && len(entries) > 0 {
+		n = 1
+	}
+	for _, entry := range entries[:n] {
+		if err := cache.ds.Delete(badTipSetKey(entry.Key)); err != nil {
+			return errors.Wrap(err, "failed to evict bad tipset")
+		}
+	}
+	cache.size = len(entries) - n
+	return nil
+}
+
+// Has checks for membership in the BadTipSetCache.
+func (cache *BadTipSetCache) Has(key types.SortedCidSet) bool {
+	has, err := cache.ds.Has(badTipSetKey(key))
+	if err != nil {
+		logSyncer.Errorf("failed to read bad tipset cache: %s", err)
+	}
+	return has
+}
+
+// Get returns the cache entry for the tipset with the given key.
+func (cache *BadTipSetCache) Get(key types.SortedCidSet) (*BadTipSet, error) {
+	val, err := cache.ds.Get(badTipSetKey(key))
+	if err == datastore.ErrNotFound {
+		return nil, ErrBadTipSetNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read bad tipset")
+	}
+	var entry BadTipSet
+	if err := cbor.DecodeInto(val, &entry); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode bad tipset %s", key.String())
+	}
+	return &entry, nil
+}
+
+// List returns all entries of the BadTipSetCache.
+func (cache *BadTipSetCache) List() ([]*BadTipSet, error) {
+	results, err := cache.ds.Query(query.Query{Prefix: badTipSetPrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query bad tipset cache")
+	}
+	defer results.Close() // nolint: errcheck
+
+	var out []*BadTipSet
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			return nil, entry.Error
+		}
+		var bad BadTipSet
+		if err := cbor.DecodeInto(entry.Value, &bad); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode bad tipset %s", entry.Key)
+		}
+		out = append(out, &bad)
+	}
+	return out, nil
+}
+
+// Remove removes the tipset with the given key from the BadTipSetCache, so
+// that the syncer tries to validate it again.
+func (cache *BadTipSetCache) Remove(key types.SortedCidSet) error {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	_, ok := cache.bad[tsKey]
-	return ok
+
+	has, err := cache.ds.Has(badTipSetKey(key))
+	if err != nil {
+		return errors.Wrap(err, "failed to read bad tipset cache")
+	}
+	if !has {
+		return ErrBadTipSetNotFound
+	}
+	if err := cache.ds.Delete(badTipSetKey(key)); err != nil {
+		return err
+	}
+	if cache.size > 0 {
+		cache.size--
+	}
+	return nil
+}
+
+// badTipSetKey returns the datastore key of the entry for the tipset with the
+// given key.
+func badTipSetKey(key types.SortedCidSet) datastore.Key {
+	cids := make([]string, 0, key.Len())
+	for it := key.Iter(); !it.Complete(); it.Next() {
+		cids = append(cids, it.Value().String())
+	}
+	return datastore.NewKey(badTipSetPrefix).ChildString(strings.Join(cids, "-"))
 }
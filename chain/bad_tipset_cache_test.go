@@ -0,0 +1,94 @@
+package chain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/repo"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestBadTipSetCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := repo.NewInMemoryRepo().ChainDatastore()
+	cache := chain.NewBadTipSetCache(ds, chain.MaxBadTipSets)
+
+	ts1 := th.RequireNewTipSet(t, &types.Block{Height: 1, Nonce: 1})
+	ts2 := th.RequireNewTipSet(t, &types.Block{Height: 2, Nonce: 2})
+	ts3 := th.RequireNewTipSet(t, &types.Block{Height: 2, Nonce: 3})
+	from := th.RequireRandomPeerID(t)
+
+	require.NoError(t, cache.AddChain([]types.TipSet{ts1, ts2}, errors.New("bad state root"), from))
+	assert.True(t, cache.Has(ts1.ToSortedCidSet()))
+	assert.True(t, cache.Has(ts2.ToSortedCidSet()))
+	assert.False(t, cache.Has(ts3.ToSortedCidSet()))
+
+	t.Run("records reason and peer", func(t *testing.T) {
+		bad, err := cache.Get(ts1.ToSortedCidSet())
+		require.NoError(t, err)
+		assert.Equal(t, ts1.ToSortedCidSet(), bad.Key)
+		assert.Equal(t, uint64(1), bad.Height)
+		assert.Equal(t, "bad state root", bad.Reason)
+		assert.Equal(t, from.Pretty(), bad.Peer)
+
+		bad, err = cache.Get(ts2.ToSortedCidSet())
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(bad.Reason, "descends from bad tipset"))
+
+		_, err = cache.Get(ts3.ToSortedCidSet())
+		assert.Equal(t, chain.ErrBadTipSetNotFound, err)
+	})
+
+	t.Run("persists across restarts", func(t *testing.T) {
+		reloaded := chain.NewBadTipSetCache(ds, chain.MaxBadTipSets)
+		assert.True(t, reloaded.Has(ts1.ToSortedCidSet()))
+
+		all, err := reloaded.List()
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("removes entries", func(t *testing.T) {
+		require.NoError(t, cache.Remove(ts1.ToSortedCidSet()))
+		assert.False(t, cache.Has(ts1.ToSortedCidSet()))
+		assert.Equal(t, chain.ErrBadTipSetNotFound, cache.Remove(ts1.ToSortedCidSet()))
+	})
+}
+
+func TestBadTipSetCacheLimit(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := repo.NewInMemoryRepo().ChainDatastore()
+	cache := chain.NewBadTipSetCache(ds, 4)
+
+	var tipsets []types.TipSet
+	for i := 1; i <= 5; i++ {
+		ts := th.RequireNewTipSet(t, &types.Block{Height: types.Uint64(i), Nonce: types.Uint64(i)})
+		tipsets = append(tipsets, ts)
+		require.NoError(t, cache.Add(ts, "bad", ""))
+	}
+
+	// Adding the fifth evicted the lowest.
+	all, err := cache.List()
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+	assert.False(t, cache.Has(tipsets[0].ToSortedCidSet()))
+	assert.True(t, cache.Has(tipsets[4].ToSortedCidSet()))
+
+	// The bound holds for a cache reopened on the same datastore.
+	reloaded := chain.NewBadTipSetCache(ds, 4)
+	ts := th.RequireNewTipSet(t, &types.Block{Height: 6, Nonce: 6})
+	require.NoError(t, reloaded.Add(ts, "bad", ""))
+	all, err = reloaded.List()
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+	assert.False(t, reloaded.Has(tipsets[1].ToSortedCidSet()))
+}
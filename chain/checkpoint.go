@@ -0,0 +1,117 @@
+package chain
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrCheckpointMismatch is returned when a chain does not pass through a
+// trusted checkpoint.
+var ErrCheckpointMismatch = errors.New("chain does not pass through trusted checkpoint")
+
+// Checkpoint is a tipset trusted to be in the chain at a height.  Chains not
+// passing through a checkpoint are rejected, which protects nodes syncing
+// from scratch from long-range forks.  The genesis block is the implicit
+// checkpoint at height 0.
+type Checkpoint struct {
+	Height uint64
+	Key    types.SortedCidSet
+}
+
+// AddCheckpoint adds a trusted checkpoint to the store.  It errors if the
+// current chain does not pass through the checkpoint.  If the store is not
+// loaded yet the checkpoint is verified by Load.
+func (store *Store) AddCheckpoint(ctx context.Context, cp Checkpoint) error {
+	if cp.Key.Len() == 0 {
+		return errors.New("checkpoint must have a tipset key")
+	}
+	if cp.Height == 0 {
+		if !cp.Key.Equals(types.NewSortedCidSet(store.GenesisCid())) {
+			return errors.Wrapf(ErrCheckpointMismatch, "genesis is %s", store.GenesisCid().String())
+		}
+		return nil
+	}
+
+	// Hold the lock across the check and the insertion, so that the head
+	// doesn't move to a chain not passing through the checkpoint in between.
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.head.Defined() {
+		headHeight, err := store.head.Height()
+		if err != nil {
+			return err
+		}
+		if cp.Height <= headHeight {
+			ts, err := store.getTipSetByHeight(cp.Height)
+			if err != nil {
+				return err
+			}
+			h, err := ts.Height()
+			if err != nil {
+				return err
+			}
+			if h != cp.Height || !ts.ToSortedCidSet().Equals(cp.Key) {
+				return errors.Wrapf(ErrCheckpointMismatch, "current chain has %s at height %d", ts.String(), h)
+			}
+		}
+	}
+
+	if store.checkpoints == nil {
+		store.checkpoints = make(map[uint64]types.SortedCidSet)
+	}
+	store.checkpoints[cp.Height] = cp.Key
+	return nil
+}
+
+// Checkpoints returns the store's trusted checkpoints ordered by height,
+// excluding genesis.
+func (store *Store) Checkpoints() []Checkpoint {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	cps := make([]Checkpoint, 0, len(store.checkpoints))
+	for h, key := range store.checkpoints {
+		cps = append(cps, Checkpoint{Height: h, Key: key})
+	}
+	sort.Slice(cps, func(i, j int) bool {
+		return cps[i].Height < cps[j].Height
+	})
+	return cps
+}
+
+// VerifyCheckpoints checks that the chain segment tipsets, linking to a
+// tipset at height base, passes through every checkpoint above base up to
+// the segment's highest tipset.
+func (store *Store) VerifyCheckpoints(tipsets []types.TipSet, base uint64) error {
+	byHeight := make(map[uint64]types.TipSet, len(tipsets))
+	var top uint64
+	for _, ts := range tipsets {
+		h, err := ts.Height()
+		if err != nil {
+			return err
+		}
+		byHeight[h] = ts
+		if h > top {
+			top = h
+		}
+	}
+
+	for _, cp := range store.Checkpoints() {
+		if cp.Height <= base || cp.Height > top {
+			continue
+		}
+		ts, ok := byHeight[cp.Height]
+		if !ok {
+			return errors.Wrapf(ErrCheckpointMismatch, "null round at checkpoint height %d", cp.Height)
+		}
+		if !ts.ToSortedCidSet().Equals(cp.Key) {
+			return errors.Wrapf(ErrCheckpointMismatch, "chain has %s at height %d", ts.String(), cp.Height)
+		}
+	}
+	return nil
+}
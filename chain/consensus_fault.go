@@ -0,0 +1,89 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// faultWindow is the number of rounds below the highest observed block for
+// which the detector remembers blocks.  Equivocations older than that are not
+// detected.
+const faultWindow = 100
+
+// ConsensusFault is proof that a miner equivocated: it mined two different
+// blocks at the same height.
+type ConsensusFault struct {
+	BlockA *types.Block
+	BlockB *types.Block
+}
+
+type minerHeight struct {
+	miner  address.Address
+	height uint64
+}
+
+// FaultDetector detects consensus faults among the blocks it observes.  It
+// should only observe blocks that passed validation, so that faults are
+// backed by valid block signatures.
+type FaultDetector struct {
+	mu     sync.Mutex
+	blocks map[minerHeight]*types.Block
+	// reported holds the miners and heights for which a fault was already
+	// returned, so that each equivocation is reported once.
+	reported  map[minerHeight]bool
+	maxHeight uint64
+}
+
+// NewFaultDetector returns a FaultDetector that has observed no blocks.
+func NewFaultDetector() *FaultDetector {
+	return &FaultDetector{
+		blocks:   make(map[minerHeight]*types.Block),
+		reported: make(map[minerHeight]bool),
+	}
+}
+
+// Observe records blk and returns the consensus fault it proves, if its miner
+// already mined a different block at the same height, or nil.  A fault is
+// returned only the first time it is observed.
+func (fd *FaultDetector) Observe(blk *types.Block) *ConsensusFault {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	h := uint64(blk.Height)
+	if h+faultWindow < fd.maxHeight {
+		return nil
+	}
+
+	key := minerHeight{miner: blk.Miner, height: h}
+	seen, ok := fd.blocks[key]
+	if ok {
+		if seen.Cid().Equals(blk.Cid()) || fd.reported[key] {
+			return nil
+		}
+		fd.reported[key] = true
+		return &ConsensusFault{BlockA: seen, BlockB: blk}
+	}
+	fd.blocks[key] = blk
+
+	if h > fd.maxHeight {
+		fd.maxHeight = h
+		fd.prune()
+	}
+	return nil
+}
+
+// prune forgets blocks that fell out of the fault window.
+func (fd *FaultDetector) prune() {
+	for key := range fd.blocks {
+		if key.height+faultWindow < fd.maxHeight {
+			delete(fd.blocks, key)
+		}
+	}
+	for key := range fd.reported {
+		if key.height+faultWindow < fd.maxHeight {
+			delete(fd.reported, key)
+		}
+	}
+}
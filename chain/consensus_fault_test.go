@@ -0,0 +1,44 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestFaultDetector(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := address.NewForTestGetter()
+	minerA, minerB := addrGetter(), addrGetter()
+
+	t.Run("detects two blocks by the same miner at the same height", func(t *testing.T) {
+		fd := chain.NewFaultDetector()
+		blk1 := &types.Block{Miner: minerA, Height: 3, Nonce: 1}
+		blk2 := &types.Block{Miner: minerA, Height: 3, Nonce: 2}
+
+		assert.Nil(t, fd.Observe(blk1))
+		assert.Nil(t, fd.Observe(blk1))
+		fault := fd.Observe(blk2)
+		require.NotNil(t, fault)
+		assert.Equal(t, blk1, fault.BlockA)
+		assert.Equal(t, blk2, fault.BlockB)
+
+		// The fault is reported once.
+		assert.Nil(t, fd.Observe(blk2))
+	})
+
+	t.Run("ignores blocks by different miners or at different heights", func(t *testing.T) {
+		fd := chain.NewFaultDetector()
+
+		assert.Nil(t, fd.Observe(&types.Block{Miner: minerA, Height: 3}))
+		assert.Nil(t, fd.Observe(&types.Block{Miner: minerB, Height: 3}))
+		assert.Nil(t, fd.Observe(&types.Block{Miner: minerA, Height: 4}))
+	})
+}
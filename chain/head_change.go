@@ -0,0 +1,40 @@
+package chain
+
+import (
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// HeadChangeType tells how a tipset is affected by a move of the head.
+type HeadChangeType int
+
+const (
+	// HeadChangeCurrent reports the head at the start of a notification
+	// stream
+	HeadChangeCurrent = HeadChangeType(iota)
+
+	// HeadChangeApply reports a tipset added to the chain
+	HeadChangeApply
+
+	// HeadChangeRevert reports a tipset removed from the chain by a reorg
+	HeadChangeRevert
+)
+
+// String returns a human readable name for the head change type.
+func (t HeadChangeType) String() string {
+	switch t {
+	case HeadChangeCurrent:
+		return "current"
+	case HeadChangeApply:
+		return "apply"
+	case HeadChangeRevert:
+		return "revert"
+	default:
+		return "unknown"
+	}
+}
+
+// HeadChange is a change to the chain caused by a move of the head.
+type HeadChange struct {
+	Type   HeadChangeType
+	TipSet types.TipSet
+}
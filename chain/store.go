@@ -2,6 +2,7 @@ package chain
 
 import (
 	"context"
+	"fmt"
 	"runtime/debug"
 	"sync"
 
@@ -30,6 +31,14 @@ var logStore = logging.Logger("chain.store")
 
 var headKey = datastore.NewKey("/chain/heaviestTipSet")
 
+// heightIndexPrefix is the datastore prefix of the index from height to the
+// key of the tipset at that height in the canonical chain.
+const heightIndexPrefix = "/chain/height"
+
+// ErrNoTipSetAtHeight is returned when looking up a height above the head of
+// the canonical chain.
+var ErrNoTipSetAtHeight = errors.New("no tipset at height in the canonical chain")
+
 // Store is a generic implementation of the Store interface.
 // It works(tm) for now.
 type Store struct {
@@ -49,7 +58,9 @@ type Store struct {
 	genesis cid.Cid
 	// head is the tipset at the head of the best known chain.
 	head types.TipSet
-	// Protects head and genesisCid.
+	// checkpoints maps heights to the keys of trusted tipsets at them.
+	checkpoints map[uint64]types.SortedCidSet
+	// Protects head, genesisCid and checkpoints.
 	mu sync.RWMutex
 
 	// headEvents is a pubsub channel that publishes an event every time the head changes.
@@ -113,6 +124,7 @@ func (store *Store) Load(ctx context.Context) (err error) {
 	logStatusEvery := uint64(startHeight / 10)
 
 	var genesii types.TipSet
+	var loaded []types.TipSet
 	// Provide tipsets directly from the block store, not from the tipset index which is
 	// being rebuilt by this traversal.
 	tipsetProvider := TipSetProviderFromBlocks(ctx, store)
@@ -141,6 +153,7 @@ func (store *Store) Load(ctx context.Context) (err error) {
 		}
 
 		genesii = iterator.Value()
+		loaded = append(loaded, genesii)
 	}
 	// Check genesis here.
 	if genesii.Len() != 1 {
@@ -152,6 +165,11 @@ func (store *Store) Load(ctx context.Context) (err error) {
 		return errors.Errorf("expected genesis cid: %s, loaded genesis cid: %s", store.genesis, loadCid)
 	}
 
+	// Check the loaded chain passes through the trusted checkpoints.
+	if err := store.VerifyCheckpoints(loaded, 0); err != nil {
+		return err
+	}
+
 	logStore.Infof("finished loading %d tipsets from %s", startHeight, headTs.String())
 	// Set actual head.
 	return store.SetHead(ctx, headTs)
@@ -328,8 +346,22 @@ func (store *Store) setHeadPersistent(ctx context.Context, ts types.TipSet) erro
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	// Ensure consistency by storing this new head on disk.
-	if errInner := store.writeHead(ctx, ts.ToSortedCidSet()); errInner != nil {
+	// Ensure consistency by storing this new head on disk, in one batch with
+	// the height index so that neither is written without the other.
+	batch, err := store.ds.Batch()
+	if err != nil {
+		return err
+	}
+
+	if errInner := store.writeHead(ctx, batch, ts.ToSortedCidSet()); errInner != nil {
+		return errors.Wrap(errInner, "failed to write new Head to datastore")
+	}
+
+	if errInner := store.writeHeightIndex(ctx, batch, store.head, ts); errInner != nil {
+		return errors.Wrap(errInner, "failed to update height index")
+	}
+
+	if errInner := batch.Commit(); errInner != nil {
 		return errors.Wrap(errInner, "failed to write new Head to datastore")
 	}
 
@@ -338,15 +370,131 @@ func (store *Store) setHeadPersistent(ctx context.Context, ts types.TipSet) erro
 	return nil
 }
 
-// writeHead writes the given cid set as head to disk.
-func (store *Store) writeHead(ctx context.Context, cids types.SortedCidSet) error {
+// writeHead writes the given cid set as head to w.
+func (store *Store) writeHead(ctx context.Context, w datastore.Write, cids types.SortedCidSet) error {
 	logStore.Debugf("WriteHead %s", cids.String())
 	val, err := cbor.DumpObject(cids)
 	if err != nil {
 		return err
 	}
 
-	return store.ds.Put(headKey, val)
+	return w.Put(headKey, val)
+}
+
+// writeHeightIndex writes to w the updates of the height index for a move of
+// the head from oldHead to newHead.  It rewrites the entries of newHead and its
+// ancestors until it reaches a tipset already indexed at its height, which
+// means the rest of the chain is shared with the previous canonical chain.
+// Entries for null rounds of the new chain and for heights above newHead are
+// removed.  As Load sets the head with no previous head, it indexes the whole
+// chain of a repo written before the index existed.
+func (store *Store) writeHeightIndex(ctx context.Context, w datastore.Write, oldHead, newHead types.TipSet) error {
+	newHeight, err := newHead.Height()
+	if err != nil {
+		return err
+	}
+
+	// Drop entries above the new head, left behind if the head moved to a
+	// heavier but shorter chain.
+	if oldHead.Defined() {
+		oldHeight, err := oldHead.Height()
+		if err != nil {
+			return err
+		}
+		for h := newHeight + 1; h <= oldHeight; h++ {
+			if err := w.Delete(heightKey(h)); err != nil && err != datastore.ErrNotFound {
+				return err
+			}
+		}
+	}
+
+	// Walk back from the new head until the index agrees with the chain.
+	above := newHeight + 1
+	for it := IterAncestors(ctx, store, newHead); !it.Complete(); err = it.Next() {
+		if err != nil {
+			return err
+		}
+		h, err := it.Value().Height()
+		if err != nil {
+			return err
+		}
+		// Heights skipped between this tipset and its child are null rounds.
+		for nullHeight := h + 1; nullHeight < above; nullHeight++ {
+			if err := w.Delete(heightKey(nullHeight)); err != nil && err != datastore.ErrNotFound {
+				return err
+			}
+		}
+		above = h
+
+		key := it.Value().ToSortedCidSet()
+		indexed, err := store.readHeightIndex(h)
+		if err == nil && indexed.Equals(key) {
+			return nil
+		}
+		if err != nil && errors.Cause(err) != datastore.ErrNotFound {
+			return err
+		}
+
+		val, err := cbor.DumpObject(key)
+		if err != nil {
+			return err
+		}
+		if err := w.Put(heightKey(h), val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHeightIndex reads the key of the canonical tipset at height h.
+func (store *Store) readHeightIndex(h uint64) (types.SortedCidSet, error) {
+	var key types.SortedCidSet
+	bb, err := store.ds.Get(heightKey(h))
+	if err != nil {
+		return key, errors.Wrapf(err, "failed to read height index at %d", h)
+	}
+	if err := cbor.DecodeInto(bb, &key); err != nil {
+		return key, errors.Wrapf(err, "failed to decode height index at %d", h)
+	}
+	return key, nil
+}
+
+// heightKey returns the datastore key of the height index entry for h.
+// Heights are zero padded so that entries sort by height.
+func heightKey(h uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("%s/%020d", heightIndexPrefix, h))
+}
+
+// GetTipSetByHeight returns the tipset at height h in the canonical chain
+// ending at the current head.  If h is a null round the closest tipset below
+// h is returned.
+func (store *Store) GetTipSetByHeight(h uint64) (types.TipSet, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.getTipSetByHeight(h)
+}
+
+// getTipSetByHeight is GetTipSetByHeight for callers holding store.mu.
+func (store *Store) getTipSetByHeight(h uint64) (types.TipSet, error) {
+	headHeight, err := store.head.Height()
+	if err != nil {
+		return types.UndefTipSet, err
+	}
+	if h > headHeight {
+		return types.UndefTipSet, ErrNoTipSetAtHeight
+	}
+
+	for {
+		key, err := store.readHeightIndex(h)
+		if err == nil {
+			return store.GetTipSet(key)
+		}
+		if errors.Cause(err) != datastore.ErrNotFound || h == 0 {
+			return types.UndefTipSet, err
+		}
+		h--
+	}
 }
 
 // writeTipSetAndState writes the tipset key and the state root id to the
@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	"github.com/ipfs/go-hamt-ipld"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 
@@ -320,6 +322,48 @@ func TestHead(t *testing.T) {
 	assert.Equal(t, dstP.genTS.ToSortedCidSet(), chain.GetHead())
 }
 
+// Canonical tipsets are indexed by height as the head moves.
+func TestGetTipSetByHeight(t *testing.T) {
+	tf.UnitTest(t)
+	dstP := initDSTParams()
+
+	ctx := context.Background()
+	initStoreTest(ctx, t, dstP)
+	chain := newChainStore(dstP)
+	requirePutTestChain(t, chain, dstP)
+
+	assertSetHead(t, chain, dstP.link4)
+
+	ts, err := chain.GetTipSetByHeight(0)
+	require.NoError(t, err)
+	assert.Equal(t, dstP.genTS, ts)
+
+	ts, err = chain.GetTipSetByHeight(2)
+	require.NoError(t, err)
+	assert.Equal(t, dstP.link2, ts)
+
+	// Heights 4 and 5 are null rounds, resolved to the tipset below them.
+	ts, err = chain.GetTipSetByHeight(5)
+	require.NoError(t, err)
+	assert.Equal(t, dstP.link3, ts)
+
+	ts, err = chain.GetTipSetByHeight(6)
+	require.NoError(t, err)
+	assert.Equal(t, dstP.link4, ts)
+
+	_, err = chain.GetTipSetByHeight(7)
+	assert.Error(t, err)
+
+	// Moving the head back removes the entries above it.
+	assertSetHead(t, chain, dstP.link2)
+	_, err = chain.GetTipSetByHeight(3)
+	assert.Error(t, err)
+
+	ts, err = chain.GetTipSetByHeight(1)
+	require.NoError(t, err)
+	assert.Equal(t, dstP.link1, ts)
+}
+
 func assertEmptyCh(t *testing.T, ch <-chan interface{}) {
 	select {
 	case <-ch:
@@ -329,6 +373,43 @@ func assertEmptyCh(t *testing.T, ch <-chan interface{}) {
 }
 
 // Head events are propagated on HeadEvents.
+// Load indexes the chain of a repo written before the height index existed.
+func TestLoadBackfillsHeightIndex(t *testing.T) {
+	tf.UnitTest(t)
+	dstP := initDSTParams()
+
+	ctx := context.Background()
+	initStoreTest(ctx, t, dstP)
+
+	r := repo.NewInMemoryRepo()
+	ds := r.Datastore()
+	chainStore := chain.NewStore(ds, dstP.genCid)
+	requirePutTestChain(t, chainStore, dstP)
+	assertSetHead(t, chainStore, dstP.link4)
+	chainStore.Stop()
+
+	// Drop the index, as in a repo predating it.
+	res, err := ds.Query(query.Query{Prefix: "/chain/height", KeysOnly: true})
+	require.NoError(t, err)
+	entries, err := res.Rest()
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	for _, e := range entries {
+		require.NoError(t, ds.Delete(datastore.NewKey(e.Key)))
+	}
+
+	rebootChain := chain.NewStore(ds, dstP.genCid)
+	require.NoError(t, rebootChain.Load(ctx))
+
+	ts, err := rebootChain.GetTipSetByHeight(1)
+	require.NoError(t, err)
+	assert.Equal(t, dstP.link1, ts)
+
+	ts, err = rebootChain.GetTipSetByHeight(6)
+	require.NoError(t, err)
+	assert.Equal(t, dstP.link4, ts)
+}
+
 func TestHeadEvents(t *testing.T) {
 	tf.UnitTest(t)
 	dstP := initDSTParams()
@@ -370,6 +451,50 @@ func TestHeadEvents(t *testing.T) {
 /* Loading  */
 // Load does not error and gives the chain store access to all blocks and
 // tipset indexes along the heaviest chain.
+func TestCheckpoints(t *testing.T) {
+	tf.UnitTest(t)
+	dstP := initDSTParams()
+
+	ctx := context.Background()
+	initStoreTest(ctx, t, dstP)
+
+	r := repo.NewInMemoryRepo()
+	ds := r.Datastore()
+	chainStore := chain.NewStore(ds, dstP.genCid)
+	requirePutTestChain(t, chainStore, dstP)
+	assertSetHead(t, chainStore, dstP.link4)
+
+	t.Run("verified against the current chain", func(t *testing.T) {
+		assert.NoError(t, chainStore.AddCheckpoint(ctx, chain.Checkpoint{Height: 2, Key: dstP.link2.ToSortedCidSet()}))
+		assert.Error(t, chainStore.AddCheckpoint(ctx, chain.Checkpoint{Height: 3, Key: dstP.link2.ToSortedCidSet()}))
+		// Height 4 is a null round in the current chain.
+		assert.Error(t, chainStore.AddCheckpoint(ctx, chain.Checkpoint{Height: 4, Key: dstP.link3.ToSortedCidSet()}))
+		assert.Error(t, chainStore.AddCheckpoint(ctx, chain.Checkpoint{Height: 0, Key: dstP.link1.ToSortedCidSet()}))
+		assert.Len(t, chainStore.Checkpoints(), 1)
+	})
+
+	t.Run("verified on segments", func(t *testing.T) {
+		segment := []types.TipSet{dstP.link3, dstP.link4}
+		assert.NoError(t, chainStore.VerifyCheckpoints(segment, 2))
+		assert.NoError(t, chainStore.VerifyCheckpoints([]types.TipSet{dstP.link2, dstP.link3}, 1))
+		// A segment skipping the checkpoint height does not pass through it.
+		assert.Error(t, chainStore.VerifyCheckpoints([]types.TipSet{dstP.link3}, 1))
+	})
+
+	t.Run("verified on load", func(t *testing.T) {
+		chainStore.Stop()
+
+		rebootChain := chain.NewStore(ds, dstP.genCid)
+		require.NoError(t, rebootChain.AddCheckpoint(ctx, chain.Checkpoint{Height: 3, Key: dstP.link3.ToSortedCidSet()}))
+		assert.NoError(t, rebootChain.Load(ctx))
+		rebootChain.Stop()
+
+		badChain := chain.NewStore(ds, dstP.genCid)
+		require.NoError(t, badChain.AddCheckpoint(ctx, chain.Checkpoint{Height: 5, Key: dstP.link4.ToSortedCidSet()}))
+		assert.Error(t, badChain.Load(ctx))
+	})
+}
+
 func TestLoadAndReboot(t *testing.T) {
 	tf.UnitTest(t)
 	dstP := initDSTParams()
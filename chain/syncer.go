@@ -8,6 +8,7 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-hamt-ipld"
 	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-peer"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
@@ -38,6 +39,8 @@ var (
 	ErrChainHasBadTipSet = errors.New("input chain contains a cached bad tipset")
 	// ErrNewChainTooLong is returned when processing a fork that split off from the main chain too many blocks ago.
 	ErrNewChainTooLong = errors.New("input chain forked from best chain too far in the past")
+	// ErrNewChainTooFarAhead is returned in caught up mode when the input chain's head is too far ahead of the main chain.
+	ErrNewChainTooFarAhead = errors.New("input chain head too far ahead of best chain")
 	// ErrUnexpectedStoreState indicates that the syncer's chain store is violating expected invariants.
 	ErrUnexpectedStoreState = errors.New("the chain store is in an unexpected state")
 )
@@ -71,6 +74,18 @@ const (
 	CaughtUp
 )
 
+// String returns a human readable name for the sync mode.
+func (m SyncMode) String() string {
+	switch m {
+	case Syncing:
+		return "syncing"
+	case CaughtUp:
+		return "caught up"
+	default:
+		return "unknown"
+	}
+}
+
 type syncerChainReader interface {
 	BlockHeight() (uint64, error)
 	GetBlock(context.Context, cid.Cid) (*types.Block, error)
@@ -83,12 +98,19 @@ type syncerChainReader interface {
 	HasTipSetAndStatesWithParentsAndHeight(pTsKey string, h uint64) bool
 	GetTipSetAndStatesByParentsAndHeight(pTsKey string, h uint64) ([]*TipSetAndState, error)
 	HasAllBlocks(ctx context.Context, cs []cid.Cid) bool
+	VerifyCheckpoints(tipsets []types.TipSet, base uint64) error
 }
 
 type syncFetcher interface {
 	GetBlocks(context.Context, []cid.Cid) ([]*types.Block, error)
 }
 
+// invalidTipSetError is returned by syncOne for tipsets failing consensus
+// validation, the only ones the syncer adds to the bad tipset cache.
+type invalidTipSetError struct {
+	error
+}
+
 // Syncer updates its chain.Store according to the methods of its
 // consensus.Protocol.  It uses a bad tipset cache and a limit on new
 // blocks to traverse during chain collection.  The Syncer can query the
@@ -118,32 +140,41 @@ type Syncer struct {
 	// stateStore is the cborStore used for reading and writing state root
 	// to ipld object mappings.
 	stateStore *hamt.CborIpldStore
-	// badTipSetCache is used to filter out collections of invalid blocks.
-	badTipSets *badTipSetCache
+	// badTipSets is used to filter out collections of invalid blocks.
+	badTipSets *BadTipSetCache
 	consensus  consensus.Protocol
 	chainStore syncerChainReader
 	// syncMode is an enumerable indicating whether the chain is currently caught
-	// up or still syncing. Presently, syncMode is always Syncing pending
-	// implementation in issue #1160.
-	//
-	// TODO: https://github.com/filecoin-project/go-filecoin/issues/1160
+	// up or still syncing.  It is guarded by modeMu rather than mu so that it
+	// can be read while a sync is in progress.
 	syncMode SyncMode
+	modeMu   sync.RWMutex
+	// faults detects miners equivocating among validated blocks, which
+	// are passed to onFault if it is set.
+	faults  *FaultDetector
+	onFault func(ConsensusFault)
 }
 
 // NewSyncer constructs a Syncer ready for use.
-func NewSyncer(cst *hamt.CborIpldStore, c consensus.Protocol, s syncerChainReader, f syncFetcher, syncMode SyncMode) *Syncer {
+func NewSyncer(cst *hamt.CborIpldStore, c consensus.Protocol, s syncerChainReader, f syncFetcher, bad *BadTipSetCache, syncMode SyncMode) *Syncer {
 	return &Syncer{
 		fetcher:    f,
 		stateStore: cst,
-		badTipSets: &badTipSetCache{
-			bad: make(map[string]struct{}),
-		},
+		badTipSets: bad,
 		consensus:  c,
 		chainStore: s,
 		syncMode:   syncMode,
+		faults:     NewFaultDetector(),
 	}
 }
 
+// OnConsensusFault sets the function called with every consensus fault
+// detected among the blocks the syncer validates.  It must be called before
+// the syncer is used.
+func (syncer *Syncer) OnConsensusFault(handler func(ConsensusFault)) {
+	syncer.onFault = handler
+}
+
 // getBlksMaybeFromNet resolves cids of blocks.  It gets blocks through the
 // fetcher.  The fetcher wraps a bitswap session which wraps a bitswap exchange,
 // and the bitswap exchange wraps the node's shared blockstore.  So if blocks
@@ -178,9 +209,15 @@ func (syncer *Syncer) collectChain(ctx context.Context, tipsetCids types.SortedC
 	defer logSyncer.Infof("chain fetch from network complete %v", fetchedHead)
 
 	// Continue collecting the chain if we're either not yet caught up or the
-	// number of new input blocks is less than the FinalityLimit constant.
+	// new input blocks are within the FinalityLimit of the current head.
 	// Otherwise, halt assuming the new blocks come from an invalid chain.
-	for (syncer.syncMode == Syncing) || !syncer.exceedsFinalityLimit(chain) {
+	for {
+		if syncer.SyncMode() == CaughtUp {
+			if err := syncer.checkFinalityLimit(chain); err != nil {
+				return nil, err
+			}
+		}
+
 		// check the cache for bad tipsets before doing anything
 		tsKey := tipsetCids.String()
 
@@ -191,7 +228,7 @@ func (syncer *Syncer) collectChain(ctx context.Context, tipsetCids types.SortedC
 
 		logSyncer.Debugf("CollectChain next link: %s", tsKey)
 
-		if syncer.badTipSets.Has(tsKey) {
+		if syncer.badTipSets.Has(tipsetCids) {
 			return nil, ErrChainHasBadTipSet
 		}
 
@@ -217,8 +254,6 @@ func (syncer *Syncer) collectChain(ctx context.Context, tipsetCids types.SortedC
 			return nil, err
 		}
 	}
-
-	return nil, ErrNewChainTooLong
 }
 
 // tipSetState returns the state resulting from applying the input tipset to
@@ -280,8 +315,9 @@ func (syncer *Syncer) syncOne(ctx context.Context, parent, next types.TipSet) er
 	// a new state to add to the store.
 	st, err = syncer.consensus.RunStateTransition(ctx, next, ancestors, st)
 	if err != nil {
-		return err
+		return &invalidTipSetError{err}
 	}
+	syncer.detectFaults(next)
 	root, err := st.Flush(ctx)
 	if err != nil {
 		return err
@@ -333,6 +369,21 @@ func (syncer *Syncer) syncOne(ctx context.Context, parent, next types.TipSet) er
 	return nil
 }
 
+// detectFaults checks the validated tipset ts for blocks whose miners
+// equivocated and hands the faults to the fault handler.
+func (syncer *Syncer) detectFaults(ts types.TipSet) {
+	for i := 0; i < ts.Len(); i++ {
+		fault := syncer.faults.Observe(ts.At(i))
+		if fault == nil {
+			continue
+		}
+		logSyncer.Warningf("miner %s mined blocks %s and %s at height %d", fault.BlockA.Miner, fault.BlockA.Cid(), fault.BlockB.Cid(), fault.BlockA.Height)
+		if syncer.onFault != nil {
+			syncer.onFault(*fault)
+		}
+	}
+}
+
 func (syncer *Syncer) logReorg(ctx context.Context, curHead, newHead types.TipSet) {
 	curHeadIter := IterAncestors(ctx, syncer.chainStore, curHead)
 	newHeadIter := IterAncestors(ctx, syncer.chainStore, newHead)
@@ -423,7 +474,13 @@ func (syncer *Syncer) widen(ctx context.Context, ts types.TipSet) (types.TipSet,
 // represent a valid extension. It limits the length of new chains it will
 // attempt to validate and caches invalid blocks it has encountered to
 // help prevent DOS.
-func (syncer *Syncer) HandleNewTipset(ctx context.Context, tipsetCids types.SortedCidSet) (err error) {
+func (syncer *Syncer) HandleNewTipset(ctx context.Context, tipsetCids types.SortedCidSet) error {
+	return syncer.HandleNewTipsetFromPeer(ctx, "", tipsetCids)
+}
+
+// HandleNewTipsetFromPeer is HandleNewTipset for a tipset received from peer
+// from.  The peer is recorded with the tipsets added to the bad tipset cache.
+func (syncer *Syncer) HandleNewTipsetFromPeer(ctx context.Context, from peer.ID, tipsetCids types.SortedCidSet) (err error) {
 	logSyncer.Debugf("Begin fetch and sync of chain with head %v", tipsetCids)
 	ctx, span := trace.StartSpan(ctx, "Syncer.HandleNewTipset")
 	span.AddAttributes(trace.StringAttribute("tipset", tipsetCids.String()))
@@ -437,6 +494,7 @@ func (syncer *Syncer) HandleNewTipset(ctx context.Context, tipsetCids types.Sort
 
 	// If the store already has all these blocks the syncer is finished.
 	if syncer.chainStore.HasAllBlocks(ctx, tipsetCids.ToSlice()) {
+		syncer.maybeCatchUp(ctx, tipsetCids)
 		return nil
 	}
 
@@ -444,6 +502,12 @@ func (syncer *Syncer) HandleNewTipset(ctx context.Context, tipsetCids types.Sort
 	// the store. This is the only code that may go to the network to
 	// resolve cids to blocks.
 	chain, err := syncer.collectChain(ctx, tipsetCids)
+	if err == ErrNewChainTooFarAhead {
+		// The node may have fallen behind the network without a peer
+		// reporting its height, e.g. after a partition or a suspend, so
+		// it returns to Syncing mode to accept the next head it is given.
+		syncer.setSyncMode(Syncing)
+	}
 	if err != nil {
 		return err
 	}
@@ -456,6 +520,15 @@ func (syncer *Syncer) HandleNewTipset(ctx context.Context, tipsetCids types.Sort
 		return err
 	}
 
+	// Reject chains that do not pass through the trusted checkpoints.
+	parentHeight, err := parent.Height()
+	if err != nil {
+		return err
+	}
+	if err := syncer.chainStore.VerifyCheckpoints(chain, parentHeight); err != nil {
+		return err
+	}
+
 	// Try adding the tipsets of the chain to the store, checking for new
 	// heaviest tipsets.
 	for i, ts := range chain {
@@ -469,34 +542,109 @@ func (syncer *Syncer) HandleNewTipset(ctx context.Context, tipsetCids types.Sort
 			if wts.Defined() {
 				logSyncer.Debug("attempt to sync after widen")
 				err = syncer.syncOne(ctx, parent, wts)
+				if invalid, ok := err.(*invalidTipSetError); ok {
+					return invalid.error
+				}
 				if err != nil {
 					return err
 				}
 			}
 		}
 		if err = syncer.syncOne(ctx, parent, ts); err != nil {
-			// While `syncOne` can indeed fail for reasons other than consensus,
-			// adding to the badTipSets at this point is the simplest, since we
-			// have access to the chain. If syncOne fails for non-consensus reasons,
-			// there is no assumption that the running node's data is valid at all,
-			// so we don't really lose anything with this simplification.
-			syncer.badTipSets.AddChain(chain[i:])
-			return err
+			// Only tipsets failing validation are cached, as a failure to
+			// validate them, e.g. on cancellation, would be a false positive
+			// outliving a restart.
+			invalid, ok := err.(*invalidTipSetError)
+			if !ok {
+				return err
+			}
+			if ctx.Err() == nil {
+				if cacheErr := syncer.badTipSets.AddChain(chain[i:], invalid.error, from); cacheErr != nil {
+					logSyncer.Errorf("failed to cache bad tipsets: %s", cacheErr)
+				}
+			}
+			return invalid.error
 		}
 		if i%500 == 0 {
 			logSyncer.Infof("processing block %d of %v for chain with head at %v", i, len(chain), tipsetCids.String())
 		}
 		parent = ts
 	}
+	syncer.maybeCatchUp(ctx, tipsetCids)
 	return nil
 }
 
-func (syncer *Syncer) exceedsFinalityLimit(chain []types.TipSet) bool {
+// SyncMode returns the syncer's current mode.
+func (syncer *Syncer) SyncMode() SyncMode {
+	syncer.modeMu.RLock()
+	defer syncer.modeMu.RUnlock()
+	return syncer.syncMode
+}
+
+func (syncer *Syncer) setSyncMode(mode SyncMode) {
+	syncer.modeMu.Lock()
+	defer syncer.modeMu.Unlock()
+	if syncer.syncMode != mode {
+		logSyncer.Infof("sync mode changed from %s to %s", syncer.syncMode, mode)
+	}
+	syncer.syncMode = mode
+}
+
+// HandlePeerHeight updates the sync mode given the height of the head a peer
+// reported on connection, e.g. through the hello protocol.  A peer more than
+// FinalityLimit blocks ahead of the store's head means the node has fallen
+// behind the network, so the syncer returns to Syncing mode in order to accept
+// the peer's chain.  A head rejected with ErrNewChainTooFarAhead also returns
+// the syncer to Syncing mode.
+func (syncer *Syncer) HandlePeerHeight(height uint64) {
+	blockHeight, err := syncer.chainStore.BlockHeight()
+	if err != nil {
+		return
+	}
+	if height > blockHeight+uint64(FinalityLimit) {
+		syncer.setSyncMode(Syncing)
+	}
+}
+
+// maybeCatchUp switches the syncer to CaughtUp mode once the store's head is
+// at least as high as the most recently handled tipset, i.e. the syncer has
+// caught up with the newest chain it has heard of.
+func (syncer *Syncer) maybeCatchUp(ctx context.Context, tipsetCids types.SortedCidSet) {
+	if syncer.SyncMode() == CaughtUp || tipsetCids.Len() == 0 {
+		return
+	}
+	blk, err := syncer.chainStore.GetBlock(ctx, tipsetCids.ToSlice()[0])
+	if err != nil {
+		return
+	}
+	blockHeight, err := syncer.chainStore.BlockHeight()
+	if err != nil {
+		return
+	}
+	if blockHeight >= uint64(blk.Height) {
+		syncer.setSyncMode(CaughtUp)
+	}
+}
+
+// checkFinalityLimit enforces the caught up mode restrictions on the
+// partially collected chain, ordered from its lowest tipset to its head.  The
+// head of the new chain may be at most FinalityLimit blocks above the store's
+// head, and the new chain must not fork from the store's chain more than
+// FinalityLimit blocks below the store's head.
+func (syncer *Syncer) checkFinalityLimit(chain []types.TipSet) error {
 	if len(chain) == 0 {
-		return false
+		return nil
 	}
 	blockHeight, _ := syncer.chainStore.BlockHeight()
-	finalityHeight := types.NewBlockHeight(blockHeight).Add(types.NewBlockHeight(uint64(FinalityLimit)))
-	chainHeight, _ := chain[0].Height()
-	return types.NewBlockHeight(chainHeight).LessThan(finalityHeight)
+	limit := uint64(FinalityLimit)
+
+	newHeadHeight, _ := chain[len(chain)-1].Height()
+	if newHeadHeight > blockHeight+limit {
+		return ErrNewChainTooFarAhead
+	}
+	lowestHeight, _ := chain[0].Height()
+	if lowestHeight+limit < blockHeight {
+		return ErrNewChainTooLong
+	}
+	return nil
 }
@@ -118,10 +118,12 @@ func requireSetTestChain(t *testing.T, con consensus.Protocol, mockStateRoots bo
 	dstP.link1blk1 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link1blk1.Proof, dstP.link1blk1.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link1blk1, mockSignerPubKey, mockSigner)
 
 	dstP.link1blk2 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link1blk2.Proof, dstP.link1blk2.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link1blk2, mockSignerPubKey, mockSigner)
 
 	dstP.link1 = th.RequireNewTipSet(t, dstP.link1blk1, dstP.link1blk2)
 
@@ -136,15 +138,18 @@ func requireSetTestChain(t *testing.T, con consensus.Protocol, mockStateRoots bo
 	dstP.link2blk1 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link2blk1.Proof, dstP.link2blk1.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link2blk1, mockSignerPubKey, mockSigner)
 
 	dstP.link2blk2 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link2blk2.Proof, dstP.link2blk2.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link2blk2, mockSignerPubKey, mockSigner)
 
 	fakeChildParams.Nonce = uint64(1)
 	dstP.link2blk3 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link2blk3.Proof, dstP.link2blk3.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link2blk3, mockSignerPubKey, mockSigner)
 
 	dstP.link2 = th.RequireNewTipSet(t, dstP.link2blk1, dstP.link2blk2, dstP.link2blk3)
 
@@ -159,6 +164,7 @@ func requireSetTestChain(t *testing.T, con consensus.Protocol, mockStateRoots bo
 	dstP.link3blk1 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link3blk1.Proof, dstP.link3blk1.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link3blk1, mockSignerPubKey, mockSigner)
 
 	dstP.link3 = th.RequireNewTipSet(t, dstP.link3blk1)
 
@@ -174,11 +180,13 @@ func requireSetTestChain(t *testing.T, con consensus.Protocol, mockStateRoots bo
 	dstP.link4blk1 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link4blk1.Proof, dstP.link4blk1.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link4blk1, mockSignerPubKey, mockSigner)
 
 	fakeChildParams.Nonce = uint64(1)
 	dstP.link4blk2 = th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	dstP.link4blk2.Proof, dstP.link4blk2.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, dstP.link4blk2, mockSignerPubKey, mockSigner)
 
 	dstP.link4 = th.RequireNewTipSet(t, dstP.link4blk1, dstP.link4blk2)
 
@@ -204,7 +212,7 @@ func loadSyncerFromRepo(t *testing.T, r repo.Repo, dstP *SyncerTestParams) (*cha
 	chainStore := chain.NewStore(chainDS, calcGenBlk.Cid())
 
 	blockSource := th.NewTestFetcher()
-	syncer := chain.NewSyncer(cst, con, chainStore, blockSource, chain.Syncing) // note we use same cst for on and offline for tests
+	syncer := chain.NewSyncer(cst, con, chainStore, blockSource, chain.NewBadTipSetCache(chainDS, chain.MaxBadTipSets), chain.Syncing) // note we use same cst for on and offline for tests
 
 	ctx := context.Background()
 	err = chainStore.Load(ctx)
@@ -276,7 +284,7 @@ func initSyncTest(t *testing.T, con consensus.Protocol, genFunc func(cst *hamt.C
 	chainStore := chain.NewStore(chainDS, calcGenBlk.Cid())
 
 	fetcher := th.NewTestFetcher()
-	syncer := chain.NewSyncer(cst, con, chainStore, fetcher, syncMode) // note we use same cst for on and offline for tests
+	syncer := chain.NewSyncer(cst, con, chainStore, fetcher, chain.NewBadTipSetCache(chainDS, chain.MaxBadTipSets), syncMode) // note we use same cst for on and offline for tests
 
 	// Initialize stores to contain dstP.genesis block and state
 	calcGenTS := th.RequireNewTipSet(t, calcGenBlk)
@@ -639,6 +647,7 @@ func TestFarFutureTipsetsWhenCaughtUp(t *testing.T) {
 		linkBlk := th.RequireMkFakeChildWithCon(t, fakeChildParams)
 		linkBlk.Proof, linkBlk.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 		require.NoError(t, err)
+		th.RequireSignBlock(t, linkBlk, mockSignerPubKey, mockSigner)
 
 		fakeChildParams.Parent = th.RequireNewTipSet(t, linkBlk)
 		tipsetCids = requirePutBlocks(t, blockSource, linkBlk)
@@ -647,6 +656,47 @@ func TestFarFutureTipsetsWhenCaughtUp(t *testing.T) {
 	assert.Error(t, syncer.HandleNewTipset(ctx, tipsetCids))
 }
 
+// Syncer returns to syncing mode when it rejects a head too far ahead in
+// caught up mode, so that a node fallen behind without hearing from a peer
+// accepts the next head.
+func TestFarFutureTipsetsReturnToSyncing(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+	dstP := initDSTParams()
+	con, syncer, blockSource := initSyncTestWithMode(t, dstP, chain.CaughtUp)
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
+	mockSignerPubKey := mockSigner.PubKeys[0]
+	fakeChildParams := th.FakeChildParams{
+		Parent:      dstP.genTS,
+		GenesisCid:  dstP.genCid,
+		StateRoot:   dstP.genStateRoot,
+		Consensus:   con,
+		MinerAddr:   dstP.minerAddress,
+		MinerPubKey: mockSignerPubKey,
+		Signer:      mockSigner,
+	}
+	ctx := context.Background()
+	minerPower := types.NewBytesAmount(25)
+	totalPower := types.NewBytesAmount(100)
+
+	var err error
+	var tipsetCids types.SortedCidSet
+	for i := 0; i < chain.FinalityLimit+10; i++ {
+		linkBlk := th.RequireMkFakeChildWithCon(t, fakeChildParams)
+		linkBlk.Proof, linkBlk.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
+		require.NoError(t, err)
+		th.RequireSignBlock(t, linkBlk, mockSignerPubKey, mockSigner)
+
+		fakeChildParams.Parent = th.RequireNewTipSet(t, linkBlk)
+		tipsetCids = requirePutBlocks(t, blockSource, linkBlk)
+	}
+
+	assert.Equal(t, chain.ErrNewChainTooFarAhead, syncer.HandleNewTipset(ctx, tipsetCids))
+	assert.Equal(t, chain.Syncing, syncer.SyncMode())
+
+	require.NoError(t, syncer.HandleNewTipset(ctx, tipsetCids))
+	assert.Equal(t, chain.CaughtUp, syncer.SyncMode())
+}
+
 // Syncer succeeds when input blocks massively exceed the current block height
 // in syncing mode
 func TestFarFutureTipsetsWhenSyncing(t *testing.T) {
@@ -676,6 +726,7 @@ func TestFarFutureTipsetsWhenSyncing(t *testing.T) {
 		linkBlk := th.RequireMkFakeChildWithCon(t, fakeChildParams)
 		linkBlk.Proof, linkBlk.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, mockSigner)
 		require.NoError(t, err)
+		th.RequireSignBlock(t, linkBlk, mockSignerPubKey, mockSigner)
 
 		fakeChildParams.Parent = th.RequireNewTipSet(t, linkBlk)
 		tipsetCids = requirePutBlocks(t, blockSource, linkBlk)
@@ -684,6 +735,31 @@ func TestFarFutureTipsetsWhenSyncing(t *testing.T) {
 	assert.NoError(t, syncer.HandleNewTipset(ctx, tipsetCids))
 }
 
+// Syncer switches to caught up mode once it has synced to the head it was
+// given, and back to syncing mode when a peer reports a far away head.
+func TestSyncModeTransitions(t *testing.T) {
+	tf.UnitTest(t)
+	dstP := initDSTParams()
+
+	syncer, chainStore, _, blockSource := initSyncTestDefault(t, dstP)
+	ctx := context.Background()
+	assert.Equal(t, chain.Syncing, syncer.SyncMode())
+
+	_ = requirePutBlocks(t, blockSource, dstP.link1.ToSlice()...)
+	_ = requirePutBlocks(t, blockSource, dstP.link2.ToSlice()...)
+	cids := requirePutBlocks(t, blockSource, dstP.link3.ToSlice()...)
+	require.NoError(t, syncer.HandleNewTipset(ctx, cids))
+	assertHead(t, chainStore, dstP.link3)
+	assert.Equal(t, chain.CaughtUp, syncer.SyncMode())
+
+	// Peers close to our head keep the syncer caught up.
+	syncer.HandlePeerHeight(uint64(chain.FinalityLimit))
+	assert.Equal(t, chain.CaughtUp, syncer.SyncMode())
+
+	syncer.HandlePeerHeight(uint64(chain.FinalityLimit) + 4)
+	assert.Equal(t, chain.Syncing, syncer.SyncMode())
+}
+
 // Syncer errors if blocks don't form a tipset
 func TestBlocksNotATipSet(t *testing.T) {
 	tf.UnitTest(t)
@@ -956,30 +1032,35 @@ func TestHeaviestIsWidenedAncestor(t *testing.T) {
 		Consensus:  con,
 		GenesisCid: dstP.genCid,
 		StateRoot:  dstP.genStateRoot,
-		MinerAddr:  dstP.minerAddress,
-		Signer:     signer,
-		Nonce:      uint64(1),
+		MinerAddr:   dstP.minerAddress,
+		MinerPubKey: mockSignerPubKey,
+		Signer:      signer,
+		Nonce:       uint64(1),
 	}
 
 	var err error
 	forklink2blk1 := th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	forklink2blk1.Proof, forklink2blk1.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, signer)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, forklink2blk1, mockSignerPubKey, signer)
 
 	fakeChildParams.Nonce = uint64(52)
 	forklink2blk2 := th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	forklink2blk2.Proof, forklink2blk2.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, signer)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, forklink2blk2, mockSignerPubKey, signer)
 
 	fakeChildParams.Nonce = uint64(53)
 	forklink2blk3 := th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	forklink2blk3.Proof, forklink2blk3.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, signer)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, forklink2blk3, mockSignerPubKey, signer)
 
 	fakeChildParams.Nonce = uint64(54)
 	forklink2blk4 := th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	forklink2blk4.Proof, forklink2blk4.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, signer)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, forklink2blk4, mockSignerPubKey, signer)
 
 	forklink2 := th.RequireNewTipSet(t, forklink2blk1, forklink2blk2, forklink2blk3, forklink2blk4)
 
@@ -988,6 +1069,7 @@ func TestHeaviestIsWidenedAncestor(t *testing.T) {
 	forklink3blk1 := th.RequireMkFakeChildWithCon(t, fakeChildParams)
 	forklink3blk1.Proof, forklink3blk1.Ticket, err = th.MakeProofAndWinningTicket(mockSignerPubKey, minerPower, totalPower, signer)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, forklink3blk1, mockSignerPubKey, signer)
 
 	forklink3 := th.RequireNewTipSet(t, forklink3blk1)
 
@@ -1064,6 +1146,10 @@ func TestTipSetWeightDeep(t *testing.T) {
 	var calcGenBlk types.Block
 	require.NoError(t, cst.Get(ctx, info.GenesisCid, &calcGenBlk))
 
+	// All miners are owned by the first key, which is also their worker key.
+	minerSigner := types.NewMockSigner([]types.KeyInfo{*info.Keys[0]})
+	minerPubKey := info.Keys[0].PublicKey()
+
 	chainStore := chain.NewStore(r.ChainDatastore(), calcGenBlk.Cid())
 
 	verifier := proofs.NewFakeVerifier(true, nil)
@@ -1087,7 +1173,7 @@ func TestTipSetWeightDeep(t *testing.T) {
 	// Now sync the chainStore with consensus using a MarketView.
 	verifier = proofs.NewFakeVerifier(true, nil)
 	con = consensus.NewExpected(cst, bs, th.NewTestProcessor(), th.NewFakeBlockValidator(), &consensus.MarketView{}, calcGenBlk.Cid(), verifier, th.BlockTimeTest)
-	syncer := chain.NewSyncer(cst, con, chainStore, blockSource, chain.Syncing)
+	syncer := chain.NewSyncer(cst, con, chainStore, blockSource, chain.NewBadTipSetCache(r.ChainDatastore(), chain.MaxBadTipSets), chain.Syncing)
 	baseTS := requireHeadTipset(t, chainStore) // this is the last block of the bootstrapping chain creating miners
 	require.Equal(t, 1, baseTS.Len())
 	bootstrapStateRoot := baseTS.ToSlice()[0].StateRoot
@@ -1117,10 +1203,11 @@ func TestTipSetWeightDeep(t *testing.T) {
 	}
 
 	fakeChildParams := th.FakeChildParams{
-		Parent:     baseTS,
-		GenesisCid: calcGenBlk.Cid(),
-		StateRoot:  bootstrapStateRoot,
-		Signer:     mockSigner,
+		Parent:      baseTS,
+		GenesisCid:  calcGenBlk.Cid(),
+		StateRoot:   bootstrapStateRoot,
+		MinerPubKey: minerPubKey,
+		Signer:      minerSigner,
 
 		MinerAddr: info.Miners[1].Address,
 	}
@@ -1128,12 +1215,14 @@ func TestTipSetWeightDeep(t *testing.T) {
 	f1b1 := th.RequireMkFakeChildCore(t, fakeChildParams, wFun)
 	f1b1.Proof, f1b1.Ticket, err = th.MakeProofAndWinningTicket(signerPubKey1, info.Miners[1].Power, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, f1b1, minerPubKey, minerSigner)
 
 	fakeChildParams.Nonce = uint64(1)
 	fakeChildParams.MinerAddr = info.Miners[2].Address
 	f2b1 := th.RequireMkFakeChildCore(t, fakeChildParams, wFun)
 	f2b1.Proof, f2b1.Ticket, err = th.MakeProofAndWinningTicket(signerPubKey1, info.Miners[2].Power, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, f2b1, minerPubKey, minerSigner)
 
 	tsShared := th.RequireNewTipSet(t, f1b1, f2b1)
 
@@ -1149,16 +1238,18 @@ func TestTipSetWeightDeep(t *testing.T) {
 
 	// fork 1 is heavier than the old head.
 	fakeChildParams = th.FakeChildParams{
-		Parent:     th.RequireNewTipSet(t, f1b1),
-		GenesisCid: calcGenBlk.Cid(),
-		StateRoot:  bootstrapStateRoot,
-		Signer:     mockSigner,
+		Parent:      th.RequireNewTipSet(t, f1b1),
+		GenesisCid:  calcGenBlk.Cid(),
+		StateRoot:   bootstrapStateRoot,
+		MinerPubKey: minerPubKey,
+		Signer:      minerSigner,
 
 		MinerAddr: info.Miners[1].Address,
 	}
 	f1b2a := th.RequireMkFakeChildCore(t, fakeChildParams, wFun)
 	f1b2a.Proof, f1b2a.Ticket, err = th.MakeProofAndWinningTicket(signerPubKey1, info.Miners[1].Power, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, f1b2a, minerPubKey, minerSigner)
 
 	fakeChildParams.Nonce = uint64(1)
 
@@ -1166,6 +1257,7 @@ func TestTipSetWeightDeep(t *testing.T) {
 	f1b2b := th.RequireMkFakeChildCore(t, fakeChildParams, wFun)
 	f1b2b.Proof, f1b2b.Ticket, err = th.MakeProofAndWinningTicket(signerPubKey2, info.Miners[2].Power, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, f1b2b, minerPubKey, minerSigner)
 
 	f1 := th.RequireNewTipSet(t, f1b2a, f1b2b)
 	f1Cids := requirePutBlocks(t, blockSource, f1.ToSlice()...)
@@ -1180,9 +1272,10 @@ func TestTipSetWeightDeep(t *testing.T) {
 	// fork 2 has heavier weight because of addr3's power even though there
 	// are fewer blocks in the tipset than fork 1.
 	fakeChildParams = th.FakeChildParams{
-		Parent:     th.RequireNewTipSet(t, f2b1),
-		GenesisCid: calcGenBlk.Cid(),
-		Signer:     mockSigner,
+		Parent:      th.RequireNewTipSet(t, f2b1),
+		GenesisCid:  calcGenBlk.Cid(),
+		MinerPubKey: minerPubKey,
+		Signer:      minerSigner,
 
 		StateRoot: bootstrapStateRoot,
 		MinerAddr: info.Miners[3].Address,
@@ -1190,6 +1283,7 @@ func TestTipSetWeightDeep(t *testing.T) {
 	f2b2 := th.RequireMkFakeChildCore(t, fakeChildParams, wFun)
 	f2b2.Proof, f2b2.Ticket, err = th.MakeProofAndWinningTicket(signerPubKey2, info.Miners[3].Power, totalPower, mockSigner)
 	require.NoError(t, err)
+	th.RequireSignBlock(t, f2b2, minerPubKey, minerSigner)
 
 	f2 := th.RequireNewTipSet(t, f2b2)
 	f2Cids := requirePutBlocks(t, blockSource, f2.ToSlice()...)
@@ -1223,7 +1317,11 @@ func requireGetTipSetStateRoot(ctx context.Context, t *testing.T, chainStore tip
 }
 
 func initGenesis(minerAddress address.Address, minerOwnerAddress address.Address, minerPeerID peer.ID, cst *hamt.CborIpldStore, bs bstore.Blockstore) (*types.Block, error) {
+	// Blocks of the test chains are signed with the first mock signer's key.
+	// The miner has a sector, so that their election proofs are verified.
+	mockSigner, _ := types.NewMockSignersAndKeyInfo(1)
 	return consensus.MakeGenesisFunc(
-		consensus.MinerActor(minerAddress, minerOwnerAddress, []byte{}, minerPeerID, types.ZeroAttoFIL, types.OneKiBSectorSize),
+		consensus.MinerActor(minerAddress, minerOwnerAddress, mockSigner.PubKeys[0], minerPeerID, types.ZeroAttoFIL, types.OneKiBSectorSize),
+		consensus.MinerSector(minerAddress, 0, types.Commitments{CommR: types.CommR{1}}),
 	)(cst, bs)
 }
@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
@@ -12,7 +16,9 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
 var walletCmd = &cmds.Command{
@@ -20,9 +26,16 @@ var walletCmd = &cmds.Command{
 		Tagline: "Manage your filecoin wallets",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"balance": balanceCmd,
-		"import":  walletImportCmd,
-		"export":  walletExportCmd,
+		"balance":    balanceCmd,
+		"import":     walletImportCmd,
+		"export":     walletExportCmd,
+		"lock":       walletLockCmd,
+		"unlock":     walletUnlockCmd,
+		"passphrase": walletPassphraseCmd,
+		"init":       walletInitCmd,
+		"recover":    walletRecoverCmd,
+		"sign":       walletSignCmd,
+		"verify":     walletVerifyCmd,
 	},
 }
 
@@ -35,6 +48,7 @@ var addrsCmd = &cmds.Command{
 		"new":     addrsNewCmd,
 		"lookup":  addrsLookupCmd,
 		"default": defaultAddressCmd,
+		"history": addrsHistoryCmd,
 	},
 }
 
@@ -48,8 +62,28 @@ type AddressLsResult struct {
 }
 
 var addrsNewCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a new address in the wallet",
+		ShortDescription: `
+Generates a new key in the wallet and prints its address. The key type is
+secp256k1 by default; BLS keys produce signatures that can be aggregated.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("type", "Type of key to generate: secp256k1 or bls").WithDefault("secp256k1"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		addr, err := GetPorcelainAPI(env).WalletNewAddress()
+		var protocol address.Protocol
+		switch req.Options["type"].(string) {
+		case "secp256k1":
+			protocol = address.SECP256K1
+		case "bls":
+			protocol = address.BLS
+		default:
+			return fmt.Errorf("unknown key type %s, expected secp256k1 or bls", req.Options["type"])
+		}
+
+		addr, err := GetPorcelainAPI(env).WalletNewAddress(protocol)
 		if err != nil {
 			return err
 		}
@@ -132,6 +166,48 @@ var defaultAddressCmd = &cmds.Command{
 	},
 }
 
+var addrsHistoryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the on-chain messages sent or received by an address",
+		ShortDescription: `
+Lists messages sent or received by the address, most recent first, with the
+height at which each was included in the chain and its receipt. Requires the
+daemon to run with the message index enabled (msgIndex.enabled in the config).
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Address to list messages for"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		entries, err := GetPorcelainAPI(env).MessageSearch(req.Context, addr)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := re.Emit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: &msg.AddressMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, entry *msg.AddressMessage) error {
+			exitCode := "-"
+			if entry.Receipt != nil {
+				exitCode = strconv.Itoa(int(entry.Receipt.ExitCode))
+			}
+			_, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", entry.Height, entry.MsgCid, entry.From, entry.To, exitCode)
+			return err
+		}),
+	},
+}
+
 var balanceCmd = &cmds.Command{
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("address", true, false, "Address to get balance for"),
@@ -254,3 +330,298 @@ var walletExportCmd = &cmds.Command{
 		}),
 	},
 }
+
+type mnemonicResult struct {
+	Mnemonic string
+}
+
+var walletInitCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Initialize the wallet to derive its keys from a seed",
+		ShortDescription: `
+With --mnemonic, generates a new BIP-39 mnemonic and prints it. New secp256k1
+addresses are then derived from its seed along the path m/44'/461'/0'/0/i, so
+that writing down the mnemonic backs up all of them; see 'wallet recover'.
+Addresses created before, and BLS addresses, are not derived from the seed and
+still need 'wallet export' to back them up. A wallet's seed can't be replaced.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("mnemonic", "Generate a BIP-39 mnemonic to derive the keys from"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if mnemonic, _ := req.Options["mnemonic"].(bool); !mnemonic {
+			return errors.New("wallet init requires --mnemonic")
+		}
+
+		mnemonic, err := GetPorcelainAPI(env).WalletInitMnemonic()
+		if err != nil {
+			return err
+		}
+		return re.Emit(&mnemonicResult{mnemonic})
+	},
+	Type: &mnemonicResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, m *mnemonicResult) error {
+			_, err := fmt.Fprintln(w, m.Mnemonic)
+			return err
+		}),
+	},
+}
+
+var walletRecoverCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Recover the addresses derived from a mnemonic",
+		ShortDescription: `
+Sets the seed of a mnemonic printed by 'wallet init --mnemonic' as the wallet's
+seed, and adds the addresses derived from it to the wallet, up to the last one
+with an actor on chain, and prints them. The wallet must have no seed yet, or the
+mnemonic's, in which case only the addresses it lacks are added. Addresses the
+wallet already has that are not derived from the mnemonic, such as imported or
+BLS addresses, are kept, but the mnemonic doesn't recover them.
+The mnemonic is read from stdin if it is not given.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("mnemonic", true, false, "BIP-39 mnemonic of the wallet's seed").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addrs, err := GetPorcelainAPI(env).WalletRecover(req.Context, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		var alr AddressLsResult
+		for _, addr := range addrs {
+			alr.Addresses = append(alr.Addresses, addr.String())
+		}
+
+		return re.Emit(&alr)
+	},
+	Type: &AddressLsResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, addrs *AddressLsResult) error {
+			for _, addr := range addrs.Addresses {
+				_, err := fmt.Fprintln(w, addr)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+type signatureResult struct {
+	Signature types.Signature
+}
+
+var walletSignCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign data with the key of an address",
+		ShortDescription: `
+Signs the data with the key of the address and prints the signature in base64.
+Anyone can check it with 'wallet verify', for example to prove ownership of the
+address. The signature is of the data with a prefix no message, block or other
+data the node signs starts with, so that it can never be replayed as the
+signature of a message. Without the data argument the data is read from stdin,
+so a file can be signed with 'wallet sign <address> < file'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Address whose key signs the data"),
+		cmdkit.StringArg("data", false, false, "Data to sign, read from stdin if left out").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		data, err := signedData(req, req.Arguments[1:])
+		if err != nil {
+			return err
+		}
+
+		sig, err := GetPorcelainAPI(env).WalletSignData(data, addr)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&signatureResult{sig})
+	},
+	Type: &signatureResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *signatureResult) error {
+			_, err := fmt.Fprintln(w, base64.StdEncoding.EncodeToString(s.Signature))
+			return err
+		}),
+	},
+}
+
+var walletVerifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Verify a signature made with 'wallet sign'",
+		ShortDescription: `
+Checks that the base64 signature is one of the data made by 'wallet sign' with
+the key of the address, and fails otherwise. Without the data argument the data
+is read from stdin, as in 'wallet verify <address> <signature> < file'. This
+command runs without a daemon.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Address whose key signed the data"),
+		cmdkit.StringArg("data", false, false, "Signed data, read from stdin if left out"),
+		cmdkit.StringArg("signature", false, false, "Signature in base64").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		// the signature is the last argument, after the data unless it is read from stdin
+		last := len(req.Arguments) - 1
+		if last < 1 {
+			return errors.New("signature argument is required")
+		}
+		data, err := signedData(req, req.Arguments[1:last])
+		if err != nil {
+			return err
+		}
+		sig, err := base64.StdEncoding.DecodeString(req.Arguments[last])
+		if err != nil {
+			return errors.Wrap(err, "invalid signature")
+		}
+
+		if !wallet.VerifyData(data, addr, sig) {
+			return errors.Errorf("signature is not valid for %s", addr)
+		}
+		return re.Emit(&addressResult{addr.String()})
+	},
+	Type: &addressResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, a *addressResult) error {
+			_, err := fmt.Fprintf(w, "valid signature of %s\n", a.Address)
+			return err
+		}),
+	},
+}
+
+// signedData returns the data argument, the only one of dataArgs, or else the data the
+// client read from stdin and sent with the request.
+func signedData(req *cmds.Request, dataArgs []string) ([]byte, error) {
+	if len(dataArgs) > 0 {
+		return []byte(dataArgs[0]), nil
+	}
+	if req.Files == nil {
+		return nil, errors.New("data argument or data on stdin is required")
+	}
+
+	iter := req.Files.Entries()
+	if !iter.Next() {
+		return nil, fmt.Errorf("no data given: %s", iter.Err())
+	}
+	fi, ok := iter.Node().(files.File)
+	if !ok {
+		return nil, fmt.Errorf("given data was not a files.File")
+	}
+
+	data, err := ioutil.ReadAll(fi)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read data")
+	}
+	return data, nil
+}
+
+var walletLockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Lock the wallet",
+		ShortDescription: `
+Locks the wallet, so that the node can't sign messages or blocks with its keys
+until the wallet is unlocked.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		GetPorcelainAPI(env).WalletLock()
+		return nil
+	},
+}
+
+var walletUnlockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Unlock the wallet with its passphrase",
+		ShortDescription: `
+Unlocks the wallet, so that the node can sign with its keys until the wallet is
+locked again or the timeout passes. The passphrase is read from a file, or from
+stdin if no file is given, so that it doesn't show in the process list or the
+shell history.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("passphrase-file", true, false, "File containing the passphrase of the wallet").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("timeout", "Time after which the wallet is locked again, e.g. 300ms, 1.5h, 2h45m. Zero never locks it.").WithDefault("0"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		timeout, err := time.ParseDuration(req.Options["timeout"].(string))
+		if err != nil {
+			return errors.Wrap(err, "Invalid timeout string")
+		}
+
+		iter := req.Files.Entries()
+		passphrase, err := readPassphrase(iter)
+		if err != nil {
+			return err
+		}
+
+		return GetPorcelainAPI(env).WalletUnlock(passphrase, timeout)
+	},
+}
+
+var walletPassphraseCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Change the passphrase of the wallet",
+		ShortDescription: `
+Re-encrypts the keys of the wallet with a new passphrase, read from the second
+file, if the first file contains the current passphrase. The wallet stays locked,
+or unlocked, as it was. An empty new passphrase removes the encryption, so that
+the wallet no longer starts locked.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("passphrase-file", true, false, "File containing the current passphrase of the wallet"),
+		cmdkit.FileArg("new-passphrase-file", true, false, "File containing the new passphrase of the wallet"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		iter := req.Files.Entries()
+		passphrase, err := readPassphrase(iter)
+		if err != nil {
+			return err
+		}
+		newPassphrase, err := readPassphrase(iter)
+		if err != nil {
+			return err
+		}
+
+		return GetPorcelainAPI(env).WalletChangePassphrase(passphrase, newPassphrase)
+	},
+}
+
+// readPassphrase reads a passphrase from the next file of iter, without the trailing
+// newline.
+func readPassphrase(iter files.DirIterator) ([]byte, error) {
+	if !iter.Next() {
+		return nil, fmt.Errorf("no passphrase file given: %s", iter.Err())
+	}
+
+	fi, ok := iter.Node().(files.File)
+	if !ok {
+		return nil, fmt.Errorf("given passphrase file was not a files.File")
+	}
+
+	passphrase, err := ioutil.ReadAll(fi)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read passphrase")
+	}
+	return bytes.TrimRight(passphrase, "\r\n"), nil
+}
@@ -1,6 +1,7 @@
 package commands_test
 
 import (
+	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
@@ -33,6 +34,40 @@ func TestAddrsNewAndList(t *testing.T) {
 	}
 }
 
+func TestAddrsNewBLS(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := makeTestDaemonWithMinerAndStart(t)
+	defer d.ShutdownSuccess()
+
+	blsAddr := d.RunSuccess("address", "new", "--type=bls").ReadStdoutTrimNewlines()
+	addr, err := address.NewFromString(blsAddr)
+	require.NoError(t, err)
+	assert.Equal(t, address.BLS, addr.Protocol())
+
+	d.RunFail("unknown key type", "address", "new", "--type=rsa")
+
+	t.Log("[success] fund the BLS address")
+	msgCid := d.RunSuccess("message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--gas-price", "1",
+		"--value", "100",
+		blsAddr,
+	).ReadStdoutTrimNewlines()
+	d.RunSuccess("mining", "once")
+	d.RunSuccess("message", "wait", "--timeout=1m", msgCid)
+
+	t.Log("[success] send from the BLS address")
+	msgCid = d.RunSuccess("message", "send",
+		"--from", blsAddr,
+		"--gas-price", "1",
+		"--value", "10",
+		fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+	d.RunSuccess("mining", "once")
+	d.RunSuccess("message", "wait", "--timeout=1m", msgCid)
+}
+
 func TestWalletBalance(t *testing.T) {
 	tf.IntegrationTest(t)
 
@@ -162,3 +197,105 @@ func TestWalletExportPrivateKeyConsistentDisplay(t *testing.T) {
 
 	assert.Contains(t, exportJSON, exportTextPrivateKey)
 }
+
+func TestWalletLockUnlock(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	pf, err := ioutil.TempFile("", "passphrase")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Remove(pf.Name()))
+	}()
+	_, err = pf.WriteString("passphrase\n")
+	require.NoError(t, err)
+	require.NoError(t, pf.Close())
+
+	d := th.NewDaemon(t, th.WalletPassphraseFile(pf.Name())).Start()
+	defer d.ShutdownSuccess()
+
+	dw := d.RunSuccess("address", "ls").ReadStdoutTrimNewlines()
+
+	// the wallet starts locked
+	d.RunFail("wallet is locked", "wallet", "export", dw)
+
+	d.RunWithStdin(strings.NewReader("wrong\n"), "wallet", "unlock").AssertFail("incorrect wallet passphrase")
+	d.RunFail("wallet is locked", "wallet", "export", dw)
+
+	d.RunWithStdin(strings.NewReader("passphrase\n"), "wallet", "unlock").AssertSuccess()
+	d.RunSuccess("wallet", "export", dw)
+
+	d.RunSuccess("wallet", "lock")
+	d.RunFail("wallet is locked", "wallet", "export", dw)
+
+	d.RunSuccess("wallet", "unlock", pf.Name())
+	d.RunSuccess("wallet", "export", dw)
+	d.RunSuccess("wallet", "lock")
+
+	// change the passphrase
+	npf, err := ioutil.TempFile("", "new-passphrase")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Remove(npf.Name()))
+	}()
+	_, err = npf.WriteString("new passphrase\n")
+	require.NoError(t, err)
+	require.NoError(t, npf.Close())
+
+	d.RunFail("incorrect wallet passphrase", "wallet", "passphrase", npf.Name(), npf.Name())
+	d.RunSuccess("wallet", "passphrase", pf.Name(), npf.Name())
+	d.RunFail("wallet is locked", "wallet", "export", dw)
+	d.RunFail("incorrect wallet passphrase", "wallet", "unlock", pf.Name())
+	d.RunSuccess("wallet", "unlock", npf.Name())
+	d.RunSuccess("wallet", "export", dw)
+}
+
+func TestWalletMnemonicRecover(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d1 := th.NewDaemon(t).Start()
+	defer d1.ShutdownSuccess()
+
+	d1.RunFail("requires --mnemonic", "wallet", "init")
+	mnemonic := d1.RunSuccess("wallet", "init", "--mnemonic").ReadStdoutTrimNewlines()
+	assert.Len(t, strings.Fields(mnemonic), 24)
+	d1.RunFail("already has an HD seed", "wallet", "init", "--mnemonic")
+
+	addr := d1.RunSuccess("address", "new").ReadStdoutTrimNewlines()
+
+	d2 := th.NewDaemon(t).Start()
+	defer d2.ShutdownSuccess()
+
+	recovered := d2.RunSuccess("wallet", "recover", mnemonic).ReadStdoutTrimNewlines()
+	assert.Equal(t, addr, recovered)
+	assert.Contains(t, d2.RunSuccess("address", "ls").ReadStdoutTrimNewlines(), addr)
+
+	// both wallets derive the same addresses from now on
+	assert.Equal(t,
+		d1.RunSuccess("address", "new").ReadStdoutTrimNewlines(),
+		d2.RunSuccess("address", "new").ReadStdoutTrimNewlines(),
+	)
+}
+
+func TestWalletSignVerify(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	addr := d.RunSuccess("address", "ls").ReadStdoutTrimNewlines()
+
+	sig := d.RunSuccess("wallet", "sign", addr, "challenge").ReadStdoutTrimNewlines()
+	out := d.RunSuccess("wallet", "verify", addr, "challenge", sig).ReadStdoutTrimNewlines()
+	assert.Equal(t, "valid signature of "+addr, out)
+
+	d.RunFail("not valid", "wallet", "verify", addr, "other challenge", sig)
+	other := d.CreateAddress()
+	d.RunFail("not valid", "wallet", "verify", other, "challenge", sig)
+
+	t.Run("data on stdin", func(t *testing.T) {
+		stdinSig := d.RunWithStdin(strings.NewReader("challenge"), "wallet", "sign", addr).AssertSuccess().ReadStdoutTrimNewlines()
+		assert.Equal(t, sig, stdinSig)
+		d.RunWithStdin(strings.NewReader("challenge"), "wallet", "verify", addr, stdinSig).AssertSuccess()
+		d.RunWithStdin(strings.NewReader("other challenge"), "wallet", "verify", addr, stdinSig).AssertFail("not valid")
+	})
+}
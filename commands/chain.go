@@ -10,7 +10,9 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -19,8 +21,228 @@ var chainCmd = &cmds.Command{
 		Tagline: "Inspect the filecoin blockchain",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"head": chainHeadCmd,
-		"ls":   chainLsCmd,
+		"bad":        chainBadCmd,
+		"checkpoint": chainCheckpointCmd,
+		"get":        chainGetCmd,
+		"head":       chainHeadCmd,
+		"ls":         chainLsCmd,
+		"notify":     chainNotifyCmd,
+		"status":     chainStatusCmd,
+	},
+}
+
+var chainBadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the tipsets refused by the syncer",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":   chainBadLsCmd,
+		"show": chainBadShowCmd,
+		"rm":   chainBadRmCmd,
+	},
+}
+
+var chainBadLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the tipsets refused by the syncer",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		bad, err := GetPorcelainAPI(env).ChainBadTipSets()
+		if err != nil {
+			return err
+		}
+		return re.Emit(bad)
+	},
+	Type: []*chain.BadTipSet{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res []*chain.BadTipSet) error {
+			for _, bad := range res {
+				_, err := fmt.Fprintf(w, "%d\t%s\n", bad.Height, bad.Key.String())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var chainBadShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show why a tipset was refused by the syncer",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CIDs of the blocks of the tipset"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		key, err := parseTipSetKey(req.Arguments)
+		if err != nil {
+			return err
+		}
+		bad, err := GetPorcelainAPI(env).ChainBadTipSet(key)
+		if err != nil {
+			return err
+		}
+		return re.Emit(bad)
+	},
+	Type: chain.BadTipSet{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *chain.BadTipSet) error {
+			peer := res.Peer
+			if peer == "" {
+				peer = "(local)"
+			}
+			_, err := fmt.Fprintf(w, "tipset: %s\nheight: %d\npeer: %s\nreason: %s\n", res.Key.String(), res.Height, peer, res.Reason)
+			return err
+		}),
+	},
+}
+
+var chainBadRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Remove a tipset from the tipsets refused by the syncer",
+		ShortDescription: `The syncer validates the tipset again the next time it receives it.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CIDs of the blocks of the tipset"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		key, err := parseTipSetKey(req.Arguments)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).ChainRemoveBadTipSet(key)
+	},
+}
+
+var chainCheckpointCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add a trusted checkpoint to the chain",
+		ShortDescription: `Adds the tipset with the given block CIDs at the given height as a trusted checkpoint.
+The node rejects chains that do not pass through it. The checkpoint is saved in the config.
+Without arguments, lists the trusted checkpoints.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", false, true, "CIDs of the blocks of the checkpoint tipset"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("height", "Height of the checkpoint tipset"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := GetPorcelainAPI(env)
+		if len(req.Arguments) > 0 {
+			height, ok := req.Options["height"].(uint64)
+			if !ok {
+				return errors.New("must specify --height")
+			}
+			key, err := parseTipSetKey(req.Arguments)
+			if err != nil {
+				return err
+			}
+			if err := api.ChainCheckpoint(req.Context, chain.Checkpoint{Height: height, Key: key}); err != nil {
+				return err
+			}
+		}
+		return re.Emit(api.ChainCheckpoints())
+	},
+	Type: []chain.Checkpoint{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res []chain.Checkpoint) error {
+			for _, cp := range res {
+				_, err := fmt.Fprintf(w, "%d\t%s\n", cp.Height, cp.Key.String())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+// ChainNotifyResult is a change to the chain reported by the chain notify
+// command.
+type ChainNotifyResult struct {
+	Type   string
+	Height uint64
+	TipSet types.SortedCidSet
+}
+
+var chainNotifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream changes to the chain as its head moves",
+		ShortDescription: `Streams an apply event for each tipset added to the chain and a revert event for each tipset
+removed from it by a reorg. Without arguments, the stream starts with the current head. Given the CIDs
+of a previously seen tipset, the stream starts with the changes from that tipset to the current head.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("from", false, true, "CIDs of the blocks of the tipset to resume from"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		from, err := parseTipSetKey(req.Arguments)
+		if err != nil {
+			return err
+		}
+
+		changes, err := GetPorcelainAPI(env).ChainNotify(req.Context, from)
+		if err != nil {
+			return err
+		}
+		for change := range changes {
+			height, err := change.TipSet.Height()
+			if err != nil {
+				return err
+			}
+			if err := re.Emit(&ChainNotifyResult{
+				Type:   change.Type.String(),
+				Height: height,
+				TipSet: change.TipSet.ToSortedCidSet(),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: ChainNotifyResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *ChainNotifyResult) error {
+			_, err := fmt.Fprintf(w, "%s\t%d\t%s\n", res.Type, res.Height, res.TipSet.String())
+			return err
+		}),
+	},
+}
+
+// ChainStatusResult is the result of the chain status command.
+type ChainStatusResult struct {
+	Height   uint64
+	SyncMode string
+}
+
+var chainStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Show the height of the chain and whether the node is syncing",
+		ShortDescription: `Prints the height of the heaviest tipset and whether the node is still syncing the chain or has caught up with the network. The node refuses to mine while syncing.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := GetPorcelainAPI(env)
+		head, err := api.ChainHead()
+		if err != nil {
+			return err
+		}
+		height, err := head.Height()
+		if err != nil {
+			return err
+		}
+		return re.Emit(&ChainStatusResult{
+			Height:   height,
+			SyncMode: api.ChainSyncMode().String(),
+		})
+	},
+	Type: &ChainStatusResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *ChainStatusResult) error {
+			_, err := fmt.Fprintf(w, "height: %d\nsync mode: %s\n", res.Height, res.SyncMode)
+			return err
+		}),
 	},
 }
 
@@ -49,6 +271,39 @@ var chainHeadCmd = &cmds.Command{
 	},
 }
 
+var chainGetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Get the tipset at a height of the blockchain",
+		ShortDescription: `Prints the CIDs of the blocks of the tipset at the given height in the current chain. If no blocks were mined at that height the closest tipset below it is printed.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("height", "Height of the tipset to get"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		height, ok := req.Options["height"].(uint64)
+		if !ok {
+			return errors.New("must specify --height")
+		}
+		ts, err := GetPorcelainAPI(env).ChainGetTipSetByHeight(req.Context, height)
+		if err != nil {
+			return err
+		}
+		return re.Emit(ts.ToSortedCidSet())
+	},
+	Type: []cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res []cid.Cid) error {
+			for _, r := range res {
+				_, err := fmt.Fprintln(w, r.String())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
 var chainLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:          "List blocks in the blockchain",
@@ -108,3 +363,16 @@ var chainLsCmd = &cmds.Command{
 		}),
 	},
 }
+
+// parseTipSetKey parses the CIDs of the blocks of a tipset.
+func parseTipSetKey(args []string) (types.SortedCidSet, error) {
+	var key types.SortedCidSet
+	for _, arg := range args {
+		c, err := cid.Decode(arg)
+		if err != nil {
+			return types.SortedCidSet{}, errors.Wrapf(err, "invalid cid %s", arg)
+		}
+		(&key).Add(c)
+	}
+	return key, nil
+}
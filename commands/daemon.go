@@ -39,6 +39,7 @@ var daemonCmd = &cmds.Command{
 		cmdkit.BoolOption(ELStdout),
 		cmdkit.BoolOption(IsRelay, "advertise and allow filecoin network traffic to be relayed through this node"),
 		cmdkit.StringOption(BlockTime, "time a node waits before trying to mine the next block").WithDefault(consensus.DefaultBlockTime.String()),
+		cmdkit.StringOption(ConsensusProtocol, "consensus protocol to run, overriding the config: \"expected\" or \"authority\""),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		return daemonRun(req, re, env)
@@ -73,6 +74,13 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment)
 		rep.Config().Swarm.PublicRelayAddress = publicRelayAddress
 	}
 
+	if protocol, ok := req.Options[ConsensusProtocol].(string); ok && protocol != "" {
+		if protocol != consensus.ExpectedProtocol && protocol != consensus.AuthorityProtocol {
+			return fmt.Errorf("unknown consensus protocol %q", protocol)
+		}
+		rep.Config().Consensus.Protocol = protocol
+	}
+
 	opts, err := node.OptionsFromRepo(rep)
 	if err != nil {
 		return err
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -20,6 +21,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/fixtures"
+	"github.com/filecoin-project/go-filecoin/gengen/util"
 	"github.com/filecoin-project/go-filecoin/node"
 	"github.com/filecoin-project/go-filecoin/paths"
 	"github.com/filecoin-project/go-filecoin/repo"
@@ -33,6 +35,7 @@ var initCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.StringOption(GenesisFile, "path of file or HTTP(S) URL containing archive of genesis block DAG data"),
 		cmdkit.StringOption(PeerKeyFile, "path of file containing key to use for new node's libp2p identity"),
+		cmdkit.StringOption(WalletPassphraseFile, "path of file containing the passphrase to encrypt the wallet with. The wallet must be unlocked with it to sign, unless it is empty"),
 		cmdkit.StringOption(WithMiner, "when set, creates a custom genesis block with a pre generated miner account, requires running the daemon using dev mode (--dev)"),
 		cmdkit.StringOption(OptionSectorDir, "path of directory into which staged and sealed sectors will be written"),
 		cmdkit.StringOption(DefaultAddress, "when set, sets the daemons's default address to the provided address"),
@@ -40,6 +43,7 @@ var initCmd = &cmds.Command{
 		cmdkit.BoolOption(DevnetTest, "when set, populates config bootstrap addrs with the dns multiaddrs of the test devnet and other test devnet specific bootstrap parameters."),
 		cmdkit.BoolOption(DevnetNightly, "when set, populates config bootstrap addrs with the dns multiaddrs of the nightly devnet and other nightly devnet specific bootstrap parameters"),
 		cmdkit.BoolOption(DevnetUser, "when set, populates config bootstrap addrs with the dns multiaddrs of the user devnet and other user devnet specific bootstrap parameters"),
+		cmdkit.StringOption(ConsensusProtocol, "consensus protocol of the network, either \"expected\" or \"authority\". Defaults to the one of the genesis file's network, or \"expected\""),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		newConfig, err := getConfigFromOptions(req.Options)
@@ -68,14 +72,21 @@ var initCmd = &cmds.Command{
 		defer rep.Close() // nolint: errcheck
 
 		genesisFileSource, _ := req.Options[GenesisFile].(string)
-		genesisFile, err := loadGenesis(req.Context, rep, genesisFileSource)
+		genesisFile, params, err := loadGenesis(req.Context, rep, genesisFileSource)
 		if err != nil {
 			return err
 		}
+		if params != nil {
+			protocol, _ := req.Options[ConsensusProtocol].(string)
+			if err := applyNetworkParams(rep, params, protocol); err != nil {
+				return err
+			}
+		}
 
 		autoSealIntervalSeconds, _ := req.Options[AutoSealIntervalSeconds].(uint)
 		peerKeyFile, _ := req.Options[PeerKeyFile].(string)
-		initopts, err := getNodeInitOpts(autoSealIntervalSeconds, peerKeyFile)
+		walletPassphraseFile, _ := req.Options[WalletPassphraseFile].(string)
+		initopts, err := getNodeInitOpts(autoSealIntervalSeconds, peerKeyFile, walletPassphraseFile)
 		if err != nil {
 			return err
 		}
@@ -110,6 +121,13 @@ func getConfigFromOptions(options cmdkit.OptMap) (*config.Config, error) {
 		}
 	}
 
+	if protocol, ok := options[ConsensusProtocol].(string); ok {
+		if protocol != consensus.ExpectedProtocol && protocol != consensus.AuthorityProtocol {
+			return nil, fmt.Errorf("unknown consensus protocol %q", protocol)
+		}
+		newConfig.Consensus.Protocol = protocol
+	}
+
 	devnetTest, _ := options[DevnetTest].(bool)
 	devnetNightly, _ := options[DevnetNightly].(bool)
 	devnetUser, _ := options[DevnetUser].(bool)
@@ -149,14 +167,16 @@ func initTextEncoder(req *cmds.Request, w io.Writer, val interface{}) error {
 	return err
 }
 
-func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (consensus.GenesisInitFunc, error) {
+// loadGenesis loads the genesis car from sourceName into the repo, and
+// returns its genesis block and the network parameters written in it, if any.
+func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (consensus.GenesisInitFunc, *gengen.NetworkParams, error) {
 	if sourceName == "" {
-		return consensus.MakeGenesisFunc(consensus.ProofsMode(types.LiveProofsMode)), nil
+		return consensus.MakeGenesisFunc(consensus.ProofsMode(types.LiveProofsMode)), nil, nil
 	}
 
 	sourceURL, err := url.Parse(sourceName)
 	if err != nil {
-		return nil, fmt.Errorf("invalid filepath or URL for genesis file: %s", sourceURL)
+		return nil, nil, fmt.Errorf("invalid filepath or URL for genesis file: %s", sourceURL)
 	}
 
 	var source io.ReadCloser
@@ -165,15 +185,15 @@ func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (consens
 		// recently deployed test devnet.
 		response, err := http.Get(sourceName)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		source = response.Body
 	} else if sourceURL.Scheme != "" {
-		return nil, fmt.Errorf("unsupported protocol for genesis file: %s", sourceURL.Scheme)
+		return nil, nil, fmt.Errorf("unsupported protocol for genesis file: %s", sourceURL.Scheme)
 	} else {
 		file, err := os.Open(sourceName)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		source = file
 	}
@@ -182,11 +202,16 @@ func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (consens
 	bs := blockstore.NewBlockstore(rep.Datastore())
 	ch, err := car.LoadCar(bs, source)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if len(ch.Roots) != 1 {
-		return nil, fmt.Errorf("expected car with only a single root")
+	// The genesis block may be followed by network parameters.
+	if len(ch.Roots) != 1 && len(ch.Roots) != 2 {
+		return nil, nil, fmt.Errorf("expected car with the genesis block and optionally network parameters as roots")
+	}
+	params, err := gengen.LoadNetworkParams(bs, ch.Roots)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	gif := func(cst *hamt.CborIpldStore, bs blockstore.Blockstore) (*types.Block, error) {
@@ -199,10 +224,29 @@ func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (consens
 		return &blk, nil
 	}
 
-	return gif, nil
+	return gif, params, nil
+}
+
+// applyNetworkParams adds the network parameters of the genesis car to the
+// repo's config.  It fails if protocol, the consensus protocol passed on
+// the command line, if any, isn't the network's.
+func applyNetworkParams(rep repo.Repo, params *gengen.NetworkParams, protocol string) error {
+	cfg := rep.Config()
+	network := params.Consensus
+	if network == "" {
+		network = consensus.ExpectedProtocol
+	}
+	if protocol != "" && protocol != network {
+		return fmt.Errorf("the genesis file's network runs %q consensus, not %q", network, protocol)
+	}
+	cfg.Consensus.Protocol = network
+	for _, cp := range params.Checkpoints {
+		cfg.Sync.Checkpoints = append(cfg.Sync.Checkpoints, &config.CheckpointConfig{Height: cp.Height, TipSet: cp.TipSet})
+	}
+	return rep.ReplaceConfig(cfg)
 }
 
-func getNodeInitOpts(autoSealIntervalSeconds uint, peerKeyFile string) ([]node.InitOpt, error) {
+func getNodeInitOpts(autoSealIntervalSeconds uint, peerKeyFile string, walletPassphraseFile string) ([]node.InitOpt, error) {
 	var initOpts []node.InitOpt
 	if peerKeyFile != "" {
 		data, err := ioutil.ReadFile(peerKeyFile)
@@ -216,6 +260,14 @@ func getNodeInitOpts(autoSealIntervalSeconds uint, peerKeyFile string) ([]node.I
 		initOpts = append(initOpts, node.PeerKeyOpt(peerKey))
 	}
 
+	if walletPassphraseFile != "" {
+		passphrase, err := ioutil.ReadFile(walletPassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		initOpts = append(initOpts, node.WalletPassphraseOpt(bytes.TrimRight(passphrase, "\r\n")))
+	}
+
 	initOpts = append(initOpts, node.AutoSealIntervalSecondsOpt(autoSealIntervalSeconds))
 
 	return initOpts, nil
@@ -60,6 +60,9 @@ const (
 	// PeerKeyFile is the path of file containing key to use for new nodes libp2p identity
 	PeerKeyFile = "peerkeyfile"
 
+	// WalletPassphraseFile is the path of file containing the passphrase to encrypt a new node's wallet with
+	WalletPassphraseFile = "wallet-passphrase-file"
+
 	// WithMiner when set, creates a custom genesis block with a pre generated miner account, requires to run the daemon using dev mode (--dev)
 	WithMiner = "with-miner"
 
@@ -81,6 +84,10 @@ const (
 	// IsRelay when set causes the the daemon to provide libp2p relay
 	// services allowing other filecoin nodes behind NATs to talk directly.
 	IsRelay = "is-relay"
+
+	// ConsensusProtocol is the consensus protocol the node runs, either
+	// "expected" or "authority".
+	ConsensusProtocol = "consensus"
 )
 
 // command object for the local cli
@@ -155,6 +162,12 @@ var rootSubcmdsLocal = map[string]*cmds.Command{
 	"version": versionCmd,
 }
 
+// subcommands of top level commands available on daemon that run locally, without a daemon
+var subcmdsLocal = []*cmds.Command{
+	msgSignCmd,
+	walletVerifyCmd,
+}
+
 // all top level commands, available on daemon. set during init() to avoid configuration loops.
 var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"actor":            actorCmd,
@@ -306,6 +319,11 @@ func requiresDaemon(req *cmds.Request) bool {
 			return false
 		}
 	}
+	for _, cmd := range subcmdsLocal {
+		if req.Command == cmd {
+			return false
+		}
+	}
 	return true
 }
 
@@ -327,30 +345,31 @@ func isConnectionRefused(err error) bool {
 	return syscallErr.Err == syscall.ECONNREFUSED
 }
 
-var priceOption = cmdkit.StringOption("gas-price", "Price (FIL e.g. 0.00013) to pay for each GasUnits consumed mining this message")
-var limitOption = cmdkit.Uint64Option("gas-limit", "Maximum number of GasUnits this message is allowed to consume")
+var priceOption = cmdkit.StringOption("gas-price", "Price (FIL e.g. 0.00013) to pay for each GasUnits consumed mining this message (default: the price suggested by `message gas-price`)")
+var limitOption = cmdkit.Uint64Option("gas-limit", "Maximum number of GasUnits this message is allowed to consume (default: estimated by previewing the message)")
 var previewOption = cmdkit.BoolOption("preview", "Preview the Gas cost of this command without actually executing it")
 
+// parseGasOptions returns the gas price and limit options of a command sending a message,
+// and whether to only preview it.  A price or limit that isn't given is returned as zero,
+// which porcelain.MessageSendWithDefaultAddress replaces with a suggestion or estimate.
 func parseGasOptions(req *cmds.Request) (types.AttoFIL, types.GasUnits, bool, error) {
-	priceOption := req.Options["gas-price"]
-	if priceOption == nil {
-		return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New("price option is required")
-	}
-
-	price, ok := types.NewAttoFILFromFILString(priceOption.(string))
-	if !ok {
-		return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New("invalid gas price (specify FIL as a decimal number)")
-	}
-
-	limitOption := req.Options["gas-limit"]
-	if limitOption == nil {
-		return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New("limit option is required")
+	price := types.ZeroAttoFIL
+	if priceOption := req.Options["gas-price"]; priceOption != nil {
+		var ok bool
+		price, ok = types.NewAttoFILFromFILString(priceOption.(string))
+		if !ok {
+			return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New("invalid gas price (specify FIL as a decimal number)")
+		}
 	}
 
-	gasLimitInt, ok := limitOption.(uint64)
-	if !ok {
-		msg := fmt.Sprintf("invalid gas limit: %s", limitOption)
-		return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New(msg)
+	gasLimitInt := uint64(0)
+	if limitOption := req.Options["gas-limit"]; limitOption != nil {
+		var ok bool
+		gasLimitInt, ok = limitOption.(uint64)
+		if !ok {
+			msg := fmt.Sprintf("invalid gas limit: %s", limitOption)
+			return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New(msg)
+		}
 	}
 
 	preview, _ := req.Options["preview"].(bool)
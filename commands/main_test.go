@@ -18,6 +18,14 @@ func TestRequiresDaemon(t *testing.T) {
 	reqWithoutDaemon, err := cmds.NewRequest(context.Background(), []string{}, nil, []string{"daemon"}, nil, daemonCmd)
 	assert.NoError(t, err)
 
+	reqSubcmdWithoutDaemon, err := cmds.NewRequest(context.Background(), []string{"message", "sign"}, nil, nil, nil, msgSignCmd)
+	assert.NoError(t, err)
+
+	reqVerifyWithoutDaemon, err := cmds.NewRequest(context.Background(), []string{"wallet", "verify"}, nil, nil, nil, walletVerifyCmd)
+	assert.NoError(t, err)
+
 	assert.True(t, requiresDaemon(reqWithDaemon))
 	assert.False(t, requiresDaemon(reqWithoutDaemon))
+	assert.False(t, requiresDaemon(reqSubcmdWithoutDaemon))
+	assert.False(t, requiresDaemon(reqVerifyWithoutDaemon))
 }
@@ -1,16 +1,22 @@
 package commands
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-ipfs-files"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/abi"
@@ -19,7 +25,9 @@ import (
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
 var msgCmd = &cmds.Command{
@@ -27,9 +35,16 @@ var msgCmd = &cmds.Command{
 		Tagline: "Send and monitor messages",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"send":   msgSendCmd,
-		"status": msgStatusCmd,
-		"wait":   msgWaitCmd,
+		"create":       msgCreateCmd,
+		"estimate-gas": msgEstimateGasCmd,
+		"gas-price":    msgGasPriceCmd,
+		"replace":      msgReplaceCmd,
+		"send":         msgSendCmd,
+		"send-batch":   msgSendBatchCmd,
+		"sign":         msgSignCmd,
+		"status":       msgStatusCmd,
+		"submit":       msgSubmitCmd,
+		"wait":         msgWaitCmd,
 	},
 }
 
@@ -140,6 +155,489 @@ var msgSendCmd = &cmds.Command{
 	},
 }
 
+// MessageSendBatchResult is the return type for message send-batch command
+type MessageSendBatchResult struct {
+	Cids     []cid.Cid
+	Receipts []*types.MessageReceipt // receipts of the messages, if waited for
+	Preview  *porcelain.BatchPreview // set instead of the cids on a dry run
+}
+
+var msgSendBatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Send a batch of messages listed in a CSV file",
+		ShortDescription: `
+Reads messages from a CSV file with one message per row, with the columns:
+
+  to,value[,method[,params]]
+
+where value is in FIL and params are the method's parameters abi encoded in
+base64, as 'mpool ls' shows them. An optional first row naming the columns is
+skipped, as are lines starting with '#'. The messages are sent from the same
+address with consecutive nonces; no message is sent unless all of them are
+valid and the sender's balance covers their total value and gas. Prints the CID
+of each message in the order of the rows.
+
+Messages beyond what message pools accept from a sender (mpool.maxPendingPerSender
+and mpool.maxNonceGap) are held by this node and published as earlier ones are
+mined; their CIDs are printed all the same.
+
+With --dry-run nothing is sent; instead the gas each message uses and the total
+value of the batch are printed.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "CSV file listing the messages to send").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send the messages from"),
+		priceOption,
+		cmdkit.Uint64Option("gas-limit", "Maximum number of GasUnits each message is allowed to consume (default: estimated for each message)"),
+		cmdkit.BoolOption("dry-run", "Preview the gas used by the messages and their total value without sending them"),
+		cmdkit.BoolOption("wait", "Wait for the messages to be mined and print their exit codes"),
+		cmdkit.StringOption("timeout", "Maximum time to wait for the messages to be mined. e.g., 300ms, 1.5h, 2h45m.").WithDefault("10m"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		iter := req.Files.Entries()
+		if !iter.Next() {
+			return fmt.Errorf("no file given: %s", iter.Err())
+		}
+
+		fi, ok := iter.Node().(files.File)
+		if !ok {
+			return fmt.Errorf("given file was not a files.File")
+		}
+
+		batch, err := parseMessageBatch(fi)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return errors.New("no messages in file")
+		}
+
+		fromAddr, err := optionalAddr(req.Options["from"])
+		if err != nil {
+			return err
+		}
+
+		if dryRun, _ := req.Options["dry-run"].(bool); dryRun {
+			preview, err := GetPorcelainAPI(env).MessagePreviewBatch(req.Context, fromAddr, batch)
+			if err != nil {
+				return err
+			}
+			return re.Emit(&MessageSendBatchResult{Preview: preview})
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		cids, err := GetPorcelainAPI(env).MessageSendBatchWithDefaultAddress(req.Context, fromAddr, gasPrice, gasLimit, batch)
+		if err != nil {
+			if len(cids) > 0 {
+				return errors.Wrapf(err, "sent only the first %d messages %s", len(cids), cids)
+			}
+			return err
+		}
+		result := &MessageSendBatchResult{Cids: cids}
+
+		if wait, _ := req.Options["wait"].(bool); wait {
+			timeoutDuration, err := time.ParseDuration(req.Options["timeout"].(string))
+			if err != nil {
+				return errors.Wrap(err, "Invalid timeout string")
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context, timeoutDuration)
+			defer cancel()
+
+			for _, c := range cids {
+				err := GetPorcelainAPI(env).MessageWait(ctx, c, func(blk *types.Block, msg *types.SignedMessage, receipt *types.MessageReceipt) error {
+					result.Receipts = append(result.Receipts, receipt)
+					return nil
+				})
+				if err != nil {
+					return errors.Wrapf(err, "failed waiting for message %s", c)
+				}
+			}
+		}
+
+		return re.Emit(result)
+	},
+	Type: &MessageSendBatchResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *MessageSendBatchResult) error {
+			sw := NewSilentWriter(w)
+			if res.Preview != nil {
+				for i, gasUsed := range res.Preview.GasUsed {
+					sw.Printf("%d\t%d\n", i, gasUsed)
+				}
+				sw.Printf("Total gas used: %d\n", res.Preview.TotalGasUsed)
+				sw.Printf("Total value:    %s FIL\n", res.Preview.TotalValue)
+				return sw.Error()
+			}
+
+			for i, c := range res.Cids {
+				if i < len(res.Receipts) {
+					sw.Printf("%s\t%d\n", c, res.Receipts[i].ExitCode)
+				} else {
+					sw.Println(c.String())
+				}
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+// parseMessageBatch reads the messages of a batch from CSV rows of to, value in FIL, and
+// optionally method and base64 abi encoded params.
+func parseMessageBatch(r io.Reader) ([]porcelain.BatchMessage, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CSV")
+	}
+	if len(records) > 0 && strings.EqualFold(records[0][0], "to") {
+		records = records[1:]
+	}
+
+	batch := make([]porcelain.BatchMessage, len(records))
+	for i, record := range records {
+		if len(record) < 2 || len(record) > 4 {
+			return nil, errors.Errorf("row %d: expected 2 to 4 columns, got %d", i+1, len(record))
+		}
+
+		to, err := address.NewFromString(record[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "row %d: invalid address", i+1)
+		}
+
+		value, ok := types.NewAttoFILFromFILString(record[1])
+		if !ok {
+			return nil, errors.Errorf("row %d: mal-formed value", i+1)
+		}
+
+		batch[i] = porcelain.BatchMessage{To: to, Value: value}
+		if len(record) > 2 {
+			batch[i].Method = record[2]
+		}
+		if len(record) > 3 && record[3] != "" {
+			batch[i].Params, err = base64.StdEncoding.DecodeString(record[3])
+			if err != nil {
+				return nil, errors.Wrapf(err, "row %d: invalid params", i+1)
+			}
+		}
+	}
+	return batch, nil
+}
+
+var msgCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an unsigned message to sign offline",
+		ShortDescription: `
+Prints an unsigned message as JSON, with the nonce following the messages from
+the from address known to this node. Sign it with 'message sign' where the key
+of the from address is kept, then publish it with 'message submit'. Submit the
+message before creating another from the same address.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+		cmdkit.StringArg("method", false, false, "The method to invoke on the target actor"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("value", "Value to send with message in FIL"),
+		cmdkit.StringOption("from", "Address to send message from"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		rawVal, ok := req.Options["value"].(string)
+		if !ok {
+			rawVal = "0"
+		}
+		val, ok := types.NewAttoFILFromFILString(rawVal)
+		if !ok {
+			return errors.New("mal-formed value")
+		}
+
+		rawFrom, ok := req.Options["from"].(string)
+		if !ok {
+			return errors.New("from option is required")
+		}
+		fromAddr, err := address.NewFromString(rawFrom)
+		if err != nil {
+			return errors.Wrap(err, "invalid from address")
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		method := ""
+		if len(req.Arguments) > 1 {
+			method = req.Arguments[1]
+		}
+
+		msg, err := GetPorcelainAPI(env).MessageCreate(req.Context, fromAddr, target, val, gasPrice, gasLimit, method)
+		if err != nil {
+			return err
+		}
+		return re.Emit(msg)
+	},
+	Type: types.MeteredMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, msg *types.MeteredMessage) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "\t")
+			return encoder.Encode(msg)
+		}),
+	},
+}
+
+var msgSignCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign a message created with 'message create'",
+		ShortDescription: `
+Signs an unsigned message with the key of its from address and prints the signed
+message as JSON, ready for 'message submit'. The key is read from the wallet of
+the local repo, so this command runs without a daemon and is meant for machines
+that never connect to the network. It fails while a daemon is using the repo.
+A wallet with a passphrase is unlocked with the one in --passphrase-file.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from-file", "File containing the unsigned message as JSON"),
+		cmdkit.StringOption("passphrase-file", "File containing the passphrase of the wallet"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		path, ok := req.Options["from-file"].(string)
+		if !ok {
+			return errors.New("from-file option is required")
+		}
+
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to read message file")
+		}
+		var msg types.MeteredMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return errors.Wrap(err, "invalid message file")
+		}
+
+		rep, err := getRepo(req)
+		if err != nil {
+			return errors.Wrap(err, "failed to open repo (is a daemon running?)")
+		}
+		defer rep.Close() // nolint: errcheck
+
+		backend, err := wallet.NewDSBackend(rep.WalletDatastore())
+		if err != nil {
+			return errors.Wrap(err, "failed to open wallet")
+		}
+		w := wallet.New(backend)
+		if !w.HasAddress(msg.From) {
+			return errors.Errorf("wallet has no key for %s", msg.From)
+		}
+
+		if passphraseFile, ok := req.Options["passphrase-file"].(string); ok {
+			passphrase, err := ioutil.ReadFile(passphraseFile)
+			if err != nil {
+				return errors.Wrap(err, "failed to read passphrase file")
+			}
+			if err := w.Unlock(bytes.TrimRight(passphrase, "\r\n"), 0); err != nil {
+				return err
+			}
+		}
+
+		signed, err := types.NewSignedMessage(msg.Message, w, msg.GasPrice, msg.GasLimit)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign message")
+		}
+		return re.Emit(signed)
+	},
+	Type: types.SignedMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, msg *types.SignedMessage) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "\t")
+			return encoder.Encode(msg)
+		}),
+	},
+}
+
+var msgSubmitCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Publish a message signed with 'message sign'",
+		ShortDescription: `
+Validates a signed message against the latest state as if it were received from
+the network, adds it to the message pool and publishes it. Prints the message's
+CID, which 'message wait' and 'message status' accept.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("message", true, false, "File containing the signed message as JSON").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		iter := req.Files.Entries()
+		if !iter.Next() {
+			return fmt.Errorf("no file given: %s", iter.Err())
+		}
+
+		fi, ok := iter.Node().(files.File)
+		if !ok {
+			return fmt.Errorf("given file was not a files.File")
+		}
+
+		var msg types.SignedMessage
+		if err := json.NewDecoder(fi).Decode(&msg); err != nil {
+			return errors.Wrap(err, "invalid message")
+		}
+
+		c, err := GetPorcelainAPI(env).MessageSubmit(req.Context, &msg)
+		if err != nil {
+			return err
+		}
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
+var msgEstimateGasCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Estimate the gas limit of a message",
+		ShortDescription: `
+Previews the message against the latest state and prints the gas it uses plus a
+safety margin. This is the gas limit used by commands sending a message when no
+--gas-limit is given.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+		cmdkit.StringArg("method", false, false, "The method to invoke on the target actor"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send message from"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		var fromAddr address.Address
+		if o, ok := req.Options["from"].(string); ok {
+			fromAddr, err = address.NewFromString(o)
+			if err != nil {
+				return errors.Wrap(err, "invalid from address")
+			}
+		}
+
+		method := ""
+		if len(req.Arguments) > 1 {
+			method = req.Arguments[1]
+		}
+
+		gasLimit, err := GetPorcelainAPI(env).MessageEstimateGasLimit(req.Context, fromAddr, target, method)
+		if err != nil {
+			return err
+		}
+		return re.Emit(gasLimit)
+	},
+	Type: types.GasUnits(0),
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, gasLimit types.GasUnits) error {
+			_, err := fmt.Fprintln(w, uint64(gasLimit))
+			return err
+		}),
+	},
+}
+
+var msgGasPriceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Suggest a gas price for a new message",
+		ShortDescription: `
+Prints a gas price in FIL suggested from the gas prices of the messages in the
+most recent blocks and in the message pool. This is the gas price used by
+commands sending a message when no --gas-price is given.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		gasPrice, err := GetPorcelainAPI(env).MessageSuggestGasPrice(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(gasPrice)
+	},
+	Type: &types.AttoFIL{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, gasPrice types.AttoFIL) error {
+			return PrintString(w, gasPrice)
+		}),
+	},
+}
+
+var msgReplaceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Replace a pending message with one paying a higher gas price",
+		ShortDescription: `
+Re-signs a message sent by this node that has not been mined yet with a new gas
+price and broadcasts it in place of the original. The replacement has the same
+nonce, so at most one of the two messages is mined. Nodes only accept the
+replacement if its gas price exceeds the original's by their replace-by-fee
+margin (mpool.replaceByFeePercent). Without --gas-price the replacement pays the
+lowest price that does, and a lower --gas-price is rejected.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of the message to replace"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("gas-price", "Price (FIL e.g. 0.00013) the replacement pays for each GasUnits consumed mining it, at least the original's plus mpool.replaceByFeePercent (the default)"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Parse(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid cid "+req.Arguments[0])
+		}
+
+		gasPrice := types.ZeroAttoFIL
+		if rawPrice, ok := req.Options["gas-price"].(string); ok {
+			gasPrice, ok = types.NewAttoFILFromFILString(rawPrice)
+			if !ok {
+				return errors.New("invalid gas price (specify FIL as a decimal number)")
+			}
+		}
+
+		c, err := GetPorcelainAPI(env).MessageReplaceWithMinGasPrice(req.Context, msgCid, gasPrice)
+		if err != nil {
+			return err
+		}
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
 // WaitResult is the result of a message wait call.
 type WaitResult struct {
 	Message   *types.SignedMessage
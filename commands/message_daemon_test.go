@@ -1,9 +1,13 @@
 package commands_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,6 +73,158 @@ func TestMessageSend(t *testing.T) {
 	)
 }
 
+func TestMessageReplace(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := makeTestDaemonWithMinerAndStart(t)
+	defer d.ShutdownSuccess()
+
+	msgCid := d.RunSuccess("message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--gas-price", "1",
+		"--gas-limit", "300",
+		"--value", "10",
+		fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+
+	t.Log("[failure] gas price not high enough")
+	d.RunFail("does not exceed", "message", "replace", msgCid, "--gas-price", "1.05")
+
+	t.Log("[success] higher gas price")
+	replacementCid := d.RunSuccess("message", "replace", msgCid, "--gas-price", "2").ReadStdoutTrimNewlines()
+	assert.NotEqual(t, msgCid, replacementCid)
+
+	pending := d.RunSuccess("mpool", "ls").ReadStdout()
+	assert.Contains(t, pending, replacementCid)
+	assert.NotContains(t, pending, msgCid)
+
+	t.Log("[failure] original no longer queued")
+	d.RunFail("not in the outbound queue", "message", "replace", msgCid, "--gas-price", "3")
+
+	t.Log("[success] defaults to the lowest outbidding gas price")
+	defaultCid := d.RunSuccess("message", "replace", replacementCid).ReadStdoutTrimNewlines()
+	assert.Contains(t, d.RunSuccess("mpool", "ls").ReadStdout(), defaultCid)
+}
+
+func TestMessageGasDefaults(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := makeTestDaemonWithMinerAndStart(t)
+	defer d.ShutdownSuccess()
+
+	t.Log("[success] value transfers need no gas")
+	gasLimit := d.RunSuccess("message", "estimate-gas", fixtures.TestAddresses[1]).ReadStdoutTrimNewlines()
+	assert.Equal(t, "0", gasLimit)
+
+	t.Log("[success] suggests a price from pending messages")
+	d.RunSuccess("message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--gas-price", "1",
+		"--gas-limit", "300",
+		fixtures.TestAddresses[1],
+	)
+	gasPrice := d.RunSuccess("message", "gas-price").ReadStdoutTrimNewlines()
+	assert.Equal(t, "1", gasPrice)
+
+	t.Log("[success] send without gas options")
+	msgCid := d.RunSuccess("message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--value", "10",
+		fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+
+	d.RunSuccess("mining", "once")
+	d.RunSuccess("message", "wait", "--timeout=1m", msgCid)
+}
+
+func TestMessageOfflineSigning(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := makeTestDaemonWithMinerAndStart(t)
+	defer d.ShutdownSuccess()
+
+	unsigned := d.RunSuccess("message", "create",
+		"--from", fixtures.TestAddresses[0],
+		"--value", "10",
+		"--gas-price", "1",
+		fixtures.TestAddresses[1],
+	).ReadStdout()
+
+	var msg types.MeteredMessage
+	require.NoError(t, json.Unmarshal([]byte(unsigned), &msg))
+	assert.Equal(t, fixtures.TestAddresses[0], msg.From.String())
+	assert.True(t, types.NewAttoFILFromFIL(10).Equal(msg.Value))
+
+	msgFile := filepath.Join(d.RepoDir(), "unsigned.json")
+	require.NoError(t, ioutil.WriteFile(msgFile, []byte(unsigned), 0644))
+
+	// Signing uses the repo's wallet, which the daemon keeps locked.
+	d.RunFail("daemon running", "message", "sign", "--from-file", msgFile)
+
+	d.Stop()
+	signed := d.RunSuccess("message", "sign", "--from-file", msgFile).ReadStdout()
+	d.Start()
+
+	msgCid := d.RunWithStdin(strings.NewReader(signed), "message", "submit").AssertSuccess().ReadStdoutTrimNewlines()
+
+	d.RunSuccess("mining", "once")
+	d.RunSuccess("message", "wait", "--timeout=1m", msgCid)
+
+	t.Log("[failure] tampered message")
+	var tampered types.SignedMessage
+	require.NoError(t, json.Unmarshal([]byte(signed), &tampered))
+	tampered.Nonce++
+	tampered.Value = types.NewAttoFILFromFIL(1000)
+	raw, err := json.Marshal(&tampered)
+	require.NoError(t, err)
+	d.RunWithStdin(bytes.NewReader(raw), "message", "submit").AssertFail("invalid signature")
+}
+
+func TestMessageSendBatch(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := makeTestDaemonWithMinerAndStart(t)
+	defer d.ShutdownSuccess()
+
+	batch := "to,value\n" +
+		fixtures.TestAddresses[1] + ",10\n" +
+		fixtures.TestAddresses[2] + ",20\n" +
+		fixtures.TestAddresses[3] + ",30\n"
+
+	t.Log("[success] dry run")
+	preview := d.RunWithStdin(strings.NewReader(batch), "message", "send-batch",
+		"--from", fixtures.TestAddresses[0],
+		"--dry-run",
+	).AssertSuccess().ReadStdout()
+	assert.Contains(t, preview, "Total value:    60 FIL")
+
+	pending := d.RunSuccess("mpool", "ls").ReadStdoutTrimNewlines()
+	assert.Empty(t, pending)
+
+	t.Log("[success] send and wait")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.RunSuccess("mpool", "ls", "--wait-for-count=3")
+		d.RunSuccess("mining", "once")
+	}()
+	out := d.RunWithStdin(strings.NewReader(batch), "message", "send-batch",
+		"--from", fixtures.TestAddresses[0],
+		"--gas-price", "1",
+		"--wait",
+		"--timeout=1m",
+	).AssertSuccess().ReadStdoutTrimNewlines()
+
+	wg.Wait()
+
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 3)
+	for _, line := range lines {
+		assert.True(t, strings.HasSuffix(line, "\t0"), line)
+	}
+}
+
 func TestMessageWait(t *testing.T) {
 	tf.IntegrationTest(t)
 
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestParseMessageBatch(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrs := address.NewForTestGetter()
+	a1, a2 := addrs(), addrs()
+
+	params, err := abi.ToEncodedValues("hello")
+	require.NoError(t, err)
+
+	t.Run("parses rows", func(t *testing.T) {
+		csv := "to,value,method,params\n" +
+			"# rewards for the first week\n" +
+			a1.String() + ",1.5\n" +
+			a2.String() + ", 2, method, " + base64.StdEncoding.EncodeToString(params) + "\n"
+
+		batch, err := parseMessageBatch(strings.NewReader(csv))
+		require.NoError(t, err)
+		require.Len(t, batch, 2)
+
+		assert.Equal(t, a1, batch[0].To)
+		expected, _ := types.NewAttoFILFromFILString("1.5")
+		assert.True(t, expected.Equal(batch[0].Value))
+		assert.Equal(t, "", batch[0].Method)
+		assert.Empty(t, batch[0].Params)
+
+		assert.Equal(t, a2, batch[1].To)
+		assert.True(t, types.NewAttoFILFromFIL(2).Equal(batch[1].Value))
+		assert.Equal(t, "method", batch[1].Method)
+		assert.Equal(t, params, batch[1].Params)
+	})
+
+	t.Run("rejects invalid rows", func(t *testing.T) {
+		for _, csv := range []string{
+			a1.String() + "\n",
+			"notanaddress,1\n",
+			a1.String() + ",notavalue\n",
+			a1.String() + ",1,method,not base64!\n",
+			a1.String() + ",1,method,,extra\n",
+		} {
+			_, err := parseMessageBatch(strings.NewReader(csv))
+			assert.Error(t, err, csv)
+		}
+	})
+}
@@ -1,12 +1,16 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-ipfs-files"
+
+	"github.com/filecoin-project/go-filecoin/types"
 )
 
 var miningCmd = &cmds.Command{
@@ -14,9 +18,11 @@ var miningCmd = &cmds.Command{
 		Tagline: "Manage all mining operations for a node",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"once":  miningOnceCmd,
-		"start": miningStartCmd,
-		"stop":  miningStopCmd,
+		"once":     miningOnceCmd,
+		"start":    miningStartCmd,
+		"stop":     miningStopCmd,
+		"template": miningTemplateCmd,
+		"submit":   miningSubmitCmd,
 	},
 }
 
@@ -37,6 +43,70 @@ var miningOnceCmd = &cmds.Command{
 	},
 }
 
+var miningTemplateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an unsigned candidate block on the current head",
+		ShortDescription: `
+Selects messages from the pool and applies them on the current head, then
+outputs the resulting candidate block as JSON, with its state root and message
+receipts. The block is neither signed nor published: fill in its ticket, proof
+and signature and pass it to 'mining submit'.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		blk, err := GetBlockAPI(env).MiningTemplate(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(blk)
+	},
+	Type: types.Block{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, blk *types.Block) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "\t")
+			return encoder.Encode(blk)
+		}),
+	},
+}
+
+var miningSubmitCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add a completed block to the chain and publish it",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("block", true, false, "File containing the block as JSON").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		iter := req.Files.Entries()
+		if !iter.Next() {
+			return fmt.Errorf("no file given: %s", iter.Err())
+		}
+
+		fi, ok := iter.Node().(files.File)
+		if !ok {
+			return fmt.Errorf("given file was not a files.File")
+		}
+
+		var blk types.Block
+		if err := json.NewDecoder(fi).Decode(&blk); err != nil {
+			return err
+		}
+
+		if err := GetBlockAPI(env).MiningSubmit(req.Context, &blk); err != nil {
+			return err
+		}
+		return re.Emit(blk.Cid())
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			fmt.Fprintln(w, c) // nolint: errcheck
+			return nil
+		}),
+	},
+}
+
 var miningStartCmd = &cmds.Command{
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		if err := GetBlockAPI(env).MiningStart(req.Context); err != nil {
@@ -1,14 +1,18 @@
 package commands_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"math/big"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/fixtures"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
 )
 
 func parseInt(t *testing.T, s string) *big.Int {
@@ -37,3 +41,20 @@ func TestMiningGenBlock(t *testing.T) {
 
 	assert.Equal(t, sum.Add(beforeBalance, big.NewInt(1000)), afterBalance)
 }
+
+func TestMiningTemplateAndSubmit(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	d := makeTestDaemonWithMinerAndStart(t)
+	defer d.ShutdownSuccess()
+
+	var tmpl types.Block
+	require.NoError(t, json.Unmarshal([]byte(d.RunSuccess("mining", "template").ReadStdout()), &tmpl))
+	assert.Equal(t, types.Uint64(1), tmpl.Height)
+	assert.Empty(t, tmpl.BlockSig)
+
+	// An unsigned template is not a valid block.
+	tmplJSON, err := json.Marshal(&tmpl)
+	require.NoError(t, err)
+	d.RunWithStdin(bytes.NewReader(tmplJSON), "mining", "submit").AssertFail("")
+}
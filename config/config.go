@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"reflect"
 	"regexp"
@@ -19,14 +20,17 @@ import (
 type Config struct {
 	API           *APIConfig           `json:"api"`
 	Bootstrap     *BootstrapConfig     `json:"bootstrap"`
+	Consensus     *ConsensusConfig     `json:"consensus"`
 	Datastore     *DatastoreConfig     `json:"datastore"`
 	Heartbeat     *HeartbeatConfig     `json:"heartbeat"`
 	Mining        *MiningConfig        `json:"mining"`
 	Mpool         *MessagePoolConfig   `json:"mpool"`
+	MsgIndex      *MessageIndexConfig  `json:"msgIndex"`
 	Net           string               `json:"net"`
 	Observability *ObservabilityConfig `json:"observability"`
 	SectorBase    *SectorBaseConfig    `json:"sectorbase"`
 	Swarm         *SwarmConfig         `json:"swarm"`
+	Sync          *SyncConfig          `json:"sync"`
 	Wallet        *WalletConfig        `json:"wallet"`
 }
 
@@ -64,7 +68,9 @@ type DatastoreConfig struct {
 // the given key and value are valid. Validators will only be run if a property
 // being set matches the name given in this map.
 var Validators = map[string]func(string, string) error{
-	"heartbeat.nickname": validateLettersOnly,
+	"heartbeat.nickname":    validateLettersOnly,
+	"consensus.protocol":    validateConsensusProtocol,
+	"wallet.remoteBackends": validateRemoteBackends,
 }
 
 func newDefaultDatastoreConfig() *DatastoreConfig {
@@ -102,29 +108,55 @@ func newDefaultBootstrapConfig() *BootstrapConfig {
 	}
 }
 
+// ConsensusConfig holds all configuration options related to consensus.
+type ConsensusConfig struct {
+	// Protocol is the consensus protocol the node runs, either "expected"
+	// or "authority".  All nodes of a network must run the same protocol.
+	Protocol string `json:"protocol"`
+}
+
+func newDefaultConsensusConfig() *ConsensusConfig {
+	return &ConsensusConfig{
+		Protocol: "expected",
+	}
+}
+
 // MiningConfig holds all configuration options related to mining.
 type MiningConfig struct {
-	MinerAddress            address.Address `json:"minerAddress"`
-	AutoSealIntervalSeconds uint            `json:"autoSealIntervalSeconds"`
-	StoragePrice            types.AttoFIL   `json:"storagePrice"`
+	MinerAddress address.Address `json:"minerAddress"`
+	// AdditionalMinerAddresses lists further miners the node mines for,
+	// alongside MinerAddress.
+	AdditionalMinerAddresses []address.Address `json:"additionalMinerAddresses"`
+	AutoSealIntervalSeconds  uint              `json:"autoSealIntervalSeconds"`
+	StoragePrice             types.AttoFIL     `json:"storagePrice"`
+	// ReportConsensusFaults makes the node report the consensus faults it
+	// observes to the storage market, from its default address, which is
+	// paid a share of the slashed collateral.
+	ReportConsensusFaults bool `json:"reportConsensusFaults"`
 }
 
 func newDefaultMiningConfig() *MiningConfig {
 	return &MiningConfig{
-		MinerAddress:            address.Undef,
-		AutoSealIntervalSeconds: 120,
-		StoragePrice:            types.ZeroAttoFIL,
+		MinerAddress:             address.Undef,
+		AdditionalMinerAddresses: []address.Address{},
+		AutoSealIntervalSeconds:  120,
+		StoragePrice:             types.ZeroAttoFIL,
+		ReportConsensusFaults:    false,
 	}
 }
 
 // WalletConfig holds all configuration options related to the wallet.
 type WalletConfig struct {
 	DefaultAddress address.Address `json:"defaultAddress,omitempty"`
+	// RemoteBackends are the endpoints of external signers holding keys of the wallet,
+	// unix:///path/to/socket or http(s) URLs, with the signer's token, if any, as their user.
+	RemoteBackends []string `json:"remoteBackends"`
 }
 
 func newDefaultWalletConfig() *WalletConfig {
 	return &WalletConfig{
 		DefaultAddress: address.Undef,
+		RemoteBackends: []string{},
 	}
 }
 
@@ -207,12 +239,54 @@ type MessagePoolConfig struct {
 	MaxPoolSize uint `json:"maxPoolSize"`
 	// MaxNonceGap is the maximum nonce of a message past the last received on chain
 	MaxNonceGap types.Uint64 `json:"maxNonceGap"`
+	// ReplaceByFeePercent is the percentage by which the gas price of a message must exceed
+	// that of a pending message with the same sender and nonce to replace it
+	ReplaceByFeePercent uint `json:"replaceByFeePercent"`
+	// MaxPendingPerSender is the maximum number of pending messages from a single sender
+	MaxPendingPerSender uint `json:"maxPendingPerSender"`
+	// MinGasPrice is the lowest gas price of a message the pool will accept
+	MinGasPrice types.AttoFIL `json:"minGasPrice"`
 }
 
 func newDefaultMessagePoolConfig() *MessagePoolConfig {
 	return &MessagePoolConfig{
-		MaxPoolSize: 10000,
-		MaxNonceGap: 100,
+		MaxPoolSize:         10000,
+		MaxNonceGap:         100,
+		ReplaceByFeePercent: 10,
+		MaxPendingPerSender: 100,
+		MinGasPrice:         types.ZeroAttoFIL,
+	}
+}
+
+// MessageIndexConfig holds all configuration options related to the index of
+// on-chain messages by address.
+type MessageIndexConfig struct {
+	// Enabled turns on indexing of the messages each address sends and receives.
+	Enabled bool `json:"enabled"`
+}
+
+func newDefaultMessageIndexConfig() *MessageIndexConfig {
+	return &MessageIndexConfig{
+		Enabled: false,
+	}
+}
+
+// SyncConfig holds all configuration options related to syncing the chain.
+type SyncConfig struct {
+	// Checkpoints are tipsets trusted to be in the chain.  The node rejects
+	// chains that do not pass through them.
+	Checkpoints []*CheckpointConfig `json:"checkpoints"`
+}
+
+// CheckpointConfig is a tipset trusted to be in the chain at a height.
+type CheckpointConfig struct {
+	Height uint64             `json:"height"`
+	TipSet types.SortedCidSet `json:"tipset"`
+}
+
+func newDefaultSyncConfig() *SyncConfig {
+	return &SyncConfig{
+		Checkpoints: []*CheckpointConfig{},
 	}
 }
 
@@ -236,6 +310,7 @@ func NewDefaultConfig() *Config {
 	return &Config{
 		API:           newDefaultAPIConfig(),
 		Bootstrap:     newDefaultBootstrapConfig(),
+		Consensus:     newDefaultConsensusConfig(),
 		Datastore:     newDefaultDatastoreConfig(),
 		Swarm:         newDefaultSwarmConfig(),
 		Mining:        newDefaultMiningConfig(),
@@ -243,7 +318,9 @@ func NewDefaultConfig() *Config {
 		Heartbeat:     newDefaultHeartbeatConfig(),
 		Net:           "",
 		Mpool:         newDefaultMessagePoolConfig(),
+		MsgIndex:      newDefaultMessageIndexConfig(),
 		SectorBase:    newDefaultSectorbaseConfig(),
+		Sync:          newDefaultSyncConfig(),
 		Observability: newDefaultObservabilityConfig(),
 	}
 }
@@ -377,3 +454,24 @@ func validateLettersOnly(key string, value string) error {
 	}
 	return nil
 }
+
+func validateConsensusProtocol(key string, value string) error {
+	if value != `"expected"` && value != `"authority"` {
+		return errors.Errorf(`"%s" must be either "expected" or "authority"`, key)
+	}
+	return nil
+}
+
+func validateRemoteBackends(key string, value string) error {
+	var endpoints []string
+	if err := json.Unmarshal([]byte(value), &endpoints); err != nil {
+		return errors.Errorf(`"%s" must be a list of endpoints`, key)
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "unix" && u.Scheme != "http" && u.Scheme != "https") {
+			return errors.Errorf(`"%s" endpoint %q must be a unix://, http:// or https:// URL`, key, endpoint)
+		}
+	}
+	return nil
+}
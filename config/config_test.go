@@ -62,6 +62,9 @@ func TestWriteFile(t *testing.T) {
 		"minPeerThreshold": 0,
 		"period": "1m"
 	},
+	"consensus": {
+		"protocol": "expected"
+	},
 	"datastore": {
 		"type": "badgerds",
 		"path": "badger"
@@ -74,12 +77,20 @@ func TestWriteFile(t *testing.T) {
 	},
 	"mining": {
 		"minerAddress": "empty",
+		"additionalMinerAddresses": [],
 		"autoSealIntervalSeconds": 120,
-		"storagePrice": "0"
+		"storagePrice": "0",
+		"reportConsensusFaults": false
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": "100"
+		"maxNonceGap": "100",
+		"replaceByFeePercent": 10,
+		"maxPendingPerSender": 100,
+		"minGasPrice": "0"
+	},
+	"msgIndex": {
+		"enabled": false
 	},
 	"net": "",
 	"observability": {
@@ -100,8 +111,12 @@ func TestWriteFile(t *testing.T) {
 	"swarm": {
 		"address": "/ip4/0.0.0.0/tcp/6000"
 	},
+	"sync": {
+		"checkpoints": []
+	},
 	"wallet": {
-		"defaultAddress": "empty"
+		"defaultAddress": "empty",
+		"remoteBackends": []
 	}
 }`,
 		string(content),
@@ -124,6 +139,27 @@ func TestSetRejectsInvalidNicks(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSetRejectsUnknownConsensusProtocols(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("consensus.protocol", `"authority"`))
+	assert.Equal(t, "authority", cfg.Consensus.Protocol)
+	assert.Error(t, cfg.Set("consensus.protocol", `"stake"`))
+}
+
+func TestSetRejectsInvalidRemoteBackends(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("wallet.remoteBackends", `["unix:///tmp/signer.sock", "http://127.0.0.1:5678"]`))
+	assert.Equal(t, []string{"unix:///tmp/signer.sock", "http://127.0.0.1:5678"}, cfg.Wallet.RemoteBackends)
+	assert.Error(t, cfg.Set("wallet.remoteBackends", `["/tmp/signer.sock"]`))
+	assert.Error(t, cfg.Set("wallet.remoteBackends", `"unix:///tmp/signer.sock"`))
+}
+
 func TestConfigRoundtrip(t *testing.T) {
 	tf.UnitTest(t)
 
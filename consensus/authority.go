@@ -0,0 +1,217 @@
+package consensus
+
+// This implements a round robin proof-of-authority protocol for local
+// devnets and integration tests, where block production must be
+// deterministic.
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/metrics/tracing"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+const (
+	// ExpectedProtocol is the name of the expected consensus protocol.
+	ExpectedProtocol = "expected"
+	// AuthorityProtocol is the name of the proof-of-authority protocol.
+	AuthorityProtocol = "authority"
+)
+
+// ErrWrongSigner is returned when a block is mined by a signer out of turn.
+var ErrWrongSigner = errors.New("block mined by a signer out of turn")
+
+// Authority implements a round robin proof-of-authority consensus over a
+// fixed set of signers.  The signer at index h % len(signers) is the only one
+// allowed to mine a block at height h, so there is no election randomness and
+// every tipset holds a single block.  If the scheduled signer is offline the
+// round is null.
+type Authority struct {
+	// validator provides a set of methods used to validate a block.
+	BlockValidator
+
+	// cstore is used for loading state trees during message running.
+	cstore *hamt.CborIpldStore
+
+	// bstore contains data referenced by actors within the state
+	// during message running.
+	bstore blockstore.Blockstore
+
+	// processor is what we use to process messages and pay rewards
+	processor Processor
+
+	genesisCid cid.Cid
+
+	// signers is the ordered set of addresses allowed to mine.
+	signers []address.Address
+
+	blockTime time.Duration
+}
+
+// Ensure Authority satisfies the Protocol interface at compile time.
+var _ Protocol = (*Authority)(nil)
+
+// NewAuthority is the constructor for the Authority consensus.Protocol module.
+func NewAuthority(cs *hamt.CborIpldStore, bs blockstore.Blockstore, processor Processor, v BlockValidator, gCid cid.Cid, signers []address.Address, bt time.Duration) *Authority {
+	return &Authority{
+		cstore:         cs,
+		blockTime:      bt,
+		bstore:         bs,
+		processor:      processor,
+		genesisCid:     gCid,
+		signers:        signers,
+		BlockValidator: v,
+	}
+}
+
+// GenesisSigners returns the addresses of the miners created in the genesis
+// state, sorted by address.  They are the signer set of the Authority
+// protocol.
+func GenesisSigners(ctx context.Context, cst *hamt.CborIpldStore, genesis *types.Block) ([]address.Address, error) {
+	actors, err := state.GetAllActorsFromStore(ctx, cst, genesis.StateRoot)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load genesis state")
+	}
+
+	var signers []address.Address
+	for res := range actors {
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if !res.Actor.Code.Equals(types.MinerActorCodeCid) && !res.Actor.Code.Equals(types.BootstrapMinerActorCodeCid) {
+			continue
+		}
+		addr, err := address.NewFromString(res.Address)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, addr)
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("genesis state has no miners to act as signers")
+	}
+
+	sort.Slice(signers, func(i, j int) bool {
+		return signers[i].String() < signers[j].String()
+	})
+	return signers, nil
+}
+
+// BlockTime returns the block time used by the consensus protocol.
+func (a *Authority) BlockTime() time.Duration {
+	return a.blockTime
+}
+
+// Signers returns the ordered signer set.
+func (a *Authority) Signers() []address.Address {
+	return a.signers
+}
+
+// Signer returns the address scheduled to mine the block at height h.
+func (a *Authority) Signer(h uint64) address.Address {
+	return a.signers[h%uint64(len(a.signers))]
+}
+
+// Weight returns the weight of this TipSet in uint64 encoded fixed point
+// representation.  Each block adds one to the weight of its parent, so the
+// heaviest chain is the one with the fewest null rounds.
+func (a *Authority) Weight(ctx context.Context, ts types.TipSet, pSt state.Tree) (uint64, error) {
+	if ts.Len() == 1 && ts.At(0).Cid().Equals(a.genesisCid) {
+		return uint64(0), nil
+	}
+	parentW, err := ts.ParentWeight()
+	if err != nil {
+		return uint64(0), err
+	}
+
+	w, err := types.FixedToBig(parentW)
+	if err != nil {
+		return uint64(0), err
+	}
+	w.Add(w, new(big.Float).SetInt64(int64(ts.Len())))
+	return types.BigToFixed(w)
+}
+
+// IsHeavier returns true if tipset a is heavier than tipset b, and false
+// vice versa.  Ties are broken by comparing the concatenation of block cids
+// in the tipset.
+func (a *Authority) IsHeavier(ctx context.Context, tsA, tsB types.TipSet, aSt, bSt state.Tree) (bool, error) {
+	aW, err := a.Weight(ctx, tsA, aSt)
+	if err != nil {
+		return false, err
+	}
+	bW, err := a.Weight(ctx, tsB, bSt)
+	if err != nil {
+		return false, err
+	}
+	if aW != bW {
+		return aW > bW, nil
+	}
+
+	cmp := strings.Compare(tsA.String(), tsB.String())
+	if cmp == 0 {
+		// Caller is mistakenly calling on two identical tipsets.
+		return false, ErrUnorderedTipSets
+	}
+	return cmp == 1, nil
+}
+
+// RunStateTransition is the chain transition function that goes from a
+// starting state and a tipset to a new state.  It errors if the tipset was not
+// mined and signed by the scheduled signer, or if running the messages in the
+// tipset results in an error.
+func (a *Authority) RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (st state.Tree, err error) {
+	ctx, span := trace.StartSpan(ctx, "Authority.RunStateTransition")
+	span.AddAttributes(trace.StringAttribute("tipset", ts.String()))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	for i := 0; i < ts.Len(); i++ {
+		if err := a.BlockValidator.ValidateSemantic(ctx, ts.At(i), &ancestors[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.validateSigner(ts); err != nil {
+		return nil, err
+	}
+	if err := validateBlockSignature(ctx, a.bstore, pSt, ts.At(0)); err != nil {
+		return nil, err
+	}
+
+	vms := vm.NewStorageMap(a.bstore)
+	st, err = runMessages(ctx, a.cstore, a.processor, pSt, vms, ts, ancestors)
+	if err != nil {
+		return nil, err
+	}
+	err = vms.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// validateSigner checks that the tipset holds a single block mined by the
+// signer scheduled at its height.
+func (a *Authority) validateSigner(ts types.TipSet) error {
+	if ts.Len() != 1 {
+		return errors.Errorf("tipset %s has %d blocks, authority tipsets have exactly one", ts.String(), ts.Len())
+	}
+	blk := ts.At(0)
+	if expected := a.Signer(uint64(blk.Height)); blk.Miner != expected {
+		return errors.Wrapf(ErrWrongSigner, "block at height %d mined by %s, expected %s", blk.Height, blk.Miner, expected)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package consensus_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestAuthority(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, bstore, _ := setupCborBlockstoreProofs()
+	addrGetter := address.NewForTestGetter()
+	owner := addrGetter()
+	minerA, minerB := addrGetter(), addrGetter()
+
+	genesis, err := consensus.MakeGenesisFunc(
+		consensus.ActorAccount(owner, types.NewAttoFILFromFIL(10000)),
+		consensus.MinerActor(minerB, owner, []byte{}, th.RequireRandomPeerID(t), types.ZeroAttoFIL, types.OneKiBSectorSize),
+		consensus.MinerActor(minerA, owner, []byte{}, th.RequireRandomPeerID(t), types.ZeroAttoFIL, types.OneKiBSectorSize),
+	)(cst, bstore)
+	require.NoError(t, err)
+
+	signers, err := consensus.GenesisSigners(ctx, cst, genesis)
+	require.NoError(t, err)
+	require.Len(t, signers, 2)
+	assert.True(t, signers[0].String() < signers[1].String())
+
+	auth := consensus.NewAuthority(cst, bstore, consensus.NewDefaultProcessor(), th.NewFakeBlockValidator(), genesis.Cid(), signers, th.BlockTimeTest)
+	genTS := th.RequireNewTipSet(t, genesis)
+
+	t.Run("schedules signers round robin", func(t *testing.T) {
+		assert.Equal(t, signers[0], auth.Signer(0))
+		assert.Equal(t, signers[1], auth.Signer(1))
+		assert.Equal(t, signers[0], auth.Signer(2))
+	})
+
+	t.Run("weight counts blocks", func(t *testing.T) {
+		w, err := auth.Weight(ctx, genTS, nil)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), w)
+
+		child := th.RequireNewTipSet(t, &types.Block{
+			Parents:      genTS.ToSortedCidSet(),
+			ParentWeight: types.Uint64(w),
+			Height:       1,
+			Miner:        auth.Signer(1),
+		})
+		w, err = auth.Weight(ctx, child, nil)
+		require.NoError(t, err)
+		one, err := types.BigToFixed(big.NewFloat(1))
+		require.NoError(t, err)
+		assert.Equal(t, one, w)
+	})
+
+	t.Run("rejects blocks mined out of turn", func(t *testing.T) {
+		st, err := state.LoadStateTree(ctx, cst, genesis.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+
+		outOfTurn := th.RequireNewTipSet(t, &types.Block{
+			Parents: genTS.ToSortedCidSet(),
+			Height:  1,
+			Miner:   auth.Signer(0),
+		})
+		_, err = auth.RunStateTransition(ctx, outOfTurn, []types.TipSet{genTS}, st)
+		assert.Equal(t, consensus.ErrWrongSigner, errors.Cause(err))
+	})
+}
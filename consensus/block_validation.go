@@ -84,7 +84,11 @@ func (dv *DefaultBlockValidator) ValidateSyntax(ctx context.Context, blk *types.
 	if len(blk.Ticket) == 0 {
 		return fmt.Errorf("block %s has nil ticket", blk.Cid().String())
 	}
-	// TODO validate block signature: 1054
+	// The signature itself is checked against the miner's worker key when
+	// the block is validated against its parent state.
+	if len(blk.BlockSig) == 0 {
+		return fmt.Errorf("block %s has nil signature", blk.Cid().String())
+	}
 	return nil
 }
 
@@ -96,12 +96,14 @@ func TestBlockValidSyntax(t *testing.T) {
 	validSt := types.NewCidForTestGetter()()
 	validAd := address.NewForTestGetter()()
 	validTi := []byte{1}
+	validSig := []byte{2}
 	// create a valid block
 	blk := &types.Block{
 		Timestamp: validTs,
 		StateRoot: validSt,
 		Miner:     validAd,
 		Ticket:    validTi,
+		BlockSig:  validSig,
 	}
 	require.NoError(t, validator.ValidateSyntax(ctx, blk))
 
@@ -132,4 +134,10 @@ func TestBlockValidSyntax(t *testing.T) {
 	blk.Ticket = validTi
 	require.NoError(t, validator.ValidateSyntax(ctx, blk))
 
+	// invalidate signature
+	blk.BlockSig = nil
+	require.Error(t, validator.ValidateSyntax(ctx, blk))
+	blk.BlockSig = validSig
+	require.NoError(t, validator.ValidateSyntax(ctx, blk))
+
 }
@@ -0,0 +1,66 @@
+package consensus
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+// AggregateBLSMessages prepares messages for inclusion in a block. It returns the
+// messages with those from BLS addresses stripped of their signatures, in the same
+// order, along with the aggregate of the stripped signatures to be set as the block's
+// BLSAggregateSig. The aggregate is empty if there are no messages from BLS addresses.
+func AggregateBLSMessages(msgs []*types.SignedMessage) ([]*types.SignedMessage, types.Signature, error) {
+	var out []*types.SignedMessage
+	var sigs [][]byte
+	for _, msg := range msgs {
+		if !msg.IsFromBLS() {
+			out = append(out, msg)
+			continue
+		}
+		sigs = append(sigs, msg.Signature)
+		out = append(out, msg.WithoutSignature())
+	}
+	if len(sigs) == 0 {
+		return out, nil, nil
+	}
+
+	agg, err := wutil.AggregateBLS(sigs)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to aggregate message signatures")
+	}
+	return out, agg, nil
+}
+
+// VerifyBLSAggregate checks that the messages of a block from BLS addresses carry no
+// signatures, and that aggregate is the aggregate of their signatures. The aggregate
+// must be empty if there are no messages from BLS addresses.
+func VerifyBLSAggregate(msgs []*types.SignedMessage, aggregate types.Signature) error {
+	var pks, data [][]byte
+	for _, msg := range msgs {
+		if !msg.IsFromBLS() {
+			continue
+		}
+		if len(msg.Signature) > 0 {
+			return errors.Errorf("message from BLS address %s is signed", msg.From)
+		}
+		bmsg, err := msg.MeteredMessage.Marshal()
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal message")
+		}
+		pks = append(pks, msg.From.Payload())
+		data = append(data, bmsg)
+	}
+
+	if len(pks) == 0 {
+		if len(aggregate) > 0 {
+			return errors.New("aggregate signature without messages from BLS addresses")
+		}
+		return nil
+	}
+	if !wutil.VerifyBLSAggregate(pks, data, aggregate) {
+		return errors.New("invalid aggregate signature of messages from BLS addresses")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package consensus_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestBLSAggregate(t *testing.T) {
+	tf.UnitTest(t)
+
+	blsSigner := types.NewMockSigner(types.MustGenerateBLSKeyInfo(2))
+	to := address.NewForTestGetter()()
+
+	signedFrom := func(signer types.MockSigner, from address.Address, nonce uint64) *types.SignedMessage {
+		msg := types.NewMessage(from, to, nonce, types.NewAttoFILFromFIL(1), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, signer, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	t.Run("aggregates the signatures of messages from BLS addresses", func(t *testing.T) {
+		secpMsg := signedFrom(signer, addresses[0], 0)
+		msgs := []*types.SignedMessage{
+			signedFrom(blsSigner, blsSigner.Addresses[0], 0),
+			secpMsg,
+			signedFrom(blsSigner, blsSigner.Addresses[1], 0),
+			signedFrom(blsSigner, blsSigner.Addresses[0], 1),
+		}
+
+		blockMsgs, agg, err := consensus.AggregateBLSMessages(msgs)
+		require.NoError(t, err)
+		require.Len(t, blockMsgs, len(msgs))
+		assert.NotEmpty(t, agg)
+
+		for i, msg := range blockMsgs {
+			assert.True(t, msg.Equals(msgs[i]))
+			if msg.IsFromBLS() {
+				assert.Empty(t, msg.Signature)
+			}
+		}
+		assert.Equal(t, secpMsg.Signature, blockMsgs[1].Signature)
+
+		assert.NoError(t, consensus.VerifyBLSAggregate(blockMsgs, agg))
+	})
+
+	t.Run("no aggregate without messages from BLS addresses", func(t *testing.T) {
+		msgs := []*types.SignedMessage{signedFrom(signer, addresses[0], 0)}
+
+		blockMsgs, agg, err := consensus.AggregateBLSMessages(msgs)
+		require.NoError(t, err)
+		assert.Empty(t, agg)
+		assert.NoError(t, consensus.VerifyBLSAggregate(blockMsgs, agg))
+
+		_, blsAgg, err := consensus.AggregateBLSMessages([]*types.SignedMessage{signedFrom(blsSigner, blsSigner.Addresses[0], 0)})
+		require.NoError(t, err)
+		assert.Error(t, consensus.VerifyBLSAggregate(blockMsgs, blsAgg))
+	})
+
+	t.Run("rejects an aggregate not matching the messages", func(t *testing.T) {
+		msgs := []*types.SignedMessage{
+			signedFrom(blsSigner, blsSigner.Addresses[0], 0),
+			signedFrom(blsSigner, blsSigner.Addresses[1], 0),
+		}
+		blockMsgs, agg, err := consensus.AggregateBLSMessages(msgs)
+		require.NoError(t, err)
+
+		// a message left out of the aggregate
+		assert.Error(t, consensus.VerifyBLSAggregate(blockMsgs, msgs[0].Signature))
+		assert.Error(t, consensus.VerifyBLSAggregate(blockMsgs, nil))
+
+		// a message altered after aggregation
+		blockMsgs[1].Nonce = 1
+		assert.Error(t, consensus.VerifyBLSAggregate(blockMsgs, agg))
+	})
+
+	t.Run("rejects signed messages from BLS addresses", func(t *testing.T) {
+		msg := signedFrom(blsSigner, blsSigner.Addresses[0], 0)
+		_, agg, err := consensus.AggregateBLSMessages([]*types.SignedMessage{msg})
+		require.NoError(t, err)
+
+		assert.Error(t, consensus.VerifyBLSAggregate([]*types.SignedMessage{msg}, agg))
+	})
+}
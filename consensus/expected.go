@@ -20,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/address"
@@ -50,6 +51,12 @@ var (
 	ErrInvalidBase = errors.New("block does not connect to a known good chain")
 	// ErrUnorderedTipSets is returned when weight and minticket are the same between two tipsets.
 	ErrUnorderedTipSets = errors.New("trying to order two identical tipsets")
+	// ErrInvalidBlockSignature is returned when a block is not signed by its miner's worker key.
+	ErrInvalidBlockSignature = errors.New("block signature does not match the miner's worker key")
+	// ErrInvalidElectionProof is returned when a block's proof is not a valid PoSt over its miner's sectors.
+	ErrInvalidElectionProof = errors.New("block proof is not a valid PoSt over the miner's sectors")
+	// ErrNoElectionSectors is returned when a block is mined by a miner that has no sectors to prove.
+	ErrNoElectionSectors = errors.New("block miner has no committed sectors to prove")
 )
 
 // TicketSigner is an interface for a test signer that can create tickets.
@@ -249,7 +256,7 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ance
 	}
 
 	vms := vm.NewStorageMap(c.bstore)
-	st, err = c.runMessages(ctx, pSt, vms, ts, ancestors)
+	st, err = runMessages(ctx, c.cstore, c.processor, pSt, vms, ts, ancestors)
 	if err != nil {
 		return nil, err
 	}
@@ -260,22 +267,27 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ance
 	return st, nil
 }
 
-// validateMining checks validity of the block ticket, proof, and miner address.
+// validateMining checks validity of the block ticket, proof, miner address and
+// signature.
 //    Returns an error if:
 //    	* any tipset's block was mined by an invalid miner address.
-//      * the block proof is invalid for the challenge
+//      * the block proof is not a valid PoSt over the miner's sectors for the challenge
 //      * the block ticket fails the power check, i.e. is not a winning ticket
+//      * the block is not signed by the miner's worker key
 //    Returns nil if all the above checks pass.
 // See https://github.com/filecoin-project/specs/blob/master/mining.md#chain-validation
 func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.TipSet, parentTs types.TipSet) error {
 	for i := 0; i < ts.Len(); i++ {
 		blk := ts.At(i)
-		// TODO: Also need to validate BlockSig
 
 		// TODO: Once we've picked a delay function (see #2119), we need to
 		// verify its proof here. The proof will likely be written to a field on
 		// the mined block.
 
+		if err := c.validateElectionProof(ctx, st, blk, parentTs); err != nil {
+			return err
+		}
+
 		// See https://github.com/filecoin-project/specs/blob/master/mining.md#ticket-checking
 		result, err := IsWinningTicket(ctx, c.bstore, c.PwrTableView, st, blk.Ticket, blk.Miner)
 		if err != nil {
@@ -285,6 +297,135 @@ func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.T
 		if !result {
 			return errors.New("not a winning ticket")
 		}
+
+		if err := validateBlockSignature(ctx, c.bstore, st, blk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateElectionProof checks that the block proof is a PoSt over the
+// sectors its miner has committed in state st, for the challenge drawn from
+// the parent tipset.  Bootstrap miners have no real replicas, so as with
+// their submitPoSt messages their election proofs are not verified.  Other
+// miners must have committed sectors.
+func (c *Expected) validateElectionProof(ctx context.Context, st state.Tree, blk *types.Block, parentTs types.TipSet) error {
+	commRs, bootstrap, err := MinerElectionSectors(ctx, c.bstore, st, blk.Miner)
+	if err != nil {
+		return errors.Wrap(err, "can't get miner sectors")
+	}
+	if bootstrap {
+		return nil
+	}
+	// A PoSt over no sectors proves nothing.
+	if len(commRs.Values()) == 0 {
+		return ErrNoElectionSectors
+	}
+
+	parentHeight, err := parentTs.Height()
+	if err != nil {
+		return err
+	}
+	if uint64(blk.Height) <= parentHeight {
+		return errors.Errorf("block height %d is not above parent height %d", blk.Height, parentHeight)
+	}
+	challenge, err := CreateChallengeSeed(parentTs, uint64(blk.Height)-parentHeight-1)
+	if err != nil {
+		return errors.Wrap(err, "can't create challenge seed")
+	}
+
+	sectorSize, err := minerSectorSize(ctx, c.bstore, st, blk.Miner)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.verifier.VerifyPoST(proofs.VerifyPoStRequest{
+		ChallengeSeed: challenge,
+		SortedCommRs:  commRs,
+		Faults:        []uint64{},
+		Proofs:        []types.PoStProof{blk.Proof},
+		SectorSize:    sectorSize,
+	})
+	if err != nil {
+		return errors.Wrap(err, "can't verify election proof")
+	}
+	if !res.IsValid {
+		return ErrInvalidElectionProof
+	}
+	return nil
+}
+
+// MinerElectionSectors returns the replica commitments of the sectors miner
+// has committed in state st, over which its election PoSt is generated, and
+// whether it is a bootstrap miner whose sectors are not backed by replicas.
+func MinerElectionSectors(ctx context.Context, bs blockstore.Blockstore, st state.Tree, miner address.Address) (proofs.SortedCommRs, bool, error) {
+	vms := vm.NewStorageMap(bs)
+	rets, ec, err := CallQueryMethod(ctx, st, vms, miner, "isBootstrapMiner", []byte{}, address.Undef, nil)
+	if err != nil {
+		return proofs.SortedCommRs{}, false, err
+	}
+	if ec != 0 {
+		return proofs.SortedCommRs{}, false, errors.Errorf("non-zero return code from isBootstrapMiner query: %d", ec)
+	}
+	bootstrapVal, err := abi.Deserialize(rets[0], abi.Boolean)
+	if err != nil {
+		return proofs.SortedCommRs{}, false, errors.Wrap(err, "deserialization failed")
+	}
+	if bootstrapVal.Val.(bool) {
+		return proofs.SortedCommRs{}, true, nil
+	}
+
+	rets, ec, err = CallQueryMethod(ctx, st, vms, miner, "getSectorCommitments", []byte{}, address.Undef, nil)
+	if err != nil {
+		return proofs.SortedCommRs{}, false, err
+	}
+	if ec != 0 {
+		return proofs.SortedCommRs{}, false, errors.Errorf("non-zero return code from getSectorCommitments query: %d", ec)
+	}
+	commitmentsVal, err := abi.Deserialize(rets[0], abi.CommitmentsMap)
+	if err != nil {
+		return proofs.SortedCommRs{}, false, errors.Wrap(err, "deserialization failed")
+	}
+
+	var commRs []types.CommR
+	for _, comms := range commitmentsVal.Val.(map[string]types.Commitments) {
+		commRs = append(commRs, comms.CommR)
+	}
+	return proofs.NewSortedCommRs(commRs...), false, nil
+}
+
+// minerSectorSize returns the sector size of miner in state st.
+func minerSectorSize(ctx context.Context, bs blockstore.Blockstore, st state.Tree, miner address.Address) (*types.BytesAmount, error) {
+	vms := vm.NewStorageMap(bs)
+	rets, ec, err := CallQueryMethod(ctx, st, vms, miner, "getSectorSize", []byte{}, address.Undef, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get miner sector size")
+	}
+	if ec != 0 {
+		return nil, errors.Errorf("non-zero return code from getSectorSize query: %d", ec)
+	}
+	return types.NewBytesAmountFromBytes(rets[0]), nil
+}
+
+// validateBlockSignature checks that blk is signed by the worker key of its
+// miner, as returned by the miner actor's getKey method in state st.
+func validateBlockSignature(ctx context.Context, bs blockstore.Blockstore, st state.Tree, blk *types.Block) error {
+	vms := vm.NewStorageMap(bs)
+	rets, ec, err := CallQueryMethod(ctx, st, vms, blk.Miner, "getKey", []byte{}, address.Undef, nil)
+	if err != nil {
+		return errors.Wrap(err, "can't get miner worker key")
+	}
+	if ec != 0 {
+		return errors.Errorf("non-zero return code from getKey query: %d", ec)
+	}
+
+	workerAddr, err := types.PublicKeyAddress(rets[0])
+	if err != nil {
+		return errors.Wrap(err, "invalid miner worker key")
+	}
+	if !types.IsValidSignature(blk.SignatureData(), workerAddr, blk.BlockSig) {
+		return ErrInvalidBlockSignature
 	}
 	return nil
 }
@@ -346,7 +487,7 @@ func CreateChallengeSeed(parents types.TipSet, nullBlkCount uint64) (types.PoStC
 // An error is returned if individual blocks contain messages that do not
 // lead to successful state transitions.  An error is also returned if the node
 // faults while running aggregate state computation.
-func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (state.Tree, error) {
+func runMessages(ctx context.Context, cstore *hamt.CborIpldStore, processor Processor, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (state.Tree, error) {
 	var cpySt state.Tree
 
 	// TODO: don't process messages twice
@@ -357,12 +498,12 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 			return nil, errors.Wrap(err, "error validating block state")
 		}
 		// state copied so changes don't propagate between block validations
-		cpySt, err = state.LoadStateTree(ctx, c.cstore, cpyCid, builtin.Actors)
+		cpySt, err = state.LoadStateTree(ctx, cstore, cpyCid, builtin.Actors)
 		if err != nil {
 			return nil, errors.Wrap(err, "error validating block state")
 		}
 
-		receipts, err := c.processor.ProcessBlock(ctx, cpySt, vms, blk, ancestors)
+		receipts, err := processor.ProcessBlock(ctx, cpySt, vms, blk, ancestors)
 		if err != nil {
 			return nil, errors.Wrap(err, "error validating block state")
 		}
@@ -386,7 +527,7 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 	// NOTE: It is possible to optimize further by applying block validation
 	// in sorted order to reuse first block transitions as the starting state
 	// for the tipSetProcessor.
-	_, err := c.processor.ProcessTipSet(ctx, st, vms, ts, ancestors)
+	_, err := processor.ProcessTipSet(ctx, st, vms, ts, ancestors)
 	if err != nil {
 		return nil, errors.Wrap(err, "error validating tipset")
 	}
@@ -410,3 +551,19 @@ func CreateTicket(proof types.PoStProof, signerPubKey []byte, signer TicketSigne
 	// Don't hash it here; it gets hashed in walletutil.Sign
 	return signer.SignBytes(buf[:], signerAddr)
 }
+
+// SignBlock signs the block with the key of signerPubKey, which must be the
+// worker key of the block's miner, and sets its BlockSig.  All other fields
+// of the block must be final.
+func SignBlock(blk *types.Block, signerPubKey []byte, signer TicketSigner) error {
+	signerAddr, err := signer.GetAddressForPubKey(signerPubKey)
+	if err != nil {
+		return errors.Wrap(err, "could not get address for signerPubKey")
+	}
+	sig, err := signer.SignBytes(blk.SignatureData(), signerAddr)
+	if err != nil {
+		return errors.Wrap(err, "could not sign block")
+	}
+	blk.BlockSig = sig
+	return nil
+}
@@ -1,12 +1,15 @@
 package consensus_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"fmt"
 	"testing"
 
+	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/proofs"
@@ -40,7 +43,14 @@ func TestNewExpected(t *testing.T) {
 
 // requireMakeBlocks sets up 3 blocks with 3 owner actors and 3 miner actors and puts them in the state tree.
 // the owner actors have associated mockSigners for signing blocks (not implemented yet) and tickets.
+// Each miner has committed one sector.
 func requireMakeBlocks(ctx context.Context, t *testing.T, pTipSet types.TipSet, tree state.Tree, vms vm.StorageMap) []*types.Block {
+	return requireMakeBlocksWithSectors(ctx, t, pTipSet, tree, vms, true)
+}
+
+// requireMakeBlocksWithSectors is requireMakeBlocks, with miners that have
+// committed no sectors unless withSectors is set.
+func requireMakeBlocksWithSectors(ctx context.Context, t *testing.T, pTipSet types.TipSet, tree state.Tree, vms vm.StorageMap, withSectors bool) []*types.Block {
 	// make  a set of owner keypairs so they can sign blocks
 	mockSigner, kis := types.NewMockSignersAndKeyInfo(3)
 
@@ -59,17 +69,23 @@ func requireMakeBlocks(ctx context.Context, t *testing.T, pTipSet types.TipSet,
 
 		minerAddrs[i], err = address.NewActorAddress([]byte(fmt.Sprintf("%s%s", name, "Miner")))
 		require.NoError(t, err)
-		minerActor := th.RequireNewMinerActor(t, vms, minerAddrs[i], addr,
-			ownerPubKeys[i], 10000, th.RequireRandomPeerID(t), types.ZeroAttoFIL)
+		minerActor := actor.NewActor(types.MinerActorCodeCid, types.ZeroAttoFIL)
+		minerState := miner.NewState(addr, ownerPubKeys[i], th.RequireRandomPeerID(t), types.OneKiBSectorSize)
+		if withSectors {
+			minerState.SectorCommitments["0"] = types.Commitments{CommR: types.CommR{byte(i + 1)}}
+		}
+		storage := vms.NewStorage(minerAddrs[i], minerActor)
+		require.NoError(t, (&miner.Actor{}).InitializeState(storage, minerState))
+		require.NoError(t, storage.Flush())
 		require.NoError(t, tree.SetActor(ctx, minerAddrs[i], minerActor))
 	}
 	stateRoot, err := tree.Flush(ctx)
 	require.NoError(t, err)
 
 	blocks := []*types.Block{
-		th.NewValidTestBlockFromTipSet(pTipSet, stateRoot, 1, minerAddrs[0], ownerPubKeys[0], mockSigner),
-		th.NewValidTestBlockFromTipSet(pTipSet, stateRoot, 1, minerAddrs[1], ownerPubKeys[1], mockSigner),
-		th.NewValidTestBlockFromTipSet(pTipSet, stateRoot, 1, minerAddrs[2], ownerPubKeys[2], mockSigner),
+		th.RequireNewValidTestBlockFromTipSet(t, pTipSet, stateRoot, 1, minerAddrs[0], ownerPubKeys[0], mockSigner),
+		th.RequireNewValidTestBlockFromTipSet(t, pTipSet, stateRoot, 1, minerAddrs[1], ownerPubKeys[1], mockSigner),
+		th.RequireNewValidTestBlockFromTipSet(t, pTipSet, stateRoot, 1, minerAddrs[2], ownerPubKeys[2], mockSigner),
 	}
 	return blocks
 }
@@ -109,6 +125,61 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("returns an error when a block is not signed by its miner's worker key", func(t *testing.T) {
+		ptv := th.NewTestPowerTableView(types.NewBytesAmount(1), types.NewBytesAmount(1))
+		exp := consensus.NewExpected(cistore, bstore, th.NewTestProcessor(), th.NewFakeBlockValidator(), ptv, genesisBlock.Cid(), verifier, th.BlockTimeTest)
+
+		pTipSet := types.RequireNewTipSet(t, genesisBlock)
+
+		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+		vms := vm.NewStorageMap(bstore)
+
+		blocks := requireMakeBlocks(ctx, t, pTipSet, stateTree, vms)
+		// Sign the first miner's block with the second miner's key.
+		otherSigner, otherKis := types.NewMockSignersAndKeyInfo(2)
+		th.RequireSignBlock(t, blocks[0], otherKis[1].PublicKey(), otherSigner)
+
+		tipSet := types.RequireNewTipSet(t, blocks...)
+
+		_, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		assert.Equal(t, consensus.ErrInvalidBlockSignature, errors.Cause(err))
+	})
+
+	t.Run("returns an error when a block proof is not a valid PoSt over its miner's sectors", func(t *testing.T) {
+		ptv := th.NewTestPowerTableView(types.NewBytesAmount(1), types.NewBytesAmount(1))
+		exp := consensus.NewExpected(cistore, bstore, th.NewTestProcessor(), th.NewFakeBlockValidator(), ptv, genesisBlock.Cid(), proofs.NewFakeVerifier(false, nil), th.BlockTimeTest)
+
+		pTipSet := types.RequireNewTipSet(t, genesisBlock)
+
+		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+		vms := vm.NewStorageMap(bstore)
+
+		blocks := requireMakeBlocks(ctx, t, pTipSet, stateTree, vms)
+		tipSet := types.RequireNewTipSet(t, blocks...)
+
+		_, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		assert.Equal(t, consensus.ErrInvalidElectionProof, errors.Cause(err))
+	})
+
+	t.Run("returns an error when a block is mined by a miner without sectors", func(t *testing.T) {
+		ptv := th.NewTestPowerTableView(types.NewBytesAmount(1), types.NewBytesAmount(1))
+		exp := consensus.NewExpected(cistore, bstore, th.NewTestProcessor(), th.NewFakeBlockValidator(), ptv, genesisBlock.Cid(), verifier, th.BlockTimeTest)
+
+		pTipSet := types.RequireNewTipSet(t, genesisBlock)
+
+		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+		vms := vm.NewStorageMap(bstore)
+
+		blocks := requireMakeBlocksWithSectors(ctx, t, pTipSet, stateTree, vms, false)
+		tipSet := types.RequireNewTipSet(t, blocks...)
+
+		_, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		assert.Equal(t, consensus.ErrNoElectionSectors, errors.Cause(err))
+	})
+
 	t.Run("returns nil + mining error when IsWinningTicket fails due to miner power error", func(t *testing.T) {
 
 		ptv := NewFailingMinerTestPowerTableView(types.NewBytesAmount(1), types.NewBytesAmount(5))
@@ -264,6 +335,92 @@ func TestCreateChallenge(t *testing.T) {
 	}
 }
 
+// sectorVerifier accepts only proof as a PoSt over sectors including commR.
+type sectorVerifier struct {
+	proof types.PoStProof
+	commR types.CommR
+}
+
+func (v sectorVerifier) VerifyPoST(req proofs.VerifyPoStRequest) (proofs.VerifyPoSTResponse, error) {
+	proven := false
+	for _, commR := range req.SortedCommRs.Values() {
+		proven = proven || commR == v.commR
+	}
+	valid := proven && len(req.Proofs) == 1 && bytes.Equal(req.Proofs[0][:], v.proof[:])
+	return proofs.VerifyPoSTResponse{IsValid: valid}, nil
+}
+
+func (sectorVerifier) VerifySeal(proofs.VerifySealRequest) (proofs.VerifySealResponse, error) {
+	panic("boom")
+}
+
+// Miners set up in the genesis block are not bootstrap miners unless a test
+// opts in, so their election proofs are verified.
+func TestExpected_GenesisMinerElectionProof(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mockSigner, kis := types.NewMockSignersAndKeyInfo(1)
+	owner, err := kis[0].Address()
+	require.NoError(t, err)
+	minerPubKey := kis[0].PublicKey()
+	minerAddr := address.NewForTestGetter()()
+	peerID := th.RequireRandomPeerID(t)
+	commR := types.CommR{42}
+	validProof := types.PoStProof(th.MakeRandomPoStProofForTest())
+
+	// mine has the genesis miner mine a block with the proof on top of the
+	// genesis block made with the options, and runs the state transition.
+	mine := func(t *testing.T, proof types.PoStProof, opts ...consensus.GenOption) error {
+		cistore, bstore, _ := setupCborBlockstoreProofs()
+		genesisBlock, err := consensus.MakeGenesisFunc(opts...)(cistore, bstore)
+		require.NoError(t, err)
+
+		ptv := th.NewTestPowerTableView(types.NewBytesAmount(1), types.NewBytesAmount(1))
+		verifier := sectorVerifier{proof: validProof, commR: commR}
+		exp := consensus.NewExpected(cistore, bstore, th.NewTestProcessor(), th.NewFakeBlockValidator(), ptv, genesisBlock.Cid(), verifier, th.BlockTimeTest)
+
+		pTipSet := types.RequireNewTipSet(t, genesisBlock)
+		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
+		require.NoError(t, err)
+
+		blk := th.RequireNewValidTestBlockFromTipSet(t, pTipSet, genesisBlock.StateRoot, 1, minerAddr, minerPubKey, mockSigner)
+		blk.Proof = proof
+		th.RequireSignBlock(t, blk, minerPubKey, mockSigner)
+
+		_, err = exp.RunStateTransition(ctx, types.RequireNewTipSet(t, blk), []types.TipSet{pTipSet}, stateTree)
+		return err
+	}
+
+	minerActor := consensus.MinerActor(minerAddr, owner, minerPubKey, peerID, types.ZeroAttoFIL, types.OneKiBSectorSize)
+	minerSector := consensus.MinerSector(minerAddr, 0, types.Commitments{CommR: commR})
+
+	t.Run("accepts a valid PoSt over the miner's sectors", func(t *testing.T) {
+		assert.NoError(t, mine(t, validProof, minerActor, minerSector))
+	})
+
+	t.Run("rejects a bad PoSt", func(t *testing.T) {
+		badProof := types.PoStProof(th.MakeRandomPoStProofForTest())
+		err := mine(t, badProof, minerActor, minerSector)
+		assert.Equal(t, consensus.ErrInvalidElectionProof, errors.Cause(err))
+	})
+
+	t.Run("rejects a missing PoSt", func(t *testing.T) {
+		err := mine(t, types.PoStProof{}, minerActor, minerSector)
+		assert.Equal(t, consensus.ErrInvalidElectionProof, errors.Cause(err))
+	})
+
+	t.Run("rejects a miner without sectors", func(t *testing.T) {
+		err := mine(t, validProof, minerActor)
+		assert.Equal(t, consensus.ErrNoElectionSectors, errors.Cause(err))
+	})
+
+	t.Run("skips the proofs of bootstrap miners", func(t *testing.T) {
+		bootstrapMiner := consensus.BootstrapMinerActor(minerAddr, owner, minerPubKey, peerID, types.ZeroAttoFIL, types.OneKiBSectorSize)
+		assert.NoError(t, mine(t, types.PoStProof{}, bootstrapMiner))
+	})
+}
+
 func setupCborBlockstoreProofs() (*hamt.CborIpldStore, blockstore.Blockstore, proofs.Verifier) {
 	mds := datastore.NewMapDatastore()
 	bs := blockstore.NewBlockstore(mds)
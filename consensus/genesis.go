@@ -2,9 +2,12 @@ package consensus
 
 import (
 	"context"
+	"strconv"
+
 	"github.com/ipfs/go-hamt-ipld"
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
@@ -35,8 +38,9 @@ func init() {
 }
 
 type minerActorConfig struct {
-	state   *miner.State
-	balance types.AttoFIL
+	state     *miner.State
+	balance   types.AttoFIL
+	bootstrap bool
 }
 
 // Config is used to configure values in the GenesisInitFunction.
@@ -70,6 +74,32 @@ func MinerActor(addr address.Address, owner address.Address, key []byte, pid pee
 	}
 }
 
+// BootstrapMinerActor returns a config option that sets up a bootstrap miner
+// actor account, as gengen creates, whose election proofs are not verified.
+func BootstrapMinerActor(addr address.Address, owner address.Address, key []byte, pid peer.ID, coll types.AttoFIL, sectorSize *types.BytesAmount) GenOption {
+	return func(gc *Config) error {
+		gc.miners[addr] = &minerActorConfig{
+			state:     miner.NewState(owner, key, pid, sectorSize),
+			balance:   coll,
+			bootstrap: true,
+		}
+		return nil
+	}
+}
+
+// MinerSector returns a config option that commits a sector for a miner set
+// up by an earlier option.
+func MinerSector(addr address.Address, sectorID uint64, comms types.Commitments) GenOption {
+	return func(gc *Config) error {
+		m, ok := gc.miners[addr]
+		if !ok {
+			return errors.Errorf("no miner %s to commit a sector for", addr)
+		}
+		m.state.SectorCommitments[strconv.FormatUint(sectorID, 10)] = comms
+		return nil
+	}
+}
+
 // ActorNonce returns a config option that sets the nonce of an existing actor.
 func ActorNonce(addr address.Address, nonce uint64) GenOption {
 	return func(gc *Config) error {
@@ -135,6 +165,9 @@ func MakeGenesisFunc(opts ...GenOption) GenesisInitFunc {
 		// Initialize miner actors
 		for addr, val := range genCfg.miners {
 			a := miner.NewActor()
+			if val.bootstrap {
+				a = actor.NewActor(types.BootstrapMinerActorCodeCid, types.ZeroAttoFIL)
+			}
 			a.Balance = val.balance
 
 			if err := st.SetActor(ctx, addr, a); err != nil {
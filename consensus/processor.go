@@ -63,7 +63,7 @@ var _ Processor = (*DefaultProcessor)(nil)
 // NewDefaultProcessor creates a default processor from the given state tree and vms.
 func NewDefaultProcessor() *DefaultProcessor {
 	return &DefaultProcessor{
-		signedMessageValidator: NewDefaultMessageValidator(),
+		signedMessageValidator: NewBlockMessageValidator(),
 		blockRewarder:          NewDefaultBlockRewarder(),
 	}
 }
@@ -103,6 +103,9 @@ func NewConfiguredProcessor(validator SignedMessageValidator, rewarder BlockRewa
 // will in many cases be successfully applied even though an
 // error was thrown causing any state changes to be rolled back.
 // See comments on ApplyMessage for specific intent.
+//
+// ProcessBlock also verifies the block's aggregate signature of its messages
+// from BLS addresses, which are included without their own signatures.
 func (p *DefaultProcessor) ProcessBlock(ctx context.Context, st state.Tree, vms vm.StorageMap, blk *types.Block, ancestors []types.TipSet) (results []*ApplicationResult, err error) {
 	ctx, span := trace.StartSpan(ctx, "DefaultProcessor.ProcessBlock")
 	span.AddAttributes(trace.StringAttribute("block", blk.Cid().String()))
@@ -113,6 +116,10 @@ func (p *DefaultProcessor) ProcessBlock(ctx context.Context, st state.Tree, vms
 
 	var emptyResults []*ApplicationResult
 
+	if err := VerifyBLSAggregate(blk.Messages, blk.BLSAggregateSig); err != nil {
+		return emptyResults, errors.ApplyErrorPermanentWrapf(err, "invalid block %s", blk.Cid())
+	}
+
 	// find miner's owner address
 	minerOwnerAddr, err := minerOwnerAddress(ctx, st, vms, blk.Miner)
 	if err != nil {
@@ -274,6 +274,60 @@ func TestProcessBlockBadMsgSig(t *testing.T) {
 	assert.EqualError(t, err, "apply message failed: invalid signature by sender over message data")
 }
 
+func TestProcessBlockBLSAggregate(t *testing.T) {
+	tf.UnitTest(t)
+
+	newAddress := address.NewForTestGetter()
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	blsSigner := types.NewMockSigner(types.MustGenerateBLSKeyInfo(1))
+
+	toAddr := newAddress()
+	fromAddr := blsSigner.Addresses[0]
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(100000)),
+		fromAddr:               th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(10000)),
+	})
+
+	vms := th.VMStorage()
+	minerAddr, err := address.NewActorAddress([]byte("miner"))
+	require.NoError(t, err)
+	minerOwner, err := address.NewActorAddress([]byte("mo"))
+	require.NoError(t, err)
+	stCid, _ := mustCreateStorageMiner(ctx, t, st, vms, minerAddr, minerOwner)
+
+	msg := types.NewMessage(fromAddr, toAddr, 0, types.NewAttoFILFromFIL(550), "", nil)
+	smsg, err := types.NewSignedMessage(*msg, blsSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	blockMsgs, agg, err := AggregateBLSMessages([]*types.SignedMessage{smsg})
+	require.NoError(t, err)
+
+	t.Run("invalid aggregate fails", func(t *testing.T) {
+		blk := &types.Block{
+			Height:    20,
+			StateRoot: stCid,
+			Miner:     minerAddr,
+			Messages:  blockMsgs,
+		}
+		results, err := NewDefaultProcessor().ProcessBlock(ctx, st, vms, blk, nil)
+		require.Nil(t, results)
+		assert.Contains(t, err.Error(), "invalid aggregate signature")
+	})
+
+	t.Run("valid aggregate applies unsigned messages", func(t *testing.T) {
+		blk := &types.Block{
+			Height:          20,
+			StateRoot:       stCid,
+			Miner:           minerAddr,
+			Messages:        blockMsgs,
+			BLSAggregateSig: agg,
+		}
+		results, err := NewDefaultProcessor().ProcessBlock(ctx, st, vms, blk, nil)
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+	})
+}
+
 // ProcessBlock should not fail with an unsigned block reward message.
 func TestProcessBlockReward(t *testing.T) {
 	tf.UnitTest(t)
@@ -36,7 +36,8 @@ type SignedMessageValidator interface {
 }
 
 type defaultMessageValidator struct {
-	allowHighNonce bool
+	allowHighNonce      bool
+	allowAggregatedSigs bool
 }
 
 // NewDefaultMessageValidator creates a new default validator.
@@ -53,10 +54,19 @@ func NewOutboundMessageValidator() SignedMessageValidator {
 	return &defaultMessageValidator{allowHighNonce: true}
 }
 
+// NewBlockMessageValidator creates a new default validator for messages included in blocks.
+// This validator matches the default behaviour but accepts unsigned messages from BLS
+// addresses, whose signatures are aggregated into the block's BLSAggregateSig and verified
+// by the processor with the block.
+func NewBlockMessageValidator() SignedMessageValidator {
+	return &defaultMessageValidator{allowAggregatedSigs: true}
+}
+
 var _ SignedMessageValidator = (*defaultMessageValidator)(nil)
 
 func (v *defaultMessageValidator) Validate(ctx context.Context, msg *types.SignedMessage, fromActor *actor.Actor) error {
-	if !msg.VerifySignature() {
+	aggregated := v.allowAggregatedSigs && msg.IsFromBLS() && len(msg.Signature) == 0
+	if !aggregated && !msg.VerifySignature() {
 		return errInvalidSignature
 	}
 
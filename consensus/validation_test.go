@@ -43,6 +43,34 @@ func TestMessageValidator(t *testing.T) {
 		assert.NoError(t, validator.Validate(ctx, msg, actor))
 	})
 
+	t.Run("valid from BLS address", func(t *testing.T) {
+		blsSigner := types.NewMockSigner(types.MustGenerateBLSKeyInfo(1))
+		from := blsSigner.Addresses[0]
+		msg := types.NewMessage(from, bob, 100, attoFil(5), "method", []byte("params"))
+		signed, err := types.NewSignedMessage(*msg, blsSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		assert.NoError(t, validator.Validate(ctx, signed, actor))
+
+		signed.Value = attoFil(6)
+		assert.Errorf(t, validator.Validate(ctx, signed, actor), "signature")
+	})
+
+	t.Run("unsigned from BLS address accepted only in blocks", func(t *testing.T) {
+		blsSigner := types.NewMockSigner(types.MustGenerateBLSKeyInfo(1))
+		msg := types.NewMessage(blsSigner.Addresses[0], bob, 100, attoFil(5), "method", []byte("params"))
+		signed, err := types.NewSignedMessage(*msg, blsSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		unsigned := signed.WithoutSignature()
+
+		assert.Errorf(t, validator.Validate(ctx, unsigned, actor), "signature")
+		assert.NoError(t, consensus.NewBlockMessageValidator().Validate(ctx, unsigned, actor))
+		assert.NoError(t, consensus.NewBlockMessageValidator().Validate(ctx, signed, actor))
+
+		unsignedSecp := newMessage(t, alice, bob, 100, 5, 1, 0)
+		unsignedSecp.Signature = nil
+		assert.Errorf(t, consensus.NewBlockMessageValidator().Validate(ctx, unsignedSecp, actor), "signature")
+	})
+
 	t.Run("invalid signature fails", func(t *testing.T) {
 		msg := newMessage(t, alice, bob, 100, 5, 1, 0)
 		msg.Signature = []byte{}
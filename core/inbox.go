@@ -55,7 +55,8 @@ func (ib *Inbox) Pool() *MessagePool {
 
 // HandleNewHead updates the message pool in response to a new head tipset.
 // This removes messages from the pool that are found in the newly adopted chain and adds back
-// those from the removed chain (if any) that do not appear in the new chain.
+// those from the removed chain (if any) that do not appear in the new chain. Remaining messages
+// are validated again against the new head's state, and dropped if no longer valid.
 // We think that the right model for keeping the message pool up to date is
 // to think about it like a garbage collector.
 func (ib *Inbox) HandleNewHead(ctx context.Context, oldHead, newHead types.TipSet) error {
@@ -96,6 +97,9 @@ func (ib *Inbox) HandleNewHead(ctx context.Context, oldHead, newHead types.TipSe
 		ib.pool.Remove(c)
 	}
 
+	// Drop messages that are invalid in the new head's state, e.g. whose nonce it consumed.
+	ib.pool.Revalidate(ctx)
+
 	// prune all messages that have been in the pool too long
 	return timeoutMessages(ctx, ib.pool, ib.chain, newHead, ib.maxAgeTipsets)
 }
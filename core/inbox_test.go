@@ -303,6 +303,26 @@ func TestUpdateMessagePool(t *testing.T) {
 		assertPoolEquals(t, p)
 	})
 
+	t.Run("Drops messages invalid in the new head's state", func(t *testing.T) {
+		// Msg pool: [m0, m1], Chain: b[]
+		// to
+		// Msg pool: [],       Chain: b[], b[] (m0 and m1 no longer valid)
+		store, chainProvider := newStoreAndProvider(0)
+		validator := th.NewMockMessagePoolValidator()
+		p := core.NewMessagePool(config.NewDefaultConfig().Mpool, validator)
+		ib := core.NewInbox(p, 10, chainProvider)
+
+		m := types.NewSignedMsgs(2, mockSigner)
+		mustAdd(ib, m[0], m[1])
+
+		head := headOf(core.NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}}))
+		next := headOf(core.NewChainWithMessages(store, head, msgsSet{msgs{}}))
+
+		validator.Valid = false
+		assert.NoError(t, ib.HandleNewHead(ctx, head, next))
+		assertPoolEquals(t, p)
+	})
+
 	t.Run("Times out old messages", func(t *testing.T) {
 		var err error
 		store, chainProvider := newStoreAndProvider(0)
@@ -1,20 +1,33 @@
 package core
 
 import (
+	"container/heap"
 	"context"
+	"math/big"
 	"sync"
 
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+func init() {
+	cbor.RegisterCborType(storedMessage{})
+}
+
 var mpSize = metrics.NewInt64Gauge("message_pool_size", "The size of the message pool")
 
+// mpoolPrefix is the datastore prefix under which pending messages are stored.
+const mpoolPrefix = "/mpool"
+
 // MessagePoolValidator defines a validator that ensures a message can go through the pool.
 type MessagePoolValidator interface {
 	Validate(ctx context.Context, msg *types.SignedMessage) error
@@ -26,19 +39,33 @@ type MessagePoolValidator interface {
 // via network or directly created via user command that have yet to be included
 // in a block. Messages are removed as they are processed.
 //
+// A MessagePool created with a datastore persists its pending messages so that they survive
+// restarts, see Load.
+//
 // MessagePool is safe for concurrent access.
 type MessagePool struct {
 	lk sync.RWMutex
 
 	cfg           *config.MessagePoolConfig
 	validator     MessagePoolValidator
+	ds            repo.Datastore            // persists pending messages, nil if the pool is in memory only
 	pending       map[cid.Cid]*timedmessage // all pending messages
-	addressNonces map[addressNonce]bool     // set of address nonce pairs used to efficiently validate duplicate nonces
+	addressNonces map[addressNonce]cid.Cid  // cids of pending messages by address nonce pair, used to efficiently find duplicate nonces
+	senderCounts  map[address.Address]uint  // number of pending messages from each sender
+	byPrice       evictionQueue             // pending messages in eviction order, cheapest first
 }
 
 type timedmessage struct {
 	message *types.SignedMessage
 	addedAt uint64
+	cid     cid.Cid
+	index   int // position in the pool's eviction queue
+}
+
+// storedMessage is the persisted form of a pending message.
+type storedMessage struct {
+	Message *types.SignedMessage
+	AddedAt uint64
 }
 
 type addressNonce struct {
@@ -56,12 +83,55 @@ func NewMessagePool(cfg *config.MessagePoolConfig, validator MessagePoolValidato
 		cfg:           cfg,
 		validator:     validator,
 		pending:       make(map[cid.Cid]*timedmessage),
-		addressNonces: make(map[addressNonce]bool),
+		addressNonces: make(map[addressNonce]cid.Cid),
+		senderCounts:  make(map[address.Address]uint),
+	}
+}
+
+// NewPersistentMessagePool constructs a new MessagePool persisting its pending messages in ds.
+func NewPersistentMessagePool(cfg *config.MessagePoolConfig, validator MessagePoolValidator, ds repo.Datastore) *MessagePool {
+	pool := NewMessagePool(cfg, validator)
+	pool.ds = ds
+	return pool
+}
+
+// Load adds the messages persisted in the pool's datastore to the pool, validating them
+// against the current state. Messages that are no longer valid are discarded.
+func (pool *MessagePool) Load(ctx context.Context) error {
+	if pool.ds == nil {
+		return nil
+	}
+
+	results, err := pool.ds.Query(query.Query{Prefix: mpoolPrefix})
+	if err != nil {
+		return errors.Wrap(err, "failed to query persisted message pool")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to read persisted message pool")
+	}
+
+	for _, entry := range entries {
+		var stored storedMessage
+		err := cbor.DecodeInto(entry.Value, &stored)
+		if err == nil {
+			_, err = pool.Add(ctx, stored.Message, stored.AddedAt)
+		}
+		if err != nil {
+			log.Infof("discarding persisted message %s: %s", entry.Key, err)
+			if err := pool.ds.Delete(datastore.NewKey(entry.Key)); err != nil {
+				return errors.Wrapf(err, "failed to delete persisted message %s", entry.Key)
+			}
+		}
 	}
+	return nil
 }
 
 // Add adds a message to the pool, tagged with the block height at which it was received.
-// Does nothing if the message is already in the pool.
+// Does nothing if the message is already in the pool.  A message with the same sender and
+// nonce as a pending message replaces it if its gas price is high enough, see
+// config.MessagePoolConfig.ReplaceByFeePercent.  When the pool is full a message evicts the
+// lowest priced pending message if it pays a higher gas price.
 func (pool *MessagePool) Add(ctx context.Context, msg *types.SignedMessage, height uint64) (cid.Cid, error) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
@@ -77,12 +147,20 @@ func (pool *MessagePool) Add(ctx context.Context, msg *types.SignedMessage, heig
 		return c, nil
 	}
 
-	if err = pool.validateMessage(ctx, msg); err != nil {
+	displaced, err := pool.validateMessage(ctx, msg)
+	if err != nil {
 		return cid.Undef, errors.Wrap(err, "validation error adding message to pool")
 	}
+	if displaced.Defined() {
+		pool.remove(displaced)
+	}
 
-	pool.pending[c] = &timedmessage{message: msg, addedAt: height}
-	pool.addressNonces[newAddressNonce(msg)] = true
+	tm := &timedmessage{message: msg, addedAt: height, cid: c}
+	pool.pending[c] = tm
+	heap.Push(&pool.byPrice, tm)
+	pool.addressNonces[newAddressNonce(msg)] = c
+	pool.senderCounts[msg.From]++
+	pool.persist(c, msg, height)
 	mpSize.Set(ctx, int64(len(pool.pending)))
 	return c, nil
 }
@@ -117,12 +195,83 @@ func (pool *MessagePool) Remove(c cid.Cid) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
 
+	pool.remove(c)
+	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+}
+
+// remove removes the message by CID from the pending pool. The caller must hold the lock.
+func (pool *MessagePool) remove(c cid.Cid) {
 	msg, ok := pool.pending[c]
-	if ok {
-		delete(pool.addressNonces, newAddressNonce(msg.message))
-		delete(pool.pending, c)
+	if !ok {
+		return
 	}
-	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+
+	an := newAddressNonce(msg.message)
+	if pool.addressNonces[an].Equals(c) {
+		delete(pool.addressNonces, an)
+	}
+	if pool.senderCounts[an.addr] <= 1 {
+		delete(pool.senderCounts, an.addr)
+	} else {
+		pool.senderCounts[an.addr]--
+	}
+	delete(pool.pending, c)
+	heap.Remove(&pool.byPrice, msg.index)
+
+	if pool.ds != nil {
+		if err := pool.ds.Delete(mpoolKey(c)); err != nil {
+			log.Errorf("failed to delete persisted message %s: %s", c, err)
+		}
+	}
+}
+
+// persist writes a pending message to the pool's datastore, if any. The pool keeps working
+// from memory if this fails, so errors are only logged.
+func (pool *MessagePool) persist(c cid.Cid, msg *types.SignedMessage, height uint64) {
+	if pool.ds == nil {
+		return
+	}
+	val, err := cbor.DumpObject(&storedMessage{Message: msg, AddedAt: height})
+	if err == nil {
+		err = pool.ds.Put(mpoolKey(c), val)
+	}
+	if err != nil {
+		log.Errorf("failed to persist message %s: %s", c, err)
+	}
+}
+
+// mpoolKey returns the datastore key of the pending message with cid c.
+func mpoolKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(mpoolPrefix).ChildString(c.String())
+}
+
+// Revalidate checks all pending messages with the pool's validator again and removes those
+// that are no longer valid, e.g. because a new head consumed their nonce or left their sender
+// unable to pay for them. It returns the CIDs of the removed messages.
+func (pool *MessagePool) Revalidate(ctx context.Context) []cid.Cid {
+	pool.lk.RLock()
+	pending := make(map[cid.Cid]*types.SignedMessage, len(pool.pending))
+	for c, msg := range pool.pending {
+		pending[c] = msg.message
+	}
+	pool.lk.RUnlock()
+
+	// Validation may load state, so don't hold the lock while doing it.
+	var invalid []cid.Cid
+	for c, msg := range pending {
+		if err := pool.validator.Validate(ctx, msg); err != nil {
+			log.Debugf("removing message %s from pool: %s", c, err)
+			invalid = append(invalid, c)
+		}
+	}
+
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	for _, c := range invalid {
+		pool.remove(c)
+	}
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	return invalid
 }
 
 // LargestNonce returns the largest nonce used by a message from address in the pool.
@@ -154,18 +303,117 @@ func (pool *MessagePool) PendingBefore(minimumHeight uint64) []cid.Cid {
 }
 
 // validateMessage validates that too many messages aren't added to the pool and the ones that are
-// have a high probability of making it through processing.
-func (pool *MessagePool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
-	if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
-		return errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+// have a high probability of making it through processing.  It returns the cid of the pending
+// message the new one displaces, if any: either the message it replaces or the one it evicts
+// from a full pool.
+func (pool *MessagePool) validateMessage(ctx context.Context, message *types.SignedMessage) (cid.Cid, error) {
+	if message.GasPrice.LessThan(pool.cfg.MinGasPrice) {
+		return cid.Undef, errors.Errorf("message gas price %s is below the minimum %s", message.GasPrice, pool.cfg.MinGasPrice)
 	}
 
-	// check that message with this nonce does not already exist
-	_, found := pool.addressNonces[newAddressNonce(message)]
+	// check whether a message with this nonce already exists, and if so whether the new one
+	// pays enough more to replace it
+	displaced, found := pool.addressNonces[newAddressNonce(message)]
 	if found {
-		return errors.Errorf("message pool contains message with same actor and nonce but different cid")
+		existing := pool.pending[displaced].message
+		if !pool.outbids(message.GasPrice, existing.GasPrice) {
+			return cid.Undef, errors.Errorf("message pool contains message with same actor and nonce but different cid, and gas price %s does not exceed its %s by %d%%",
+				message.GasPrice, existing.GasPrice, pool.cfg.ReplaceByFeePercent)
+		}
+	} else {
+		// replacements don't grow the pool, but other messages must fit within the limits
+		if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
+			cheapest := pool.byPrice.cheapest()
+			if cheapest == nil || !message.GasPrice.GreaterThan(cheapest.message.GasPrice) {
+				return cid.Undef, errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+			}
+			displaced = cheapest.cid
+		}
+		senderCount := pool.senderCounts[message.From]
+		if displaced.Defined() && pool.pending[displaced].message.From == message.From {
+			senderCount--
+		}
+		if senderCount >= pool.cfg.MaxPendingPerSender {
+			return cid.Undef, errors.Errorf("message pool contains the maximum of %d messages from %s", pool.cfg.MaxPendingPerSender, message.From)
+		}
 	}
 
 	// check that the message is likely to succeed in processing
-	return pool.validator.Validate(ctx, message)
+	if err := pool.validator.Validate(ctx, message); err != nil {
+		return cid.Undef, err
+	}
+	return displaced, nil
+}
+
+// evictionQueue is a heap of pending messages ordered by the order in which they are
+// evicted from a full pool: lowest gas price first, and among messages with equal gas prices
+// the one with the highest nonce, so that evictions don't leave gaps in a sender's nonces.
+type evictionQueue []*timedmessage
+
+func (q evictionQueue) Len() int { return len(q) }
+
+func (q evictionQueue) Less(i, j int) bool {
+	mi, mj := q[i].message, q[j].message
+	if !mi.GasPrice.Equal(mj.GasPrice) {
+		return mi.GasPrice.LessThan(mj.GasPrice)
+	}
+	return mi.Nonce > mj.Nonce
+}
+
+func (q evictionQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+// Push implements heap.Interface.
+func (q *evictionQueue) Push(x interface{}) {
+	tm := x.(*timedmessage)
+	tm.index = len(*q)
+	*q = append(*q, tm)
+}
+
+// Pop implements heap.Interface.
+func (q *evictionQueue) Pop() interface{} {
+	old := *q
+	tm := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return tm
+}
+
+// cheapest returns the pending message evicted first from a full pool, or nil if there is
+// none.
+func (q evictionQueue) cheapest() *timedmessage {
+	if len(q) == 0 {
+		return nil
+	}
+	return q[0]
+}
+
+// outbids returns true if gasPrice exceeds existingGasPrice by at least the configured
+// replace-by-fee margin.
+func (pool *MessagePool) outbids(gasPrice, existingGasPrice types.AttoFIL) bool {
+	return OutbidsGasPrice(gasPrice, existingGasPrice, pool.cfg.ReplaceByFeePercent)
+}
+
+// OutbidsGasPrice returns true if gasPrice exceeds existingGasPrice by at least percent
+// percent, and so may replace a message priced at existingGasPrice.
+func OutbidsGasPrice(gasPrice, existingGasPrice types.AttoFIL, percent uint) bool {
+	if !gasPrice.GreaterThan(existingGasPrice) {
+		return false
+	}
+	scaled := gasPrice.MulBigInt(big.NewInt(100))
+	required := existingGasPrice.MulBigInt(big.NewInt(int64(100 + percent)))
+	return scaled.GreaterEqual(required)
+}
+
+// MinReplacementGasPrice returns the lowest gas price that outbids existingGasPrice by percent
+// percent.
+func MinReplacementGasPrice(existingGasPrice types.AttoFIL, percent uint) types.AttoFIL {
+	required := existingGasPrice.MulBigInt(big.NewInt(int64(100 + percent))).DivCeil(types.NewAttoFIL(big.NewInt(100)))
+	if !required.GreaterThan(existingGasPrice) {
+		required = existingGasPrice.Add(types.NewAttoFIL(big.NewInt(1)))
+	}
+	return required
 }
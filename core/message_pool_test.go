@@ -6,11 +6,13 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/repo"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -66,6 +68,7 @@ func TestMessagePoolValidate(t *testing.T) {
 		// pull the default size from the default config value
 		mpoolCfg := config.NewDefaultConfig().Mpool
 		maxMessagePoolSize := mpoolCfg.MaxPoolSize
+		mpoolCfg.MaxPendingPerSender = maxMessagePoolSize
 		ctx := context.Background()
 		pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
 
@@ -99,6 +102,144 @@ func TestMessagePoolValidate(t *testing.T) {
 		assert.Contains(t, err.Error(), "message with same actor and nonce")
 	})
 
+	t.Run("replaces a message with same nonce and a high enough gas price", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.ReplaceByFeePercent = 10
+		pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
+
+		smsg1 := mustSetGasPrice(mockSigner, newSignedMessage(), types.NewGasPrice(100))
+		c1, err := pool.Add(ctx, smsg1, 0)
+		require.NoError(t, err)
+
+		// 9% more is not enough.
+		smsg2 := mustSetGasPrice(mockSigner, newSignedMessage(), types.NewGasPrice(109))
+		_, err = pool.Add(ctx, smsg2, 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message with same actor and nonce")
+
+		smsg3 := mustSetGasPrice(mockSigner, newSignedMessage(), types.NewGasPrice(110))
+		c3, err := pool.Add(ctx, smsg3, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, []*types.SignedMessage{smsg3}, pool.Pending())
+		_, ok := pool.Get(c1)
+		assert.False(t, ok)
+
+		// The replacement frees the nonce once removed.
+		pool.Remove(c3)
+		_, err = pool.Add(ctx, smsg2, 0)
+		require.NoError(t, err)
+	})
+
+	t.Run("replacements are accepted by a full pool", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPoolSize = 1
+		pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
+
+		_, err := pool.Add(ctx, mustSetGasPrice(mockSigner, newSignedMessage(), types.NewGasPrice(100)), 0)
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, mustSetGasPrice(mockSigner, newSignedMessage(), types.NewGasPrice(200)), 0)
+		require.NoError(t, err)
+		assert.Len(t, pool.Pending(), 1)
+	})
+
+	t.Run("evicts the lowest priced message from a full pool", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPoolSize = 3
+		pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
+
+		alice, bob, carol := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2]
+		a0 := newMessageFrom(alice, 0, 5)
+		a1 := newMessageFrom(alice, 1, 2)
+		b0 := newMessageFrom(bob, 0, 2)
+		for _, msg := range []*types.SignedMessage{a0, a1, b0} {
+			_, err := pool.Add(ctx, msg, 0)
+			require.NoError(t, err)
+		}
+
+		// A message paying no more than the cheapest pending one is rejected.
+		_, err := pool.Add(ctx, newMessageFrom(carol, 0, 2), 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message pool is full")
+
+		// Of the cheapest messages the one with the highest nonce is evicted.
+		c0 := newMessageFrom(carol, 0, 3)
+		_, err = pool.Add(ctx, c0, 0)
+		require.NoError(t, err)
+		assert.Len(t, pool.Pending(), 3)
+		assertPoolContains(t, pool, a0, b0, c0)
+
+		// The evicted message's nonce is free again.
+		_, err = pool.Add(ctx, newMessageFrom(alice, 1, 4), 0)
+		require.NoError(t, err)
+		assertPoolContains(t, pool, a0, c0)
+	})
+
+	t.Run("limits the pending messages of each sender", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPendingPerSender = 2
+		pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
+
+		alice, bob := mockSigner.Addresses[0], mockSigner.Addresses[1]
+		for _, msg := range []*types.SignedMessage{newMessageFrom(alice, 0, 1), newMessageFrom(alice, 1, 1)} {
+			_, err := pool.Add(ctx, msg, 0)
+			require.NoError(t, err)
+		}
+
+		_, err := pool.Add(ctx, newMessageFrom(alice, 2, 1), 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "maximum of 2 messages from")
+
+		// Other senders and replacements are unaffected.
+		_, err = pool.Add(ctx, newMessageFrom(bob, 0, 1), 0)
+		require.NoError(t, err)
+		c1, err := pool.Add(ctx, newMessageFrom(alice, 1, 2), 0)
+		require.NoError(t, err)
+
+		// Removing a message makes room for another.
+		pool.Remove(c1)
+		_, err = pool.Add(ctx, newMessageFrom(alice, 2, 1), 0)
+		require.NoError(t, err)
+	})
+
+	t.Run("a sender at its limit may evict its own message from a full pool", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPoolSize = 2
+		mpoolCfg.MaxPendingPerSender = 2
+		pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
+
+		alice := mockSigner.Addresses[0]
+		a0 := newMessageFrom(alice, 0, 3)
+		for _, msg := range []*types.SignedMessage{a0, newMessageFrom(alice, 1, 1)} {
+			_, err := pool.Add(ctx, msg, 0)
+			require.NoError(t, err)
+		}
+
+		a1 := newMessageFrom(alice, 1, 2)
+		_, err := pool.Add(ctx, a1, 0)
+		require.NoError(t, err)
+		assertPoolContains(t, pool, a0, a1)
+	})
+
+	t.Run("rejects messages below the minimum gas price", func(t *testing.T) {
+		ctx := context.Background()
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MinGasPrice = types.NewGasPrice(10)
+		pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
+
+		_, err := pool.Add(ctx, newMessageFrom(mockSigner.Addresses[0], 0, 9), 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "below the minimum")
+
+		_, err = pool.Add(ctx, newMessageFrom(mockSigner.Addresses[0], 0, 10), 0)
+		require.NoError(t, err)
+	})
+
 	t.Run("validates using supplied validator", func(t *testing.T) {
 		ctx := context.Background()
 		validator := th.NewMockMessagePoolValidator()
@@ -112,6 +253,73 @@ func TestMessagePoolValidate(t *testing.T) {
 	})
 }
 
+func TestMessagePoolRevalidate(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	validator := th.NewMockMessagePoolValidator()
+	pool := core.NewMessagePool(config.NewDefaultConfig().Mpool, validator)
+
+	msgs := types.NewSignedMsgs(2, mockSigner)
+	for _, msg := range msgs {
+		_, err := pool.Add(ctx, msg, 0)
+		require.NoError(t, err)
+	}
+
+	assert.Empty(t, pool.Revalidate(ctx))
+	assert.Len(t, pool.Pending(), 2)
+
+	validator.Valid = false
+	assert.Len(t, pool.Revalidate(ctx), 2)
+	assert.Empty(t, pool.Pending())
+
+	// Nonces of removed messages are free again.
+	validator.Valid = true
+	_, err := pool.Add(ctx, msgs[0], 0)
+	require.NoError(t, err)
+}
+
+func TestMessagePoolLoad(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	ds := repo.NewInMemoryRepo().Datastore()
+
+	pool := core.NewPersistentMessagePool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), ds)
+	msgs := types.NewSignedMsgs(3, mockSigner)
+	for i, msg := range msgs {
+		_, err := pool.Add(ctx, msg, uint64(i))
+		require.NoError(t, err)
+	}
+	c0, err := msgs[0].Cid()
+	require.NoError(t, err)
+	pool.Remove(c0)
+
+	t.Run("restores pending messages", func(t *testing.T) {
+		loaded := core.NewPersistentMessagePool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), ds)
+		require.NoError(t, loaded.Load(ctx))
+
+		assertPoolEquals(t, loaded, msgs[1], msgs[2])
+
+		// Messages keep the height they were received at.
+		c1, err := msgs[1].Cid()
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{c1}, loaded.PendingBefore(2))
+	})
+
+	t.Run("discards messages that are no longer valid", func(t *testing.T) {
+		validator := th.NewMockMessagePoolValidator()
+		validator.Valid = false
+		loaded := core.NewPersistentMessagePool(config.NewDefaultConfig().Mpool, validator, ds)
+		require.NoError(t, loaded.Load(ctx))
+		assert.Empty(t, loaded.Pending())
+
+		validator.Valid = true
+		require.NoError(t, loaded.Load(ctx))
+		assert.Empty(t, loaded.Pending())
+	})
+}
+
 func TestMessagePoolDedup(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -138,6 +346,7 @@ func TestMessagePoolAsync(t *testing.T) {
 	count := uint(400)
 	mpoolCfg := config.NewDefaultConfig().Mpool
 	mpoolCfg.MaxPoolSize = count
+	mpoolCfg.MaxPendingPerSender = count
 	msgs := types.NewSignedMsgs(count, mockSigner)
 
 	pool := core.NewMessagePool(mpoolCfg, th.NewMockMessagePoolValidator())
@@ -206,12 +415,41 @@ func TestLargestNonce(t *testing.T) {
 	})
 }
 
+func newMessageFrom(from address.Address, nonce types.Uint64, gasPrice int64) *types.SignedMessage {
+	msg := newSignedMessage().Message
+	msg.From = from
+	msg.Nonce = nonce
+	smsg, err := types.NewSignedMessage(msg, mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(0))
+	if err != nil {
+		panic("Error signing message")
+	}
+	return smsg
+}
+
+// assertPoolContains asserts that each of msgs is pending in pool.
+func assertPoolContains(t *testing.T, pool *core.MessagePool, msgs ...*types.SignedMessage) {
+	for _, msg := range msgs {
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		_, ok := pool.Get(c)
+		assert.True(t, ok, "pool does not contain %s", msg)
+	}
+}
+
 func mustSetNonce(signer types.Signer, message *types.SignedMessage, nonce types.Uint64) *types.SignedMessage {
 	return mustResignMessage(signer, message, func(m *types.Message) {
 		m.Nonce = nonce
 	})
 }
 
+func mustSetGasPrice(signer types.Signer, message *types.SignedMessage, gasPrice types.AttoFIL) *types.SignedMessage {
+	smsg, err := types.NewSignedMessage(message.Message, signer, gasPrice, message.GasLimit)
+	if err != nil {
+		panic("Error signing message")
+	}
+	return smsg
+}
+
 func mustResignMessage(signer types.Signer, message *types.SignedMessage, f func(*types.Message)) *types.SignedMessage {
 	var msg types.Message
 	msg = message.Message
@@ -226,3 +464,20 @@ func mustResignMessage(signer types.Signer, message *types.SignedMessage, f func
 func signMessage(signer types.Signer, message types.Message) (*types.SignedMessage, error) {
 	return types.NewSignedMessage(message, signer, types.NewGasPrice(0), types.NewGasUnits(0))
 }
+
+func TestMinReplacementGasPrice(t *testing.T) {
+	tf.UnitTest(t)
+
+	assertMin := func(expected, existing int64, percent uint) {
+		actual := core.MinReplacementGasPrice(types.NewGasPrice(existing), percent)
+		assert.True(t, types.NewGasPrice(expected).Equal(actual), "expected %d, got %s", expected, actual)
+		assert.True(t, core.OutbidsGasPrice(actual, types.NewGasPrice(existing), percent))
+	}
+
+	assertMin(110, 100, 10)
+	assertMin(13, 11, 10)
+	assertMin(1, 0, 10)
+	assertMin(101, 100, 0)
+
+	assert.False(t, core.OutbidsGasPrice(types.NewGasPrice(12), types.NewGasPrice(11), 10))
+}
@@ -2,15 +2,28 @@ package core
 
 import (
 	"context"
+	"sort"
+	"strconv"
 	"sync"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+func init() {
+	cbor.RegisterCborType(QueuedMessage{})
+}
+
+// outboxPrefix is the datastore prefix under which queued messages are stored.
+const outboxPrefix = "/outbox"
+
 var (
 	mqSizeGa   = metrics.NewInt64Gauge("message_queue_size", "The size of the message queue")
 	mqOldestGa = metrics.NewInt64Gauge("message_queue_oldest", "The age of the oldest message in the queue or zero when empty")
@@ -23,11 +36,15 @@ var (
 // not enforced.
 // A message queue is intended to record outbound messages that have been transmitted but not yet appeared in a block,
 // where the stamp could be block height.
+// A MessageQueue created with a datastore persists its messages so that they survive restarts,
+// see Load.
 // MessageQueue is safe for concurrent access.
 type MessageQueue struct {
 	lk sync.RWMutex
 	// Message queues keyed by sending actor address, in nonce order
 	queues map[address.Address][]*QueuedMessage
+	// Persists queued messages, nil if the queue is in memory only
+	ds repo.Datastore
 }
 
 // QueuedMessage is a message an the stamp it was enqueued with.
@@ -43,6 +60,51 @@ func NewMessageQueue() *MessageQueue {
 	}
 }
 
+// NewPersistentMessageQueue constructs a new, empty queue persisting its messages in ds.
+func NewPersistentMessageQueue(ds repo.Datastore) *MessageQueue {
+	mq := NewMessageQueue()
+	mq.ds = ds
+	return mq
+}
+
+// Load replaces the contents of the queue with the messages persisted in its datastore.
+func (mq *MessageQueue) Load(ctx context.Context) error {
+	defer func() {
+		mqSizeGa.Set(ctx, mq.Size())
+		mqOldestGa.Set(ctx, int64(mq.Oldest()))
+	}()
+
+	if mq.ds == nil {
+		return nil
+	}
+
+	results, err := mq.ds.Query(query.Query{Prefix: outboxPrefix})
+	if err != nil {
+		return errors.Wrap(err, "failed to query persisted message queue")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to read persisted message queue")
+	}
+
+	queues := make(map[address.Address][]*QueuedMessage)
+	for _, entry := range entries {
+		var qm QueuedMessage
+		if err := cbor.DecodeInto(entry.Value, &qm); err != nil {
+			return errors.Wrapf(err, "failed to decode queued message %s", entry.Key)
+		}
+		queues[qm.Msg.From] = append(queues[qm.Msg.From], &qm)
+	}
+	for _, q := range queues {
+		sort.Slice(q, func(i, j int) bool { return q[i].Msg.Nonce < q[j].Msg.Nonce })
+	}
+
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+	mq.queues = queues
+	return nil
+}
+
 // Enqueue appends a new message for an address. If the queue already contains any messages for
 // from same address, the new message's nonce must be exactly one greater than the largest nonce
 // present.
@@ -62,10 +124,39 @@ func (mq *MessageQueue) Enqueue(ctx context.Context, msg *types.SignedMessage, s
 			return errors.Errorf("Invalid nonce %d, expected %d", msg.Nonce, nextNonce)
 		}
 	}
-	mq.queues[msg.From] = append(q, &QueuedMessage{msg, stamp})
+	qm := &QueuedMessage{msg, stamp}
+	if err := mq.persist(qm); err != nil {
+		return err
+	}
+	mq.queues[msg.From] = append(q, qm)
 	return nil
 }
 
+// Replace swaps the queued message with the same sender and nonce as msg for msg, stamping it
+// with stamp, and returns the message it replaced.  It returns an error if no message from the
+// sender with that nonce is queued.
+func (mq *MessageQueue) Replace(ctx context.Context, msg *types.SignedMessage, stamp uint64) (*types.SignedMessage, error) {
+	defer func() {
+		mqOldestGa.Set(ctx, int64(mq.Oldest()))
+	}()
+
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	for _, qm := range mq.queues[msg.From] {
+		if qm.Msg.Nonce == msg.Nonce {
+			if err := mq.persist(&QueuedMessage{msg, stamp}); err != nil {
+				return nil, err
+			}
+			replaced := qm.Msg
+			qm.Msg = msg
+			qm.Stamp = stamp
+			return replaced, nil
+		}
+	}
+	return nil, errors.Errorf("no message from %s with nonce %d in queue", msg.From, msg.Nonce)
+}
+
 // RemoveNext removes and returns a single message from the queue, if it bears the expected nonce value, with found = true.
 // Returns found = false if the queue is empty or the expected nonce is less than any in the queue for that address
 // (indicating the message had already been removed).
@@ -85,6 +176,7 @@ func (mq *MessageQueue) RemoveNext(ctx context.Context, sender address.Address,
 		head := q[0]
 		if expectedNonce == uint64(head.Msg.Nonce) {
 			mq.queues[sender] = q[1:] // pop the head
+			mq.unpersist(head.Msg)
 			msg = head.Msg
 			found = true
 		} else if expectedNonce > uint64(head.Msg.Nonce) {
@@ -107,6 +199,9 @@ func (mq *MessageQueue) Clear(ctx context.Context, sender address.Address) bool
 	defer mq.lk.Unlock()
 
 	q := mq.queues[sender]
+	for _, qm := range q {
+		mq.unpersist(qm.Msg)
+	}
 	delete(mq.queues, sender)
 	return len(q) > 0
 }
@@ -130,6 +225,7 @@ func (mq *MessageQueue) ExpireBefore(ctx context.Context, stamp uint64) map[addr
 			// record the number of messages to be expired
 			mqExpireCt.Inc(ctx, int64(len(q)))
 			for _, m := range q {
+				mq.unpersist(m.Msg)
 				expired[sender] = append(expired[sender], m.Msg)
 			}
 
@@ -213,3 +309,35 @@ func (mq *MessageQueue) List(sender address.Address) []*QueuedMessage {
 	}
 	return out
 }
+
+// persist writes a queued message to the queue's datastore, if any.
+func (mq *MessageQueue) persist(qm *QueuedMessage) error {
+	if mq.ds == nil {
+		return nil
+	}
+	val, err := cbor.DumpObject(qm)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode queued message")
+	}
+	if err := mq.ds.Put(queuedMessageKey(qm.Msg), val); err != nil {
+		return errors.Wrap(err, "failed to persist queued message")
+	}
+	return nil
+}
+
+// unpersist deletes a message removed from the queue from the queue's datastore, if any.
+// The message is gone from memory already, so errors are only logged.
+func (mq *MessageQueue) unpersist(msg *types.SignedMessage) {
+	if mq.ds == nil {
+		return
+	}
+	if err := mq.ds.Delete(queuedMessageKey(msg)); err != nil {
+		log.Errorf("failed to delete persisted message from %s with nonce %d: %s", msg.From, msg.Nonce, err)
+	}
+}
+
+// queuedMessageKey returns the datastore key of a queued message, which is unique per sender
+// and nonce.
+func queuedMessageKey(msg *types.SignedMessage) datastore.Key {
+	return datastore.NewKey(outboxPrefix).ChildString(msg.From.String()).ChildString(strconv.FormatUint(uint64(msg.Nonce), 10))
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/repo"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -196,6 +197,69 @@ func TestMessageQueue(t *testing.T) {
 		assertLargestNonce(q, alice, 1)
 	})
 
+	t.Run("replace", func(t *testing.T) {
+		msgs := []*types.SignedMessage{
+			mm.NewSignedMessage(alice, 0),
+			mm.NewSignedMessage(alice, 1),
+		}
+		replacement := mm.NewSignedMessage(alice, 1)
+
+		q := core.NewMessageQueue()
+		requireEnqueue(q, msgs[0], 0)
+		requireEnqueue(q, msgs[1], 0)
+
+		replaced, err := q.Replace(ctx, replacement, 5)
+		require.NoError(t, err)
+		assert.Equal(t, msgs[1], replaced)
+		assert.Equal(t, int64(2), q.Size())
+		assert.Equal(t, &core.QueuedMessage{Msg: replacement, Stamp: 5}, q.List(alice)[1])
+
+		_, err = q.Replace(ctx, mm.NewSignedMessage(alice, 2), 5)
+		assert.Error(t, err)
+		_, err = q.Replace(ctx, mm.NewSignedMessage(bob, 0), 5)
+		assert.Error(t, err)
+	})
+
+	t.Run("persists messages", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().Datastore()
+		fromAlice := []*types.SignedMessage{
+			mm.NewSignedMessage(alice, 0),
+			mm.NewSignedMessage(alice, 1),
+			mm.NewSignedMessage(alice, 2),
+		}
+		fromBob := mm.NewSignedMessage(bob, 7)
+		replacement := mm.NewSignedMessage(alice, 2)
+
+		q := core.NewPersistentMessageQueue(ds)
+		requireEnqueue(q, fromAlice[0], 1)
+		requireEnqueue(q, fromAlice[1], 2)
+		requireEnqueue(q, fromAlice[2], 3)
+		requireEnqueue(q, fromBob, 4)
+		requireRemoveNext(q, alice, 0)
+		_, err := q.Replace(ctx, replacement, 5)
+		require.NoError(t, err)
+
+		loaded := core.NewPersistentMessageQueue(ds)
+		require.NoError(t, loaded.Load(ctx))
+		assert.Equal(t, int64(3), loaded.Size())
+		assertLargestNonce(loaded, alice, 2)
+		assertLargestNonce(loaded, bob, 7)
+
+		aliceQueue := loaded.List(alice)
+		require.Len(t, aliceQueue, 2)
+		assert.True(t, types.SmsgCidsEqual(fromAlice[1], aliceQueue[0].Msg))
+		assert.Equal(t, uint64(2), aliceQueue[0].Stamp)
+		assert.True(t, types.SmsgCidsEqual(replacement, aliceQueue[1].Msg))
+		assert.Equal(t, uint64(5), aliceQueue[1].Stamp)
+
+		// Removals are persisted too.
+		assert.True(t, loaded.Clear(ctx, bob))
+		assert.NotEmpty(t, loaded.ExpireBefore(ctx, 3))
+		reloaded := core.NewPersistentMessageQueue(ds)
+		require.NoError(t, reloaded.Load(ctx))
+		assert.Equal(t, int64(0), reloaded.Size())
+	})
+
 	t.Run("independent addresses", func(t *testing.T) {
 		fromAlice := []*types.SignedMessage{
 			mm.NewSignedMessage(alice, 0),
@@ -2,11 +2,13 @@ package core
 
 import (
 	"context"
+	"math/big"
 	"sync"
 
 	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -29,12 +31,20 @@ type Outbox struct {
 	publisher publisher
 	// Maintains message queue in response to new tipsets.
 	policy QueuePolicy
+	// Limits of the message pools the outbox publishes to, beyond which messages are held.
+	mpoolCfg *config.MessagePoolConfig
 
 	chains outboxChainProvider
 	actors actorProvider
 
 	// Protects the "next nonce" calculation to avoid collisions.
 	nonceLock sync.Mutex
+
+	// Heights at which queued messages were last re-broadcast, by message cid.
+	rebroadcastAt map[cid.Cid]uint64
+	// Queued messages not yet published because the message pools would not accept them, by
+	// message cid. They follow all the published messages of their sender.
+	held map[cid.Cid]struct{}
 }
 
 type outboxChainProvider interface {
@@ -53,9 +63,14 @@ type publisher interface {
 
 var msgSendErrCt = metrics.NewInt64Counter("message_sender_error", "Number of errors encountered while sending a message")
 
+// OutboxRebroadcastRounds is the number of rounds after which a queued message that has not been
+// mined is published again, in case peers dropped it from their pools or never received it.
+const OutboxRebroadcastRounds = 3
+
 // NewOutbox creates a new outbox
 func NewOutbox(signer types.Signer, validator consensus.SignedMessageValidator, queue *MessageQueue,
-	publisher publisher, policy QueuePolicy, chains outboxChainProvider, actors actorProvider) *Outbox {
+	publisher publisher, policy QueuePolicy, chains outboxChainProvider, actors actorProvider,
+	mpoolCfg *config.MessagePoolConfig) *Outbox {
 	return &Outbox{
 		signer:    signer,
 		validator: validator,
@@ -64,7 +79,55 @@ func NewOutbox(signer types.Signer, validator consensus.SignedMessageValidator,
 		policy:    policy,
 		chains:    chains,
 		actors:    actors,
+		mpoolCfg:  mpoolCfg,
+
+		rebroadcastAt: make(map[cid.Cid]uint64),
+		held:          make(map[cid.Cid]struct{}),
+	}
+}
+
+// Load restores the outbound message queue persisted before the node last stopped, dropping
+// messages whose nonce the chain has since consumed. The messages of a sender beyond the
+// message pools' MaxPendingPerSender are held again.
+func (ob *Outbox) Load(ctx context.Context) error {
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	if err := ob.queue.Load(ctx); err != nil {
+		return err
+	}
+
+	head := ob.chains.GetHead()
+	for _, sender := range ob.queue.Queues() {
+		fromActor, err := ob.actors.GetActorAt(ctx, head, sender)
+		if err != nil {
+			// Keep the messages, the policy expires them if they are never mined.
+			log.Warningf("failed to check queued messages of %s: %s", sender, err)
+			continue
+		}
+		for _, qm := range ob.queue.List(sender) {
+			if qm.Msg.Nonce >= fromActor.Nonce {
+				break
+			}
+			if _, _, err := ob.queue.RemoveNext(ctx, sender, uint64(qm.Msg.Nonce)); err != nil {
+				return err
+			}
+		}
 	}
+
+	for _, sender := range ob.queue.Queues() {
+		for i, qm := range ob.queue.List(sender) {
+			if uint(i) < ob.mpoolCfg.MaxPendingPerSender {
+				continue
+			}
+			c, err := qm.Msg.Cid()
+			if err != nil {
+				return err
+			}
+			ob.held[c] = struct{}{}
+		}
+	}
+	return nil
 }
 
 // Queue returns the outbox's outbound message queue.
@@ -72,7 +135,9 @@ func (ob *Outbox) Queue() *MessageQueue {
 	return ob.queue
 }
 
-// Send marshals and sends a message, retaining it in the outbound message queue.
+// Send marshals and sends a message, retaining it in the outbound message queue. A message the
+// message pools would not accept yet is held in the queue, and published once earlier
+// messages from its sender are mined.
 func (ob *Outbox) Send(ctx context.Context, from, to address.Address, value types.AttoFIL,
 	gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (out cid.Cid, err error) {
 	defer func() {
@@ -118,22 +183,375 @@ func (ob *Outbox) Send(ctx context.Context, from, to address.Address, value type
 		return cid.Undef, errors.Wrap(err, "failed to get block height")
 	}
 
-	// Add to the local message queue/pool at the last possible moment before broadcasting to network.
-	if err := ob.queue.Enqueue(ctx, signed, height); err != nil {
-		return cid.Undef, errors.Wrap(err, "failed to add message to outbound queue")
+	if err := ob.publishOrHold(ctx, signed, fromActor, height); err != nil {
+		return cid.Undef, err
+	}
+
+	return signed.Cid()
+}
+
+// BatchMessage is a message to send with SendBatch.
+type BatchMessage struct {
+	To       address.Address
+	Value    types.AttoFIL
+	GasPrice types.AttoFIL
+	GasLimit types.GasUnits
+	Method   string
+	Params   []byte // abi encoded
+}
+
+// SendBatch marshals and sends messages from an address with consecutive nonces, retaining
+// them in the outbound message queue. No message is sent unless all are valid and the sender
+// can pay for all of them. The messages beyond what the message pools accept from a sender,
+// MaxPendingPerSender messages at most MaxNonceGap past its nonce, are held in the queue and
+// published as earlier ones are mined. If sending fails part way, the cids of the messages
+// already sent are returned with the error.
+func (ob *Outbox) SendBatch(ctx context.Context, from address.Address, batch []BatchMessage) (out []cid.Cid, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+	}()
+
+	// Lock for the whole batch so that no other message takes a nonce in between.
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	head := ob.chains.GetHead()
+
+	fromActor, err := ob.actors.GetActorAt(ctx, head, from)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no actor at address %s", from)
+	}
+
+	nonce, err := nextNonce(fromActor, ob.queue, from)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed calculating nonce for actor at %s", from)
+	}
+
+	// Validate each message against the balance the messages before it leave.
+	remaining := *fromActor
+	signed := make([]*types.SignedMessage, len(batch))
+	for i, bm := range batch {
+		rawMsg := types.NewMessage(from, bm.To, nonce+uint64(i), bm.Value, bm.Method, bm.Params)
+		signed[i], err = types.NewSignedMessage(*rawMsg, ob.signer, bm.GasPrice, bm.GasLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sign message %d", i)
+		}
+
+		if err := ob.validator.Validate(ctx, signed[i], &remaining); err != nil {
+			return nil, errors.Wrapf(err, "invalid message %d", i)
+		}
+		maxGasCharge := bm.GasPrice.MulBigInt(big.NewInt(int64(bm.GasLimit)))
+		remaining.Balance = remaining.Balance.Sub(bm.Value).Sub(maxGasCharge)
+	}
+
+	height, err := tipsetHeight(ob.chains, head)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get block height")
 	}
 
-	err = ob.publisher.Publish(ctx, signed, height)
+	for i, msg := range signed {
+		c, err := msg.Cid()
+		if err != nil {
+			return out, err
+		}
+
+		if err := ob.publishOrHold(ctx, msg, fromActor, height); err != nil {
+			return out, errors.Wrapf(err, "failed to send message %d", i)
+		}
+		out = append(out, c)
+	}
+
+	return out, nil
+}
+
+// publishOrHold publishes a message and adds it to the outbound queue, or only queues it as
+// held if the message pools would not accept it yet. Only published or held messages are
+// queued, so that a failure doesn't leave a queued message holding a nonce without ever
+// reaching the network. The caller must hold the nonce lock.
+func (ob *Outbox) publishOrHold(ctx context.Context, msg *types.SignedMessage, fromActor *actor.Actor, height uint64) error {
+	c, err := msg.Cid()
 	if err != nil {
+		return err
+	}
+
+	published, holding := ob.pending(msg.From)
+	if holding || !ob.accepted(fromActor, uint64(msg.Nonce), published) {
+		if err := ob.queue.Enqueue(ctx, msg, height); err != nil {
+			return errors.Wrap(err, "failed to add message to outbound queue")
+		}
+		ob.held[c] = struct{}{}
+		return nil
+	}
+
+	if err := ob.publisher.Publish(ctx, msg, height); err != nil {
+		return err
+	}
+	if err := ob.queue.Enqueue(ctx, msg, height); err != nil {
+		return errors.Wrap(err, "message was published but could not be added to the outbound queue")
+	}
+	return nil
+}
+
+// pending returns the number of queued messages from an address that were published, and
+// whether any of its messages are held.
+func (ob *Outbox) pending(from address.Address) (published int, holding bool) {
+	for _, qm := range ob.queue.List(from) {
+		c, err := qm.Msg.Cid()
+		if err != nil {
+			continue
+		}
+		if _, ok := ob.held[c]; ok {
+			holding = true
+		} else {
+			published++
+		}
+	}
+	return published, holding
+}
+
+// accepted returns true if the message pools accept a message with the nonce from an actor
+// with published messages pending: at most MaxPendingPerSender of them, with nonces at most
+// MaxNonceGap past the actor's.
+func (ob *Outbox) accepted(fromActor *actor.Actor, nonce uint64, published int) bool {
+	if uint(published) >= ob.mpoolCfg.MaxPendingPerSender {
+		return false
+	}
+	return nonce <= uint64(fromActor.Nonce) || nonce-uint64(fromActor.Nonce) <= uint64(ob.mpoolCfg.MaxNonceGap)
+}
+
+// publishHeld publishes, in nonce order, the held messages the message pools accept now that
+// earlier messages may have been mined, and forgets the held messages no longer queued.
+func (ob *Outbox) publishHeld(ctx context.Context, head types.TipSet, height uint64) {
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	held := make(map[cid.Cid]struct{})
+	for _, sender := range ob.queue.Queues() {
+		var fromActor *actor.Actor
+		published := 0
+		blocked := false
+		for _, qm := range ob.queue.List(sender) {
+			c, err := qm.Msg.Cid()
+			if err != nil {
+				log.Errorf("failed to get cid of queued message: %s", err)
+				continue
+			}
+			if _, ok := ob.held[c]; !ok {
+				published++
+				continue
+			}
+
+			if !blocked && fromActor == nil {
+				fromActor, err = ob.actors.GetActorAt(ctx, head.ToSortedCidSet(), sender)
+				if err != nil {
+					log.Warningf("failed to check held messages of %s: %s", sender, err)
+					blocked = true
+				}
+			}
+			if !blocked && ob.accepted(fromActor, uint64(qm.Msg.Nonce), published) {
+				if err := ob.publisher.Publish(ctx, qm.Msg, height); err == nil {
+					// Restart the message's age, so that it doesn't expire for the time it was held.
+					if _, err := ob.queue.Replace(ctx, qm.Msg, height); err != nil {
+						log.Errorf("failed to restamp published message %s: %s", c, err)
+					}
+					published++
+					continue
+				}
+				log.Errorf("failed to publish held message %s: %s", c, err)
+			}
+			// Later messages wait for this one, so that they reach the pools in nonce order. Its age
+			// is kept fresh so that the queue doesn't expire once it leads the sender's messages.
+			blocked = true
+			held[c] = struct{}{}
+			if qm.Stamp < height {
+				if _, err := ob.queue.Replace(ctx, qm.Msg, height); err != nil {
+					log.Errorf("failed to restamp held message %s: %s", c, err)
+				}
+			}
+		}
+	}
+	ob.held = held
+}
+
+// Replace re-signs the queued message with cid c at gasPrice and sends it in place of the
+// original, so that a message stuck behind a low gas price can be mined.  The replacement
+// keeps the original's nonce, so at most one of them is mined.
+func (ob *Outbox) Replace(ctx context.Context, c cid.Cid, gasPrice types.AttoFIL) (out cid.Cid, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+	}()
+
+	// Lock to avoid racing a send for the same actor.
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	original, stamp, found := ob.findQueued(c)
+	if !found {
+		return cid.Undef, errors.Errorf("message %s is not in the outbound queue", c)
+	}
+	_, held := ob.held[c]
+
+	head := ob.chains.GetHead()
+
+	fromActor, err := ob.actors.GetActorAt(ctx, head, original.From)
+	if err != nil {
+		return cid.Undef, errors.Wrapf(err, "no actor at address %s", original.From)
+	}
+
+	signed, err := types.NewSignedMessage(original.Message, ob.signer, gasPrice, original.GasLimit)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to sign message")
+	}
+
+	err = ob.validator.Validate(ctx, signed, fromActor)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "invalid message")
+	}
+
+	height, err := tipsetHeight(ob.chains, head)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to get block height")
+	}
+
+	// A held message is replaced in the queue only, the replacement is published in its turn.
+	if held {
+		if _, err := ob.queue.Replace(ctx, signed, stamp); err != nil {
+			return cid.Undef, errors.Wrap(err, "failed to replace message in outbound queue")
+		}
+		sc, err := signed.Cid()
+		if err != nil {
+			return cid.Undef, err
+		}
+		delete(ob.held, c)
+		ob.held[sc] = struct{}{}
+		return sc, nil
+	}
+
+	if _, err := ob.queue.Replace(ctx, signed, height); err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to replace message in outbound queue")
+	}
+
+	if err := ob.publisher.Publish(ctx, signed, height); err != nil {
+		// The original is still pending, keep tracking it.
+		if _, rerr := ob.queue.Replace(ctx, original, stamp); rerr != nil {
+			log.Errorf("failed to restore message %s in outbound queue: %s", c, rerr)
+		}
 		return cid.Undef, err
 	}
 
 	return signed.Cid()
 }
 
-// HandleNewHead maintains the message queue in response to a new head tipset.
+// NextNonce returns the nonce the next message sent from an address should have, accounting
+// for the messages from it in the outbound queue. The nonce is not reserved, so a message
+// created with it must be submitted before another is sent from the same address.
+func (ob *Outbox) NextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	fromActor, err := ob.actors.GetActorAt(ctx, ob.chains.GetHead(), from)
+	if err != nil {
+		return 0, errors.Wrapf(err, "no actor at address %s", from)
+	}
+	return nextNonce(fromActor, ob.queue, from)
+}
+
+// Submit publishes a message signed elsewhere, for instance by a key that never leaves an
+// offline machine. The message is validated as it is added to the message pool, and is
+// retained in the outbound queue so it is re-broadcast until mined.
+func (ob *Outbox) Submit(ctx context.Context, signed *types.SignedMessage) (out cid.Cid, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+	}()
+
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	height, err := tipsetHeight(ob.chains, ob.chains.GetHead())
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to get block height")
+	}
+
+	if err := ob.publisher.Publish(ctx, signed, height); err != nil {
+		return cid.Undef, err
+	}
+
+	// The message is already on its way, failing to track it only loses the re-broadcasts.
+	if err := ob.queue.Enqueue(ctx, signed, height); err != nil {
+		log.Warningf("submitted message from %s is not tracked in the outbound queue: %s", signed.From, err)
+	}
+
+	return signed.Cid()
+}
+
+// findQueued returns the queued message with cid c and its stamp.
+func (ob *Outbox) findQueued(c cid.Cid) (*types.SignedMessage, uint64, bool) {
+	for _, sender := range ob.queue.Queues() {
+		for _, qm := range ob.queue.List(sender) {
+			mc, err := qm.Msg.Cid()
+			if err == nil && mc.Equals(c) {
+				return qm.Msg, qm.Stamp, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// HandleNewHead maintains the message queue in response to a new head tipset, publishes the
+// held messages the message pools now accept, and re-broadcasts queued messages that have not
+// been mined for OutboxRebroadcastRounds rounds.
 func (ob *Outbox) HandleNewHead(ctx context.Context, oldHead, newHead types.TipSet) error {
-	return ob.policy.HandleNewHead(ctx, ob.queue, oldHead, newHead)
+	if err := ob.policy.HandleNewHead(ctx, ob.queue, oldHead, newHead); err != nil {
+		return err
+	}
+
+	height, err := newHead.Height()
+	if err != nil {
+		return err
+	}
+	ob.publishHeld(ctx, newHead, height)
+	ob.rebroadcast(ctx, height)
+	return nil
+}
+
+// rebroadcast publishes again each queued message, other than held ones, that was last
+// published, or enqueued, at least OutboxRebroadcastRounds rounds before height.
+func (ob *Outbox) rebroadcast(ctx context.Context, height uint64) {
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	rebroadcastAt := make(map[cid.Cid]uint64)
+	for _, sender := range ob.queue.Queues() {
+		for _, qm := range ob.queue.List(sender) {
+			c, err := qm.Msg.Cid()
+			if err != nil {
+				log.Errorf("failed to get cid of queued message: %s", err)
+				continue
+			}
+			if _, held := ob.held[c]; held {
+				continue
+			}
+
+			last, ok := ob.rebroadcastAt[c]
+			if !ok || last < qm.Stamp {
+				last = qm.Stamp
+			}
+			if height >= last+OutboxRebroadcastRounds {
+				if err := ob.publisher.Publish(ctx, qm.Msg, height); err != nil {
+					log.Errorf("failed to re-broadcast message %s: %s", c, err)
+				}
+				last = height
+			}
+			rebroadcastAt[c] = last
+		}
+	}
+	ob.rebroadcastAt = rebroadcastAt
 }
 
 // nextNonce returns the next expected nonce value for an account actor. This is the larger
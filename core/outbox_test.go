@@ -10,7 +10,10 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/repo"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/pkg/errors"
@@ -28,7 +31,7 @@ func TestOutbox(t *testing.T) {
 		publisher := &mockPublisher{}
 		provider := &fakeProvider{}
 
-		ob := core.NewOutbox(w, nullValidator{rejectMessages: true}, queue, publisher, nullPolicy{}, provider, provider)
+		ob := core.NewOutbox(w, nullValidator{rejectMessages: true}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
 
 		cid, err := ob.Send(context.Background(), sender, sender, types.NewAttoFILFromFIL(2), types.NewGasPrice(0), types.NewGasUnits(0), "")
 		assert.Errorf(t, err, "for testing")
@@ -49,7 +52,7 @@ func TestOutbox(t *testing.T) {
 		actr.Nonce = 42
 		provider.Set(t, blk, sender, actr)
 
-		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider)
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
 		require.Empty(t, queue.List(sender))
 		require.Nil(t, publisher.message)
 
@@ -79,7 +82,7 @@ func TestOutbox(t *testing.T) {
 		actr.Nonce = 42
 		provider.Set(t, blk, sender, actr)
 
-		s := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider)
+		s := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
 
 		var wg sync.WaitGroup
 		addTwentyMessages := func(batch int) {
@@ -115,6 +118,375 @@ func TestOutbox(t *testing.T) {
 		}
 	})
 
+	t.Run("send batch assigns consecutive nonces", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		addrs := address.NewForTestGetter()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		blk.Height = 1000
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		actr.Nonce = 42
+		provider.Set(t, blk, sender, actr)
+
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+
+		_, err := ob.Send(ctx, sender, addrs(), types.ZeroAttoFIL, types.NewGasPrice(1), types.NewGasUnits(0), "")
+		require.NoError(t, err)
+
+		batch := []core.BatchMessage{
+			{To: addrs(), Value: types.NewAttoFILFromFIL(1), GasPrice: types.NewGasPrice(1)},
+			{To: addrs(), Value: types.NewAttoFILFromFIL(2), GasPrice: types.NewGasPrice(1)},
+			{To: addrs(), Value: types.NewAttoFILFromFIL(3), GasPrice: types.NewGasPrice(1)},
+		}
+		cids, err := ob.SendBatch(ctx, sender, batch)
+		require.NoError(t, err)
+		require.Len(t, cids, 3)
+
+		queued := queue.List(sender)
+		require.Len(t, queued, 4)
+		for i, bm := range batch {
+			qm := queued[i+1]
+			assert.Equal(t, types.Uint64(43+i), qm.Msg.Nonce)
+			assert.Equal(t, bm.To, qm.Msg.To)
+			assert.True(t, bm.Value.Equal(qm.Msg.Value))
+
+			c, err := qm.Msg.Cid()
+			require.NoError(t, err)
+			assert.Equal(t, c, cids[i])
+		}
+		assert.Equal(t, queued[3].Msg, publisher.message)
+	})
+
+	t.Run("send batch sends nothing if a message is invalid", func(t *testing.T) {
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		provider.Set(t, blk, sender, actr)
+
+		ob := core.NewOutbox(w, nullValidator{rejectMessages: true}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+
+		batch := []core.BatchMessage{{To: sender, Value: types.ZeroAttoFIL, GasPrice: types.NewGasPrice(1)}}
+		cids, err := ob.SendBatch(context.Background(), sender, batch)
+		assert.Error(t, err)
+		assert.Empty(t, cids)
+		assert.Empty(t, queue.List(sender))
+		assert.Nil(t, publisher.message)
+	})
+
+	t.Run("send batch holds messages the message pool would not accept yet", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		addrs := address.NewForTestGetter()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		provider.Set(t, blk, sender, actr)
+
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPendingPerSender = 3
+		mpoolCfg.MaxNonceGap = 10
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, mpoolCfg)
+
+		batch := make([]core.BatchMessage, 4)
+		for i := range batch {
+			batch[i] = core.BatchMessage{To: addrs(), Value: types.ZeroAttoFIL, GasPrice: types.NewGasPrice(1)}
+		}
+		cids, err := ob.SendBatch(ctx, sender, batch)
+		require.NoError(t, err)
+		assert.Len(t, cids, 4)
+		assert.Len(t, queue.List(sender), 4)
+		assert.Equal(t, 3, publisher.published)
+		assert.Equal(t, types.Uint64(2), publisher.message.Nonce)
+
+		// A message sent after held ones waits behind them.
+		_, err = ob.Send(ctx, sender, addrs(), types.ZeroAttoFIL, types.NewGasPrice(1), types.NewGasUnits(0), "")
+		require.NoError(t, err)
+		assert.Len(t, queue.List(sender), 5)
+		assert.Equal(t, 3, publisher.published)
+
+		// Nothing more is published until earlier messages are mined.
+		require.NoError(t, ob.HandleNewHead(ctx, provider.tipset, provider.tipset))
+		assert.Equal(t, 3, publisher.published)
+
+		_, _, err = queue.RemoveNext(ctx, sender, 0)
+		require.NoError(t, err)
+		actr.Nonce = 1
+		require.NoError(t, ob.HandleNewHead(ctx, provider.tipset, provider.tipset))
+		assert.Equal(t, 4, publisher.published)
+		assert.Equal(t, types.Uint64(3), publisher.message.Nonce)
+	})
+
+	t.Run("send batch larger than the message pool's limit is published as it is mined", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		addrs := address.NewForTestGetter()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		provider.Set(t, blk, sender, actr)
+
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, mpoolCfg)
+
+		size := int(mpoolCfg.MaxPendingPerSender) + 50
+		batch := make([]core.BatchMessage, size)
+		for i := range batch {
+			batch[i] = core.BatchMessage{To: addrs(), Value: types.ZeroAttoFIL, GasPrice: types.NewGasPrice(1)}
+		}
+		cids, err := ob.SendBatch(ctx, sender, batch)
+		require.NoError(t, err)
+		assert.Len(t, cids, size)
+		assert.Len(t, queue.List(sender), size)
+		assert.Equal(t, int(mpoolCfg.MaxPendingPerSender), publisher.published)
+
+		// Mine the first 50 messages.
+		for nonce := uint64(0); nonce < 50; nonce++ {
+			_, found, err := queue.RemoveNext(ctx, sender, nonce)
+			require.NoError(t, err)
+			require.True(t, found)
+		}
+		actr.Nonce = 50
+		require.NoError(t, ob.HandleNewHead(ctx, provider.tipset, provider.tipset))
+		assert.Equal(t, size, publisher.published)
+		assert.Equal(t, types.Uint64(size-1), publisher.message.Nonce)
+	})
+
+	t.Run("send batch requires the balance to cover the whole batch", func(t *testing.T) {
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		addrs := address.NewForTestGetter()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		actr, _ := account.NewActor(types.NewAttoFILFromFIL(5))
+		provider.Set(t, blk, sender, actr)
+
+		ob := core.NewOutbox(w, consensus.NewOutboundMessageValidator(), queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+
+		// Each message is affordable on its own, but not both.
+		batch := []core.BatchMessage{
+			{To: addrs(), Value: types.NewAttoFILFromFIL(3), GasPrice: types.NewGasPrice(1)},
+			{To: addrs(), Value: types.NewAttoFILFromFIL(3), GasPrice: types.NewGasPrice(1)},
+		}
+		_, err := ob.SendBatch(context.Background(), sender, batch)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid message 1")
+		assert.Empty(t, queue.List(sender))
+		assert.Nil(t, publisher.message)
+	})
+
+	t.Run("send batch only queues published messages", func(t *testing.T) {
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		addrs := address.NewForTestGetter()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{returnError: errors.New("publish failed")}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		provider.Set(t, blk, sender, actr)
+
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+
+		batch := []core.BatchMessage{{To: addrs(), Value: types.ZeroAttoFIL, GasPrice: types.NewGasPrice(1)}}
+		cids, err := ob.SendBatch(context.Background(), sender, batch)
+		require.Error(t, err)
+		assert.Empty(t, cids)
+		assert.Empty(t, queue.List(sender))
+	})
+
+	t.Run("replace re-signs and publishes a queued message", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := address.NewForTestGetter()()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		blk.Height = 1000
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		provider.Set(t, blk, sender, actr)
+
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+
+		c, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(1), types.NewGasUnits(10), "")
+		require.NoError(t, err)
+		original := publisher.message
+
+		replacement, err := ob.Replace(ctx, c, types.NewGasPrice(5))
+		require.NoError(t, err)
+		assert.NotEqual(t, c, replacement)
+
+		queued := queue.List(sender)
+		require.Len(t, queued, 1)
+		assert.Equal(t, publisher.message, queued[0].Msg)
+		assert.Equal(t, original.Nonce, publisher.message.Nonce)
+		assert.Equal(t, original.GasLimit, publisher.message.GasLimit)
+		assert.True(t, types.NewGasPrice(5).Equal(publisher.message.GasPrice))
+
+		_, err = ob.Replace(ctx, c, types.NewGasPrice(10))
+		assert.Error(t, err)
+
+		// A rejected replacement leaves the queued message alone.
+		publisher.returnError = errors.New("rejected")
+		_, err = ob.Replace(ctx, replacement, types.NewGasPrice(6))
+		assert.Error(t, err)
+		rc, err := queue.List(sender)[0].Msg.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, replacement, rc)
+	})
+
+	t.Run("submit publishes and tracks a message signed elsewhere", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := address.NewForTestGetter()()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		blk.Height = 1000
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		actr.Nonce = 42
+		provider.Set(t, blk, sender, actr)
+
+		// The outbox has no signer for the sender.
+		ob := core.NewOutbox(types.NewMockSigner(nil), nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+
+		nonce, err := ob.NextNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(42), nonce)
+
+		signed, err := types.NewSignedMessage(*types.NewMessage(sender, toAddr, nonce, types.ZeroAttoFIL, "", nil), w, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+
+		c, err := ob.Submit(ctx, signed)
+		require.NoError(t, err)
+		expected, err := signed.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, expected, c)
+		assert.Equal(t, signed, publisher.message)
+		assert.Equal(t, uint64(1000), publisher.height)
+
+		queued := queue.List(sender)
+		require.Len(t, queued, 1)
+		assert.Equal(t, signed, queued[0].Msg)
+
+		nonce, err = ob.NextNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(43), nonce)
+
+		// A message rejected by the network is not tracked.
+		publisher.returnError = errors.New("rejected")
+		signed, err = types.NewSignedMessage(*types.NewMessage(sender, toAddr, nonce, types.ZeroAttoFIL, "", nil), w, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		_, err = ob.Submit(ctx, signed)
+		assert.Error(t, err)
+		assert.Len(t, queue.List(sender), 1)
+	})
+
+	t.Run("load restores unmined messages", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := address.NewForTestGetter()()
+		ds := repo.NewInMemoryRepo().Datastore()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		blk.Height = 1000
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		actr.Nonce = 40
+		provider.Set(t, blk, sender, actr)
+
+		ob := core.NewOutbox(w, nullValidator{}, core.NewPersistentMessageQueue(ds), publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+		for i := 0; i < 4; i++ {
+			_, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), "")
+			require.NoError(t, err)
+		}
+
+		// Two of the messages are mined while the node is down.
+		actr.Nonce = 42
+		queue := core.NewPersistentMessageQueue(ds)
+		restarted := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+		require.NoError(t, restarted.Load(ctx))
+
+		queued := queue.List(sender)
+		require.Len(t, queued, 2)
+		assert.Equal(t, types.Uint64(42), queued[0].Msg.Nonce)
+		assert.Equal(t, types.Uint64(43), queued[1].Msg.Nonce)
+
+		// The next message follows the restored ones.
+		_, err := restarted.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), "")
+		require.NoError(t, err)
+		assert.Equal(t, types.Uint64(44), publisher.message.Nonce)
+	})
+
+	t.Run("re-broadcasts unmined messages", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := address.NewForTestGetter()()
+		queue := core.NewMessageQueue()
+		publisher := &mockPublisher{}
+		provider := &fakeProvider{}
+
+		blk := types.NewBlockForTest(nil, 1)
+		blk.Height = 1000
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		provider.Set(t, blk, sender, actr)
+
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
+		c, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), "")
+		require.NoError(t, err)
+
+		assertRebroadcast := func(height uint64, expected bool) {
+			publisher.message = nil
+			head := types.NewBlockForTest(nil, height)
+			head.Height = types.Uint64(height)
+			require.NoError(t, ob.HandleNewHead(ctx, provider.tipset, types.RequireNewTipSet(t, head)))
+			if !expected {
+				assert.Nil(t, publisher.message, "unexpected broadcast at height %d", height)
+				return
+			}
+			require.NotNil(t, publisher.message, "no broadcast at height %d", height)
+			assert.Equal(t, height, publisher.height)
+			pc, err := publisher.message.Cid()
+			require.NoError(t, err)
+			assert.Equal(t, c, pc)
+		}
+
+		assertRebroadcast(1000+core.OutboxRebroadcastRounds-1, false)
+		assertRebroadcast(1000+core.OutboxRebroadcastRounds, true)
+		assertRebroadcast(1000+core.OutboxRebroadcastRounds+1, false)
+		assertRebroadcast(1000+2*core.OutboxRebroadcastRounds, true)
+	})
+
 	t.Run("fails with non-account actor", func(t *testing.T) {
 		w, _ := types.NewMockSignersAndKeyInfo(1)
 		sender := w.Addresses[0]
@@ -127,7 +499,7 @@ func TestOutbox(t *testing.T) {
 		actr := storagemarket.NewActor() // Not an account actor
 		provider.Set(t, blk, sender, actr)
 
-		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider)
+		ob := core.NewOutbox(w, nullValidator{}, queue, publisher, nullPolicy{}, provider, provider, config.NewDefaultConfig().Mpool)
 
 		_, err := ob.Send(context.Background(), sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), "")
 		assert.Error(t, err)
@@ -135,16 +507,18 @@ func TestOutbox(t *testing.T) {
 	})
 }
 
-// A publisher which just stores the last message published.
+// A publisher which just stores the last message published and counts publications.
 type mockPublisher struct {
 	returnError error                // Error to be returned by Publish()
 	message     *types.SignedMessage // Message received by Publish()
 	height      uint64               // Height received by Publish()
+	published   int                  // Number of calls to Publish()
 }
 
 func (p *mockPublisher) Publish(ctx context.Context, message *types.SignedMessage, height uint64) error {
 	p.message = message
 	p.height = height
+	p.published++
 	return p.returnError
 }
 
@@ -2,6 +2,7 @@ package gengen
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	mrand "math/rand"
@@ -17,6 +18,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
 
+	"github.com/ipfs/go-block-format"
 	bserv "github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-car"
 	"github.com/ipfs/go-cid"
@@ -66,6 +68,30 @@ type GenesisCfg struct {
 
 	// ProofsMode affects sealing, sector packing, PoSt, etc. in the proofs library
 	ProofsMode types.ProofsMode
+
+	// Consensus is the consensus protocol of the network, "expected" if
+	// empty.  Under "authority" the miners above are the signer set.
+	Consensus string
+
+	// Checkpoints are tipsets trusted to be in the chain, which nodes
+	// initialized from the genesis car enforce.
+	Checkpoints []*CheckpointCfg
+}
+
+// CheckpointCfg is a tipset trusted to be in the chain at a height.
+type CheckpointCfg struct {
+	Height uint64
+	TipSet types.SortedCidSet
+}
+
+// NetworkParams are the parameters of a network, besides its genesis block,
+// that nodes initialized from its genesis car adopt.  GenGenesisCar writes
+// them as JSON in a raw block, the second root of the car.
+type NetworkParams struct {
+	// Consensus is the consensus protocol of the network, "expected" if
+	// empty.
+	Consensus   string `json:",omitempty"`
+	Checkpoints []*CheckpointCfg
 }
 
 // RenderedGenInfo contains information about a genesis block creation
@@ -78,6 +104,9 @@ type RenderedGenInfo struct {
 
 	// GenesisCid is the cid of the created genesis block
 	GenesisCid cid.Cid
+
+	// Consensus is the consensus protocol nodes must be initialized with
+	Consensus string
 }
 
 // RenderedMinerInfo contains info about a created miner
@@ -98,6 +127,22 @@ type RenderedMinerInfo struct {
 //
 // WARNING: Do not use maps in this code, they will make this code non deterministic.
 func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs blockstore.Blockstore, seed int64) (*RenderedGenInfo, error) {
+	protocol := cfg.Consensus
+	if protocol == "" {
+		protocol = consensus.ExpectedProtocol
+	}
+	if protocol != consensus.ExpectedProtocol && protocol != consensus.AuthorityProtocol {
+		return nil, fmt.Errorf("unknown consensus protocol %q", protocol)
+	}
+	if protocol == consensus.AuthorityProtocol && len(cfg.Miners) == 0 {
+		return nil, fmt.Errorf("authority consensus requires at least one miner")
+	}
+	for _, cp := range cfg.Checkpoints {
+		if cp.Height == 0 || cp.TipSet.Len() == 0 {
+			return nil, fmt.Errorf("checkpoints must have a height above 0 and a tipset")
+		}
+	}
+
 	pnrg := mrand.New(mrand.NewSource(seed))
 	keys, err := genKeys(cfg.Keys, pnrg)
 	if err != nil {
@@ -159,6 +204,7 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 		Keys:       keys,
 		GenesisCid: c,
 		Miners:     miners,
+		Consensus:  protocol,
 	}, nil
 }
 
@@ -316,8 +362,53 @@ func GenGenesisCar(cfg *GenesisCfg, out io.Writer, seed int64) (*RenderedGenInfo
 	if err != nil {
 		return nil, err
 	}
+	roots := []cid.Cid{info.GenesisCid}
+
+	// Cars of expected consensus networks without checkpoints keep the
+	// genesis block as only root, so that older nodes can load them.
+	if info.Consensus != consensus.ExpectedProtocol || len(cfg.Checkpoints) > 0 {
+		np := &NetworkParams{Checkpoints: cfg.Checkpoints}
+		if info.Consensus != consensus.ExpectedProtocol {
+			np.Consensus = info.Consensus
+		}
+		raw, err := json.Marshal(np)
+		if err != nil {
+			return nil, err
+		}
+		// A raw block, so that the car walk doesn't follow the checkpoints'
+		// cids to blocks it doesn't have.
+		hash, err := mh.Sum(raw, mh.SHA2_256, -1)
+		if err != nil {
+			return nil, err
+		}
+		params, err := blocks.NewBlockWithCid(raw, cid.NewCidV1(cid.Raw, hash))
+		if err != nil {
+			return nil, err
+		}
+		if err := bstore.Put(params); err != nil {
+			return nil, err
+		}
+		roots = append(roots, params.Cid())
+	}
+
+	return info, car.WriteCar(ctx, dserv, roots, out)
+}
 
-	return info, car.WriteCar(ctx, dserv, []cid.Cid{info.GenesisCid}, out)
+// LoadNetworkParams reads the network parameters of the genesis car with
+// the given roots from bs.  It returns nil if the car has none.
+func LoadNetworkParams(bs blockstore.Blockstore, roots []cid.Cid) (*NetworkParams, error) {
+	if len(roots) < 2 {
+		return nil, nil
+	}
+	blk, err := bs.Get(roots[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read network parameters")
+	}
+	var params NetworkParams
+	if err := json.Unmarshal(blk.RawData(), &params); err != nil {
+		return nil, errors.Wrap(err, "failed to decode network parameters")
+	}
+	return &params, nil
 }
 
 // applyMessageDirect applies a given message directly to the given state tree and storage map and returns the result of the message.
@@ -1,22 +1,26 @@
 package gengen_test
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"testing"
 
 	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-car"
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-hamt-ipld"
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-exchange-offline"
 
+	"github.com/filecoin-project/go-filecoin/consensus"
 	. "github.com/filecoin-project/go-filecoin/gengen/util"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testConfig = &GenesisCfg{
@@ -79,3 +83,50 @@ func TestGenGenDeterministicBetweenBuilds(t *testing.T) {
 		}
 	}
 }
+
+func TestGenGenNetworkParams(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := *testConfig
+	cfg.Checkpoints = []*CheckpointCfg{{
+		Height: 10,
+		TipSet: types.NewSortedCidSet(types.SomeCid()),
+	}}
+
+	var buf bytes.Buffer
+	_, err := GenGenesisCar(&cfg, &buf, 0)
+	require.NoError(t, err)
+
+	bstore := blockstore.NewBlockstore(ds.NewMapDatastore())
+	ch, err := car.LoadCar(bstore, &buf)
+	require.NoError(t, err)
+	require.Len(t, ch.Roots, 2)
+
+	params, err := LoadNetworkParams(bstore, ch.Roots)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Checkpoints, params.Checkpoints)
+	assert.Equal(t, "", params.Consensus)
+
+	// Authority networks carry their protocol.
+	cfg = *testConfig
+	cfg.Consensus = consensus.AuthorityProtocol
+	buf.Reset()
+	_, err = GenGenesisCar(&cfg, &buf, 0)
+	require.NoError(t, err)
+	ch, err = car.LoadCar(bstore, &buf)
+	require.NoError(t, err)
+	params, err = LoadNetworkParams(bstore, ch.Roots)
+	require.NoError(t, err)
+	assert.Equal(t, consensus.AuthorityProtocol, params.Consensus)
+	assert.Empty(t, params.Checkpoints)
+
+	// Cars without parameters have the genesis block as only root.
+	buf.Reset()
+	_, err = GenGenesisCar(testConfig, &buf, 0)
+	require.NoError(t, err)
+	ch, err = car.LoadCar(bstore, &buf)
+	require.NoError(t, err)
+	params, err = LoadNetworkParams(bstore, ch.Roots)
+	require.NoError(t, err)
+	assert.Nil(t, params)
+}
@@ -0,0 +1,100 @@
+package mining
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// SignerSchedule returns the signer allowed to mine at a height.
+type SignerSchedule interface {
+	Signer(h uint64) address.Address
+}
+
+// AuthorityWorker mines blocks under the proof-of-authority protocol.  It
+// mines a block only in the rounds its miner is scheduled for, without
+// election tickets or proofs, so block production is deterministic.
+type AuthorityWorker struct {
+	*DefaultWorker
+	schedule SignerSchedule
+}
+
+// NewAuthorityWorker wraps w so that it mines according to schedule.
+func NewAuthorityWorker(w *DefaultWorker, schedule SignerSchedule) *AuthorityWorker {
+	return &AuthorityWorker{DefaultWorker: w, schedule: schedule}
+}
+
+// Mine implements the Worker interface.  The returned bool indicates if this
+// miner created a new block or not.
+func (w *AuthorityWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCount int, outCh chan<- Output) bool {
+	if !base.Defined() {
+		log.Warning("AuthorityWorker.Mine returning because it can't mine on an empty tipset")
+		outCh <- Output{Err: errors.New("bad input tipset with no blocks sent to Mine()")}
+		return false
+	}
+
+	baseHeight, err := base.Height()
+	if err != nil {
+		outCh <- Output{Err: err}
+		return false
+	}
+	if w.schedule.Signer(baseHeight+uint64(nullBlkCount)+1) != w.minerAddr {
+		return false
+	}
+
+	challenge, err := consensus.CreateChallengeSeed(base, uint64(nullBlkCount))
+	if err != nil {
+		outCh <- Output{Err: err}
+		return false
+	}
+
+	// Wait out the block time so that signers take their turns at the
+	// network's pace.
+	select {
+	case <-ctx.Done():
+		log.Infof("Mining run on base %s with %d null blocks canceled.", base.String(), nullBlkCount)
+		return false
+	case <-time.After(w.api.BlockTime()):
+	}
+
+	// Authority blocks are not elected, so they carry no PoSt.  Their ticket
+	// is the challenge seed, which only seeds the challenge of the next
+	// round.
+	ticket := types.Signature(append([]byte{}, challenge[:]...))
+
+	next, err := w.Generate(ctx, base, ticket, nil, uint64(nullBlkCount))
+	if err == nil {
+		log.Debugf("AuthorityWorker.Mine generates new block! %s", next.Cid().String())
+	}
+	outCh <- NewOutput(next, err)
+	return true
+}
+
+// GenerateTemplate implements the TemplateGenerator interface.  It fails
+// unless the miner is scheduled to sign the block, and sets the block's
+// ticket the way Mine does so that completing the template only takes the
+// block signature.
+func (w *AuthorityWorker) GenerateTemplate(ctx context.Context, base types.TipSet, nullBlockCount uint64) (*types.Block, error) {
+	baseHeight, err := base.Height()
+	if err != nil {
+		return nil, errors.Wrap(err, "get base tip set height")
+	}
+	height := baseHeight + nullBlockCount + 1
+	if signer := w.schedule.Signer(height); signer != w.minerAddr {
+		return nil, errors.Errorf("miner %s is not scheduled to sign at height %d, %s is", w.minerAddr, height, signer)
+	}
+
+	challenge, err := consensus.CreateChallengeSeed(base, nullBlockCount)
+	if err != nil {
+		return nil, err
+	}
+	ticket := types.Signature(append([]byte{}, challenge[:]...))
+
+	next, _, err := w.generateUnsigned(ctx, base, ticket, nil, nullBlockCount)
+	return next, err
+}
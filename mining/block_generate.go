@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
 )
@@ -26,30 +27,90 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 		log.Infof("[TIMER] DefaultWorker.Generate baseTipset: %s - elapsed time: %s", baseTipSet.String(), time.Since(generateTimer).Round(time.Millisecond))
 	}()
 
+	next, res, err := w.generateUnsigned(ctx, baseTipSet, ticket, proof, nullBlockCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the height before signing so that the miner can never sign
+	// two blocks at the same height.
+	if w.minedHeights != nil {
+		if err := w.minedHeights.Record(w.minerAddr, uint64(next.Height)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := consensus.SignBlock(next, w.minerPubKey, w.workerSigner); err != nil {
+		return nil, errors.Wrap(err, "generate sign block")
+	}
+
+	for i, msg := range res.PermanentFailures {
+		// We will not be able to apply this message in the future because the error was permanent.
+		// Therefore, we will remove it from the MessagePool now.
+		// There might be better places to do this, such as wherever successful messages are removed
+		// from the pool, or by posting the failure to an event bus to be handled async.
+		log.Infof("permanent ApplyMessage failure, [%s] (%s)", msg, res.PermanentErrors[i])
+		mc, err := msg.Cid()
+		if err == nil {
+			w.messageSource.Remove(mc)
+		} else {
+			log.Warningf("failed to get CID from message", err)
+		}
+	}
+
+	for i, msg := range res.TemporaryFailures {
+		// We might be able to apply this message in the future because the error was temporary.
+		// Therefore, we will leave it in the MessagePool for now.
+
+		log.Infof("temporary ApplyMessage failure, [%s] (%s)", msg, res.TemporaryErrors[i])
+	}
+
+	return next, nil
+}
+
+// GenerateTemplate returns an unsigned candidate block on baseTipSet, holding
+// the messages selected from the pool along with the state root and receipts
+// they produce.  The template has no ticket, proof or signature for the
+// caller to fill in, and generating it neither records a mined height nor
+// removes messages from the pool.
+func (w *DefaultWorker) GenerateTemplate(ctx context.Context, baseTipSet types.TipSet, nullBlockCount uint64) (*types.Block, error) {
+	next, _, err := w.generateUnsigned(ctx, baseTipSet, nil, nil, nullBlockCount)
+	return next, err
+}
+
+// generateUnsigned creates a new block from the messages in the pool, without
+// signing it, and returns it with the result of applying its messages.
+func (w *DefaultWorker) generateUnsigned(ctx context.Context,
+	baseTipSet types.TipSet,
+	ticket types.Signature,
+	proof types.PoStProof,
+	nullBlockCount uint64) (*types.Block, consensus.ApplyMessagesResponse, error) {
+
+	var res consensus.ApplyMessagesResponse
 	stateTree, err := w.getStateTree(ctx, baseTipSet)
 	if err != nil {
-		return nil, errors.Wrap(err, "get state tree")
+		return nil, res, errors.Wrap(err, "get state tree")
 	}
 
 	if !w.powerTable.HasPower(ctx, stateTree, w.blockstore, w.minerAddr) {
-		return nil, errors.Errorf("bad miner address, miner must store files before mining: %s", w.minerAddr)
+		return nil, res, errors.Errorf("bad miner address, miner must store files before mining: %s", w.minerAddr)
 	}
 
 	weight, err := w.getWeight(ctx, baseTipSet)
 	if err != nil {
-		return nil, errors.Wrap(err, "get weight")
+		return nil, res, errors.Wrap(err, "get weight")
 	}
 
 	baseHeight, err := baseTipSet.Height()
 	if err != nil {
-		return nil, errors.Wrap(err, "get base tip set height")
+		return nil, res, errors.Wrap(err, "get base tip set height")
 	}
 
 	blockHeight := baseHeight + nullBlockCount + 1
 
 	ancestors, err := w.getAncestors(ctx, baseTipSet, types.NewBlockHeight(blockHeight))
 	if err != nil {
-		return nil, errors.Wrap(err, "get base tip set ancestors")
+		return nil, res, errors.Wrap(err, "get base tip set ancestors")
 	}
 
 	pending := w.messageSource.Pending()
@@ -57,18 +118,18 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 	messages := mq.Drain()
 
 	vms := vm.NewStorageMap(w.blockstore)
-	res, err := w.processor.ApplyMessagesAndPayRewards(ctx, stateTree, vms, messages, w.minerOwnerAddr, types.NewBlockHeight(blockHeight), ancestors)
+	res, err = w.processor.ApplyMessagesAndPayRewards(ctx, stateTree, vms, messages, w.minerOwnerAddr, types.NewBlockHeight(blockHeight), ancestors)
 	if err != nil {
-		return nil, errors.Wrap(err, "generate apply messages")
+		return nil, res, errors.Wrap(err, "generate apply messages")
 	}
 
 	newStateTreeCid, err := stateTree.Flush(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "generate flush state tree")
+		return nil, res, errors.Wrap(err, "generate flush state tree")
 	}
 
 	if err = vms.Flush(); err != nil {
-		return nil, errors.Wrap(err, "generate flush vm storage map")
+		return nil, res, errors.Wrap(err, "generate flush vm storage map")
 	}
 
 	var receipts []*types.MessageReceipt
@@ -76,10 +137,16 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 		receipts = append(receipts, r.Receipt)
 	}
 
+	blockMessages, blsAggregateSig, err := consensus.AggregateBLSMessages(res.SuccessfulMessages)
+	if err != nil {
+		return nil, res, errors.Wrap(err, "generate aggregate message signatures")
+	}
+
 	next := &types.Block{
 		Miner:           w.minerAddr,
 		Height:          types.Uint64(blockHeight),
-		Messages:        res.SuccessfulMessages,
+		Messages:        blockMessages,
+		BLSAggregateSig: blsAggregateSig,
 		MessageReceipts: receipts,
 		Parents:         baseTipSet.ToSortedCidSet(),
 		ParentWeight:    types.Uint64(weight),
@@ -90,26 +157,5 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 		Timestamp: types.Uint64(time.Now().Unix()),
 	}
 
-	for i, msg := range res.PermanentFailures {
-		// We will not be able to apply this message in the future because the error was permanent.
-		// Therefore, we will remove it from the MessagePool now.
-		// There might be better places to do this, such as wherever successful messages are removed
-		// from the pool, or by posting the failure to an event bus to be handled async.
-		log.Infof("permanent ApplyMessage failure, [%s] (%s)", msg, res.PermanentErrors[i])
-		mc, err := msg.Cid()
-		if err == nil {
-			w.messageSource.Remove(mc)
-		} else {
-			log.Warningf("failed to get CID from message", err)
-		}
-	}
-
-	for i, msg := range res.TemporaryFailures {
-		// We might be able to apply this message in the future because the error was temporary.
-		// Therefore, we will leave it in the MessagePool for now.
-
-		log.Infof("temporary ApplyMessage failure, [%s] (%s)", msg, res.TemporaryErrors[i])
-	}
-
-	return next, nil
+	return next, res, nil
 }
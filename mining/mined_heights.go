@@ -0,0 +1,82 @@
+package mining
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+// minedHeightsPrefix is the datastore prefix under which the last mined
+// height of each miner is stored.
+const minedHeightsPrefix = "/mining/lastheight"
+
+// ErrAlreadyMined is returned when asked to mine a block at or below the
+// height of the last block the miner mined.  Mining it could sign two blocks
+// at the same height, which is a consensus fault.
+var ErrAlreadyMined = errors.New("miner already mined a block at or above this height")
+
+// MinedHeights persists the height of the last block mined by each miner, so
+// that a worker never equivocates, even across restarts.
+type MinedHeights struct {
+	mu sync.Mutex
+	ds repo.Datastore
+}
+
+// NewMinedHeights returns MinedHeights persisted in ds.
+func NewMinedHeights(ds repo.Datastore) *MinedHeights {
+	return &MinedHeights{ds: ds}
+}
+
+// Last returns the height of the last block mined by miner, and false if it
+// has not mined any.
+func (mh *MinedHeights) Last(miner address.Address) (uint64, bool, error) {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+
+	return mh.last(miner)
+}
+
+// Record records that miner mines a block at height h.  It returns
+// ErrAlreadyMined if the miner already mined at h or above.
+func (mh *MinedHeights) Record(miner address.Address, h uint64) error {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+
+	last, ok, err := mh.last(miner)
+	if err != nil {
+		return err
+	}
+	if ok && h <= last {
+		return errors.Wrapf(ErrAlreadyMined, "miner %s mined at height %d, can't mine at %d", miner, last, h)
+	}
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, h)
+	if err := mh.ds.Put(minedHeightKey(miner), buf); err != nil {
+		return errors.Wrap(err, "failed to persist mined height")
+	}
+	return nil
+}
+
+func (mh *MinedHeights) last(miner address.Address) (uint64, bool, error) {
+	buf, err := mh.ds.Get(minedHeightKey(miner))
+	if err == datastore.ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to read mined height")
+	}
+	if len(buf) != 8 {
+		return 0, false, errors.Errorf("invalid mined height of %d bytes", len(buf))
+	}
+	return binary.BigEndian.Uint64(buf), true, nil
+}
+
+func minedHeightKey(miner address.Address) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{minedHeightsPrefix, miner.String()})
+}
@@ -0,0 +1,42 @@
+package mining_test
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/mining"
+	"github.com/filecoin-project/go-filecoin/repo"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestMinedHeights(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := repo.Datastore(datastore.NewMapDatastore())
+	addrGetter := address.NewForTestGetter()
+	minerA, minerB := addrGetter(), addrGetter()
+
+	heights := mining.NewMinedHeights(ds)
+	_, ok, err := heights.Last(minerA)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, heights.Record(minerA, 5))
+	assert.Equal(t, mining.ErrAlreadyMined, errors.Cause(heights.Record(minerA, 5)))
+	assert.Equal(t, mining.ErrAlreadyMined, errors.Cause(heights.Record(minerA, 4)))
+	require.NoError(t, heights.Record(minerB, 5))
+	require.NoError(t, heights.Record(minerA, 6))
+
+	// Heights survive a restart.
+	heights = mining.NewMinedHeights(ds)
+	last, ok, err := heights.Last(minerA)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(6), last)
+	assert.Equal(t, mining.ErrAlreadyMined, errors.Cause(heights.Record(minerA, 6)))
+}
@@ -0,0 +1,42 @@
+package mining
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// MultiWorker mines on behalf of several miners.  Each round it runs the
+// worker of every miner on the same base, so that each miner tries its own
+// election ticket, and forwards every block they mine.
+type MultiWorker struct {
+	workers []Worker
+}
+
+// NewMultiWorker returns a worker that runs workers concurrently each round.
+func NewMultiWorker(workers ...Worker) *MultiWorker {
+	return &MultiWorker{workers: workers}
+}
+
+// Mine implements the Worker interface.  The returned bool indicates if any
+// of the miners created a new block.
+func (w *MultiWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCount int, outCh chan<- Output) bool {
+	var wg sync.WaitGroup
+	won := make([]bool, len(w.workers))
+	for i, worker := range w.workers {
+		wg.Add(1)
+		go func(i int, worker Worker) {
+			defer wg.Done()
+			won[i] = worker.Mine(ctx, base, nullBlkCount, outCh)
+		}(i, worker)
+	}
+	wg.Wait()
+
+	for _, ok := range won {
+		if ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package mining
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMultiWorkerMinesForEachMiner(t *testing.T) {
+	tf.UnitTest(t)
+
+	ts := newTestUtils(t)
+	loser := NewTestWorkerWithDeps(func(context.Context, types.TipSet, int, chan<- Output) bool {
+		return false
+	})
+
+	t.Run("forwards the blocks of every winning miner", func(t *testing.T) {
+		outCh := make(chan Output, 3)
+		w := NewMultiWorker(NewTestWorkerWithDeps(MakeEchoMine(t)), loser, NewTestWorkerWithDeps(MakeEchoMine(t)))
+
+		assert.True(t, w.Mine(context.Background(), ts, 0, outCh))
+		assert.Equal(t, 2, len(outCh))
+	})
+
+	t.Run("reports no block when every miner loses", func(t *testing.T) {
+		outCh := make(chan Output, 2)
+		w := NewMultiWorker(loser, loser)
+
+		assert.False(t, w.Mine(context.Background(), ts, 0, outCh))
+		assert.Equal(t, 0, len(outCh))
+	})
+}
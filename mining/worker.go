@@ -16,6 +16,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
@@ -42,6 +43,12 @@ type Worker interface {
 	Mine(runCtx context.Context, base types.TipSet, nullBlkCount int, outCh chan<- Output) bool
 }
 
+// TemplateGenerator generates unsigned candidate blocks for external block
+// producers to complete.
+type TemplateGenerator interface {
+	GenerateTemplate(ctx context.Context, base types.TipSet, nullBlockCount uint64) (*types.Block, error)
+}
+
 // GetStateTree is a function that gets the aggregate state tree of a TipSet. It's
 // its own function to facilitate testing.
 type GetStateTree func(context.Context, types.TipSet) (state.Tree, error)
@@ -68,6 +75,12 @@ type MessageApplier interface {
 	ApplyMessagesAndPayRewards(ctx context.Context, st state.Tree, vms vm.StorageMap, messages []*types.SignedMessage, minerOwnerAddr address.Address, bh *types.BlockHeight, ancestors []types.TipSet) (consensus.ApplyMessagesResponse, error)
 }
 
+// PoStGenerator generates proofs-of-spacetime over the miner's sealed
+// sectors.  It is implemented by the sector builder.
+type PoStGenerator interface {
+	GeneratePoSt(sectorbuilder.GeneratePoStRequest) (sectorbuilder.GeneratePoStResponse, error)
+}
+
 type workerPorcelainAPI interface {
 	BlockTime() time.Duration
 }
@@ -77,6 +90,8 @@ type DefaultWorker struct {
 	api workerPorcelainAPI
 
 	createPoSTFunc DoSomeWorkFunc
+	postGenerator  PoStGenerator
+	minedHeights   *MinedHeights
 	minerAddr      address.Address
 	minerOwnerAddr address.Address
 	minerPubKey    []byte
@@ -108,6 +123,8 @@ func NewDefaultWorker(messageSource MessageSource,
 	minerOwner address.Address,
 	minerPubKey []byte,
 	workerSigner consensus.TicketSigner,
+	postGenerator PoStGenerator,
+	minedHeights *MinedHeights,
 	api workerPorcelainAPI) *DefaultWorker {
 
 	w := NewDefaultWorkerWithDeps(messageSource,
@@ -125,9 +142,11 @@ func NewDefaultWorker(messageSource MessageSource,
 		api,
 		func() {})
 
-	// TODO: create real PoST.
-	// https://github.com/filecoin-project/go-filecoin/issues/1791
+	// Proofs are held back until the block time has passed, so that fast
+	// proofs don't speed up the rounds.
 	w.createPoSTFunc = w.fakeCreatePoST
+	w.postGenerator = postGenerator
+	w.minedHeights = minedHeights
 
 	return w
 }
@@ -200,7 +219,12 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 		outCh <- Output{Err: err}
 		return false
 	}
-	prCh := createProof(challenge, w.createPoSTFunc)
+	prCh, err := w.createElectionProof(ctx, st, challenge)
+	if err != nil {
+		log.Errorf("Worker.Mine couldn't create election proof: %s", err.Error())
+		outCh <- Output{Err: err}
+		return false
+	}
 
 	var proof types.PoStProof
 	var ticket []byte
@@ -213,7 +237,12 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 			log.Errorf("Worker.Mine got zero value from channel prChRead")
 			return false
 		}
-		proof := append(types.PoStProof{}, prChRead[:]...)
+		if prChRead.err != nil {
+			log.Errorf("Worker.Mine couldn't generate election PoSt: %s", prChRead.err.Error())
+			outCh <- Output{Err: prChRead.err}
+			return false
+		}
+		proof = prChRead.proof
 		ticket, err = consensus.CreateTicket(proof, w.minerPubKey, w.workerSigner)
 		if err != nil {
 			log.Errorf("failed to create ticket: %s", err)
@@ -221,8 +250,6 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 		}
 	}
 
-	// TODO: Test the interplay of isWinningTicket() and createPoSTFunc()
-	// https://github.com/filecoin-project/go-filecoin/issues/1791
 	weHaveAWinner, err := consensus.IsWinningTicket(ctx, w.blockstore, w.powerTable, st, ticket, w.minerAddr)
 
 	if err != nil {
@@ -244,13 +271,66 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 	return false
 }
 
-// TODO: Actually use the results of the PoST once it is implemented.
-// Currently createProof just passes the challenge seed through.
+// electionProof is the result of generating an election PoSt.
+type electionProof struct {
+	proof types.PoStProof
+	err   error
+}
+
+// createElectionProof generates the election PoSt for challenge over the
+// sectors the miner has committed in st.  Bootstrap miners and workers without
+// a PoStGenerator fall back to createProof, whose proof is the challenge seed
+// itself.  Either way the proof is not delivered before createPoSTFunc
+// returns, so that rounds last the block time however fast the proof is.
+func (w *DefaultWorker) createElectionProof(ctx context.Context, st state.Tree, challenge types.PoStChallengeSeed) (<-chan electionProof, error) {
+	c := make(chan electionProof, 1)
+	if w.postGenerator != nil {
+		commRs, bootstrap, err := consensus.MinerElectionSectors(ctx, w.blockstore, st, w.minerAddr)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get miner sectors")
+		}
+		if !bootstrap {
+			// Consensus rejects a PoSt over no sectors.
+			if len(commRs.Values()) == 0 {
+				return nil, errors.Errorf("miner %s has no committed sectors to prove", w.minerAddr)
+			}
+			go func() {
+				paced := make(chan struct{})
+				go func() {
+					w.createPoSTFunc()
+					close(paced)
+				}()
+				res, err := w.postGenerator.GeneratePoSt(sectorbuilder.GeneratePoStRequest{
+					SortedCommRs:  commRs,
+					ChallengeSeed: challenge,
+				})
+				<-paced
+				if err != nil {
+					c <- electionProof{err: errors.Wrap(err, "failed to generate PoSt")}
+					return
+				}
+				if len(res.Proofs) == 0 {
+					c <- electionProof{err: errors.New("sector builder generated no PoSt proofs")}
+					return
+				}
+				c <- electionProof{proof: res.Proofs[0]}
+			}()
+			return c, nil
+		}
+	}
+
+	go func() {
+		seed := <-createProof(challenge, w.createPoSTFunc)
+		c <- electionProof{proof: append(types.PoStProof{}, seed[:]...)}
+	}()
+	return c, nil
+}
+
+// createProof waits on createPoST and then passes the challenge seed through.
+// It stands in for the PoSt when there are no replicas to prove.
 func createProof(challengeSeed types.PoStChallengeSeed, createPoST DoSomeWorkFunc) <-chan types.PoStChallengeSeed {
 	c := make(chan types.PoStChallengeSeed)
 	go func() {
-		// TODO send new PoST on channel once we can create it
-		//  https://github.com/filecoin-project/go-filecoin/issues/1791
 		createPoST()
 		c <- challengeSeed
 	}()
@@ -258,7 +338,7 @@ func createProof(challengeSeed types.PoStChallengeSeed, createPoST DoSomeWorkFun
 }
 
 // fakeCreatePoST is the default implementation of DoSomeWorkFunc.
-// It simply sleeps for the blockTime.
+// It simply sleeps for the blockTime, which paces the mining rounds.
 func (w *DefaultWorker) fakeCreatePoST() {
 	time.Sleep(w.api.BlockTime())
 }
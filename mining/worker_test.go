@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
@@ -14,15 +15,18 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/mining"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/state"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
 )
 
 func Test_Mine(t *testing.T) {
@@ -96,6 +100,99 @@ func Test_Mine(t *testing.T) {
 	})
 }
 
+func TestMineGeneratesElectionPoSt(t *testing.T) {
+	tf.UnitTest(t)
+
+	mockSigner, blockSignerAddr := setupSigner()
+	cst, pool, fakeActorCodeCid := sharedSetupInitial()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := vm.NewStorageMap(bs)
+
+	minerAddr, minerOwnerAddr := mockSigner.Addresses[3], mockSigner.Addresses[4]
+	commR := types.CommR{1, 2, 3}
+	minerAct := actor.NewActor(types.MinerActorCodeCid, types.NewAttoFILFromFIL(10000))
+	minerState := miner.NewState(minerOwnerAddr, []byte{}, th.RequireRandomPeerID(t), types.OneKiBSectorSize)
+	minerState.SectorCommitments["0"] = types.Commitments{CommR: commR}
+	storage := vms.NewStorage(minerAddr, minerAct)
+	require.NoError(t, (&miner.Actor{}).InitializeState(storage, minerState))
+	require.NoError(t, storage.Flush())
+
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewFakeActorWithTokens(t, vms, mockSigner.Addresses[2], fakeActorCodeCid, types.NewAttoFILFromFIL(1000000)),
+		minerAddr:              minerAct,
+		minerOwnerAddr:         th.RequireNewFakeActor(t, vms, minerOwnerAddr, fakeActorCodeCid),
+	})
+	getStateTree := func(c context.Context, ts types.TipSet) (state.Tree, error) {
+		return st, nil
+	}
+	getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
+		return nil, nil
+	}
+
+	tipSet := th.RequireNewTipSet(t, &types.Block{Height: 2, StateRoot: types.SomeCid()})
+	generator := &testPoStGenerator{proof: th.MakeRandomPoStProofForTest()}
+	worker := mining.NewDefaultWorker(pool, getStateTree, getWeightTest, getAncestors, th.NewTestProcessor(),
+		mining.NewTestPowerTableView(1), bs, cst, minerAddr, minerOwnerAddr, blockSignerAddr, &mockSigner,
+		generator, nil, th.NewDefaultTestWorkerPorcelainAPI())
+
+	outCh := make(chan mining.Output)
+	start := time.Now()
+	go worker.Mine(context.Background(), tipSet, 0, outCh)
+	r := <-outCh
+	require.NoError(t, r.Err)
+	// The proof is generated instantly, but the round still lasts the block time.
+	assert.True(t, time.Since(start) >= th.BlockTimeTest)
+
+	challenge, err := consensus.CreateChallengeSeed(tipSet, 0)
+	require.NoError(t, err)
+	assert.Equal(t, challenge, generator.req.ChallengeSeed)
+	assert.Equal(t, []types.CommR{commR}, generator.req.SortedCommRs.Values())
+	assert.Equal(t, generator.proof, r.NewBlock.Proof)
+}
+
+func TestMineFailsWithoutSectors(t *testing.T) {
+	tf.UnitTest(t)
+
+	mockSigner, blockSignerAddr := setupSigner()
+	cst, pool, fakeActorCodeCid := sharedSetupInitial()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := vm.NewStorageMap(bs)
+
+	minerAddr, minerOwnerAddr := mockSigner.Addresses[3], mockSigner.Addresses[4]
+	minerAct := th.RequireNewMinerActor(t, vms, minerAddr, minerOwnerAddr, []byte{}, 10, th.RequireRandomPeerID(t), types.NewAttoFILFromFIL(10000))
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		address.NetworkAddress: th.RequireNewFakeActorWithTokens(t, vms, mockSigner.Addresses[2], fakeActorCodeCid, types.NewAttoFILFromFIL(1000000)),
+		minerAddr:              minerAct,
+		minerOwnerAddr:         th.RequireNewFakeActor(t, vms, minerOwnerAddr, fakeActorCodeCid),
+	})
+	getStateTree := func(c context.Context, ts types.TipSet) (state.Tree, error) {
+		return st, nil
+	}
+
+	tipSet := th.RequireNewTipSet(t, &types.Block{Height: 2, StateRoot: types.SomeCid()})
+	generator := &testPoStGenerator{proof: th.MakeRandomPoStProofForTest()}
+	worker := mining.NewDefaultWorker(pool, getStateTree, getWeightTest, nil, th.NewTestProcessor(),
+		mining.NewTestPowerTableView(1), bs, cst, minerAddr, minerOwnerAddr, blockSignerAddr, &mockSigner,
+		generator, nil, th.NewDefaultTestWorkerPorcelainAPI())
+
+	outCh := make(chan mining.Output, 1)
+	assert.False(t, worker.Mine(context.Background(), tipSet, 0, outCh))
+	r := <-outCh
+	assert.Contains(t, r.Err.Error(), "no committed sectors")
+	assert.Nil(t, generator.req.SortedCommRs.Values())
+}
+
+// testPoStGenerator records the request it is called with and returns proof.
+type testPoStGenerator struct {
+	req   sectorbuilder.GeneratePoStRequest
+	proof types.PoStProof
+}
+
+func (g *testPoStGenerator) GeneratePoSt(req sectorbuilder.GeneratePoStRequest) (sectorbuilder.GeneratePoStResponse, error) {
+	g.req = req
+	return sectorbuilder.GeneratePoStResponse{Proofs: []types.PoStProof{g.proof}}, nil
+}
+
 func sharedSetupInitial() (*hamt.CborIpldStore, *core.MessagePool, cid.Cid) {
 	cst := hamt.NewCborStore()
 	pool := core.NewMessagePool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
@@ -235,6 +332,10 @@ func TestGenerateMultiBlockTipSet(t *testing.T) {
 	assert.Len(t, blk.Messages, 0)
 	assert.Equal(t, types.Uint64(101), blk.Height)
 	assert.Equal(t, types.Uint64(1020), blk.ParentWeight)
+
+	workerAddr, err := address.NewSecp256k1Address(blockSignerAddr)
+	require.NoError(t, err)
+	assert.True(t, types.IsValidSignature(blk.SignatureData(), workerAddr, blk.BlockSig))
 }
 
 // After calling Generate, do the new block and new state of the message pool conform to our expectations?
@@ -317,6 +418,182 @@ func TestGeneratePoolBlockResults(t *testing.T) {
 	assert.Len(t, blk.Messages, 1) // This is the good message
 }
 
+func TestGenerateAggregatesBLSSignatures(t *testing.T) {
+	tf.UnitTest(t)
+
+	CreatePoSTFunc := func() {}
+
+	ctx := context.Background()
+	mockSigner, blockSignerAddr := setupSigner()
+	blsSigner := types.NewMockSigner(types.MustGenerateBLSKeyInfo(1))
+	newCid := types.NewCidForTestGetter()
+	st, pool, addrs, cst, bs := sharedSetup(t, mockSigner)
+
+	blsAddr := blsSigner.Addresses[0]
+	require.NoError(t, st.SetActor(ctx, blsAddr, th.RequireNewAccountActor(t, types.NewAttoFILFromFIL(100))))
+
+	getStateTree := func(c context.Context, ts types.TipSet) (state.Tree, error) {
+		return st, nil
+	}
+	getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
+		return nil, nil
+	}
+	worker := mining.NewDefaultWorkerWithDeps(pool, getStateTree, getWeightTest, getAncestors, consensus.NewDefaultProcessor(),
+		&th.TestView{}, bs, cst, addrs[4], addrs[3], blockSignerAddr, mockSigner, th.NewDefaultTestWorkerPorcelainAPI(), CreatePoSTFunc)
+
+	msg1 := types.NewMessage(blsAddr, addrs[0], 0, types.ZeroAttoFIL, "", nil)
+	smsg1, err := types.NewSignedMessage(*msg1, blsSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	msg2 := types.NewMessage(addrs[0], addrs[1], 0, types.ZeroAttoFIL, "", nil)
+	smsg2, err := types.NewSignedMessage(*msg2, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+
+	c1, err := pool.Add(ctx, smsg1, 0)
+	require.NoError(t, err)
+	_, err = pool.Add(ctx, smsg2, 0)
+	require.NoError(t, err)
+
+	stateRoot, err := st.Flush(ctx)
+	require.NoError(t, err)
+
+	baseBlock := types.Block{
+		Parents:   types.NewSortedCidSet(newCid()),
+		Height:    types.Uint64(100),
+		StateRoot: stateRoot,
+		Proof:     types.PoStProof{},
+	}
+	blk, err := worker.Generate(ctx, th.RequireNewTipSet(t, &baseBlock), nil, types.PoStProof{}, 0)
+	require.NoError(t, err)
+	require.Len(t, blk.Messages, 2)
+
+	for _, msg := range blk.Messages {
+		if msg.From == blsAddr {
+			assert.Empty(t, msg.Signature)
+			mc, err := msg.Cid()
+			require.NoError(t, err)
+			assert.True(t, c1.Equals(mc))
+		} else {
+			assert.Equal(t, smsg2.Signature, msg.Signature)
+		}
+	}
+	assert.NotEmpty(t, blk.BLSAggregateSig)
+	assert.NoError(t, consensus.VerifyBLSAggregate(blk.Messages, blk.BLSAggregateSig))
+}
+
+func TestGenerateTemplate(t *testing.T) {
+	tf.UnitTest(t)
+
+	CreatePoSTFunc := func() {}
+
+	ctx := context.Background()
+	mockSigner, blockSignerAddr := setupSigner()
+	newCid := types.NewCidForTestGetter()
+	st, pool, addrs, cst, bs := sharedSetup(t, mockSigner)
+
+	getStateTree := func(c context.Context, ts types.TipSet) (state.Tree, error) {
+		return st, nil
+	}
+	getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
+		return nil, nil
+	}
+	minerAddr := addrs[4]
+	worker := mining.NewDefaultWorkerWithDeps(pool, getStateTree, getWeightTest, getAncestors, consensus.NewDefaultProcessor(),
+		&th.TestView{}, bs, cst, minerAddr, addrs[3], blockSignerAddr, mockSigner, th.NewDefaultTestWorkerPorcelainAPI(), CreatePoSTFunc)
+
+	// The first message applies, the second has a nonce that is too low.
+	msg1 := types.NewMessage(addrs[0], addrs[1], 0, types.ZeroAttoFIL, "", nil)
+	smsg1, err := types.NewSignedMessage(*msg1, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	msg2 := types.NewMessage(addrs[1], addrs[0], 0, types.ZeroAttoFIL, "", nil)
+	smsg2, err := types.NewSignedMessage(*msg2, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	_, err = pool.Add(ctx, smsg1, 0)
+	require.NoError(t, err)
+	_, err = pool.Add(ctx, smsg2, 0)
+	require.NoError(t, err)
+
+	act, err := st.GetActor(ctx, addrs[1])
+	require.NoError(t, err)
+	act.Nonce = types.Uint64(1)
+	require.NoError(t, st.SetActor(ctx, addrs[1], act))
+	stateRoot, err := st.Flush(ctx)
+	require.NoError(t, err)
+
+	baseBlock := types.Block{
+		Parents:   types.NewSortedCidSet(newCid()),
+		Height:    types.Uint64(100),
+		StateRoot: stateRoot,
+	}
+	baseTipSet := th.RequireNewTipSet(t, &baseBlock)
+	blk, err := worker.GenerateTemplate(ctx, baseTipSet, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, minerAddr, blk.Miner)
+	assert.Equal(t, types.Uint64(101), blk.Height)
+	assert.Equal(t, baseTipSet.ToSortedCidSet(), blk.Parents)
+	assert.Equal(t, []*types.SignedMessage{smsg1}, blk.Messages)
+	assert.Len(t, blk.MessageReceipts, 1)
+	assert.True(t, blk.StateRoot.Defined())
+	assert.Empty(t, blk.Ticket)
+	assert.Empty(t, blk.BlockSig)
+
+	// Templates don't prune the pool.
+	assert.Len(t, pool.Pending(), 2)
+}
+
+type fixedSchedule struct {
+	signer address.Address
+}
+
+func (s fixedSchedule) Signer(h uint64) address.Address {
+	return s.signer
+}
+
+func TestAuthorityGenerateTemplate(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mockSigner, blockSignerAddr := setupSigner()
+	newCid := types.NewCidForTestGetter()
+	st, pool, addrs, cst, bs := sharedSetup(t, mockSigner)
+
+	getStateTree := func(c context.Context, ts types.TipSet) (state.Tree, error) {
+		return st, nil
+	}
+	getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
+		return nil, nil
+	}
+	minerAddr := addrs[4]
+	worker := mining.NewDefaultWorkerWithDeps(pool, getStateTree, getWeightTest, getAncestors, consensus.NewDefaultProcessor(),
+		&th.TestView{}, bs, cst, minerAddr, addrs[3], blockSignerAddr, mockSigner, th.NewDefaultTestWorkerPorcelainAPI(), func() {})
+
+	stateRoot, err := st.Flush(ctx)
+	require.NoError(t, err)
+	baseBlock := types.Block{
+		Parents:   types.NewSortedCidSet(newCid()),
+		Height:    types.Uint64(100),
+		StateRoot: stateRoot,
+	}
+	baseTipSet := th.RequireNewTipSet(t, &baseBlock)
+
+	t.Run("sets the challenge seed as ticket", func(t *testing.T) {
+		blk, err := mining.NewAuthorityWorker(worker, fixedSchedule{minerAddr}).GenerateTemplate(ctx, baseTipSet, 0)
+		require.NoError(t, err)
+
+		challenge, err := consensus.CreateChallengeSeed(baseTipSet, 0)
+		require.NoError(t, err)
+		assert.Equal(t, types.Signature(challenge[:]), blk.Ticket)
+		assert.Empty(t, blk.Proof)
+		assert.Empty(t, blk.BlockSig)
+	})
+
+	t.Run("fails when another miner is scheduled", func(t *testing.T) {
+		_, err := mining.NewAuthorityWorker(worker, fixedSchedule{addrs[0]}).GenerateTemplate(ctx, baseTipSet, 0)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not scheduled to sign")
+	})
+}
+
 func TestGenerateSetsBasicFields(t *testing.T) {
 	tf.UnitTest(t)
 
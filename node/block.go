@@ -2,10 +2,13 @@ package node
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/metrics/tracing"
 	"github.com/filecoin-project/go-filecoin/net/pubsub"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -14,6 +17,9 @@ import (
 // BlockTopic is the pubsub topic identifier on which new blocks are announced.
 const BlockTopic = "/fil/blocks"
 
+// TODO: pick the gas price of consensus fault reports from a query.
+const consensusFaultGasPrice = 1
+
 // AddNewBlock receives a newly mined block and stores, validates and propagates it to the network.
 func (node *Node) AddNewBlock(ctx context.Context, b *types.Block) (err error) {
 	ctx, span := trace.StartSpan(ctx, "Node.AddNewBlock")
@@ -60,10 +66,38 @@ func (node *Node) processBlock(ctx context.Context, pubSubMsg pubsub.Message) (e
 	// Don't be too quick to change that, though: the syncer re-fetching the block
 	// is currently critical to reliable validation.
 	// See https://github.com/filecoin-project/go-filecoin/issues/2962
-	err = node.Syncer.HandleNewTipset(ctx, types.NewSortedCidSet(blk.Cid()))
+	err = node.Syncer.HandleNewTipsetFromPeer(ctx, pubSubMsg.GetFrom(), types.NewSortedCidSet(blk.Cid()))
 	if err != nil {
 		return errors.Wrap(err, "processing block from network")
 	}
 
 	return nil
 }
+
+// reportConsensusFault submits fault to the storage market from the node's
+// default address, which is paid a reward once the offending miner is
+// slashed.  It is called by the syncer, when mining.reportConsensusFaults is
+// set, so it does not wait for the message to be sent.
+func (node *Node) reportConsensusFault(fault chain.ConsensusFault) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		msgCid, err := node.PorcelainAPI.MessageSendWithDefaultAddress(
+			ctx,
+			address.Undef,
+			address.StorageMarketAddress,
+			types.ZeroAttoFIL,
+			types.NewGasPrice(consensusFaultGasPrice),
+			types.NewGasUnits(0), // estimated
+			"slashConsensusFault",
+			fault.BlockA.ToNode().RawData(),
+			fault.BlockB.ToNode().RawData(),
+		)
+		if err != nil {
+			log.Errorf("failed to report consensus fault of miner %s: %s", fault.BlockA.Miner, err)
+			return
+		}
+		log.Infof("reported consensus fault of miner %s in message %s", fault.BlockA.Miner, msgCid)
+	}()
+}
@@ -70,6 +70,9 @@ func TestBlockPropsManyNodes(t *testing.T) {
 		Proof:        proof,
 		Ticket:       ticket,
 	}
+	minerPubKey, err := minerNode.PorcelainAPI.MinerGetKey(ctx, minerAddr)
+	require.NoError(t, err)
+	testhelpers.RequireSignBlock(t, nextBlk, minerPubKey, minerNode.Wallet)
 
 	// Wait for network connection notifications to propagate
 	time.Sleep(time.Millisecond * 300)
@@ -106,13 +109,14 @@ func TestChainSync(t *testing.T) {
 	require.NoError(t, err)
 	baseTS := headTipSet
 
-	signer, ki := types.NewMockSignersAndKeyInfo(1)
-	mockSignerPubKey := ki[0].PublicKey()
+	// Blocks are signed with the miner's worker key, held by the miner node.
+	minerPubKey, err := nodes[0].PorcelainAPI.MinerGetKey(ctx, minerAddr)
+	require.NoError(t, err)
 	stateRoot := baseTS.ToSlice()[0].StateRoot
 
-	nextBlk1 := testhelpers.NewValidTestBlockFromTipSet(baseTS, stateRoot, 1, minerAddr, mockSignerPubKey, signer)
-	nextBlk2 := testhelpers.NewValidTestBlockFromTipSet(baseTS, stateRoot, 2, minerAddr, mockSignerPubKey, signer)
-	nextBlk3 := testhelpers.NewValidTestBlockFromTipSet(baseTS, stateRoot, 3, minerAddr, mockSignerPubKey, signer)
+	nextBlk1 := testhelpers.RequireNewValidTestBlockFromTipSet(t, baseTS, stateRoot, 1, minerAddr, minerPubKey, nodes[0].Wallet)
+	nextBlk2 := testhelpers.RequireNewValidTestBlockFromTipSet(t, baseTS, stateRoot, 2, minerAddr, minerPubKey, nodes[0].Wallet)
+	nextBlk3 := testhelpers.RequireNewValidTestBlockFromTipSet(t, baseTS, stateRoot, 3, minerAddr, minerPubKey, nodes[0].Wallet)
 
 	assert.NoError(t, nodes[0].AddNewBlock(ctx, nextBlk1))
 	assert.NoError(t, nodes[0].AddNewBlock(ctx, nextBlk2))
@@ -153,7 +157,7 @@ func makeNodes(t *testing.T, numNodes int) (address.Address, []*Node) {
 	)
 	seed.GiveKey(t, minerNode, 0)
 	mineraddr, minerOwnerAddr := seed.GiveMiner(t, minerNode, 0)
-	_, err := storage.NewMiner(mineraddr, minerOwnerAddr, minerNode, minerNode.Repo.DealsDatastore(), minerNode.PorcelainAPI)
+	_, err := storage.NewMiner(mineraddr, minerOwnerAddr, minerNode.SectorBuilder(), minerNode, minerNode.Repo.DealsDatastore(), minerNode.PorcelainAPI)
 	assert.NoError(t, err)
 
 	nodes := []*Node{minerNode}
@@ -24,6 +24,7 @@ type InitCfg struct {
 	PeerKey                 ci.PrivKey
 	DefaultWalletAddress    address.Address
 	AutoSealIntervalSeconds uint
+	WalletPassphrase        []byte
 }
 
 // InitOpt is an init option function
@@ -44,6 +45,14 @@ func DefaultWalletAddressOpt(addr address.Address) InitOpt {
 	}
 }
 
+// WalletPassphraseOpt returns a config option that sets the passphrase the wallet's keys are
+// encrypted with.
+func WalletPassphraseOpt(passphrase []byte) InitOpt {
+	return func(c *InitCfg) {
+		c.WalletPassphrase = passphrase
+	}
+}
+
 // AutoSealIntervalSecondsOpt configures the daemon to check for and seal any staged sectors on an interval.
 func AutoSealIntervalSecondsOpt(autoSealIntervalSeconds uint) InitOpt {
 	return func(c *InitCfg) {
@@ -79,6 +88,11 @@ func Init(ctx context.Context, r repo.Repo, gen consensus.GenesisInitFunc, opts
 		return errors.Wrap(err, "failed to store private key")
 	}
 
+	backend, err := wallet.NewDSBackend(r.WalletDatastore())
+	if err != nil {
+		return errors.Wrap(err, "failed to set up wallet backend")
+	}
+
 	newConfig := r.Config()
 
 	newConfig.Mining.AutoSealIntervalSeconds = cfg.AutoSealIntervalSeconds
@@ -87,7 +101,7 @@ func Init(ctx context.Context, r repo.Repo, gen consensus.GenesisInitFunc, opts
 		newConfig.Wallet.DefaultAddress = cfg.DefaultWalletAddress
 	} else if r.Config().Wallet.DefaultAddress == (address.Undef) {
 		// TODO: but behind a config option if this should be generated
-		addr, err := newAddress(r)
+		addr, err := backend.NewAddress(address.SECP256K1)
 		if err != nil {
 			return errors.Wrap(err, "failed to generate default address")
 		}
@@ -99,6 +113,12 @@ func Init(ctx context.Context, r repo.Repo, gen consensus.GenesisInitFunc, opts
 		return errors.Wrap(err, "failed to update config with new values")
 	}
 
+	if len(cfg.WalletPassphrase) > 0 {
+		if err := backend.ChangePassphrase(cfg.WalletPassphrase); err != nil {
+			return errors.Wrap(err, "failed to encrypt wallet")
+		}
+	}
+
 	return nil
 }
 
@@ -117,19 +137,3 @@ func makePrivateKey(nbits int) (ci.PrivKey, error) {
 
 	return sk, nil
 }
-
-// newAddress creates a new private-public keypair in the default wallet
-// and returns the address for it.
-func newAddress(r repo.Repo) (address.Address, error) {
-	backend, err := wallet.NewDSBackend(r.WalletDatastore())
-	if err != nil {
-		return address.Undef, errors.Wrap(err, "failed to set up wallet backend")
-	}
-
-	addr, err := backend.NewAddress()
-	if err != nil {
-		return address.Undef, errors.Wrap(err, "failed to create address")
-	}
-
-	return addr, err
-}
@@ -72,7 +72,7 @@ func TestMessagePropagation(t *testing.T) {
 			address.NetworkAddress,
 			types.NewAttoFILFromFIL(1),
 			types.NewGasPrice(1),
-			types.NewGasUnits(0),
+			types.NewGasUnits(300),
 			"foo",
 		)
 		require.NoError(t, err)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -60,6 +61,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/protocol/block"
+	"github.com/filecoin-project/go-filecoin/protocol/chainexchange"
 	"github.com/filecoin-project/go-filecoin/protocol/hello"
 	"github.com/filecoin-project/go-filecoin/protocol/retrieval"
 	"github.com/filecoin-project/go-filecoin/protocol/storage"
@@ -97,6 +99,9 @@ type nodeChainReader interface {
 
 type nodeChainSyncer interface {
 	HandleNewTipset(ctx context.Context, tipsetCids types.SortedCidSet) error
+	HandleNewTipsetFromPeer(ctx context.Context, from libp2ppeer.ID, tipsetCids types.SortedCidSet) error
+	HandlePeerHeight(height uint64)
+	SyncMode() chain.SyncMode
 }
 
 // Node represents a full Filecoin node.
@@ -126,6 +131,8 @@ type Node struct {
 	Inbox *core.Inbox
 	// Messages sent and not yet mined.
 	Outbox *core.Outbox
+	// Index of on-chain messages by address, nil unless enabled in config.
+	MessageIndexer *msg.Indexer
 
 	Wallet *wallet.Wallet
 
@@ -140,18 +147,26 @@ type Node struct {
 	}
 	miningCtx    context.Context
 	miningDoneWg *sync.WaitGroup
+	// templateGenerator is built on the first template request and reused
+	// while the primary miner stays the same.
+	templateGenerator struct {
+		sync.Mutex
+		minerAddr address.Address
+		generator mining.TemplateGenerator
+	}
 
 	// Storage Market Interfaces
-	StorageMiner *storage.Miner
+	StorageMiners *storage.MinerSet
 
 	// Retrieval Interfaces
 	RetrievalMiner *retrieval.Miner
 
 	// Network Fields
-	BlockSub     pubsub.Subscription
-	MessageSub   pubsub.Subscription
-	HelloSvc     *hello.Handler
-	Bootstrapper *net.Bootstrapper
+	BlockSub         pubsub.Subscription
+	MessageSub       pubsub.Subscription
+	HelloSvc         *hello.Handler
+	ChainExchangeSvc *chainexchange.Server
+	Bootstrapper     *net.Bootstrapper
 
 	// Data Storage Fields
 
@@ -159,11 +174,12 @@ type Node struct {
 	// it contains all persistent artifacts of the filecoin node
 	Repo repo.Repo
 
-	// SectorBuilder is used by the miner to fill and seal sectors.
-	sectorBuilder sectorbuilder.SectorBuilder
+	// sectorBuilders are used by the miners to fill and seal sectors, one
+	// per mining address.
+	sectorBuilders map[address.Address]sectorbuilder.SectorBuilder
 
-	// Fetcher is the interface for fetching data from nodes.
-	Fetcher *net.Fetcher
+	// Fetcher is the interface for fetching chain data from nodes.
+	Fetcher *chainexchange.Fetcher
 
 	// Exchange is the interface for fetching data from other nodes.
 	Exchange exchange.Interface
@@ -391,7 +407,7 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 	//nwork := bsnet.NewFromIpfsHost(innerHost, router)
 	bswap := bitswap.New(ctx, nwork, bs)
 	bservice := bserv.New(bs, bswap)
-	fetcher := net.NewFetcher(ctx, bservice, blkValid)
+	fetcher := chainexchange.NewFetcher(chainexchange.NewClient(peerHost, blkValid), net.NewFetcher(ctx, bservice, blkValid))
 
 	cstOffline := hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
 	genCid, err := readGenesisCid(nc.Repo.Datastore())
@@ -401,6 +417,12 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 
 	// set up chainstore
 	chainStore := chain.NewStore(nc.Repo.ChainDatastore(), genCid)
+	// Checkpoints are verified against the chain when it is loaded on Start.
+	for _, cp := range nc.Repo.Config().Sync.Checkpoints {
+		if err := chainStore.AddCheckpoint(ctx, chain.Checkpoint{Height: cp.Height, Key: cp.TipSet}); err != nil {
+			return nil, errors.Wrap(err, "invalid checkpoint in config")
+		}
+	}
 	chainState := cst.NewChainStateProvider(chainStore, &cstOffline)
 	powerTable := &consensus.MarketView{}
 
@@ -409,15 +431,30 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 	if nc.Rewarder == nil {
 		processor = consensus.NewDefaultProcessor()
 	} else {
-		processor = consensus.NewConfiguredProcessor(consensus.NewDefaultMessageValidator(), nc.Rewarder)
+		processor = consensus.NewConfiguredProcessor(consensus.NewBlockMessageValidator(), nc.Rewarder)
 	}
 
 	// set up consensus
 	var nodeConsensus consensus.Protocol
-	if nc.Verifier == nil {
-		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, blkValid, powerTable, genCid, &proofs.RustVerifier{}, nc.BlockTime)
-	} else {
-		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, blkValid, powerTable, genCid, nc.Verifier, nc.BlockTime)
+	switch protocol := nc.Repo.Config().Consensus.Protocol; protocol {
+	case consensus.AuthorityProtocol:
+		var genesis types.Block
+		if err := cstOffline.Get(ctx, genCid, &genesis); err != nil {
+			return nil, errors.Wrap(err, "failed to load genesis block")
+		}
+		signers, err := consensus.GenesisSigners(ctx, &cstOffline, &genesis)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read authority signers")
+		}
+		nodeConsensus = consensus.NewAuthority(&cstOffline, bs, processor, blkValid, genCid, signers, nc.BlockTime)
+	case consensus.ExpectedProtocol, "":
+		if nc.Verifier == nil {
+			nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, blkValid, powerTable, genCid, &proofs.RustVerifier{}, nc.BlockTime)
+		} else {
+			nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, blkValid, powerTable, genCid, nc.Verifier, nc.BlockTime)
+		}
+	default:
+		return nil, errors.Errorf("unknown consensus protocol %q", protocol)
 	}
 
 	// Set up libp2p network
@@ -430,53 +467,79 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to set up wallet backend")
 	}
-	fcWallet := wallet.New(backend)
+	backends := []wallet.Backend{backend}
+	for _, endpoint := range nc.Repo.Config().Wallet.RemoteBackends {
+		remote, err := wallet.NewRemoteBackend(endpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to set up remote wallet backend")
+		}
+		backends = append(backends, remote)
+	}
+	fcWallet := wallet.New(backends...)
 
 	// only the syncer gets the storage which is online connected
-	chainSyncer := chain.NewSyncer(&cstOffline, nodeConsensus, chainStore, fetcher, chain.Syncing)
-	msgPool := core.NewMessagePool(nc.Repo.Config().Mpool, consensus.NewIngestionValidator(chainState, nc.Repo.Config().Mpool))
+	// A node with no one to sync from is caught up with the chain it has.
+	syncMode := chain.Syncing
+	if nc.OfflineMode || len(nc.Repo.Config().Bootstrap.Addresses) == 0 {
+		syncMode = chain.CaughtUp
+	}
+	badTipSets := chain.NewBadTipSetCache(nc.Repo.ChainDatastore(), chain.MaxBadTipSets)
+	chainSyncer := chain.NewSyncer(&cstOffline, nodeConsensus, chainStore, fetcher, badTipSets, syncMode)
+	msgPool := core.NewPersistentMessagePool(nc.Repo.Config().Mpool, consensus.NewIngestionValidator(chainState, nc.Repo.Config().Mpool), nc.Repo.Datastore())
 	inbox := core.NewInbox(msgPool, core.InboxMaxAgeTipsets, chainStore)
 
-	msgQueue := core.NewMessageQueue()
+	msgQueue := core.NewPersistentMessageQueue(nc.Repo.Datastore())
 	outboxPolicy := core.NewMessageQueuePolicy(chainStore, core.OutboxMaxAgeRounds)
 	msgPublisher := newDefaultMessagePublisher(pubsub.NewPublisher(fsub), core.Topic, msgPool)
-	outbox := core.NewOutbox(fcWallet, consensus.NewOutboundMessageValidator(), msgQueue, msgPublisher, outboxPolicy, chainStore, chainState)
+	outbox := core.NewOutbox(fcWallet, consensus.NewOutboundMessageValidator(), msgQueue, msgPublisher, outboxPolicy, chainStore, chainState, nc.Repo.Config().Mpool)
+
+	var msgIndexer *msg.Indexer
+	if nc.Repo.Config().MsgIndex.Enabled {
+		msgIndexer = msg.NewIndexer(chainStore, bs, &cstOffline, nc.Repo.ChainDatastore())
+	}
 
 	PorcelainAPI := porcelain.New(plumbing.New(&plumbing.APIDeps{
+		BadTipSets:   badTipSets,
 		Bitswap:      bswap,
 		Chain:        chainState,
 		Config:       cfg.NewConfig(nc.Repo),
 		DAG:          dag.NewDAG(merkledag.NewDAGService(bservice)),
 		Deals:        strgdls.New(nc.Repo.DealsDatastore()),
 		Expected:     nodeConsensus,
+		MsgIndexer:   msgIndexer,
 		MsgPool:      msgPool,
 		MsgPreviewer: msg.NewPreviewer(fcWallet, chainStore, &cstOffline, bs),
 		MsgQueryer:   msg.NewQueryer(nc.Repo, fcWallet, chainStore, &cstOffline, bs),
 		MsgWaiter:    msg.NewWaiter(chainStore, bs, &cstOffline),
 		Network:      net.New(peerHost, pubsub.NewPublisher(fsub), pubsub.NewSubscriber(fsub), net.NewRouter(router), bandwidthTracker, net.NewPinger(peerHost, pingService)),
 		Outbox:       outbox,
+		Syncer:       chainSyncer,
 		Wallet:       fcWallet,
 	}))
 
 	nd := &Node{
-		blockservice: bservice,
-		Blockstore:   bs,
-		cborStore:    &cstOffline,
-		Consensus:    nodeConsensus,
-		ChainReader:  chainStore,
-		Syncer:       chainSyncer,
-		PowerTable:   powerTable,
-		PorcelainAPI: PorcelainAPI,
-		Fetcher:      fetcher,
-		Exchange:     bswap,
-		host:         peerHost,
-		Inbox:        inbox,
-		OfflineMode:  nc.OfflineMode,
-		Outbox:       outbox,
-		PeerHost:     peerHost,
-		Repo:         nc.Repo,
-		Wallet:       fcWallet,
-		Router:       router,
+		blockservice:   bservice,
+		Blockstore:     bs,
+		cborStore:      &cstOffline,
+		Consensus:      nodeConsensus,
+		ChainReader:    chainStore,
+		Syncer:         chainSyncer,
+		PowerTable:     powerTable,
+		PorcelainAPI:   PorcelainAPI,
+		Fetcher:        fetcher,
+		Exchange:       bswap,
+		host:           peerHost,
+		Inbox:          inbox,
+		MessageIndexer: msgIndexer,
+		OfflineMode:    nc.OfflineMode,
+		Outbox:         outbox,
+		PeerHost:       peerHost,
+		Repo:           nc.Repo,
+		Wallet:         fcWallet,
+		Router:         router,
+	}
+	if nc.Repo.Config().Mining.ReportConsensusFaults {
+		chainSyncer.OnConsensusFault(nd.reportConsensusFault)
 	}
 
 	// Bootstrapping network peers.
@@ -513,6 +576,20 @@ func (node *Node) Start(ctx context.Context) error {
 		return err
 	}
 
+	// Restore the messages that were pending when the node last stopped.
+	if err := node.Outbox.Load(ctx); err != nil {
+		return errors.Wrap(err, "failed to load outbound message queue")
+	}
+	if err := node.Inbox.Pool().Load(ctx); err != nil {
+		return errors.Wrap(err, "failed to load message pool")
+	}
+
+	if node.MessageIndexer != nil {
+		if err := node.MessageIndexer.Start(ctx); err != nil {
+			return errors.Wrap(err, "failed to start message index")
+		}
+	}
+
 	// Only set these up if there is a miner configured.
 	if _, err := node.miningAddress(); err == nil {
 		if err := node.setupMining(ctx); err != nil {
@@ -524,13 +601,18 @@ func (node *Node) Start(ctx context.Context) error {
 	// Start up 'hello' handshake service
 	syncCallBack := func(pid libp2ppeer.ID, cids []cid.Cid, height uint64) {
 		cidSet := types.NewSortedCidSet(cids...)
-		err := node.Syncer.HandleNewTipset(context.Background(), cidSet)
+		node.Fetcher.AddPeer(pid)
+		node.Syncer.HandlePeerHeight(height)
+		err := node.Syncer.HandleNewTipsetFromPeer(context.Background(), pid, cidSet)
 		if err != nil {
 			log.Infof("error handling blocks: %s", cidSet.String())
 		}
 	}
 	node.HelloSvc = hello.New(node.Host(), node.ChainReader.GenesisCid(), syncCallBack, node.PorcelainAPI.ChainHead, node.Repo.Config().Net, flags.Commit)
 
+	// Serve our chain to peers syncing over the chain exchange protocol
+	node.ChainExchangeSvc = chainexchange.NewServer(node.Host(), node.ChainReader)
+
 	err = node.setupProtocols()
 	if err != nil {
 		return errors.Wrap(err, "failed to set up protocols:")
@@ -609,12 +691,24 @@ func (node *Node) setupHeartbeatServices(ctx context.Context) error {
 }
 
 func (node *Node) setupMining(ctx context.Context) error {
-	// initialize a sector builder
-	sectorBuilder, err := initSectorBuilderForNode(ctx, node)
+	minerAddrs, err := node.miningAddresses()
 	if err != nil {
-		return errors.Wrap(err, "failed to initialize sector builder")
+		return err
+	}
+
+	// initialize a sector builder for each miner
+	sectorBuilders := make(map[address.Address]sectorbuilder.SectorBuilder)
+	for _, minerAddr := range minerAddrs {
+		sectorBuilder, err := initSectorBuilderForNode(ctx, node, minerAddr)
+		if err != nil {
+			for _, sb := range sectorBuilders {
+				sb.Close() // nolint: errcheck
+			}
+			return errors.Wrapf(err, "failed to initialize sector builder for miner %s", minerAddr)
+		}
+		sectorBuilders[minerAddr] = sectorBuilder
 	}
-	node.sectorBuilder = sectorBuilder
+	node.sectorBuilders = sectorBuilders
 
 	return nil
 }
@@ -679,8 +773,10 @@ func (node *Node) handleNewHeaviestTipSet(ctx context.Context, head types.TipSet
 			}
 			head = newHead
 
-			if node.StorageMiner != nil {
-				node.StorageMiner.OnNewHeaviestTipSet(newHead)
+			if node.StorageMiners != nil {
+				for _, storageMiner := range node.StorageMiners.Miners() {
+					storageMiner.OnNewHeaviestTipSet(newHead)
+				}
 			}
 			node.HeaviestTipSetHandled()
 		case <-ctx.Done():
@@ -711,14 +807,17 @@ func (node *Node) Stop(ctx context.Context) {
 	node.StopMining(ctx)
 
 	node.cancelSubscriptions()
+	if node.MessageIndexer != nil {
+		node.MessageIndexer.Stop()
+	}
 	node.ChainReader.Stop()
 
-	if node.SectorBuilder() != nil {
-		if err := node.SectorBuilder().Close(); err != nil {
+	for _, sb := range node.sectorBuilders {
+		if err := sb.Close(); err != nil {
 			fmt.Printf("error closing sector builder: %s\n", err)
 		}
-		node.sectorBuilder = nil
 	}
+	node.sectorBuilders = nil
 
 	if err := node.Host().Close(); err != nil {
 		fmt.Printf("error closing host: %s\n", err)
@@ -753,6 +852,26 @@ func (node *Node) miningAddress() (address.Address, error) {
 	return addr, nil
 }
 
+// miningAddresses returns the addresses of all the mining actors mining on
+// behalf of the node, starting with the one returned by miningAddress.
+func (node *Node) miningAddresses() ([]address.Address, error) {
+	addr, err := node.miningAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []address.Address{addr}
+	seen := map[address.Address]bool{addr: true}
+	for _, a := range node.Repo.Config().Mining.AdditionalMinerAddresses {
+		if a.Empty() || seen[a] {
+			continue
+		}
+		seen[a] = true
+		addrs = append(addrs, a)
+	}
+	return addrs, nil
+}
+
 // MiningTimes returns the configured time it takes to mine a block, and also
 // the mining delay duration, which is currently a fixed fraction of block time.
 // Note this is mocked behavior, in production this time is determined by how
@@ -764,28 +883,26 @@ func (node *Node) MiningTimes() (time.Duration, time.Duration) {
 }
 
 // StartMining causes the node to start feeding blocks to the mining worker and initializes
-// the SectorBuilder for the mining address.
+// the SectorBuilder and storage miner for each mining address.
 func (node *Node) StartMining(ctx context.Context) error {
 	if node.IsMining() {
 		return errors.New("Node is already mining")
 	}
-	minerAddr, err := node.miningAddress()
+	if node.Syncer.SyncMode() == chain.Syncing {
+		return errors.New("Node is still syncing the chain")
+	}
+	minerAddrs, err := node.miningAddresses()
 	if err != nil {
 		return errors.Wrap(err, "failed to get mining address")
 	}
 
-	// ensure we have a sector builder
-	if node.SectorBuilder() == nil {
+	// ensure we have sector builders
+	if node.sectorBuilders == nil {
 		if err := node.setupMining(ctx); err != nil {
 			return err
 		}
 	}
 
-	minerOwnerAddr, err := node.miningOwnerAddress(ctx, minerAddr)
-	if err != nil {
-		return errors.Wrapf(err, "failed to get mining owner address for miner %s", minerAddr)
-	}
-
 	_, mineDelay := node.MiningTimes()
 
 	if node.MiningWorker == nil {
@@ -808,56 +925,20 @@ func (node *Node) StartMining(ctx context.Context) error {
 		go node.handleNewMiningOutput(outCh)
 	}
 
-	// initialize a storage miner
-	storageMiner, err := initStorageMinerForNode(ctx, node)
-	if err != nil {
-		return errors.Wrap(err, "failed to initialize storage miner")
-	}
-	node.StorageMiner = storageMiner
-
-	// loop, turning sealing-results into commitSector messages to be included
-	// in the chain
-	go func() {
-		for {
-			select {
-			case result := <-node.SectorBuilder().SectorSealResults():
-				if result.SealingErr != nil {
-					log.Errorf("failed to seal sector with id %d: %s", result.SectorID, result.SealingErr.Error())
-				} else if result.SealingResult != nil {
-
-					// TODO: determine these algorithmically by simulating call and querying historical prices
-					gasPrice := types.NewGasPrice(1)
-					gasUnits := types.NewGasUnits(300)
-
-					val := result.SealingResult
-					// This call can fail due to, e.g. nonce collisions. Our miners existence depends on this.
-					// We should deal with this, but MessageSendWithRetry is problematic.
-					msgCid, err := node.PorcelainAPI.MessageSend(
-						node.miningCtx,
-						minerOwnerAddr,
-						minerAddr,
-						types.ZeroAttoFIL,
-						gasPrice,
-						gasUnits,
-						"commitSector",
-						val.SectorID,
-						val.CommD[:],
-						val.CommR[:],
-						val.CommRStar[:],
-						val.Proof[:],
-					)
-					if err != nil {
-						log.Errorf("failed to send commitSector message from %s to %s for sector with id %d: %s", minerOwnerAddr, minerAddr, val.SectorID, err)
-						continue
-					}
-
-					node.StorageMiner.OnCommitmentSent(val, msgCid, nil)
-				}
-			case <-node.miningCtx.Done():
-				return
-			}
+	// initialize a storage miner for each miner, serving the storage
+	// protocols and proving its sectors
+	if node.StorageMiners == nil {
+		node.StorageMiners = storage.NewMinerSet(node.Host())
+	}
+	for _, minerAddr := range minerAddrs {
+		storageMiner, err := initStorageMinerForNode(ctx, node, minerAddr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to initialize storage miner %s", minerAddr)
 		}
-	}()
+		node.StorageMiners.Add(storageMiner)
+
+		go node.commitSealedSectors(storageMiner)
+	}
 
 	// schedules sealing of staged piece-data
 	if node.Repo.Config().Mining.AutoSealIntervalSeconds > 0 {
@@ -868,9 +949,11 @@ func (node *Node) StartMining(ctx context.Context) error {
 					return
 				case <-time.After(time.Duration(node.Repo.Config().Mining.AutoSealIntervalSeconds) * time.Second):
 					log.Info("auto-seal has been triggered")
-					if err := node.SectorBuilder().SealAllStagedSectors(node.miningCtx); err != nil {
-						log.Errorf("scheduler received error from node.SectorBuilder.SealAllStagedSectors (%s) - exiting", err.Error())
-						return
+					for _, sb := range node.SectorBuilders() {
+						if err := sb.SealAllStagedSectors(node.miningCtx); err != nil {
+							log.Errorf("scheduler received error from SectorBuilder.SealAllStagedSectors (%s) - exiting", err.Error())
+							return
+						}
 					}
 				}
 			}
@@ -883,12 +966,74 @@ func (node *Node) StartMining(ctx context.Context) error {
 	return nil
 }
 
-func initSectorBuilderForNode(ctx context.Context, node *Node) (sectorbuilder.SectorBuilder, error) {
-	minerAddr, err := node.miningAddress()
+// commitSealedSectors loops, turning the sealing-results of the sector
+// builder of storageMiner into commitSector messages to be included in the
+// chain, until mining stops.
+func (node *Node) commitSealedSectors(storageMiner *storage.Miner) {
+	minerAddr := storageMiner.Address()
+	minerOwnerAddr, err := node.miningOwnerAddress(node.miningCtx, minerAddr)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get node's mining address")
+		log.Errorf("failed to get mining owner address for miner %s: %s", minerAddr, err)
+		return
 	}
 
+	for {
+		select {
+		case result := <-storageMiner.SectorBuilder().SectorSealResults():
+			if result.SealingErr != nil {
+				log.Errorf("failed to seal sector with id %d: %s", result.SectorID, result.SealingErr.Error())
+			} else if result.SealingResult != nil {
+
+				// TODO: determine this algorithmically by querying historical prices
+				gasPrice := types.NewGasPrice(1)
+
+				val := result.SealingResult
+				gasUnits, err := node.PorcelainAPI.MessageEstimateGasLimit(
+					node.miningCtx,
+					minerOwnerAddr,
+					minerAddr,
+					"commitSector",
+					val.SectorID,
+					val.CommD[:],
+					val.CommR[:],
+					val.CommRStar[:],
+					val.Proof[:],
+				)
+				if err != nil {
+					log.Errorf("failed to estimate the gas limit of commitSector for sector with id %d: %s", val.SectorID, err)
+					continue
+				}
+
+				// This call can fail due to, e.g. nonce collisions. Our miners existence depends on this.
+				// We should deal with this, but MessageSendWithRetry is problematic.
+				msgCid, err := node.PorcelainAPI.MessageSend(
+					node.miningCtx,
+					minerOwnerAddr,
+					minerAddr,
+					types.ZeroAttoFIL,
+					gasPrice,
+					gasUnits,
+					"commitSector",
+					val.SectorID,
+					val.CommD[:],
+					val.CommR[:],
+					val.CommRStar[:],
+					val.Proof[:],
+				)
+				if err != nil {
+					log.Errorf("failed to send commitSector message from %s to %s for sector with id %d: %s", minerOwnerAddr, minerAddr, val.SectorID, err)
+					continue
+				}
+
+				storageMiner.OnCommitmentSent(val, msgCid, nil)
+			}
+		case <-node.miningCtx.Done():
+			return
+		}
+	}
+}
+
+func initSectorBuilderForNode(ctx context.Context, node *Node, minerAddr address.Address) (sectorbuilder.SectorBuilder, error) {
 	sectorSize, err := node.PorcelainAPI.MinerGetSectorSize(ctx, minerAddr)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get sector size for miner w/address %s", minerAddr.String())
@@ -911,6 +1056,11 @@ func initSectorBuilderForNode(ctx context.Context, node *Node) (sectorbuilder.Se
 	if err != nil {
 		return nil, err
 	}
+	// The sectors of additional miners live in a directory of their own
+	// so that sector builders don't mix up each other's sectors.
+	if minerAddr != node.Repo.Config().Mining.MinerAddress {
+		sectorDir = filepath.Join(sectorDir, minerAddr.String())
+	}
 
 	stagingDir, err := paths.StagingDir(sectorDir)
 	if err != nil {
@@ -939,18 +1089,13 @@ func initSectorBuilderForNode(ctx context.Context, node *Node) (sectorbuilder.Se
 	return sb, nil
 }
 
-func initStorageMinerForNode(ctx context.Context, node *Node) (*storage.Miner, error) {
-	minerAddr, err := node.miningAddress()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get node's mining address")
-	}
-
+func initStorageMinerForNode(ctx context.Context, node *Node, minerAddr address.Address) (*storage.Miner, error) {
 	miningOwnerAddr, err := node.miningOwnerAddress(ctx, minerAddr)
 	if err != nil {
 		return nil, errors.Wrap(err, "no mining owner available, skipping storage miner setup")
 	}
 
-	miner, err := storage.NewMiner(minerAddr, miningOwnerAddr, node, node.Repo.DealsDatastore(), node.PorcelainAPI)
+	miner, err := storage.NewMiner(minerAddr, miningOwnerAddr, node.sectorBuilders[minerAddr], node, node.Repo.DealsDatastore(), node.PorcelainAPI)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to instantiate storage miner")
 	}
@@ -970,12 +1115,12 @@ func (node *Node) StopMining(ctx context.Context) {
 		node.miningDoneWg.Wait()
 	}
 
-	// TODO: stop node.StorageMiner
+	// TODO: stop node.StorageMiners
 }
 
 // NewAddress creates a new account address on the default wallet backend.
 func (node *Node) NewAddress() (address.Address, error) {
-	return wallet.NewAddress(node.Wallet)
+	return wallet.NewAddress(node.Wallet, address.SECP256K1)
 }
 
 // miningOwnerAddress returns the owner of miningAddr.
@@ -1016,7 +1161,8 @@ func (node *Node) setupProtocols() error {
 		mineDelay,
 		node.StartMining,
 		node.StopMining,
-		node.CreateMiningWorker)
+		node.CreateMiningWorker,
+		node.CreateTemplateGenerator)
 
 	node.BlockMiningAPI = &blockMiningAPI
 
@@ -1032,15 +1178,64 @@ func (node *Node) setupProtocols() error {
 }
 
 // CreateMiningWorker creates a mining.Worker for the node using the configured
-// getStateTree, getWeight, and getAncestors functions for the node
+// getStateTree, getWeight, and getAncestors functions for the node.  When the
+// node mines for several miners, the worker tries a ticket for each of them
+// every round.
 func (node *Node) CreateMiningWorker(ctx context.Context) (mining.Worker, error) {
-	processor := consensus.NewDefaultProcessor()
+	minerAddrs, err := node.miningAddresses()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get mining address")
+	}
 
+	minedHeights := mining.NewMinedHeights(node.Repo.Datastore())
+	var workers []mining.Worker
+	for _, minerAddr := range minerAddrs {
+		worker, err := node.createMinerWorker(ctx, minerAddr, minedHeights)
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, worker)
+	}
+	if len(workers) == 1 {
+		return workers[0], nil
+	}
+	return mining.NewMultiWorker(workers...), nil
+}
+
+// CreateTemplateGenerator returns a mining.TemplateGenerator generating block
+// templates for the node's primary miner.  The generator is created once and
+// reused until the primary miner changes.
+func (node *Node) CreateTemplateGenerator(ctx context.Context) (mining.TemplateGenerator, error) {
 	minerAddr, err := node.miningAddress()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get mining address")
 	}
 
+	node.templateGenerator.Lock()
+	defer node.templateGenerator.Unlock()
+	if node.templateGenerator.generator != nil && node.templateGenerator.minerAddr == minerAddr {
+		return node.templateGenerator.generator, nil
+	}
+
+	// Templates are completed and submitted by an external producer, so the
+	// generator doesn't record mined heights.
+	worker, err := node.createMinerWorker(ctx, minerAddr, nil)
+	if err != nil {
+		return nil, err
+	}
+	generator, ok := worker.(mining.TemplateGenerator)
+	if !ok {
+		return nil, errors.Errorf("miner %s can't generate block templates", minerAddr)
+	}
+	node.templateGenerator.minerAddr = minerAddr
+	node.templateGenerator.generator = generator
+	return generator, nil
+}
+
+// createMinerWorker creates the mining.Worker of a single miner.
+func (node *Node) createMinerWorker(ctx context.Context, minerAddr address.Address, minedHeights *mining.MinedHeights) (mining.Worker, error) {
+	processor := consensus.NewDefaultProcessor()
+
 	minerPubKey, err := node.PorcelainAPI.MinerGetKey(ctx, minerAddr)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get key from miner actor")
@@ -1051,10 +1246,14 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (mining.Worker, error)
 		log.Errorf("could not get owner address of miner actor")
 		return nil, err
 	}
-	return mining.NewDefaultWorker(
+	worker := mining.NewDefaultWorker(
 		node.Inbox.Pool(), node.getStateTree, node.getWeight, node.getAncestors, processor, node.PowerTable,
 		node.Blockstore, node.CborStore(), minerAddr, minerOwnerAddr, minerPubKey,
-		node.Wallet, node.PorcelainAPI), nil
+		node.Wallet, node.sectorBuilders[minerAddr], minedHeights, node.PorcelainAPI)
+	if authority, ok := node.Consensus.(*consensus.Authority); ok {
+		return mining.NewAuthorityWorker(worker, authority), nil
+	}
+	return worker, nil
 }
 
 // getStateFromKey returns the state tree based on tipset fetched with provided key tsKey
@@ -1101,9 +1300,25 @@ func (node *Node) Host() host.Host {
 	return node.host
 }
 
-// SectorBuilder returns the nodes sectorBuilder.
+// SectorBuilder returns the sectorBuilder of the node's primary miner.
 func (node *Node) SectorBuilder() sectorbuilder.SectorBuilder {
-	return node.sectorBuilder
+	return node.sectorBuilders[node.Repo.Config().Mining.MinerAddress]
+}
+
+// SectorBuilders returns the sectorBuilders of all the node's miners.
+func (node *Node) SectorBuilders() []sectorbuilder.SectorBuilder {
+	minerAddrs, err := node.miningAddresses()
+	if err != nil {
+		return nil
+	}
+
+	var sectorBuilders []sectorbuilder.SectorBuilder
+	for _, minerAddr := range minerAddrs {
+		if sb, ok := node.sectorBuilders[minerAddr]; ok {
+			sectorBuilders = append(sectorBuilders, sb)
+		}
+	}
+	return sectorBuilders
 }
 
 // BlockService returns the nodes blockservice.
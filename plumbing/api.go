@@ -44,11 +44,13 @@ import (
 type API struct {
 	logger logging.EventLogger
 
+	badTipSets   *chain.BadTipSetCache
 	bitswap      exchange.Interface
 	chain        *cst.ChainStateProvider
 	config       *cfg.Config
 	dag          *dag.DAG
 	expected     consensus.Protocol
+	msgIndexer   *msg.Indexer
 	msgPool      *core.MessagePool
 	msgPreviewer *msg.Previewer
 	msgQueryer   *msg.Queryer
@@ -56,23 +58,27 @@ type API struct {
 	network      *net.Network
 	outbox       *core.Outbox
 	storagedeals *strgdls.Store
+	syncer       *chain.Syncer
 	wallet       *wallet.Wallet
 }
 
 // APIDeps contains all the API's dependencies
 type APIDeps struct {
+	BadTipSets   *chain.BadTipSetCache
 	Bitswap      exchange.Interface
 	Chain        *cst.ChainStateProvider
 	Config       *cfg.Config
 	DAG          *dag.DAG
 	Deals        *strgdls.Store
 	Expected     consensus.Protocol
+	MsgIndexer   *msg.Indexer
 	MsgPool      *core.MessagePool
 	MsgPreviewer *msg.Previewer
 	MsgQueryer   *msg.Queryer
 	MsgWaiter    *msg.Waiter
 	Network      *net.Network
 	Outbox       *core.Outbox
+	Syncer       *chain.Syncer
 	Wallet       *wallet.Wallet
 }
 
@@ -81,11 +87,13 @@ func New(deps *APIDeps) *API {
 	return &API{
 		logger: logging.Logger("porcelain"),
 
+		badTipSets:   deps.BadTipSets,
 		bitswap:      deps.Bitswap,
 		chain:        deps.Chain,
 		config:       deps.Config,
 		dag:          deps.DAG,
 		expected:     deps.Expected,
+		msgIndexer:   deps.MsgIndexer,
 		msgPool:      deps.MsgPool,
 		msgPreviewer: deps.MsgPreviewer,
 		msgQueryer:   deps.MsgQueryer,
@@ -93,6 +101,7 @@ func New(deps *APIDeps) *API {
 		network:      deps.Network,
 		outbox:       deps.Outbox,
 		storagedeals: deps.Deals,
+		syncer:       deps.Syncer,
 		wallet:       deps.Wallet,
 	}
 }
@@ -135,6 +144,36 @@ func (api *API) ConfigGet(dottedPath string) (interface{}, error) {
 	return api.config.Get(dottedPath)
 }
 
+// ChainAddCheckpoint adds a trusted checkpoint the chain must pass through.
+// It errors if the current chain does not pass through it.  The checkpoint
+// is not persisted.
+func (api *API) ChainAddCheckpoint(ctx context.Context, cp chain.Checkpoint) error {
+	return api.chain.AddCheckpoint(ctx, cp)
+}
+
+// ChainCheckpoints returns the trusted checkpoints the chain must pass
+// through, ordered by height.
+func (api *API) ChainCheckpoints() []chain.Checkpoint {
+	return api.chain.Checkpoints()
+}
+
+// ChainBadTipSets returns the tipsets the syncer refused, with the reason
+// they were refused.
+func (api *API) ChainBadTipSets() ([]*chain.BadTipSet, error) {
+	return api.badTipSets.List()
+}
+
+// ChainBadTipSet returns why the tipset with the given key was refused.
+func (api *API) ChainBadTipSet(key types.SortedCidSet) (*chain.BadTipSet, error) {
+	return api.badTipSets.Get(key)
+}
+
+// ChainRemoveBadTipSet removes a tipset from the bad tipset cache so that the
+// syncer validates it again the next time it is received.
+func (api *API) ChainRemoveBadTipSet(key types.SortedCidSet) error {
+	return api.badTipSets.Remove(key)
+}
+
 // ChainGetBlock gets a block by CID
 func (api *API) ChainGetBlock(ctx context.Context, id cid.Cid) (*types.Block, error) {
 	return api.chain.GetBlock(ctx, id)
@@ -145,6 +184,27 @@ func (api *API) ChainHead() (types.TipSet, error) {
 	return api.chain.Head()
 }
 
+// ChainGetTipSetByHeight returns the tipset at the given height in the
+// canonical chain. If no block was mined at that height the closest tipset
+// below it is returned.
+func (api *API) ChainGetTipSetByHeight(ctx context.Context, height uint64) (types.TipSet, error) {
+	return api.chain.GetTipSetByHeight(height)
+}
+
+// ChainNotify returns a channel of apply and revert events for the tipsets
+// joining and leaving the chain as its head moves.  A non-empty from resumes
+// the stream from the tipset with that key, otherwise it starts at the
+// current head.  The channel is closed when ctx is done.
+func (api *API) ChainNotify(ctx context.Context, from types.SortedCidSet) (<-chan *chain.HeadChange, error) {
+	return api.chain.Notify(ctx, from)
+}
+
+// ChainSyncMode returns whether the node is still syncing the chain or has
+// caught up with the network.
+func (api *API) ChainSyncMode() chain.SyncMode {
+	return api.syncer.SyncMode()
+}
+
 // ChainLs returns an iterator of tipsets from head to genesis
 func (api *API) ChainLs(ctx context.Context) (*chain.TipsetIterator, error) {
 	return api.chain.Ls(ctx)
@@ -219,11 +279,46 @@ func (api *API) MessageSend(ctx context.Context, from, to address.Address, value
 	return api.outbox.Send(ctx, from, to, value, gasPrice, gasLimit, method, params...)
 }
 
+// MessageSendBatch sends messages from an address with consecutive nonces, signing them with
+// the wallet, and returns their cids in order. No message is sent unless all are valid.
+// Messages the message pools would not accept yet are published as earlier ones are mined.
+func (api *API) MessageSendBatch(ctx context.Context, from address.Address, batch []core.BatchMessage) ([]cid.Cid, error) {
+	return api.outbox.SendBatch(ctx, from, batch)
+}
+
+// MessageReplace replaces a message sent by this node that has not been mined yet with a
+// copy paying gasPrice, and returns the cid of the copy. The copy has the same nonce so at
+// most one of them is mined.
+func (api *API) MessageReplace(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	return api.outbox.Replace(ctx, msgCid, gasPrice)
+}
+
+// MessageNextNonce returns the nonce the next message from an address should have, counting
+// the messages from it this node has sent or submitted that are not mined yet.
+func (api *API) MessageNextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	return api.outbox.NextNonce(ctx, from)
+}
+
+// MessageSubmit validates and publishes a message that was signed outside this node, and
+// returns its cid. The message is re-broadcast until mined, like messages sent by this node.
+func (api *API) MessageSubmit(ctx context.Context, msg *types.SignedMessage) (cid.Cid, error) {
+	return api.outbox.Submit(ctx, msg)
+}
+
 // MessageFind returns a message and receipt from the blockchain, if it exists.
 func (api *API) MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error) {
 	return api.msgWaiter.Find(ctx, msgCid)
 }
 
+// MessageSearch returns the on-chain messages sent or received by an address,
+// most recent first. It requires the node to run the message index.
+func (api *API) MessageSearch(ctx context.Context, addr address.Address) ([]*msg.AddressMessage, error) {
+	if api.msgIndexer == nil {
+		return nil, msg.ErrIndexDisabled
+	}
+	return api.msgIndexer.Search(ctx, addr)
+}
+
 // MessageWait invokes the callback when a message with the given cid appears on chain.
 // It will find the message in both the case that it is already on chain and
 // the case that it appears in a newly mined block. An error is returned if one is
@@ -293,6 +388,12 @@ func (api *API) SignBytes(data []byte, addr address.Address) (types.Signature, e
 	return api.wallet.SignBytes(data, addr)
 }
 
+// WalletSignData signs arbitrary data with the key of the address, separated from messages
+// and the other data the node signs so that the signature can't be replayed for them
+func (api *API) WalletSignData(data []byte, addr address.Address) (types.Signature, error) {
+	return api.wallet.SignData(data, addr)
+}
+
 // WalletAddresses gets addresses from the wallet
 func (api *API) WalletAddresses() []address.Address {
 	return api.wallet.Addresses()
@@ -308,9 +409,20 @@ func (api *API) WalletGetPubKeyForAddress(addr address.Address) ([]byte, error)
 	return api.wallet.GetPubKeyForAddress(addr)
 }
 
-// WalletNewAddress generates a new wallet address
-func (api *API) WalletNewAddress() (address.Address, error) {
-	return wallet.NewAddress(api.wallet)
+// WalletNewAddress generates a new wallet address using the given protocol, SECP256K1 or BLS
+func (api *API) WalletNewAddress(protocol address.Protocol) (address.Address, error) {
+	return wallet.NewAddress(api.wallet, protocol)
+}
+
+// WalletSetSeed sets the HD seed new secp256k1 wallet addresses are derived from
+func (api *API) WalletSetSeed(seed []byte) error {
+	return wallet.SetSeed(api.wallet, seed)
+}
+
+// WalletDeriveAddress returns the address derived from the wallet's HD seed with the index,
+// without adding it to the wallet
+func (api *API) WalletDeriveAddress(index uint32) (address.Address, error) {
+	return wallet.DeriveAddress(api.wallet, index)
 }
 
 // WalletImport adds a given set of KeyInfos to the wallet
@@ -323,6 +435,23 @@ func (api *API) WalletExport(addrs []address.Address) ([]*types.KeyInfo, error)
 	return api.wallet.Export(addrs)
 }
 
+// WalletLock locks the wallet, so that its keys can't be used to sign until it is unlocked
+func (api *API) WalletLock() {
+	api.wallet.Lock()
+}
+
+// WalletUnlock unlocks the wallet with its passphrase until it is locked again or the
+// timeout passes. A zero timeout never passes.
+func (api *API) WalletUnlock(passphrase []byte, timeout time.Duration) error {
+	return api.wallet.Unlock(passphrase, timeout)
+}
+
+// WalletChangePassphrase re-encrypts the wallet with a new passphrase, if passphrase is
+// the current one.
+func (api *API) WalletChangePassphrase(passphrase, newPassphrase []byte) error {
+	return api.wallet.ChangePassphrase(passphrase, newPassphrase)
+}
+
 // DAGGetNode returns the associated DAG node for the passed in CID.
 func (api *API) DAGGetNode(ctx context.Context, ref string) (interface{}, error) {
 	return api.dag.GetNode(ctx, ref)
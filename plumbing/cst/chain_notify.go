@@ -0,0 +1,139 @@
+package cst
+
+import (
+	"context"
+
+	logging "github.com/ipfs/go-log"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("plumbing/chain_store")
+
+// Notify returns a channel of the changes to the chain as its head moves.
+// Each move of the head is reported as reverts of the tipsets leaving the
+// chain, from the old head down, followed by applies of the tipsets joining
+// it, from the common ancestor up.
+//
+// If from is empty the stream starts with a HeadChangeCurrent event for the
+// current head.  Otherwise it starts with the changes from the tipset with
+// key from to the current head, which lets a client resume from the last
+// tipset it has seen.  The channel is closed when ctx is done.
+//
+// A client reading slower than the head moves does not hold up the store:
+// the heads it has not caught up with are coalesced into the latest one, and
+// it is sent the changes from the last tipset it was sent to that.
+func (chn *ChainStateProvider) Notify(ctx context.Context, from types.SortedCidSet) (<-chan *chain.HeadChange, error) {
+	// Subscribe before reading the head so that no move is missed.
+	headCh := chn.reader.HeadEvents().Sub(chain.NewHeadTopic)
+
+	head, err := chn.Head()
+	if err != nil {
+		chn.reader.HeadEvents().Unsub(headCh, chain.NewHeadTopic)
+		return nil, err
+	}
+
+	var last types.TipSet
+	if from.Len() != 0 {
+		last, err = chain.LoadTipSetBlocks(ctx, chn.reader, from)
+		if err != nil {
+			chn.reader.HeadEvents().Unsub(headCh, chain.NewHeadTopic)
+			return nil, err
+		}
+	}
+
+	heads := make(chan types.TipSet, 1)
+	go latestHeads(headCh, heads)
+
+	out := make(chan *chain.HeadChange)
+	go func() {
+		defer close(out)
+		defer chn.reader.HeadEvents().Unsub(headCh, chain.NewHeadTopic)
+
+		if !last.Defined() {
+			if !sendHeadChange(ctx, out, &chain.HeadChange{Type: chain.HeadChangeCurrent, TipSet: head}) {
+				return
+			}
+			last = head
+		}
+
+		next := head
+		for {
+			if !next.Equals(last) {
+				changes, err := chn.headChanges(ctx, last, next)
+				if err != nil {
+					log.Errorf("failed to compute head changes from %s to %s: %s", last.String(), next.String(), err)
+					return
+				}
+				for _, change := range changes {
+					if !sendHeadChange(ctx, out, change) {
+						return
+					}
+				}
+				last = next
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case ts, more := <-heads:
+				if !more {
+					return
+				}
+				next = ts
+			}
+		}
+	}()
+	return out, nil
+}
+
+// latestHeads drains the head events of headCh, so that the publisher never
+// blocks on it, and keeps only the latest head in heads.  It closes heads
+// once headCh is closed on unsubscribing.
+func latestHeads(headCh <-chan interface{}, heads chan types.TipSet) {
+	defer close(heads)
+	for raw := range headCh {
+		ts, ok := raw.(types.TipSet)
+		if !ok || !ts.Defined() {
+			continue
+		}
+		// Replace the head not yet picked up, if any.  This is the only
+		// sender, so the send below never blocks.
+		select {
+		case <-heads:
+		default:
+		}
+		heads <- ts
+	}
+}
+
+// headChanges returns the changes moving the head from oldHead to newHead.
+func (chn *ChainStateProvider) headChanges(ctx context.Context, oldHead, newHead types.TipSet) ([]*chain.HeadChange, error) {
+	// Reverted tipsets may no longer be tracked by the store's tip index.
+	provider := chain.TipSetProviderFromBlocks(ctx, chn.reader)
+	oldTips, newTips, err := core.CollectTipsToCommonAncestor(ctx, provider, oldHead, newHead)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]*chain.HeadChange, 0, len(oldTips)+len(newTips))
+	for _, ts := range oldTips {
+		changes = append(changes, &chain.HeadChange{Type: chain.HeadChangeRevert, TipSet: ts})
+	}
+	// newTips are ordered by decreasing height, apply them from the bottom up.
+	for i := len(newTips) - 1; i >= 0; i-- {
+		changes = append(changes, &chain.HeadChange{Type: chain.HeadChangeApply, TipSet: newTips[i]})
+	}
+	return changes, nil
+}
+
+func sendHeadChange(ctx context.Context, out chan<- *chain.HeadChange, change *chain.HeadChange) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case out <- change:
+		return true
+	}
+}
@@ -0,0 +1,131 @@
+package cst
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/repo"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func requirePutChild(ctx context.Context, t *testing.T, store *chain.Store, parent types.TipSet, nonce uint64) types.TipSet {
+	h, err := parent.Height()
+	require.NoError(t, err)
+	ts := th.RequireNewTipSet(t, &types.Block{
+		Parents: parent.ToSortedCidSet(),
+		Height:  types.Uint64(h + 1),
+		Nonce:   types.Uint64(nonce),
+	})
+	require.NoError(t, store.PutTipSetAndState(ctx, &chain.TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: parent.At(0).Cid(),
+	}))
+	return ts
+}
+
+func requireNextChange(t *testing.T, ch <-chan *chain.HeadChange, typ chain.HeadChangeType, ts types.TipSet) {
+	select {
+	case change := <-ch:
+		require.NotNil(t, change)
+		assert.Equal(t, typ, change.Type)
+		assert.Equal(t, ts.ToSortedCidSet(), change.TipSet.ToSortedCidSet())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for head change")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	genesis := th.RequireNewTipSet(t, &types.Block{Nonce: 451})
+	store := chain.NewStore(repo.NewInMemoryRepo().ChainDatastore(), genesis.At(0).Cid())
+	require.NoError(t, store.PutTipSetAndState(ctx, &chain.TipSetAndState{
+		TipSet:          genesis,
+		TipSetStateRoot: genesis.At(0).Cid(),
+	}))
+	require.NoError(t, store.SetHead(ctx, genesis))
+	provider := NewChainStateProvider(store, hamt.NewCborStore())
+
+	a1 := requirePutChild(ctx, t, store, genesis, 1)
+	a2 := requirePutChild(ctx, t, store, a1, 2)
+	b2 := requirePutChild(ctx, t, store, a1, 3)
+	b3 := requirePutChild(ctx, t, store, b2, 4)
+
+	ch, err := provider.Notify(ctx, types.SortedCidSet{})
+	require.NoError(t, err)
+	requireNextChange(t, ch, chain.HeadChangeCurrent, genesis)
+
+	t.Run("applies and reverts as the head moves", func(t *testing.T) {
+		require.NoError(t, store.SetHead(ctx, a2))
+		requireNextChange(t, ch, chain.HeadChangeApply, a1)
+		requireNextChange(t, ch, chain.HeadChangeApply, a2)
+
+		require.NoError(t, store.SetHead(ctx, b3))
+		requireNextChange(t, ch, chain.HeadChangeRevert, a2)
+		requireNextChange(t, ch, chain.HeadChangeApply, b2)
+		requireNextChange(t, ch, chain.HeadChangeApply, b3)
+	})
+
+	t.Run("resumes from a tipset", func(t *testing.T) {
+		resumed, err := provider.Notify(ctx, a2.ToSortedCidSet())
+		require.NoError(t, err)
+		requireNextChange(t, resumed, chain.HeadChangeRevert, a2)
+		requireNextChange(t, resumed, chain.HeadChangeApply, b2)
+		requireNextChange(t, resumed, chain.HeadChangeApply, b3)
+	})
+}
+
+func TestNotifySlowClientDoesNotBlockHead(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	genesis := th.RequireNewTipSet(t, &types.Block{Nonce: 451})
+	store := chain.NewStore(repo.NewInMemoryRepo().ChainDatastore(), genesis.At(0).Cid())
+	require.NoError(t, store.PutTipSetAndState(ctx, &chain.TipSetAndState{
+		TipSet:          genesis,
+		TipSetStateRoot: genesis.At(0).Cid(),
+	}))
+	require.NoError(t, store.SetHead(ctx, genesis))
+	provider := NewChainStateProvider(store, hamt.NewCborStore())
+
+	ch, err := provider.Notify(ctx, types.SortedCidSet{})
+	require.NoError(t, err)
+	requireNextChange(t, ch, chain.HeadChangeCurrent, genesis)
+
+	// Move the head many more times than the head events buffer while the
+	// client reads nothing.
+	tipsets := []types.TipSet{genesis}
+	for i := 1; i <= 300; i++ {
+		tipsets = append(tipsets, requirePutChild(ctx, t, store, tipsets[i-1], uint64(i)))
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, ts := range tipsets[1:] {
+			require.NoError(t, store.SetHead(ctx, ts))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("setting the head blocked on a slow client")
+	}
+
+	// The client still gets every tipset applied, in order.
+	for _, ts := range tipsets[1:] {
+		requireNextChange(t, ch, chain.HeadChangeApply, ts)
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/cskr/pubsub"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/pkg/errors"
+
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/address"
@@ -12,17 +17,18 @@ import (
 	"github.com/filecoin-project/go-filecoin/sampling"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
-	"github.com/ipfs/go-cid"
-	"github.com/ipfs/go-hamt-ipld"
-	"github.com/pkg/errors"
 )
 
 type chainReader interface {
+	AddCheckpoint(ctx context.Context, cp chain.Checkpoint) error
 	BlockHeight() (uint64, error)
+	Checkpoints() []chain.Checkpoint
 	GetBlock(context.Context, cid.Cid) (*types.Block, error)
 	GetHead() types.SortedCidSet
 	GetTipSet(types.SortedCidSet) (types.TipSet, error)
+	GetTipSetByHeight(h uint64) (types.TipSet, error)
 	GetTipSetStateRoot(tsKey types.SortedCidSet) (cid.Cid, error)
+	HeadEvents() *pubsub.PubSub
 }
 
 // ChainStateProvider composes a chain and a state store to provide access to
@@ -68,6 +74,22 @@ func (chn *ChainStateProvider) Ls(ctx context.Context) (*chain.TipsetIterator, e
 	return chain.IterAncestors(ctx, chn.reader, ts), nil
 }
 
+// GetTipSetByHeight returns the tipset at the given height in the canonical
+// chain, or the closest tipset below it if the height is a null round.
+func (chn *ChainStateProvider) GetTipSetByHeight(height uint64) (types.TipSet, error) {
+	return chn.reader.GetTipSetByHeight(height)
+}
+
+// AddCheckpoint adds a trusted checkpoint the chain must pass through.
+func (chn *ChainStateProvider) AddCheckpoint(ctx context.Context, cp chain.Checkpoint) error {
+	return chn.reader.AddCheckpoint(ctx, cp)
+}
+
+// Checkpoints returns the trusted checkpoints the chain must pass through.
+func (chn *ChainStateProvider) Checkpoints() []chain.Checkpoint {
+	return chn.reader.Checkpoints()
+}
+
 // GetBlock gets a block by CID
 func (chn *ChainStateProvider) GetBlock(ctx context.Context, id cid.Cid) (*types.Block, error) {
 	return chn.reader.GetBlock(ctx, id)
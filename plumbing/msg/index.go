@@ -0,0 +1,322 @@
+package msg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func init() {
+	cbor.RegisterCborType(AddressMessage{})
+}
+
+// MessageIndexPrefix is the datastore prefix under which the message index
+// is stored.
+const MessageIndexPrefix = "msgindex"
+
+// ErrIndexDisabled is returned when searching for messages on a node that
+// does not run the message index.
+var ErrIndexDisabled = errors.New("message index is not enabled")
+
+// indexHeadKey is the key at which the last indexed head is stored.
+var indexHeadKey = datastore.KeyWithNamespaces([]string{MessageIndexPrefix, "head"})
+
+// indexerChainReader is the subset of the chain store used by the Indexer.
+type indexerChainReader interface {
+	waiterChainReader
+}
+
+// AddressMessage is an entry in the message index: a message sent or received
+// by an address, with the height at which it was included in the chain and
+// its receipt.
+type AddressMessage struct {
+	Height  uint64
+	MsgCid  cid.Cid
+	From    address.Address
+	To      address.Address
+	Receipt *types.MessageReceipt
+}
+
+// Indexer maintains an index of on-chain messages by the addresses that sent
+// or received them, so that an address' history can be found without
+// traversing the chain.  It follows the head of the chain store and reverts
+// entries for tipsets that are dropped from the chain by a reorg.
+type Indexer struct {
+	chainReader indexerChainReader
+	waiter      *Waiter
+	ds          repo.Datastore
+
+	// Protects head and writes to ds.
+	mu sync.Mutex
+	// head is the tipset up to which the chain has been indexed.
+	head types.TipSet
+
+	headCh chan interface{}
+	cancel context.CancelFunc
+}
+
+// NewIndexer returns a new Indexer persisting its entries in ds.
+func NewIndexer(chainReader indexerChainReader, bs bstore.Blockstore, cst *hamt.CborIpldStore, ds repo.Datastore) *Indexer {
+	return &Indexer{
+		chainReader: chainReader,
+		waiter:      NewWaiter(chainReader, bs, cst),
+		ds:          ds,
+	}
+}
+
+// Start brings the index up to date with the current head and then follows
+// new heads until Stop is called.  The chain store must be loaded.
+func (idx *Indexer) Start(ctx context.Context) error {
+	idx.headCh = idx.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
+
+	prev, err := idx.loadHead(ctx)
+	if err != nil {
+		return err
+	}
+	idx.head = prev
+
+	head, err := idx.chainReader.GetTipSet(idx.chainReader.GetHead())
+	if err != nil {
+		return err
+	}
+	if err := idx.HandleNewHead(ctx, head); err != nil {
+		return err
+	}
+
+	cctx, cancel := context.WithCancel(context.Background())
+	idx.cancel = cancel
+	go idx.follow(cctx)
+	return nil
+}
+
+// Stop stops following the chain head.
+func (idx *Indexer) Stop() {
+	if idx.headCh != nil {
+		idx.chainReader.HeadEvents().Unsub(idx.headCh, chain.NewHeadTopic)
+	}
+	if idx.cancel != nil {
+		idx.cancel()
+	}
+}
+
+func (idx *Indexer) follow(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case raw, more := <-idx.headCh:
+			if !more {
+				return
+			}
+			newHead, ok := raw.(types.TipSet)
+			if !ok || !newHead.Defined() {
+				continue
+			}
+			if err := idx.HandleNewHead(ctx, newHead); err != nil {
+				log.Errorf("message index failed to index %s: %s", newHead.String(), err)
+			}
+		}
+	}
+}
+
+// HandleNewHead updates the index for a move of the head from the last
+// indexed tipset to newHead.  Entries for tipsets no longer in the chain are
+// removed and entries for the newly adopted tipsets are added.
+func (idx *Indexer) HandleNewHead(ctx context.Context, newHead types.TipSet) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// Tipsets dropped by a reorg may no longer be tracked by the store's tip
+	// index, so read them straight from the blocks.
+	provider := chain.TipSetProviderFromBlocks(ctx, idx.chainReader)
+
+	var oldTips, newTips []types.TipSet
+	var err error
+	if idx.head.Defined() {
+		oldTips, newTips, err = core.CollectTipsToCommonAncestor(ctx, provider, idx.head, newHead)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Nothing indexed yet, index the whole chain.
+		for it := chain.IterAncestors(ctx, provider, newHead); !it.Complete(); err = it.Next() {
+			if err != nil {
+				return err
+			}
+			newTips = append(newTips, it.Value())
+		}
+	}
+
+	for _, ts := range oldTips {
+		if err := idx.revertTipSet(ts); err != nil {
+			return err
+		}
+	}
+	// newTips are ordered by decreasing height, apply them from the bottom up.
+	for i := len(newTips) - 1; i >= 0; i-- {
+		if err := idx.applyTipSet(ctx, newTips[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := idx.writeHead(newHead); err != nil {
+		return err
+	}
+	idx.head = newHead
+	return nil
+}
+
+// Search returns the indexed messages sent or received by addr, most recent
+// first.
+func (idx *Indexer) Search(ctx context.Context, addr address.Address) ([]*AddressMessage, error) {
+	// The trailing separator keeps out the entries of addresses that addr is a prefix of,
+	// like t010 for t01.
+	results, err := idx.ds.Query(query.Query{
+		Prefix: datastore.KeyWithNamespaces([]string{MessageIndexPrefix, addr.String()}).String() + "/",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query message index")
+	}
+	defer results.Close() // nolint: errcheck
+
+	var out []*AddressMessage
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			return nil, entry.Error
+		}
+		var am AddressMessage
+		if err := cbor.DecodeInto(entry.Value, &am); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode message index entry %s", entry.Key)
+		}
+		out = append(out, &am)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Height > out[j].Height
+	})
+	return out, nil
+}
+
+func (idx *Indexer) applyTipSet(ctx context.Context, ts types.TipSet) error {
+	h, err := ts.Height()
+	if err != nil {
+		return err
+	}
+
+	return forEachTipSetMessage(ts, func(c cid.Cid, msg *types.SignedMessage) error {
+		rcpt, err := idx.waiter.receiptFromTipSet(ctx, c, ts)
+		if err != nil {
+			return errors.Wrap(err, "error retrieving receipt from tipset")
+		}
+		am := &AddressMessage{
+			Height:  h,
+			MsgCid:  c,
+			From:    msg.From,
+			To:      msg.To,
+			Receipt: rcpt,
+		}
+		val, err := cbor.DumpObject(am)
+		if err != nil {
+			return err
+		}
+		for _, addr := range indexedAddresses(msg) {
+			if err := idx.ds.Put(indexKey(addr, h, c), val); err != nil {
+				return errors.Wrap(err, "failed to write message index entry")
+			}
+		}
+		return nil
+	})
+}
+
+func (idx *Indexer) revertTipSet(ts types.TipSet) error {
+	h, err := ts.Height()
+	if err != nil {
+		return err
+	}
+
+	return forEachTipSetMessage(ts, func(c cid.Cid, msg *types.SignedMessage) error {
+		for _, addr := range indexedAddresses(msg) {
+			if err := idx.ds.Delete(indexKey(addr, h, c)); err != nil && err != datastore.ErrNotFound {
+				return errors.Wrap(err, "failed to delete message index entry")
+			}
+		}
+		return nil
+	})
+}
+
+// loadHead loads the last indexed tipset, or returns an undefined tipset if
+// nothing has been indexed yet.
+func (idx *Indexer) loadHead(ctx context.Context) (types.TipSet, error) {
+	bb, err := idx.ds.Get(indexHeadKey)
+	if err == datastore.ErrNotFound {
+		return types.UndefTipSet, nil
+	}
+	if err != nil {
+		return types.UndefTipSet, errors.Wrap(err, "failed to read message index head")
+	}
+
+	var key types.SortedCidSet
+	if err := cbor.DecodeInto(bb, &key); err != nil {
+		return types.UndefTipSet, errors.Wrap(err, "failed to decode message index head")
+	}
+	return chain.LoadTipSetBlocks(ctx, idx.chainReader, key)
+}
+
+func (idx *Indexer) writeHead(ts types.TipSet) error {
+	val, err := cbor.DumpObject(ts.ToSortedCidSet())
+	if err != nil {
+		return err
+	}
+	return idx.ds.Put(indexHeadKey, val)
+}
+
+// forEachTipSetMessage calls cb once for every distinct message in ts.
+func forEachTipSetMessage(ts types.TipSet, cb func(cid.Cid, *types.SignedMessage) error) error {
+	var seen types.SortedCidSet
+	for i := 0; i < ts.Len(); i++ {
+		for _, msg := range ts.At(i).Messages {
+			c, err := msg.Cid()
+			if err != nil {
+				return err
+			}
+			if seen.Has(c) {
+				continue
+			}
+			(&seen).Add(c)
+			if err := cb(c, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexedAddresses returns the addresses under which msg is indexed.
+func indexedAddresses(msg *types.SignedMessage) []address.Address {
+	if msg.From == msg.To {
+		return []address.Address{msg.From}
+	}
+	return []address.Address{msg.From, msg.To}
+}
+
+// indexKey returns the datastore key of the index entry for message c
+// involving addr at height h.  Heights are zero padded so that keys of an
+// address sort by height.
+func indexKey(addr address.Address, h uint64, c cid.Cid) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{MessageIndexPrefix, addr.String(), fmt.Sprintf("%020d", h), c.String()})
+}
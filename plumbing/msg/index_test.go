@@ -0,0 +1,154 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/core"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestIndexerSearch(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	d := requiredCommonDeps(t, consensus.DefaultGenesis)
+	indexer := NewIndexer(d.chainStore, d.blockstore, d.cst, d.repo.ChainDatastore())
+
+	genesis, err := d.chainStore.GetTipSet(d.chainStore.GetHead())
+	require.NoError(t, err)
+
+	m1, m2, m3 := newSignedMessage(), newSignedMessage(), newSignedMessage()
+	c1, err := m1.Cid()
+	require.NoError(t, err)
+	c3, err := m3.Cid()
+	require.NoError(t, err)
+
+	mainChain := core.NewChainWithMessages(d.cst, genesis, smsgsSet{smsgs{m1}}, smsgsSet{smsgs{m2}})
+	for _, ts := range mainChain[1:] {
+		require.NoError(t, d.chainStore.PutTipSetAndState(ctx, &chain.TipSetAndState{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot,
+		}))
+	}
+	head := mainChain[len(mainChain)-1]
+	require.NoError(t, indexer.HandleNewHead(ctx, head))
+
+	t.Run("finds sent and received messages", func(t *testing.T) {
+		sent, err := indexer.Search(ctx, m1.From)
+		require.NoError(t, err)
+		require.Len(t, sent, 2)
+		// Most recent first.
+		assert.Equal(t, uint64(2), sent[0].Height)
+		assert.Equal(t, uint64(1), sent[1].Height)
+
+		received, err := indexer.Search(ctx, m1.To)
+		require.NoError(t, err)
+		require.Len(t, received, 1)
+		assert.Equal(t, c1, received[0].MsgCid)
+		assert.Equal(t, m1.From, received[0].From)
+	})
+
+	t.Run("reverts dropped tipsets on reorg", func(t *testing.T) {
+		fork := core.NewChainWithMessages(d.cst, genesis, smsgsSet{smsgs{m3}})
+		forkHead := fork[len(fork)-1]
+		require.NoError(t, d.chainStore.PutTipSetAndState(ctx, &chain.TipSetAndState{
+			TipSet:          forkHead,
+			TipSetStateRoot: forkHead.At(0).StateRoot,
+		}))
+		require.NoError(t, indexer.HandleNewHead(ctx, forkHead))
+
+		sent, err := indexer.Search(ctx, m1.From)
+		require.NoError(t, err)
+		require.Len(t, sent, 1)
+		assert.Equal(t, c3, sent[0].MsgCid)
+
+		received, err := indexer.Search(ctx, m1.To)
+		require.NoError(t, err)
+		assert.Len(t, received, 0)
+	})
+}
+
+func TestIndexerResumesFromPersistedHead(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	d := requiredCommonDeps(t, consensus.DefaultGenesis)
+
+	genesis, err := d.chainStore.GetTipSet(d.chainStore.GetHead())
+	require.NoError(t, err)
+
+	m1, m2 := newSignedMessage(), newSignedMessage()
+	chn := core.NewChainWithMessages(d.cst, genesis, smsgsSet{smsgs{m1}}, smsgsSet{smsgs{m2}})
+	for _, ts := range chn[1:] {
+		require.NoError(t, d.chainStore.PutTipSetAndState(ctx, &chain.TipSetAndState{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot,
+		}))
+	}
+
+	first := NewIndexer(d.chainStore, d.blockstore, d.cst, d.repo.ChainDatastore())
+	require.NoError(t, first.HandleNewHead(ctx, chn[1]))
+
+	// A new indexer over the same datastore picks up where the first left off.
+	require.NoError(t, d.chainStore.SetHead(ctx, chn[2]))
+	second := NewIndexer(d.chainStore, d.blockstore, d.cst, d.repo.ChainDatastore())
+	require.NoError(t, second.Start(ctx))
+	defer second.Stop()
+
+	sent, err := second.Search(ctx, m1.From)
+	require.NoError(t, err)
+	assert.Len(t, sent, 2)
+}
+
+func TestIndexerSearchDoesNotMatchAddressPrefixes(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	d := requiredCommonDeps(t, consensus.DefaultGenesis)
+	indexer := NewIndexer(d.chainStore, d.blockstore, d.cst, d.repo.ChainDatastore())
+
+	genesis, err := d.chainStore.GetTipSet(d.chainStore.GetHead())
+	require.NoError(t, err)
+
+	// The string of addr1 is a prefix of that of addr10.
+	addr1, err := address.NewIDAddress(1)
+	require.NoError(t, err)
+	addr10, err := address.NewIDAddress(10)
+	require.NoError(t, err)
+
+	from := mockSigner.Addresses[0]
+	m1, err := types.NewSignedMessage(*types.NewMessage(from, addr1, 0, types.ZeroAttoFIL, "", nil), &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	m10, err := types.NewSignedMessage(*types.NewMessage(from, addr10, 1, types.ZeroAttoFIL, "", nil), &mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+	require.NoError(t, err)
+	c1, err := m1.Cid()
+	require.NoError(t, err)
+	c10, err := m10.Cid()
+	require.NoError(t, err)
+
+	chn := core.NewChainWithMessages(d.cst, genesis, smsgsSet{smsgs{m1, m10}})
+	head := chn[len(chn)-1]
+	require.NoError(t, d.chainStore.PutTipSetAndState(ctx, &chain.TipSetAndState{
+		TipSet:          head,
+		TipSetStateRoot: head.At(0).StateRoot,
+	}))
+	require.NoError(t, indexer.HandleNewHead(ctx, head))
+
+	received1, err := indexer.Search(ctx, addr1)
+	require.NoError(t, err)
+	require.Len(t, received1, 1)
+	assert.Equal(t, c1, received1[0].MsgCid)
+
+	received10, err := indexer.Search(ctx, addr10)
+	require.NoError(t, err)
+	require.Len(t, received10, 1)
+	assert.Equal(t, c10, received10[0].MsgCid)
+}
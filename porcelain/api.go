@@ -11,6 +11,7 @@ import (
 	minerActor "github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/plumbing"
 	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -49,6 +50,12 @@ func (a *API) ChainBlockHeight() (*types.BlockHeight, error) {
 	return ChainBlockHeight(a)
 }
 
+// ChainCheckpoint adds a trusted checkpoint to the chain and persists it in
+// the config
+func (a *API) ChainCheckpoint(ctx context.Context, cp chain.Checkpoint) error {
+	return ChainCheckpoint(ctx, a, cp)
+}
+
 // CreatePayments establishes a payment channel and create multiple payments against it
 func (a *API) CreatePayments(ctx context.Context, config CreatePaymentsParams) (*CreatePaymentsReturn, error) {
 	return CreatePayments(ctx, a, config)
@@ -76,12 +83,58 @@ func (a *API) DealsLs(ctx context.Context) (<-chan *StorageDealLsResult, error)
 	return DealsLs(ctx, a)
 }
 
+// MessageCreate builds an unsigned message with the next nonce of the from address
+func (a *API) MessageCreate(
+	ctx context.Context,
+	from,
+	to address.Address,
+	value types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit types.GasUnits,
+	method string,
+	params ...interface{},
+) (*types.MeteredMessage, error) {
+	return MessageCreate(
+		ctx,
+		a,
+		from,
+		to,
+		value,
+		gasPrice,
+		gasLimit,
+		method,
+		params...,
+	)
+}
+
+// MessageEstimateGasLimit estimates the gas limit of a message from a preview of it
+func (a *API) MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	return MessageEstimateGasLimit(ctx, a, from, to, method, params...)
+}
+
 // MessagePoolWait waits for the message pool to have at least messageCount unmined messages.
 // It's useful for integration testing.
 func (a *API) MessagePoolWait(ctx context.Context, messageCount uint) ([]*types.SignedMessage, error) {
 	return MessagePoolWait(ctx, a, messageCount)
 }
 
+// MessagePreviewBatch previews the gas used by a batch of messages and the value they transfer
+func (a *API) MessagePreviewBatch(ctx context.Context, from address.Address, batch []BatchMessage) (*BatchPreview, error) {
+	return MessagePreviewBatch(ctx, a, from, batch)
+}
+
+// MessageReplaceWithMinGasPrice replaces a message in the outbound queue, paying the lowest
+// gas price that outbids it if none is provided
+func (a *API) MessageReplaceWithMinGasPrice(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	return MessageReplaceWithMinGasPrice(ctx, a, msgCid, gasPrice)
+}
+
+// MessageSendBatchWithDefaultAddress sends a batch of messages with consecutive nonces, from
+// a default address if none is provided
+func (a *API) MessageSendBatchWithDefaultAddress(ctx context.Context, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, batch []BatchMessage) ([]cid.Cid, error) {
+	return MessageSendBatchWithDefaultAddress(ctx, a, from, gasPrice, gasLimit, batch)
+}
+
 // MessageSendWithDefaultAddress calls MessageSend but with a default from
 // address if none is provided
 func (a *API) MessageSendWithDefaultAddress(
@@ -107,6 +160,11 @@ func (a *API) MessageSendWithDefaultAddress(
 	)
 }
 
+// MessageSuggestGasPrice suggests a gas price from the prices of recent and pending messages
+func (a *API) MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	return MessageSuggestGasPrice(ctx, a)
+}
+
 // MinerCreate creates a miner
 func (a *API) MinerCreate(
 	ctx context.Context,
@@ -193,6 +251,18 @@ func (a *API) WalletDefaultAddress() (address.Address, error) {
 	return WalletDefaultAddress(a)
 }
 
+// WalletInitMnemonic generates a new mnemonic and sets its seed as the wallet's HD seed,
+// returning the mnemonic.
+func (a *API) WalletInitMnemonic() (string, error) {
+	return WalletInitMnemonic(a)
+}
+
+// WalletRecover sets the seed of the mnemonic as the wallet's HD seed and adds the used
+// addresses derived from it to the wallet.
+func (a *API) WalletRecover(ctx context.Context, mnemonic string) ([]address.Address, error) {
+	return WalletRecover(ctx, a, mnemonic)
+}
+
 // PaymentChannelLs lists payment channels for a given payer
 func (a *API) PaymentChannelLs(
 	ctx context.Context,
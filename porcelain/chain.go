@@ -1,6 +1,13 @@
 package porcelain
 
 import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -20,3 +27,39 @@ func ChainBlockHeight(plumbing chBlockHeightPlumbing) (*types.BlockHeight, error
 	}
 	return types.NewBlockHeight(height), nil
 }
+
+type chCheckpointPlumbing interface {
+	ChainAddCheckpoint(ctx context.Context, cp chain.Checkpoint) error
+	ConfigGet(dottedPath string) (interface{}, error)
+	ConfigSet(dottedPath string, paramJSON string) error
+}
+
+// ChainCheckpoint adds a trusted checkpoint to the chain and persists it in
+// the config so that it is enforced after a restart.  A checkpoint already in
+// the config at the same height is replaced.
+func ChainCheckpoint(ctx context.Context, plumbing chCheckpointPlumbing, cp chain.Checkpoint) error {
+	if err := plumbing.ChainAddCheckpoint(ctx, cp); err != nil {
+		return err
+	}
+
+	val, err := plumbing.ConfigGet("sync.checkpoints")
+	if err != nil {
+		return err
+	}
+	existing, ok := val.([]*config.CheckpointConfig)
+	if !ok {
+		return errors.New("sync.checkpoints in config has unexpected type")
+	}
+
+	checkpoints := []*config.CheckpointConfig{{Height: cp.Height, TipSet: cp.Key}}
+	for _, c := range existing {
+		if c.Height != cp.Height {
+			checkpoints = append(checkpoints, c)
+		}
+	}
+	raw, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+	return plumbing.ConfigSet("sync.checkpoints", string(raw))
+}
@@ -0,0 +1,106 @@
+package porcelain
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// GasEstimateMarginPercent is the safety margin added to the gas used by a previewed message
+// to estimate its gas limit, since the state it runs against may change before it is mined.
+const GasEstimateMarginPercent = 20
+
+// GasPriceOracleTipSets is the number of most recent tipsets whose messages the gas price
+// oracle samples, in addition to the pending messages of the message pool.
+const GasPriceOracleTipSets = 10
+
+// GasPriceOraclePercentile is the percentile of the sampled gas prices the oracle suggests.
+// Paying more than most recent messages makes it likely a message is mined soon.
+const GasPriceOraclePercentile = 60
+
+// DefaultGasPrice is the gas price the oracle suggests when it has no prices to sample.
+var DefaultGasPrice = types.NewGasPrice(1)
+
+type mgeAPI interface {
+	MessagePreview(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
+}
+
+// MessageEstimateGasLimit estimates the gas limit of a message by previewing it against the
+// latest state and adding a safety margin of GasEstimateMarginPercent. Messages that only
+// transfer value don't run any actor code, and need no gas.
+func MessageEstimateGasLimit(ctx context.Context, plumbing mgeAPI, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	if method == "" {
+		return types.NewGasUnits(0), nil
+	}
+
+	used, err := plumbing.MessagePreview(ctx, from, to, method, params...)
+	if err != nil {
+		return types.NewGasUnits(0), errors.Wrap(err, "failed to preview message to estimate its gas limit")
+	}
+
+	estimate := uint64(used) + uint64(used)*GasEstimateMarginPercent/100
+	if estimate > uint64(types.BlockGasLimit) {
+		estimate = uint64(types.BlockGasLimit)
+	}
+	return types.NewGasUnits(estimate), nil
+}
+
+type mgpAPI interface {
+	ChainLs(ctx context.Context) (*chain.TipsetIterator, error)
+	ConfigGet(dottedPath string) (interface{}, error)
+	MessagePoolPending() []*types.SignedMessage
+}
+
+// MessageSuggestGasPrice suggests a gas price for a new message: the GasPriceOraclePercentile
+// percentile of the gas prices of the messages in the last GasPriceOracleTipSets tipsets and
+// in the message pool. The suggestion is never below the message pool's minimum gas price.
+func MessageSuggestGasPrice(ctx context.Context, plumbing mgpAPI) (types.AttoFIL, error) {
+	var prices []types.AttoFIL
+	for _, msg := range plumbing.MessagePoolPending() {
+		prices = append(prices, msg.GasPrice)
+	}
+
+	iter, err := plumbing.ChainLs(ctx)
+	if err != nil {
+		return types.ZeroAttoFIL, err
+	}
+	for i := 0; i < GasPriceOracleTipSets && !iter.Complete(); i++ {
+		ts := iter.Value()
+		for j := 0; j < ts.Len(); j++ {
+			for _, msg := range ts.At(j).Messages {
+				prices = append(prices, msg.GasPrice)
+			}
+		}
+		if err := iter.Next(); err != nil {
+			return types.ZeroAttoFIL, err
+		}
+	}
+
+	suggestion := DefaultGasPrice
+	if len(prices) > 0 {
+		sort.Slice(prices, func(i, j int) bool { return prices[i].LessThan(prices[j]) })
+		suggestion = prices[(len(prices)-1)*GasPriceOraclePercentile/100]
+	}
+
+	minGasPrice, err := plumbing.ConfigGet("mpool.minGasPrice")
+	if err != nil {
+		return types.ZeroAttoFIL, err
+	}
+	floor, ok := minGasPrice.(types.AttoFIL)
+	if !ok {
+		return types.ZeroAttoFIL, errors.New("mpool.minGasPrice in config has unexpected type")
+	}
+	if suggestion.LessThan(floor) {
+		suggestion = floor
+	}
+	if !suggestion.GreaterThan(types.ZeroAttoFIL) {
+		// Messages that pay nothing for gas are invalid.
+		suggestion = DefaultGasPrice
+	}
+	return suggestion, nil
+}
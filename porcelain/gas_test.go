@@ -0,0 +1,154 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type fakeGasPlumbing struct {
+	previewGas  types.GasUnits
+	previewErr  error
+	pending     []*types.SignedMessage
+	tipsets     map[string]types.TipSet
+	head        types.TipSet
+	minGasPrice types.AttoFIL
+}
+
+func (p *fakeGasPlumbing) MessagePreview(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	return p.previewGas, p.previewErr
+}
+
+func (p *fakeGasPlumbing) MessagePoolPending() []*types.SignedMessage {
+	return p.pending
+}
+
+func (p *fakeGasPlumbing) ChainLs(ctx context.Context) (*chain.TipsetIterator, error) {
+	return chain.IterAncestors(ctx, p, p.head), nil
+}
+
+func (p *fakeGasPlumbing) GetTipSet(key types.SortedCidSet) (types.TipSet, error) {
+	ts, ok := p.tipsets[key.String()]
+	if !ok {
+		return types.UndefTipSet, errors.Errorf("no tipset %s", key)
+	}
+	return ts, nil
+}
+
+func (p *fakeGasPlumbing) ConfigGet(dottedPath string) (interface{}, error) {
+	if dottedPath != "mpool.minGasPrice" {
+		return nil, errors.Errorf("unexpected config key %s", dottedPath)
+	}
+	return p.minGasPrice, nil
+}
+
+// extend adds a tipset with a single block holding msgs on top of the fake's chain.
+func (p *fakeGasPlumbing) extend(t *testing.T, msgs ...*types.SignedMessage) {
+	blk := &types.Block{Messages: msgs}
+	if p.head.Defined() {
+		h, err := p.head.Height()
+		require.NoError(t, err)
+		blk.Height = types.Uint64(h + 1)
+		blk.Parents = p.head.ToSortedCidSet()
+	}
+	p.head = types.RequireNewTipSet(t, blk)
+	if p.tipsets == nil {
+		p.tipsets = make(map[string]types.TipSet)
+	}
+	p.tipsets[p.head.ToSortedCidSet().String()] = p.head
+}
+
+func TestMessageEstimateGasLimit(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := address.NewForTestGetter()
+	from, to := addrs(), addrs()
+
+	t.Run("adds a margin to the previewed gas", func(t *testing.T) {
+		plumbing := &fakeGasPlumbing{previewGas: types.NewGasUnits(100)}
+		gasLimit, err := porcelain.MessageEstimateGasLimit(ctx, plumbing, from, to, "method")
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasUnits(100+100*porcelain.GasEstimateMarginPercent/100), gasLimit)
+	})
+
+	t.Run("does not exceed the block gas limit", func(t *testing.T) {
+		plumbing := &fakeGasPlumbing{previewGas: types.BlockGasLimit}
+		gasLimit, err := porcelain.MessageEstimateGasLimit(ctx, plumbing, from, to, "method")
+		require.NoError(t, err)
+		assert.Equal(t, types.BlockGasLimit, gasLimit)
+	})
+
+	t.Run("value transfers need no gas", func(t *testing.T) {
+		plumbing := &fakeGasPlumbing{previewErr: errors.New("no preview expected")}
+		gasLimit, err := porcelain.MessageEstimateGasLimit(ctx, plumbing, from, to, "")
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasUnits(0), gasLimit)
+	})
+
+	t.Run("fails if the preview fails", func(t *testing.T) {
+		plumbing := &fakeGasPlumbing{previewErr: errors.New("boom")}
+		_, err := porcelain.MessageEstimateGasLimit(ctx, plumbing, from, to, "method")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "boom")
+	})
+}
+
+func TestMessageSuggestGasPrice(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	ki := types.MustGenerateKeyInfo(1, 42)
+	signer := types.NewMockSigner(ki)
+	newMsg := types.NewMessageForTestGetter()
+	msgWithPrice := func(price int64) *types.SignedMessage {
+		msg := newMsg()
+		msg.From = signer.Addresses[0]
+		smsg, err := types.NewSignedMessage(*msg, signer, types.NewGasPrice(price), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	t.Run("suggests the default without samples", func(t *testing.T) {
+		plumbing := &fakeGasPlumbing{}
+		plumbing.extend(t)
+
+		price, err := porcelain.MessageSuggestGasPrice(ctx, plumbing)
+		require.NoError(t, err)
+		assert.True(t, porcelain.DefaultGasPrice.Equal(price), "got %s", price)
+	})
+
+	t.Run("samples recent blocks and the message pool", func(t *testing.T) {
+		plumbing := &fakeGasPlumbing{}
+		// Messages in tipsets older than the oracle looks at are ignored.
+		plumbing.extend(t, msgWithPrice(1000), msgWithPrice(1000), msgWithPrice(1000))
+		for i := 0; i < porcelain.GasPriceOracleTipSets-1; i++ {
+			plumbing.extend(t)
+		}
+		plumbing.extend(t, msgWithPrice(10), msgWithPrice(20), msgWithPrice(30))
+		plumbing.pending = []*types.SignedMessage{msgWithPrice(40), msgWithPrice(50)}
+
+		price, err := porcelain.MessageSuggestGasPrice(ctx, plumbing)
+		require.NoError(t, err)
+		// The 60th percentile of 10, 20, 30, 40, 50.
+		assert.True(t, types.NewGasPrice(30).Equal(price), "got %s", price)
+	})
+
+	t.Run("suggests at least the minimum gas price", func(t *testing.T) {
+		plumbing := &fakeGasPlumbing{minGasPrice: types.NewGasPrice(100)}
+		plumbing.extend(t, msgWithPrice(10))
+
+		price, err := porcelain.MessageSuggestGasPrice(ctx, plumbing)
+		require.NoError(t, err)
+		assert.True(t, types.NewGasPrice(100).Equal(price), "got %s", price)
+	})
+}
@@ -2,10 +2,14 @@ package porcelain
 
 import (
 	"context"
+
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -13,13 +17,17 @@ var log = logging.Logger("porcelain") // nolint: deadcode
 
 // mswdaAPI is the subset of the plumbing.API that MessageSendWithDefaultAddress uses.
 type mswdaAPI interface {
+	mgeAPI
+	mgpAPI
 	MessageSend(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
 	WalletDefaultAddress() (address.Address, error)
 }
 
 // MessageSendWithDefaultAddress calls MessageSend but with a default from
 // address if none is provided. If you don't need a default address provided,
-// use MessageSend instead.
+// use MessageSend instead. A zero gas price is replaced by the price suggested
+// by MessageSuggestGasPrice, and a zero gas limit by the limit estimated by
+// MessageEstimateGasLimit.
 func MessageSendWithDefaultAddress(
 	ctx context.Context,
 	plumbing mswdaAPI,
@@ -40,5 +48,131 @@ func MessageSendWithDefaultAddress(
 		from = ret
 	}
 
+	gasPrice, gasLimit, err := defaultGas(ctx, plumbing, from, to, gasPrice, gasLimit, method, params...)
+	if err != nil {
+		return cid.Undef, err
+	}
+
 	return plumbing.MessageSend(ctx, from, to, value, gasPrice, gasLimit, method, params...)
 }
+
+// msgCreateAPI is the subset of the plumbing.API that MessageCreate uses.
+type msgCreateAPI interface {
+	mgeAPI
+	mgpAPI
+	MessageNextNonce(ctx context.Context, from address.Address) (uint64, error)
+}
+
+// MessageCreate builds an unsigned message from an address with the nonce following the
+// messages from it known to this node, so that it can be signed where its key is kept and
+// submitted with MessageSubmit. Zero gas prices and limits are replaced as for
+// MessageSendWithDefaultAddress.
+func MessageCreate(
+	ctx context.Context,
+	plumbing msgCreateAPI,
+	from,
+	to address.Address,
+	value types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit types.GasUnits,
+	method string,
+	params ...interface{},
+) (*types.MeteredMessage, error) {
+	if from.Empty() {
+		return nil, errors.New("from address is required")
+	}
+
+	encodedParams, err := abi.ToEncodedValues(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid params")
+	}
+
+	nonce, err := plumbing.MessageNextNonce(ctx, from)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed calculating nonce for actor at %s", from)
+	}
+
+	gasPrice, gasLimit, err = defaultGas(ctx, plumbing, from, to, gasPrice, gasLimit, method, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := types.NewMessage(from, to, nonce, value, method, encodedParams)
+	return types.NewMeteredMessage(*msg, gasPrice, gasLimit), nil
+}
+
+// mrAPI is the subset of the plumbing.API that MessageReplaceWithMinGasPrice uses.
+type mrAPI interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	MessageReplace(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error)
+	OutboxQueues() []address.Address
+	OutboxQueueLs(sender address.Address) []*core.QueuedMessage
+}
+
+// MessageReplaceWithMinGasPrice calls MessageReplace to replace a message in the outbound
+// queue.  A zero gas price is replaced by the lowest price that outbids the original by the
+// replace-by-fee margin (mpool.replaceByFeePercent), and a lower price is rejected.
+func MessageReplaceWithMinGasPrice(ctx context.Context, plumbing mrAPI, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	original, err := findOutboxMessage(plumbing, msgCid)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	percent, err := plumbing.ConfigGet("mpool.replaceByFeePercent")
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to read mpool.replaceByFeePercent")
+	}
+	minGasPrice := core.MinReplacementGasPrice(original.GasPrice, percent.(uint))
+
+	if gasPrice.Equal(types.ZeroAttoFIL) {
+		gasPrice = minGasPrice
+	} else if gasPrice.LessThan(minGasPrice) {
+		return cid.Undef, errors.Errorf("gas price %s does not exceed the original's %s by mpool.replaceByFeePercent (%d%%), it must be at least %s",
+			gasPrice, original.GasPrice, percent, minGasPrice)
+	}
+
+	return plumbing.MessageReplace(ctx, msgCid, gasPrice)
+}
+
+// findOutboxMessage returns the message with cid c from the outbound queue.
+func findOutboxMessage(plumbing mrAPI, c cid.Cid) (*types.SignedMessage, error) {
+	for _, sender := range plumbing.OutboxQueues() {
+		for _, qm := range plumbing.OutboxQueueLs(sender) {
+			mc, err := qm.Msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if mc.Equals(c) {
+				return qm.Msg, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("message %s is not in the outbound queue", c)
+}
+
+type gasDefaultsAPI interface {
+	mgeAPI
+	mgpAPI
+}
+
+// defaultGas replaces a zero gas price by the price suggested by MessageSuggestGasPrice, and
+// a zero gas limit by the limit estimated by MessageEstimateGasLimit.
+func defaultGas(ctx context.Context, plumbing gasDefaultsAPI, from, to address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (types.AttoFIL, types.GasUnits, error) {
+	if gasPrice.Equal(types.ZeroAttoFIL) {
+		suggested, err := MessageSuggestGasPrice(ctx, plumbing)
+		if err != nil {
+			return types.ZeroAttoFIL, types.NewGasUnits(0), errors.Wrap(err, "failed to suggest a gas price")
+		}
+		gasPrice = suggested
+	}
+
+	if gasLimit == types.NewGasUnits(0) {
+		estimated, err := MessageEstimateGasLimit(ctx, plumbing, from, to, method, params...)
+		if err != nil {
+			return types.ZeroAttoFIL, types.NewGasUnits(0), err
+		}
+		gasLimit = estimated
+	}
+
+	return gasPrice, gasLimit, nil
+}
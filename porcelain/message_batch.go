@@ -0,0 +1,148 @@
+package porcelain
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// BatchMessage is one of the messages sent together by MessageSendBatchWithDefaultAddress.
+type BatchMessage struct {
+	To     address.Address
+	Value  types.AttoFIL
+	Method string
+	Params []byte // abi encoded, as the parameters of the method's signature
+}
+
+// BatchPreview is the result of previewing a batch of messages.
+type BatchPreview struct {
+	GasUsed      []types.GasUnits // gas used by each message
+	TotalGasUsed types.GasUnits
+	TotalValue   types.AttoFIL
+}
+
+// mpbAPI is the subset of the plumbing.API that MessagePreviewBatch uses.
+type mpbAPI interface {
+	ActorGetSignature(ctx context.Context, actorAddr address.Address, method string) (*exec.FunctionSignature, error)
+	MessagePreview(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
+	WalletDefaultAddress() (address.Address, error)
+}
+
+// MessagePreviewBatch previews each message of a batch against the latest state, and totals
+// the gas they use and the value they transfer. It uses the default from address if none is
+// given. Each message is previewed on its own, ignoring the effects of the others.
+func MessagePreviewBatch(ctx context.Context, plumbing mpbAPI, from address.Address, batch []BatchMessage) (*BatchPreview, error) {
+	from, err := batchFromAddress(plumbing, from)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &BatchPreview{TotalValue: types.ZeroAttoFIL}
+	for i, bm := range batch {
+		params, err := decodeBatchParams(ctx, plumbing, bm)
+		if err != nil {
+			return nil, errors.Wrapf(err, "message %d", i)
+		}
+
+		gasUsed, err := plumbing.MessagePreview(ctx, from, bm.To, bm.Method, params...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to preview message %d", i)
+		}
+
+		preview.GasUsed = append(preview.GasUsed, gasUsed)
+		preview.TotalGasUsed += gasUsed
+		preview.TotalValue = preview.TotalValue.Add(bm.Value)
+	}
+	return preview, nil
+}
+
+// msbAPI is the subset of the plumbing.API that MessageSendBatchWithDefaultAddress uses.
+type msbAPI interface {
+	mpbAPI
+	mgpAPI
+	MessageSendBatch(ctx context.Context, from address.Address, batch []core.BatchMessage) ([]cid.Cid, error)
+}
+
+// MessageSendBatchWithDefaultAddress calls MessageSendBatch to send a batch of messages from
+// an address with consecutive nonces, and returns their cids in order. It uses the default
+// from address if none is given. A zero gas price is replaced by the price suggested by
+// MessageSuggestGasPrice, and a zero gas limit by the limit MessageEstimateGasLimit estimates
+// for each message. No message is sent unless all are valid, but if sending fails part way
+// the cids of the messages sent are returned with the error.
+func MessageSendBatchWithDefaultAddress(ctx context.Context, plumbing msbAPI, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, batch []BatchMessage) ([]cid.Cid, error) {
+	from, err := batchFromAddress(plumbing, from)
+	if err != nil {
+		return nil, err
+	}
+
+	if gasPrice.Equal(types.ZeroAttoFIL) {
+		gasPrice, err = MessageSuggestGasPrice(ctx, plumbing)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to suggest a gas price")
+		}
+	}
+
+	msgs := make([]core.BatchMessage, len(batch))
+	for i, bm := range batch {
+		params, err := decodeBatchParams(ctx, plumbing, bm)
+		if err != nil {
+			return nil, errors.Wrapf(err, "message %d", i)
+		}
+
+		msgGasLimit := gasLimit
+		if msgGasLimit == types.NewGasUnits(0) {
+			msgGasLimit, err = MessageEstimateGasLimit(ctx, plumbing, from, bm.To, bm.Method, params...)
+			if err != nil {
+				return nil, errors.Wrapf(err, "message %d", i)
+			}
+		}
+
+		msgs[i] = core.BatchMessage{
+			To:       bm.To,
+			Value:    bm.Value,
+			GasPrice: gasPrice,
+			GasLimit: msgGasLimit,
+			Method:   bm.Method,
+			Params:   bm.Params,
+		}
+	}
+
+	return plumbing.MessageSendBatch(ctx, from, msgs)
+}
+
+func batchFromAddress(plumbing mpbAPI, from address.Address) (address.Address, error) {
+	if !from.Empty() {
+		return from, nil
+	}
+	ret, err := plumbing.WalletDefaultAddress()
+	if (err != nil && err == ErrNoDefaultFromAddress) || ret.Empty() {
+		return address.Undef, ErrNoDefaultFromAddress
+	}
+	return ret, nil
+}
+
+// decodeBatchParams decodes the parameters of a batch message according to the signature of
+// its method, which both checks them and allows the message to be previewed.
+func decodeBatchParams(ctx context.Context, plumbing mpbAPI, bm BatchMessage) ([]interface{}, error) {
+	if len(bm.Params) == 0 {
+		return nil, nil
+	}
+
+	sig, err := plumbing.ActorGetSignature(ctx, bm.To, bm.Method)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get signature of method %s of %s", bm.Method, bm.To)
+	}
+
+	vals, err := abi.DecodeValues(bm.Params, sig.Params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid params for method %s", bm.Method)
+	}
+	return abi.FromValues(vals), nil
+}
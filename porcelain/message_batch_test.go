@@ -0,0 +1,149 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type fakeBatchPlumbing struct {
+	fakeGasPlumbing
+	defaultAddr address.Address
+	signature   *exec.FunctionSignature
+	params      [][]interface{} // params of each previewed message
+	sentFrom    address.Address
+	sent        []core.BatchMessage
+}
+
+func (p *fakeBatchPlumbing) ActorGetSignature(ctx context.Context, actorAddr address.Address, method string) (*exec.FunctionSignature, error) {
+	if p.signature == nil {
+		return nil, errors.New("no signature")
+	}
+	return p.signature, nil
+}
+
+func (p *fakeBatchPlumbing) MessagePreview(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	p.params = append(p.params, params)
+	return p.fakeGasPlumbing.MessagePreview(ctx, from, to, method, params...)
+}
+
+func (p *fakeBatchPlumbing) WalletDefaultAddress() (address.Address, error) {
+	return p.defaultAddr, nil
+}
+
+func (p *fakeBatchPlumbing) MessageSendBatch(ctx context.Context, from address.Address, batch []core.BatchMessage) ([]cid.Cid, error) {
+	p.sentFrom = from
+	p.sent = batch
+	cids := make([]cid.Cid, len(batch))
+	for i := range batch {
+		cids[i] = types.SomeCid()
+	}
+	return cids, nil
+}
+
+func TestMessagePreviewBatch(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := address.NewForTestGetter()
+	from, to := addrs(), addrs()
+
+	t.Run("totals gas and value", func(t *testing.T) {
+		plumbing := &fakeBatchPlumbing{defaultAddr: from}
+		plumbing.previewGas = types.NewGasUnits(100)
+
+		preview, err := porcelain.MessagePreviewBatch(ctx, plumbing, address.Undef, []porcelain.BatchMessage{
+			{To: to, Value: types.NewAttoFILFromFIL(1)},
+			{To: to, Value: types.NewAttoFILFromFIL(2), Method: "method"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []types.GasUnits{100, 100}, preview.GasUsed)
+		assert.Equal(t, types.NewGasUnits(200), preview.TotalGasUsed)
+		assert.True(t, types.NewAttoFILFromFIL(3).Equal(preview.TotalValue))
+	})
+
+	t.Run("decodes params with the method signature", func(t *testing.T) {
+		plumbing := &fakeBatchPlumbing{
+			defaultAddr: from,
+			signature:   &exec.FunctionSignature{Params: []abi.Type{abi.String}},
+		}
+		params, err := abi.ToEncodedValues("hello")
+		require.NoError(t, err)
+
+		_, err = porcelain.MessagePreviewBatch(ctx, plumbing, from, []porcelain.BatchMessage{
+			{To: to, Value: types.ZeroAttoFIL, Method: "method", Params: params},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]interface{}{{"hello"}}, plumbing.params)
+	})
+
+	t.Run("fails on params not matching the signature", func(t *testing.T) {
+		plumbing := &fakeBatchPlumbing{
+			defaultAddr: from,
+			signature:   &exec.FunctionSignature{Params: []abi.Type{abi.String, abi.String}},
+		}
+		params, err := abi.ToEncodedValues("hello")
+		require.NoError(t, err)
+
+		_, err = porcelain.MessagePreviewBatch(ctx, plumbing, from, []porcelain.BatchMessage{
+			{To: to, Value: types.ZeroAttoFIL, Method: "method", Params: params},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestMessageSendBatchWithDefaultAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := address.NewForTestGetter()
+	from, to := addrs(), addrs()
+
+	t.Run("defaults the from address, gas price and gas limits", func(t *testing.T) {
+		plumbing := &fakeBatchPlumbing{defaultAddr: from}
+		plumbing.previewGas = types.NewGasUnits(100)
+		plumbing.extend(t)
+
+		batch := []porcelain.BatchMessage{
+			{To: to, Value: types.NewAttoFILFromFIL(1)},
+			{To: to, Value: types.NewAttoFILFromFIL(2), Method: "method"},
+		}
+		cids, err := porcelain.MessageSendBatchWithDefaultAddress(ctx, plumbing, address.Undef, types.ZeroAttoFIL, types.NewGasUnits(0), batch)
+		require.NoError(t, err)
+		assert.Len(t, cids, 2)
+
+		assert.Equal(t, from, plumbing.sentFrom)
+		require.Len(t, plumbing.sent, 2)
+		for i, sent := range plumbing.sent {
+			assert.Equal(t, batch[i].To, sent.To)
+			assert.True(t, batch[i].Value.Equal(sent.Value))
+			assert.True(t, porcelain.DefaultGasPrice.Equal(sent.GasPrice))
+		}
+		assert.Equal(t, types.NewGasUnits(0), plumbing.sent[0].GasLimit)
+		assert.Equal(t, types.NewGasUnits(100+100*porcelain.GasEstimateMarginPercent/100), plumbing.sent[1].GasLimit)
+	})
+
+	t.Run("keeps the given gas price and limit", func(t *testing.T) {
+		plumbing := &fakeBatchPlumbing{}
+
+		batch := []porcelain.BatchMessage{{To: to, Value: types.NewAttoFILFromFIL(1), Method: "method"}}
+		_, err := porcelain.MessageSendBatchWithDefaultAddress(ctx, plumbing, from, types.NewGasPrice(5), types.NewGasUnits(300), batch)
+		require.NoError(t, err)
+
+		require.Len(t, plumbing.sent, 1)
+		assert.True(t, types.NewGasPrice(5).Equal(plumbing.sent[0].GasPrice))
+		assert.Equal(t, types.NewGasUnits(300), plumbing.sent[0].GasLimit)
+	})
+}
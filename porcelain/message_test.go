@@ -0,0 +1,132 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type fakeMessageCreatePlumbing struct {
+	fakeGasPlumbing
+	nonce uint64
+}
+
+func (p *fakeMessageCreatePlumbing) MessageNextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	return p.nonce, nil
+}
+
+func TestMessageCreate(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := address.NewForTestGetter()
+	from, to := addrs(), addrs()
+
+	t.Run("fills in the nonce", func(t *testing.T) {
+		plumbing := &fakeMessageCreatePlumbing{nonce: 7}
+
+		msg, err := porcelain.MessageCreate(ctx, plumbing, from, to, types.NewAttoFILFromFIL(2), types.NewGasPrice(3), types.NewGasUnits(4), "")
+		require.NoError(t, err)
+		assert.Equal(t, from, msg.From)
+		assert.Equal(t, to, msg.To)
+		assert.Equal(t, types.Uint64(7), msg.Nonce)
+		assert.True(t, types.NewAttoFILFromFIL(2).Equal(msg.Value))
+		assert.True(t, types.NewGasPrice(3).Equal(msg.GasPrice))
+		assert.Equal(t, types.NewGasUnits(4), msg.GasLimit)
+	})
+
+	t.Run("defaults the gas price and limit", func(t *testing.T) {
+		plumbing := &fakeMessageCreatePlumbing{}
+		plumbing.previewGas = types.NewGasUnits(100)
+		plumbing.extend(t)
+
+		msg, err := porcelain.MessageCreate(ctx, plumbing, from, to, types.ZeroAttoFIL, types.ZeroAttoFIL, types.NewGasUnits(0), "method")
+		require.NoError(t, err)
+		assert.True(t, porcelain.DefaultGasPrice.Equal(msg.GasPrice))
+		assert.Equal(t, types.NewGasUnits(100+100*porcelain.GasEstimateMarginPercent/100), msg.GasLimit)
+	})
+
+	t.Run("requires a from address", func(t *testing.T) {
+		_, err := porcelain.MessageCreate(ctx, &fakeMessageCreatePlumbing{}, address.Undef, to, types.ZeroAttoFIL, types.NewGasPrice(1), types.NewGasUnits(0), "")
+		assert.Error(t, err)
+	})
+}
+
+type fakeMessageReplacePlumbing struct {
+	config   *config.Config
+	queued   *types.SignedMessage
+	replaced types.AttoFIL
+}
+
+func (p *fakeMessageReplacePlumbing) ConfigGet(dottedPath string) (interface{}, error) {
+	return p.config.Get(dottedPath)
+}
+
+func (p *fakeMessageReplacePlumbing) MessageReplace(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	p.replaced = gasPrice
+	return msgCid, nil
+}
+
+func (p *fakeMessageReplacePlumbing) OutboxQueues() []address.Address {
+	return []address.Address{p.queued.From}
+}
+
+func (p *fakeMessageReplacePlumbing) OutboxQueueLs(sender address.Address) []*core.QueuedMessage {
+	return []*core.QueuedMessage{{Msg: p.queued}}
+}
+
+func TestMessageReplaceWithMinGasPrice(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := address.NewForTestGetter()
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+
+	msg := types.NewMessage(signer.Addresses[0], addrs(), 0, types.ZeroAttoFIL, "", nil)
+	queued, err := types.NewSignedMessage(*msg, &signer, types.NewGasPrice(100), types.NewGasUnits(0))
+	require.NoError(t, err)
+	queuedCid, err := queued.Cid()
+	require.NoError(t, err)
+
+	newPlumbing := func() *fakeMessageReplacePlumbing {
+		conf := config.NewDefaultConfig()
+		conf.Mpool.ReplaceByFeePercent = 10
+		return &fakeMessageReplacePlumbing{config: conf, queued: queued}
+	}
+
+	t.Run("defaults to the lowest outbidding gas price", func(t *testing.T) {
+		plumbing := newPlumbing()
+		_, err := porcelain.MessageReplaceWithMinGasPrice(ctx, plumbing, queuedCid, types.ZeroAttoFIL)
+		require.NoError(t, err)
+		assert.True(t, types.NewGasPrice(110).Equal(plumbing.replaced))
+	})
+
+	t.Run("uses a higher gas price", func(t *testing.T) {
+		plumbing := newPlumbing()
+		_, err := porcelain.MessageReplaceWithMinGasPrice(ctx, plumbing, queuedCid, types.NewGasPrice(200))
+		require.NoError(t, err)
+		assert.True(t, types.NewGasPrice(200).Equal(plumbing.replaced))
+	})
+
+	t.Run("rejects a gas price that does not outbid the original", func(t *testing.T) {
+		_, err := porcelain.MessageReplaceWithMinGasPrice(ctx, newPlumbing(), queuedCid, types.NewGasPrice(105))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be at least")
+	})
+
+	t.Run("fails for messages not in the outbound queue", func(t *testing.T) {
+		_, err := porcelain.MessageReplaceWithMinGasPrice(ctx, newPlumbing(), types.NewCidForTestGetter()(), types.ZeroAttoFIL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not in the outbound queue")
+	})
+}
@@ -74,7 +74,7 @@ func (mpc *minerCreate) MessageWait(ctx context.Context, msgCid cid.Cid, cb func
 }
 
 func (mpc *minerCreate) WalletDefaultAddress() (address.Address, error) {
-	return wallet.NewAddress(mpc.wallet)
+	return wallet.NewAddress(mpc.wallet, address.SECP256K1)
 }
 
 func (mpc *minerCreate) WalletGetPubKeyForAddress(addr address.Address) ([]byte, error) {
@@ -150,7 +150,7 @@ func (mpc *minerPreviewCreate) NetworkGetPeerID() peer.ID {
 }
 
 func (mpc *minerPreviewCreate) WalletDefaultAddress() (address.Address, error) {
-	return wallet.NewAddress(mpc.wallet)
+	return wallet.NewAddress(mpc.wallet, address.SECP256K1)
 }
 
 func (mpc *minerPreviewCreate) WalletFind(address address.Address) (wallet.Backend, error) {
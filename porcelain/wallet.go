@@ -8,6 +8,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet/hd"
 )
 
 // ErrNoDefaultFromAddress is returned when a default wallet address couldn't be determined (eg, there are zero addresses in the wallet).
@@ -61,3 +62,101 @@ func WalletDefaultAddress(plumbing wdaPlumbing) (address.Address, error) {
 
 	return address.Undef, ErrNoDefaultFromAddress
 }
+
+type wimPlumbing interface {
+	WalletSetSeed(seed []byte) error
+}
+
+// WalletInitMnemonic generates a new mnemonic and sets its seed as the wallet's HD seed, from
+// which new secp256k1 addresses are derived. It returns the mnemonic, which recovers all
+// those addresses.
+func WalletInitMnemonic(plumbing wimPlumbing) (string, error) {
+	mnemonic, err := hd.NewMnemonic()
+	if err != nil {
+		return "", err
+	}
+	seed, err := hd.SeedFromMnemonic(mnemonic)
+	if err != nil {
+		return "", err
+	}
+
+	if err := plumbing.WalletSetSeed(seed); err != nil {
+		return "", err
+	}
+	return mnemonic, nil
+}
+
+// WalletRecoverGap is the number of consecutive unused addresses after which WalletRecover
+// stops looking for used addresses derived from a mnemonic.
+const WalletRecoverGap = 20
+
+type wrPlumbing interface {
+	ActorGet(ctx context.Context, addr address.Address) (*actor.Actor, error)
+	WalletAddresses() []address.Address
+	WalletSetSeed(seed []byte) error
+	WalletDeriveAddress(index uint32) (address.Address, error)
+	WalletNewAddress(protocol address.Protocol) (address.Address, error)
+}
+
+// WalletRecover sets the seed of the mnemonic as the wallet's HD seed, and adds the addresses
+// derived from it to the wallet, up to the last one with an actor in the state, or just the
+// first if none has. Addresses are scanned until WalletRecoverGap consecutive ones have no
+// actor. The wallet must have no seed, or the mnemonic's, in which case only the addresses
+// it lacks are added.
+//
+// The keys the wallet already holds, such as imported keys, BLS keys and keys created before
+// it had a seed, are kept. They are not derived from the mnemonic, which doesn't recover them;
+// only an export backs them up.
+func WalletRecover(ctx context.Context, plumbing wrPlumbing, mnemonic string) ([]address.Address, error) {
+	seed, err := hd.SeedFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	if err := plumbing.WalletSetSeed(seed); err != nil {
+		return nil, err
+	}
+
+	count := uint32(1)
+	for index, unused := uint32(0), 0; unused < WalletRecoverGap; index++ {
+		addr, err := plumbing.WalletDeriveAddress(index)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = plumbing.ActorGet(ctx, addr)
+		if err == nil {
+			count = index + 1
+			unused = 0
+			continue
+		}
+		if !state.IsActorNotFoundError(err) {
+			return nil, errors.Wrapf(err, "failed to look up actor of %s", addr)
+		}
+		unused++
+	}
+
+	held := make(map[address.Address]bool)
+	for _, addr := range plumbing.WalletAddresses() {
+		held[addr] = true
+	}
+
+	out := make([]address.Address, count)
+	for i := range out {
+		if out[i], err = plumbing.WalletDeriveAddress(uint32(i)); err != nil {
+			return nil, err
+		}
+		if held[out[i]] {
+			continue
+		}
+
+		// The wallet stores derived keys in order, so the next one it derives is this one.
+		addr, err := plumbing.WalletNewAddress(address.SECP256K1)
+		if err != nil {
+			return nil, err
+		}
+		if addr != out[i] {
+			return nil, errors.Errorf("wallet derived %s instead of %s, the address with index %d", addr, out[i], i)
+		}
+	}
+	return out, nil
+}
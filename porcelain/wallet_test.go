@@ -14,6 +14,7 @@ import (
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/wallet"
+	"github.com/filecoin-project/go-filecoin/wallet/hd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -55,7 +56,7 @@ func (wdatp *wdaTestPlumbing) WalletAddresses() []address.Address {
 }
 
 func (wdatp *wdaTestPlumbing) WalletNewAddress() (address.Address, error) {
-	return wallet.NewAddress(wdatp.wallet)
+	return wallet.NewAddress(wdatp.wallet, address.SECP256K1)
 }
 
 func TestWalletBalance(t *testing.T) {
@@ -111,6 +112,169 @@ func TestWalletDefaultAddress(t *testing.T) {
 	})
 }
 
+type wrTestPlumbing struct {
+	wallet *wallet.Wallet
+	actors map[address.Address]bool
+}
+
+type actorNotFoundError struct{}
+
+func (actorNotFoundError) Error() string       { return "actor not found" }
+func (actorNotFoundError) ActorNotFound() bool { return true }
+
+func newWrTestPlumbing(t *testing.T) *wrTestPlumbing {
+	backend, err := wallet.NewDSBackend(repo.NewInMemoryRepo().WalletDatastore())
+	require.NoError(t, err)
+	return &wrTestPlumbing{
+		wallet: wallet.New(backend),
+		actors: make(map[address.Address]bool),
+	}
+}
+
+func (wrtp *wrTestPlumbing) ActorGet(ctx context.Context, addr address.Address) (*actor.Actor, error) {
+	if !wrtp.actors[addr] {
+		return nil, actorNotFoundError{}
+	}
+	return actor.NewActor(cid.Undef, types.ZeroAttoFIL), nil
+}
+
+func (wrtp *wrTestPlumbing) WalletAddresses() []address.Address {
+	return wrtp.wallet.Addresses()
+}
+
+func (wrtp *wrTestPlumbing) WalletSetSeed(seed []byte) error {
+	return wallet.SetSeed(wrtp.wallet, seed)
+}
+
+func (wrtp *wrTestPlumbing) WalletDeriveAddress(index uint32) (address.Address, error) {
+	return wallet.DeriveAddress(wrtp.wallet, index)
+}
+
+func (wrtp *wrTestPlumbing) WalletNewAddress(protocol address.Protocol) (address.Address, error) {
+	return wallet.NewAddress(wrtp.wallet, protocol)
+}
+
+func TestWalletInitMnemonic(t *testing.T) {
+	tf.UnitTest(t)
+
+	wrtp := newWrTestPlumbing(t)
+	mnemonic, err := porcelain.WalletInitMnemonic(wrtp)
+	require.NoError(t, err)
+
+	addr, err := wrtp.WalletNewAddress(address.SECP256K1)
+	require.NoError(t, err)
+
+	t.Run("the mnemonic recovers the addresses", func(t *testing.T) {
+		recovered := newWrTestPlumbing(t)
+		addrs, err := porcelain.WalletRecover(context.Background(), recovered, mnemonic)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{addr}, addrs)
+	})
+
+	t.Run("the seed can't be replaced", func(t *testing.T) {
+		_, err := porcelain.WalletInitMnemonic(wrtp)
+		assert.Error(t, err)
+	})
+}
+
+func TestWalletRecover(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mnemonic, err := hd.NewMnemonic()
+	require.NoError(t, err)
+
+	// derive the addresses of the mnemonic in another wallet
+	derived := newWrTestPlumbing(t)
+	seed, err := hd.SeedFromMnemonic(mnemonic)
+	require.NoError(t, err)
+	require.NoError(t, derived.WalletSetSeed(seed))
+	deriveAddress := func(index uint32) address.Address {
+		addr, err := derived.WalletDeriveAddress(index)
+		require.NoError(t, err)
+		return addr
+	}
+
+	t.Run("recovers addresses up to the last with an actor", func(t *testing.T) {
+		wrtp := newWrTestPlumbing(t)
+		wrtp.actors[deriveAddress(0)] = true
+		wrtp.actors[deriveAddress(3)] = true
+		// within the gap of unused addresses after the previous one
+		last := porcelain.WalletRecoverGap + 3
+		wrtp.actors[deriveAddress(uint32(last))] = true
+
+		addrs, err := porcelain.WalletRecover(ctx, wrtp, mnemonic)
+		require.NoError(t, err)
+		require.Len(t, addrs, last+1)
+		for i, addr := range addrs {
+			assert.Equal(t, deriveAddress(uint32(i)), addr)
+		}
+		assert.Len(t, wrtp.wallet.Addresses(), last+1)
+	})
+
+	t.Run("stops looking after a gap of unused addresses", func(t *testing.T) {
+		wrtp := newWrTestPlumbing(t)
+		wrtp.actors[deriveAddress(porcelain.WalletRecoverGap)] = true
+
+		addrs, err := porcelain.WalletRecover(ctx, wrtp, mnemonic)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{deriveAddress(0)}, addrs)
+	})
+
+	t.Run("keeps the keys of a wallet without a seed", func(t *testing.T) {
+		wrtp := newWrTestPlumbing(t)
+		random, err := wrtp.WalletNewAddress(address.SECP256K1)
+		require.NoError(t, err)
+		randomBLS, err := wrtp.WalletNewAddress(address.BLS)
+		require.NoError(t, err)
+		wrtp.actors[deriveAddress(1)] = true
+
+		addrs, err := porcelain.WalletRecover(ctx, wrtp, mnemonic)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{deriveAddress(0), deriveAddress(1)}, addrs)
+
+		held := wrtp.wallet.Addresses()
+		assert.Len(t, held, 4)
+		assert.True(t, isInList(random, held))
+		assert.True(t, isInList(randomBLS, held))
+
+		// new secp256k1 addresses are derived from the seed
+		next, err := wrtp.WalletNewAddress(address.SECP256K1)
+		require.NoError(t, err)
+		assert.Equal(t, deriveAddress(2), next)
+	})
+
+	t.Run("recovers again into a wallet with the mnemonic's seed", func(t *testing.T) {
+		wrtp := newWrTestPlumbing(t)
+		_, err := porcelain.WalletRecover(ctx, wrtp, mnemonic)
+		require.NoError(t, err)
+		assert.Len(t, wrtp.wallet.Addresses(), 1)
+
+		// an address was used since
+		wrtp.actors[deriveAddress(2)] = true
+		addrs, err := porcelain.WalletRecover(ctx, wrtp, mnemonic)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{deriveAddress(0), deriveAddress(1), deriveAddress(2)}, addrs)
+		assert.Len(t, wrtp.wallet.Addresses(), 3)
+	})
+
+	t.Run("fails on a wallet with another seed", func(t *testing.T) {
+		wrtp := newWrTestPlumbing(t)
+		_, err := porcelain.WalletInitMnemonic(wrtp)
+		require.NoError(t, err)
+
+		_, err = porcelain.WalletRecover(ctx, wrtp, mnemonic)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already has an HD seed")
+	})
+
+	t.Run("fails on an invalid mnemonic", func(t *testing.T) {
+		wrtp := newWrTestPlumbing(t)
+		_, err := porcelain.WalletRecover(ctx, wrtp, "not a mnemonic")
+		assert.Error(t, err)
+	})
+}
+
 func isInList(needle address.Address, haystack []address.Address) bool {
 	for _, a := range haystack {
 		if a == needle {
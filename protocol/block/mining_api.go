@@ -21,6 +21,8 @@ type MiningAPI struct {
 	startMiningFunc  func(context.Context) error
 	stopMiningFunc   func(context.Context)
 	createWorkerFunc func(ctx context.Context) (mining.Worker, error)
+
+	createTemplateGeneratorFunc func(ctx context.Context) (mining.TemplateGenerator, error)
 }
 
 // New creates a new MiningAPI instance with the provided deps
@@ -31,6 +33,7 @@ func New(
 	startMiningFunc func(context.Context) error,
 	stopMiningfunc func(context.Context),
 	createWorkerFunc func(ctx context.Context) (mining.Worker, error),
+	createTemplateGeneratorFunc func(ctx context.Context) (mining.TemplateGenerator, error),
 ) MiningAPI {
 	return MiningAPI{
 		addNewBlockFunc:  addNewBlockFunc,
@@ -39,6 +42,8 @@ func New(
 		startMiningFunc:  startMiningFunc,
 		stopMiningFunc:   stopMiningfunc,
 		createWorkerFunc: createWorkerFunc,
+
+		createTemplateGeneratorFunc: createTemplateGeneratorFunc,
 	}
 }
 
@@ -78,3 +83,26 @@ func (a *MiningAPI) MiningStart(ctx context.Context) error {
 func (a *MiningAPI) MiningStop(ctx context.Context) {
 	a.stopMiningFunc(ctx)
 }
+
+// MiningTemplate returns an unsigned candidate block on the current head, with
+// the messages selected from the pool, the resulting state root and the
+// message receipts.  The block is neither signed nor published.
+func (a *MiningAPI) MiningTemplate(ctx context.Context) (*types.Block, error) {
+	ts, err := a.chainReader.GetTipSet(a.chainReader.GetHead())
+	if err != nil {
+		return nil, err
+	}
+
+	generator, err := a.createTemplateGeneratorFunc(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return generator.GenerateTemplate(ctx, ts, 0)
+}
+
+// MiningSubmit adds a block completed from a template to the chain and
+// publishes it.
+func (a *MiningAPI) MiningSubmit(ctx context.Context, blk *types.Block) error {
+	return a.addNewBlockFunc(ctx, blk)
+}
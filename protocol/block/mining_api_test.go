@@ -8,8 +8,10 @@ import (
 	req "github.com/stretchr/testify/require"
 	"testing"
 
+	"github.com/filecoin-project/go-filecoin/mining"
 	"github.com/filecoin-project/go-filecoin/node"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
 )
 
 func TestTrivialNew(t *testing.T) {
@@ -70,6 +72,56 @@ func TestMiningAPI_MiningStop(t *testing.T) {
 	assert.False(nd.IsMining())
 }
 
+func TestMiningAPI_MiningTemplate(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert := ast.New(t)
+	require := req.New(t)
+	ctx := context.Background()
+	api, nd := newAPI(t, assert)
+
+	require.NoError(nd.Start(ctx))
+	defer nd.Stop(ctx)
+
+	head, err := nd.ChainReader.GetTipSet(nd.ChainReader.GetHead())
+	require.NoError(err)
+	headHeight, err := head.Height()
+	require.NoError(err)
+
+	blk, err := api.MiningTemplate(ctx)
+	require.NoError(err)
+	assert.Equal(head.ToSortedCidSet(), blk.Parents)
+	assert.Equal(types.Uint64(headHeight+1), blk.Height)
+	assert.True(blk.StateRoot.Defined())
+	assert.Empty(blk.BlockSig)
+
+	// The template is not added to the chain.
+	assert.Equal(head.ToSortedCidSet(), nd.ChainReader.GetHead())
+}
+
+func TestMiningAPI_MiningSubmit(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert := ast.New(t)
+	require := req.New(t)
+	ctx := context.Background()
+	api, nd := newAPI(t, assert)
+
+	require.NoError(nd.Start(ctx))
+	defer nd.Stop(ctx)
+
+	head, err := nd.ChainReader.GetTipSet(nd.ChainReader.GetHead())
+	require.NoError(err)
+	worker, err := nd.CreateMiningWorker(ctx)
+	require.NoError(err)
+	res, err := mining.MineOnce(ctx, worker, mining.MineDelayTest, head)
+	require.NoError(err)
+	require.NoError(res.Err)
+
+	require.NoError(api.MiningSubmit(ctx, res.NewBlock))
+	assert.Equal(types.NewSortedCidSet(res.NewBlock.Cid()), nd.ChainReader.GetHead())
+}
+
 func newAPI(t *testing.T, assert *ast.Assertions) (bapi.MiningAPI, *node.Node) {
 	seed := node.MakeChainSeed(t, node.TestGenCfg)
 	configOpts := []node.ConfigOpt{}
@@ -80,7 +132,7 @@ func newAPI(t *testing.T, assert *ast.Assertions) (bapi.MiningAPI, *node.Node) {
 	bt := nd.PorcelainAPI.BlockTime()
 	seed.GiveKey(t, nd, 0)
 	mAddr, moAddr := seed.GiveMiner(t, nd, 0)
-	_, err := storage.NewMiner(mAddr, moAddr, nd, nd.Repo.DealsDatastore(), nd.PorcelainAPI)
+	_, err := storage.NewMiner(mAddr, moAddr, nd.SectorBuilder(), nd, nd.Repo.DealsDatastore(), nd.PorcelainAPI)
 	assert.NoError(err)
 	return bapi.New(
 		nd.AddNewBlock,
@@ -88,5 +140,6 @@ func newAPI(t *testing.T, assert *ast.Assertions) (bapi.MiningAPI, *node.Node) {
 		bt,
 		nd.StartMining,
 		nd.StopMining,
-		nd.CreateMiningWorker), nd
+		nd.CreateMiningWorker,
+		nd.CreateTemplateGenerator), nd
 }
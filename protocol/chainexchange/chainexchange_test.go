@@ -0,0 +1,286 @@
+package chainexchange
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type mapBlockProvider map[cid.Cid]*types.Block
+
+func (m mapBlockProvider) GetBlock(ctx context.Context, c cid.Cid) (*types.Block, error) {
+	blk, ok := m[c]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", c.String())
+	}
+	return blk, nil
+}
+
+func blockCids(blks []*types.Block) types.SortedCidSet {
+	var cids types.SortedCidSet
+	for _, blk := range blks {
+		(&cids).Add(blk.Cid())
+	}
+	return cids
+}
+
+// makeChain returns a chain of n single block tipsets on top of a genesis
+// block, from genesis up.
+func makeChain(t *testing.T, n int) ([]types.TipSet, mapBlockProvider) {
+	blocks := mapBlockProvider{}
+	genesis := &types.Block{Nonce: 451}
+	blocks[genesis.Cid()] = genesis
+	chain := []types.TipSet{th.RequireNewTipSet(t, genesis)}
+	for i := 1; i <= n; i++ {
+		parent := chain[len(chain)-1]
+		blk := &types.Block{
+			Parents: parent.ToSortedCidSet(),
+			Height:  types.Uint64(i),
+		}
+		blocks[blk.Cid()] = blk
+		chain = append(chain, th.RequireNewTipSet(t, blk))
+	}
+	return chain, blocks
+}
+
+func TestGetTipSets(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 3)
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+	a, b, c := mn.Hosts()[0], mn.Hosts()[1], mn.Hosts()[2]
+
+	chain, blocks := makeChain(t, 10)
+	NewServer(a, blocks)
+	client := NewClient(b, th.NewFakeBlockValidator())
+
+	t.Run("returns consecutive tipsets from start", func(t *testing.T) {
+		tipsets, err := client.GetTipSets(ctx, a.ID(), chain[10].ToSortedCidSet(), 0, 4)
+		require.NoError(t, err)
+		require.Len(t, tipsets, 4)
+		for i, ts := range tipsets {
+			assert.Equal(t, chain[10-i].ToSortedCidSet(), ts.ToSortedCidSet())
+		}
+	})
+
+	t.Run("skips tipsets below start", func(t *testing.T) {
+		tipsets, err := client.GetTipSets(ctx, a.ID(), chain[10].ToSortedCidSet(), 3, 4)
+		require.NoError(t, err)
+		require.Len(t, tipsets, 4)
+		for i, ts := range tipsets {
+			assert.Equal(t, chain[7-i].ToSortedCidSet(), ts.ToSortedCidSet())
+		}
+	})
+
+	t.Run("stops at genesis", func(t *testing.T) {
+		tipsets, err := client.GetTipSets(ctx, a.ID(), chain[2].ToSortedCidSet(), 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, tipsets, 3)
+	})
+
+	t.Run("errors on unknown start", func(t *testing.T) {
+		unknown := &types.Block{Nonce: 1}
+		_, err := client.GetTipSets(ctx, a.ID(), types.NewSortedCidSet(unknown.Cid()), 0, 10)
+		require.Error(t, err)
+		// Not having the chain is no fault of the peer.
+		assert.NotEqual(t, ErrInvalidResponse, errors.Cause(err))
+	})
+
+	t.Run("errors on peers not serving the protocol", func(t *testing.T) {
+		_, err := client.GetTipSets(ctx, c.ID(), chain[10].ToSortedCidSet(), 0, 4)
+		require.Error(t, err)
+		assert.Equal(t, ErrPeerUnavailable, errors.Cause(err))
+	})
+}
+
+func TestServerStatus(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	chain, blocks := makeChain(t, 10)
+	server := &Server{blocks: blocks}
+	head := chain[10].ToSortedCidSet().ToSlice()
+
+	t.Run("serves the whole chain down to genesis", func(t *testing.T) {
+		resp, tipsets := server.processRequest(ctx, &Request{Start: head, Length: 20})
+		assert.Equal(t, Success, resp.Status)
+		assert.Len(t, tipsets, 11)
+	})
+
+	t.Run("serves part of a chain longer than the local one", func(t *testing.T) {
+		partial := mapBlockProvider{}
+		for c, blk := range blocks {
+			partial[c] = blk
+		}
+		delete(partial, chain[5].ToSlice()[0].Cid())
+		server := &Server{blocks: partial}
+
+		resp, tipsets := server.processRequest(ctx, &Request{Start: head, Length: 20})
+		assert.Equal(t, Partial, resp.Status)
+		assert.Equal(t, uint64(5), resp.Count)
+		assert.Len(t, tipsets, 5)
+	})
+
+	t.Run("finds nothing when skipping past genesis", func(t *testing.T) {
+		resp, tipsets := server.processRequest(ctx, &Request{Start: head, Skip: 20, Length: 5})
+		assert.Equal(t, NotFound, resp.Status)
+		assert.Empty(t, tipsets)
+	})
+}
+
+func TestFetcherFallsBack(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+	a, b := mn.Hosts()[0], mn.Hosts()[1]
+
+	chain, blocks := makeChain(t, 5)
+	NewServer(a, blocks)
+
+	fallback := th.NewTestFetcher()
+	fetcher := NewFetcher(NewClient(b, th.NewFakeBlockValidator()), fallback)
+
+	head := chain[5].ToSortedCidSet()
+
+	// Without peers the fallback is used, and it does not have the blocks.
+	_, err = fetcher.GetBlocks(ctx, head.ToSlice())
+	assert.Error(t, err)
+
+	// Once a peer is known, the whole chain below the head is fetched at once.
+	fetcher.AddPeer(a.ID())
+	blks, err := fetcher.GetBlocks(ctx, head.ToSlice())
+	require.NoError(t, err)
+	assert.Equal(t, head, blockCids(blks))
+
+	fetcher.RemovePeer(a.ID())
+	blks, err = fetcher.GetBlocks(ctx, chain[1].ToSortedCidSet().ToSlice())
+	require.NoError(t, err)
+	assert.Equal(t, chain[1].ToSortedCidSet(), blockCids(blks))
+}
+
+// segmentRequester serves segments of a chain, recording the requests, or
+// fails the requests to the peers in errs.
+type segmentRequester struct {
+	mu       sync.Mutex
+	chain    []types.TipSet
+	errs     map[peer.ID]error
+	requests map[peer.ID][2]uint64
+}
+
+func (r *segmentRequester) GetTipSets(ctx context.Context, p peer.ID, start types.SortedCidSet, skip, length uint64) ([]types.TipSet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[p] = [2]uint64{skip, length}
+	if err, ok := r.errs[p]; ok {
+		return nil, err
+	}
+
+	top := -1
+	for i, ts := range r.chain {
+		if ts.ToSortedCidSet().Equals(start) {
+			top = i
+		}
+	}
+	var tipsets []types.TipSet
+	for i := top - int(skip); i >= 0 && uint64(len(tipsets)) < length; i-- {
+		tipsets = append(tipsets, r.chain[i])
+	}
+	return tipsets, nil
+}
+
+func TestFetcherSplitsRequests(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	chain, _ := makeChain(t, RequestLength+10)
+	head := chain[len(chain)-1]
+	peers := []peer.ID{th.RequireRandomPeerID(t), th.RequireRandomPeerID(t), th.RequireRandomPeerID(t)}
+
+	t.Run("fetches consecutive segments from each peer", func(t *testing.T) {
+		requester := &segmentRequester{chain: chain, requests: map[peer.ID][2]uint64{}}
+		fetcher := NewFetcher(requester, th.NewTestFetcher())
+		for _, p := range peers {
+			fetcher.AddPeer(p)
+		}
+
+		_, err := fetcher.GetBlocks(ctx, head.ToSortedCidSet().ToSlice())
+		require.NoError(t, err)
+
+		segmentLength := uint64((RequestLength + len(peers) - 1) / len(peers))
+		var skips []uint64
+		for _, req := range requester.requests {
+			assert.Equal(t, segmentLength, req[1])
+			skips = append(skips, req[0])
+		}
+		assert.ElementsMatch(t, []uint64{0, segmentLength, 2 * segmentLength}, skips)
+
+		// The whole window is served locally.
+		bottom := chain[len(chain)-RequestLength]
+		blks, ok := fetcher.fromWindow(bottom.ToSortedCidSet().ToSlice())
+		require.True(t, ok)
+		assert.Equal(t, bottom.ToSortedCidSet(), blockCids(blks))
+	})
+
+	t.Run("drops only peers sending invalid responses", func(t *testing.T) {
+		requester := &segmentRequester{
+			chain:    chain,
+			requests: map[peer.ID][2]uint64{},
+			errs: map[peer.ID]error{
+				peers[0]: errors.New("not found"),
+				peers[1]: errors.Wrap(ErrInvalidResponse, "bad block"),
+			},
+		}
+		fetcher := NewFetcher(requester, th.NewTestFetcher())
+		for _, p := range peers {
+			fetcher.AddPeer(p)
+		}
+
+		_, _ = fetcher.GetBlocks(ctx, head.ToSortedCidSet().ToSlice())
+		remaining := fetcher.pickPeers()
+		assert.Contains(t, remaining, peers[0])
+		assert.NotContains(t, remaining, peers[1])
+		assert.Contains(t, remaining, peers[2])
+	})
+
+	t.Run("drops unavailable peers", func(t *testing.T) {
+		requester := &segmentRequester{
+			chain:    chain,
+			requests: map[peer.ID][2]uint64{},
+			errs: map[peer.ID]error{
+				peers[0]: errors.Wrap(ErrPeerUnavailable, "protocol not supported"),
+			},
+		}
+		fetcher := NewFetcher(requester, th.NewTestFetcher())
+		for _, p := range peers {
+			fetcher.AddPeer(p)
+		}
+
+		_, _ = fetcher.GetBlocks(ctx, head.ToSortedCidSet().ToSlice())
+		remaining := fetcher.pickPeers()
+		assert.NotContains(t, remaining, peers[0])
+		assert.Len(t, remaining, 2)
+	})
+}
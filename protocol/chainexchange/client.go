@@ -0,0 +1,109 @@
+package chainexchange
+
+import (
+	"context"
+
+	host "github.com/libp2p/go-libp2p-host"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrInvalidResponse is the cause of the errors of requests to peers whose
+// responses break the protocol or carry invalid blocks.  Other errors, like a
+// peer not having the requested chain or a timeout, are no fault of the peer.
+var ErrInvalidResponse = errors.New("invalid chain exchange response")
+
+// ErrPeerUnavailable is the cause of the errors of requests to peers that
+// could not be reached over the chain exchange protocol, e.g. because they
+// don't support it or have disconnected.
+var ErrPeerUnavailable = errors.New("chain exchange peer unavailable")
+
+// Client requests consecutive tipsets from peers running a chain exchange
+// Server.
+type Client struct {
+	host      host.Host
+	validator consensus.BlockSyntaxValidator
+}
+
+// NewClient returns a Client validating the syntax of the blocks it receives
+// with the given validator.
+func NewClient(h host.Host, bv consensus.BlockSyntaxValidator) *Client {
+	return &Client{
+		host:      h,
+		validator: bv,
+	}
+}
+
+// GetTipSets requests up to length consecutive tipsets from peer p, walking
+// towards genesis from the tipset with key start after skipping skip of them.
+// The returned tipsets are ordered from the top down and are checked to form
+// a chain, starting at start if skip is 0, so a peer cannot pass off unrelated
+// blocks.  Fewer than length tipsets are returned if the peer holds only part
+// of the chain.
+func (c *Client) GetTipSets(ctx context.Context, p peer.ID, start types.SortedCidSet, skip, length uint64) ([]types.TipSet, error) {
+	s, err := c.host.NewStream(ctx, p, chainExchangeProtocol)
+	if err != nil {
+		return nil, errors.Wrapf(ErrPeerUnavailable, "failed to create chain exchange stream to peer %s: %s", p, err)
+	}
+	defer s.Close() // nolint: errcheck
+
+	req := Request{
+		Start:  start.ToSlice(),
+		Skip:   skip,
+		Length: length,
+	}
+	if err := cbu.NewMsgWriter(s).WriteMsg(&req); err != nil {
+		return nil, errors.Wrapf(ErrPeerUnavailable, "failed to write chain exchange request to peer %s: %s", p, err)
+	}
+
+	r := cbu.NewMsgReader(s)
+	var resp Response
+	if err := r.ReadMsg(&resp); err != nil {
+		return nil, errors.Wrapf(ErrPeerUnavailable, "failed to read chain exchange response from peer %s: %s", p, err)
+	}
+	switch resp.Status {
+	case Success, Partial:
+	case NotFound, BadRequest:
+		return nil, errors.Errorf("peer %s could not serve chain from %s: %s", p, start.String(), resp.ErrorMessage)
+	default:
+		return nil, errors.Wrapf(ErrInvalidResponse, "peer %s sent status %d", p, resp.Status)
+	}
+	if resp.Count > length {
+		return nil, errors.Wrapf(ErrInvalidResponse, "peer %s sent %d tipsets, requested %d", p, resp.Count, length)
+	}
+
+	// The first tipset of a skipping request is only known once received.
+	var expected types.SortedCidSet
+	if skip == 0 {
+		expected = start
+	}
+	tipsets := make([]types.TipSet, 0, resp.Count)
+	for i := uint64(0); i < resp.Count; i++ {
+		var tsb TipSetBlocks
+		if err := r.ReadMsg(&tsb); err != nil {
+			return nil, errors.Wrapf(ErrPeerUnavailable, "failed to read tipset from peer %s: %s", p, err)
+		}
+		for _, blk := range tsb.Blocks {
+			if err := c.validator.ValidateSyntax(ctx, blk); err != nil {
+				return nil, errors.Wrapf(ErrInvalidResponse, "peer %s sent invalid block %s: %s", p, blk.Cid().String(), err)
+			}
+		}
+		ts, err := types.NewTipSet(tsb.Blocks...)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidResponse, "peer %s sent blocks not forming a tipset: %s", p, err)
+		}
+		if (i > 0 || skip == 0) && !ts.ToSortedCidSet().Equals(expected) {
+			return nil, errors.Wrapf(ErrInvalidResponse, "peer %s sent tipset %s, expected %s", p, ts.String(), expected.String())
+		}
+		tipsets = append(tipsets, ts)
+
+		if expected, err = ts.Parents(); err != nil {
+			return nil, err
+		}
+	}
+	return tipsets, nil
+}
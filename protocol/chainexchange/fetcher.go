@@ -0,0 +1,202 @@
+package chainexchange
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-peer"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// RequestLength is the number of tipsets the Fetcher requests at once.
+const RequestLength = 200
+
+// ParallelPeers is the number of peers the Fetcher splits the requests of a
+// window of the chain across, each serving a segment of it concurrently.
+const ParallelPeers = 3
+
+// requestTimeout bounds a single request to a peer.
+const requestTimeout = 30 * time.Second
+
+type blockFetcher interface {
+	GetBlocks(context.Context, []cid.Cid) ([]*types.Block, error)
+}
+
+type tipSetRequester interface {
+	GetTipSets(ctx context.Context, p peer.ID, start types.SortedCidSet, skip, length uint64) ([]types.TipSet, error)
+}
+
+// Fetcher fetches blocks for the syncer.  A request for the blocks of a
+// tipset fetches the next RequestLength tipsets down the chain from peers
+// over the chain exchange protocol, so that the syncer's walk over parents is
+// served locally.  Blocks it cannot get from peers are fetched with the
+// fallback fetcher, i.e. over bitswap.
+type Fetcher struct {
+	requester tipSetRequester
+	fallback  blockFetcher
+
+	// mu protects peers and window.
+	mu sync.Mutex
+	// peers are the peers known to serve the chain exchange protocol.
+	peers map[peer.ID]struct{}
+	// window holds the blocks of the most recently fetched chain segment.
+	window map[cid.Cid]*types.Block
+}
+
+// NewFetcher returns a Fetcher requesting tipsets with requester and falling
+// back to fallback.
+func NewFetcher(requester tipSetRequester, fallback blockFetcher) *Fetcher {
+	return &Fetcher{
+		requester: requester,
+		fallback:  fallback,
+		peers:     make(map[peer.ID]struct{}),
+		window:    make(map[cid.Cid]*types.Block),
+	}
+}
+
+// AddPeer adds a peer to request tipsets from, typically one that has just
+// told us about its chain.
+func (f *Fetcher) AddPeer(p peer.ID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.peers[p] = struct{}{}
+}
+
+// RemovePeer stops requesting tipsets from a peer, typically one that sent
+// invalid responses or can't be reached.
+func (f *Fetcher) RemovePeer(p peer.ID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.peers, p)
+}
+
+// GetBlocks returns the blocks with the given cids, which are expected to
+// form a tipset.
+func (f *Fetcher) GetBlocks(ctx context.Context, cids []cid.Cid) ([]*types.Block, error) {
+	if blks, ok := f.fromWindow(cids); ok {
+		return blks, nil
+	}
+
+	if err := f.fetchWindow(ctx, types.NewSortedCidSet(cids...)); err != nil {
+		log.Debugf("falling back to bitswap for %v: %s", cids, err)
+		return f.fallback.GetBlocks(ctx, cids)
+	}
+	if blks, ok := f.fromWindow(cids); ok {
+		return blks, nil
+	}
+	return f.fallback.GetBlocks(ctx, cids)
+}
+
+func (f *Fetcher) fromWindow(cids []cid.Cid) ([]*types.Block, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	blks := make([]*types.Block, 0, len(cids))
+	for _, c := range cids {
+		blk, ok := f.window[c]
+		if !ok {
+			return nil, false
+		}
+		blks = append(blks, blk)
+	}
+	return blks, true
+}
+
+// fetchWindow requests the RequestLength tipsets of the chain from start
+// down, split in consecutive segments across up to ParallelPeers peers, and
+// replaces the window with the segments that link up from start.  Peers
+// sending invalid responses, or that can't be reached over the protocol, are
+// dropped.
+func (f *Fetcher) fetchWindow(parent context.Context, start types.SortedCidSet) error {
+	peers := f.pickPeers()
+	if len(peers) == 0 {
+		return errors.New("no chain exchange peers")
+	}
+
+	ctx, cancel := context.WithTimeout(parent, requestTimeout)
+	defer cancel()
+
+	segmentLength := (RequestLength + uint64(len(peers)) - 1) / uint64(len(peers))
+	segments := make([][]types.TipSet, len(peers))
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p peer.ID) {
+			defer wg.Done()
+			segments[i], errs[i] = f.requester.GetTipSets(ctx, p, start, uint64(i)*segmentLength, segmentLength)
+		}(i, p)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		log.Debugf("chain exchange request to peer %s failed: %s", peers[i], err)
+		switch errors.Cause(err) {
+		case ErrInvalidResponse:
+			f.RemovePeer(peers[i])
+		case ErrPeerUnavailable:
+			// A stream failing because the caller gave up is no fault of
+			// the peer.
+			if parent.Err() == nil {
+				f.RemovePeer(peers[i])
+			}
+		}
+	}
+
+	window := make(map[cid.Cid]*types.Block)
+	expected := start
+	for i, segment := range segments {
+		if errs[i] != nil || len(segment) == 0 {
+			break
+		}
+		// The chain from start is fixed by its hashes, so a segment not
+		// following the previous one is invalid.
+		if !segment[0].ToSortedCidSet().Equals(expected) {
+			log.Debugf("peer %s sent segment at %s, expected %s", peers[i], segment[0].String(), expected.String())
+			f.RemovePeer(peers[i])
+			break
+		}
+		for _, ts := range segment {
+			for _, blk := range ts.ToSlice() {
+				window[blk.Cid()] = blk
+			}
+		}
+		// The chain ends, or the peer holds only part of it.
+		if uint64(len(segment)) < segmentLength {
+			break
+		}
+		var err error
+		if expected, err = segment[len(segment)-1].Parents(); err != nil {
+			break
+		}
+	}
+	if len(window) == 0 {
+		return errors.Errorf("no peer served the chain from %s", start.String())
+	}
+	f.mu.Lock()
+	f.window = window
+	f.mu.Unlock()
+	return nil
+}
+
+// pickPeers returns up to ParallelPeers known peers.
+func (f *Fetcher) pickPeers() []peer.ID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var peers []peer.ID
+	for p := range f.peers {
+		if len(peers) == ParallelPeers {
+			break
+		}
+		peers = append(peers, p)
+	}
+	return peers
+}
@@ -0,0 +1,125 @@
+package chainexchange
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	"github.com/libp2p/go-libp2p-protocol"
+
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("/fil/chainexchange")
+
+// chainExchangeProtocol is the libp2p protocol identifier for the chain
+// exchange protocol.
+const chainExchangeProtocol = protocol.ID("/fil/chainexchange/1.0.0")
+
+// MaxRequestLength is the maximum number of tipsets served for one request.
+const MaxRequestLength = 500
+
+// serveTimeout bounds the time spent serving a single request.
+const serveTimeout = 30 * time.Second
+
+// Server serves requests for consecutive tipsets of the local chain to
+// peers catching up with the network.
+type Server struct {
+	host   host.Host
+	blocks chain.BlockProvider
+}
+
+// NewServer creates a new Server serving blocks from the given provider and
+// registers it to the given host.
+func NewServer(h host.Host, blocks chain.BlockProvider) *Server {
+	s := &Server{
+		host:   h,
+		blocks: blocks,
+	}
+	h.SetStreamHandler(chainExchangeProtocol, s.handleNewStream)
+	return s
+}
+
+func (s *Server) handleNewStream(stream inet.Stream) {
+	defer stream.Close() // nolint: errcheck
+
+	from := stream.Conn().RemotePeer()
+
+	var req Request
+	if err := cbu.NewMsgReader(stream).ReadMsg(&req); err != nil {
+		log.Debugf("bad chain exchange request from peer %s: %s", from, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serveTimeout)
+	defer cancel()
+
+	resp, tipsets := s.processRequest(ctx, &req)
+
+	w := cbu.NewMsgWriter(stream)
+	if err := w.WriteMsg(resp); err != nil {
+		log.Debugf("failed to write chain exchange response to peer %s: %s", from, err)
+		return
+	}
+	for _, ts := range tipsets {
+		if err := w.WriteMsg(&TipSetBlocks{Blocks: ts.ToSlice()}); err != nil {
+			log.Debugf("failed to write tipset to peer %s: %s", from, err)
+			return
+		}
+	}
+}
+
+// processRequest collects the tipsets answering req.
+func (s *Server) processRequest(ctx context.Context, req *Request) (*Response, []types.TipSet) {
+	if len(req.Start) == 0 || req.Length == 0 {
+		return &Response{Status: BadRequest, ErrorMessage: "request must have a start tipset and a positive length"}, nil
+	}
+	if req.Skip >= MaxRequestLength {
+		return &Response{Status: BadRequest, ErrorMessage: fmt.Sprintf("request may skip at most %d tipsets", MaxRequestLength-1)}, nil
+	}
+	length := req.Length
+	if length > MaxRequestLength {
+		length = MaxRequestLength
+	}
+
+	start, err := chain.LoadTipSetBlocks(ctx, s.blocks, types.NewSortedCidSet(req.Start...))
+	if err != nil {
+		return &Response{Status: NotFound, ErrorMessage: err.Error()}, nil
+	}
+
+	var tipsets []types.TipSet
+	var last types.TipSet
+	skipped := uint64(0)
+	provider := chain.TipSetProviderFromBlocks(ctx, s.blocks)
+	for it := chain.IterAncestors(ctx, provider, start); !it.Complete() && uint64(len(tipsets)) < length; err = it.Next() {
+		if err != nil {
+			// Serve the part of the chain we have.
+			break
+		}
+		last = it.Value()
+		if skipped < req.Skip {
+			skipped++
+			continue
+		}
+		tipsets = append(tipsets, it.Value())
+	}
+
+	if uint64(len(tipsets)) == length {
+		return &Response{Status: Success, Count: length}, tipsets
+	}
+	if len(tipsets) == 0 {
+		return &Response{Status: NotFound, ErrorMessage: fmt.Sprintf("no tipsets after skipping %d from the start tipset", req.Skip)}, nil
+	}
+	// The chain may simply end at genesis before length tipsets, otherwise
+	// the walk stopped at a tipset missing locally.
+	status := Partial
+	if h, _ := last.Height(); err == nil && h == 0 {
+		status = Success
+	}
+	return &Response{Status: status, Count: uint64(len(tipsets))}, tipsets
+}
@@ -0,0 +1,61 @@
+package chainexchange
+
+import (
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func init() {
+	cbor.RegisterCborType(Request{})
+	cbor.RegisterCborType(Response{})
+	cbor.RegisterCborType(TipSetBlocks{})
+}
+
+// Status communicates whether a chain exchange request was served.
+type Status int
+
+const (
+	// Unset is the default status
+	Unset = Status(iota)
+
+	// Success means that all requested tipsets follow the response
+	Success
+
+	// Partial means that the responder holds only part of the requested
+	// chain, the tipsets it has follow the response
+	Partial
+
+	// NotFound means that the responder does not have the start tipset, or
+	// none of the tipsets requested below it
+	NotFound
+
+	// BadRequest means that the request was malformed
+	BadRequest
+)
+
+// Request asks a peer for up to Length consecutive tipsets of its chain,
+// walking towards genesis from the tipset with key Start, after skipping the
+// first Skip of them.  Skipping lets a range of the chain be requested from
+// several peers in parallel segments.
+type Request struct {
+	Start  []cid.Cid
+	Skip   uint64
+	Length uint64
+}
+
+// Response is the first message written in reply to a Request.  It is
+// followed by Count TipSetBlocks messages, ordered from the start tipset
+// down.
+type Response struct {
+	Status       Status
+	ErrorMessage string
+	Count        uint64
+}
+
+// TipSetBlocks carries the blocks of a single tipset.  Blocks embed their
+// messages, so a tipset is always transferred with its messages.
+type TipSetBlocks struct {
+	Blocks []*types.Block
+}
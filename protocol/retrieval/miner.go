@@ -1,12 +1,15 @@
 package retrieval
 
 import (
+	"io"
 	"io/ioutil"
 
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
 	host "github.com/libp2p/go-libp2p-host"
 	inet "github.com/libp2p/go-libp2p-net"
 	"github.com/libp2p/go-libp2p-protocol"
+	"github.com/pkg/errors"
 
 	cbu "github.com/filecoin-project/go-filecoin/cborutil"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
@@ -19,7 +22,7 @@ const retrievalFreeProtocol = protocol.ID("/fil/retrieval/free/0.0.0")
 // TODO: better name
 type minerNode interface {
 	Host() host.Host
-	SectorBuilder() sectorbuilder.SectorBuilder
+	SectorBuilders() []sectorbuilder.SectorBuilder
 }
 
 // Miner serves requests for pieces from RetrievalClients.
@@ -38,6 +41,19 @@ func NewMiner(nd minerNode) *Miner {
 	return rm
 }
 
+// readPiece returns a reader for the piece from the first of the node's miners
+// that sealed it.
+func (rm *Miner) readPiece(pieceRef cid.Cid) (io.Reader, error) {
+	err := errors.New("node has no sector builder")
+	for _, sb := range rm.node.SectorBuilders() {
+		var reader io.Reader
+		if reader, err = sb.ReadPieceFromSealedSector(pieceRef); err == nil {
+			return reader, nil
+		}
+	}
+	return nil, err
+}
+
 func (rm *Miner) handleRetrievePieceForFree(s inet.Stream) {
 	defer s.Close() // nolint: errcheck
 
@@ -47,7 +63,7 @@ func (rm *Miner) handleRetrievePieceForFree(s inet.Stream) {
 		return
 	}
 
-	reader, err := rm.node.SectorBuilder().ReadPieceFromSealedSector(req.PieceRef)
+	reader, err := rm.readPiece(req.PieceRef)
 	if err != nil {
 		log.Warningf("failed to obtain a reader for piece with CID %s: %s", req.PieceRef.String(), err)
 
@@ -45,9 +45,6 @@ const (
 
 	// CreateChannelGasPrice is the gas price of the message used to create the payment channel
 	CreateChannelGasPrice = 1
-
-	// CreateChannelGasLimit is the gas limit of the message used to create the payment channel
-	CreateChannelGasLimit = 300
 )
 
 type clientPorcelainAPI interface {
@@ -58,6 +55,7 @@ type clientPorcelainAPI interface {
 	DAGGetFileSize(context.Context, cid.Cid) (uint64, error)
 	DealPut(*storagedeal.Deal) error
 	DealsLs(context.Context) (<-chan *porcelain.StorageDealLsResult, error)
+	MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
 	MinerGetAsk(ctx context.Context, minerAddr address.Address, askID uint64) (miner.Ask, error)
 	MinerGetSectorSize(ctx context.Context, minerAddr address.Address) (*types.BytesAmount, error)
@@ -171,6 +169,12 @@ func (smc *Client) ProposeDeal(ctx context.Context, miner address.Address, data
 		return nil, ctxSetup.Err()
 	}
 
+	channelExpiry := chainHeight.Add(types.NewBlockHeight(duration + ChannelExpiryInterval))
+	createChannelGasLimit, err := smc.api.MessageEstimateGasLimit(ctxSetup, fromAddress, address.PaymentBrokerAddress, "createChannel", minerOwner, channelExpiry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to estimate the gas limit of the payment channel creation")
+	}
+
 	// create payment information
 	cpResp, err := smc.api.CreatePayments(ctxSetup, porcelain.CreatePaymentsParams{
 		From:            fromAddress,
@@ -180,9 +184,9 @@ func (smc *Client) ProposeDeal(ctx context.Context, miner address.Address, data
 		MinerAddress:    miner,
 		CommP:           commP,
 		PaymentInterval: VoucherInterval,
-		ChannelExpiry:   *chainHeight.Add(types.NewBlockHeight(duration + ChannelExpiryInterval)),
+		ChannelExpiry:   *channelExpiry,
 		GasPrice:        types.NewAttoFIL(big.NewInt(CreateChannelGasPrice)),
-		GasLimit:        types.NewGasUnits(CreateChannelGasLimit),
+		GasLimit:        createChannelGasLimit,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating payment")
@@ -89,6 +89,7 @@ func TestProposeDeal(t *testing.T) {
 		// correct payment id and message cid in proposal implies a call to createChannel
 		assert.Equal(t, testAPI.channelID, proposal.Payment.Channel)
 		assert.Equal(t, &testAPI.msgCid, proposal.Payment.ChannelMsgCid)
+		assert.Equal(t, types.NewGasUnits(240), testAPI.createChannelGasLimit)
 	})
 
 	t.Run("and creates payment info", func(t *testing.T) {
@@ -161,6 +162,8 @@ type clientTestAPI struct {
 	perPayment  types.AttoFIL
 	testing     *testing.T
 	deals       map[cid.Cid]*storagedeal.Deal
+
+	createChannelGasLimit types.GasUnits
 }
 
 func newTestClientAPI(t *testing.T) *clientTestAPI {
@@ -188,6 +191,7 @@ func (ctp *clientTestAPI) ChainBlockHeight() (*types.BlockHeight, error) {
 }
 
 func (ctp *clientTestAPI) CreatePayments(ctx context.Context, config porcelain.CreatePaymentsParams) (*porcelain.CreatePaymentsReturn, error) {
+	ctp.createChannelGasLimit = config.GasLimit
 	resp := &porcelain.CreatePaymentsReturn{
 		CreatePaymentsParams: config,
 		Channel:              ctp.channelID,
@@ -302,6 +306,11 @@ func (ctp *clientTestAPI) DealPut(storageDeal *storagedeal.Deal) error {
 	return nil
 }
 
+func (ctp *clientTestAPI) MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	require.Equal(ctp.testing, "createChannel", method)
+	return types.NewGasUnits(240), nil
+}
+
 func (ctp *clientTestAPI) MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error) {
 	return [][]byte{{byte(types.TestProofsMode)}}, nil
 }
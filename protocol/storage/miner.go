@@ -16,8 +16,6 @@ import (
 	logging "github.com/ipfs/go-log"
 	dag "github.com/ipfs/go-merkledag"
 	uio "github.com/ipfs/go-unixfs/io"
-	host "github.com/libp2p/go-libp2p-host"
-	inet "github.com/libp2p/go-libp2p-net"
 	"github.com/libp2p/go-libp2p-protocol"
 	"github.com/pkg/errors"
 
@@ -25,7 +23,6 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
-	cbu "github.com/filecoin-project/go-filecoin/cborutil"
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/proofs"
@@ -41,25 +38,31 @@ var log = logging.Logger("/fil/storage")
 const makeDealProtocol = protocol.ID("/fil/storage/mk/1.0.0")
 const queryDealProtocol = protocol.ID("/fil/storage/qry/1.0.0")
 
-// TODO: replace this with a queries to pick reasonable gas price and limits.
+// TODO: replace this with a query to pick a reasonable gas price.
 const submitPostGasPrice = 1
-const submitPostGasLimit = 300
 
 const waitForPaymentChannelDuration = 2 * time.Minute
 
 const dealsAwatingSealDatastorePrefix = "dealsAwaitingSeal"
 
+var legacyDealsAwaitingSealKey = datastore.KeyWithNamespaces([]string{dealsAwatingSealDatastorePrefix})
+
 // Miner represents a storage miner.
 type Miner struct {
 	minerAddr      address.Address
 	minerOwnerAddr address.Address
 
+	sectorBuilder sectorbuilder.SectorBuilder
+
 	dealsAwaitingSealDs repo.Datastore
 
 	postInProcessLk sync.Mutex
 	postInProcess   *types.BlockHeight
 
 	dealsAwaitingSeal *dealsAwaitingSeal
+	// ownsLegacyDeals is set for the node's original mining.minerAddress,
+	// the only miner that may adopt deals persisted under the shared key.
+	ownsLegacyDeals bool
 
 	porcelainAPI minerPorcelain
 	node         node
@@ -79,6 +82,7 @@ type minerPorcelain interface {
 	DealGet(context.Context, cid.Cid) (*storagedeal.Deal, error)
 	DealPut(*storagedeal.Deal) error
 
+	MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
 	MessageSend(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
 	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
@@ -91,8 +95,6 @@ type minerPorcelain interface {
 // dependency on node should go away, fully replaced by the dependency on the porcelain api.
 type node interface {
 	BlockService() bserv.BlockService
-	Host() host.Host
-	SectorBuilder() sectorbuilder.SectorBuilder
 }
 
 // generatePostInput is a struct containing sector id and related commitments
@@ -105,10 +107,11 @@ type generatePostInput struct {
 }
 
 // NewMiner is
-func NewMiner(minerAddr, minerOwnerAddr address.Address, nd node, dealsDs repo.Datastore, porcelainAPI minerPorcelain) (*Miner, error) {
+func NewMiner(minerAddr, minerOwnerAddr address.Address, sectorBuilder sectorbuilder.SectorBuilder, nd node, dealsDs repo.Datastore, porcelainAPI minerPorcelain) (*Miner, error) {
 	sm := &Miner{
 		minerAddr:           minerAddr,
 		minerOwnerAddr:      minerOwnerAddr,
+		sectorBuilder:       sectorBuilder,
 		porcelainAPI:        porcelainAPI,
 		dealsAwaitingSealDs: dealsDs,
 		node:                nd,
@@ -116,37 +119,29 @@ func NewMiner(minerAddr, minerOwnerAddr address.Address, nd node, dealsDs repo.D
 		proposalRejector:    rejectProposal,
 	}
 
+	primaryAddr, err := porcelainAPI.ConfigGet("mining.minerAddress")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read mining.minerAddress")
+	}
+	sm.ownsLegacyDeals = primaryAddr == minerAddr
+
 	if err := sm.loadDealsAwaitingSeal(); err != nil {
 		return nil, errors.Wrap(err, "failed to load dealAwaitingSeal when creating miner")
 	}
 	sm.dealsAwaitingSeal.onSuccess = sm.onCommitSuccess
 	sm.dealsAwaitingSeal.onFail = sm.onCommitFail
 
-	nd.Host().SetStreamHandler(makeDealProtocol, sm.handleMakeDeal)
-	nd.Host().SetStreamHandler(queryDealProtocol, sm.handleQueryDeal)
-
 	return sm, nil
 }
 
-func (sm *Miner) handleMakeDeal(s inet.Stream) {
-	defer s.Close() // nolint: errcheck
-
-	var signedProposal storagedeal.SignedDealProposal
-	if err := cbu.NewMsgReader(s).ReadMsg(&signedProposal); err != nil {
-		log.Errorf("received invalid proposal: %s", err)
-		return
-	}
-
-	ctx := context.Background()
-	resp, err := sm.receiveStorageProposal(ctx, &signedProposal)
-	if err != nil {
-		log.Errorf("failed to process proposal: %s", err)
-		return
-	}
+// Address returns the address of the miner actor this storage miner serves.
+func (sm *Miner) Address() address.Address {
+	return sm.minerAddr
+}
 
-	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
-		log.Errorf("failed to write proposal response: %s", err)
-	}
+// SectorBuilder returns the sector builder that seals this miner's sectors.
+func (sm *Miner) SectorBuilder() sectorbuilder.SectorBuilder {
+	return sm.sectorBuilder
 }
 
 // receiveStorageProposal is the entry point for the miner storage protocol
@@ -320,7 +315,7 @@ func (sm *Miner) getPaymentChannel(ctx context.Context, p *storagedeal.Proposal)
 }
 
 func acceptProposal(sm *Miner, p *storagedeal.Proposal) (*storagedeal.Response, error) {
-	if sm.node.SectorBuilder() == nil {
+	if sm.sectorBuilder == nil {
 		return nil, errors.New("Mining disabled, can not process proposal")
 	}
 
@@ -457,7 +452,7 @@ func (sm *Miner) processStorageDeal(proposalCid cid.Cid) {
 	//
 	// Also, this pattern of not being able to set up book-keeping ahead of
 	// the call is inelegant.
-	sectorID, err := sm.node.SectorBuilder().AddPiece(ctx, d.Proposal.PieceRef, d.Proposal.Size.Uint64(), r)
+	sectorID, err := sm.sectorBuilder.AddPiece(ctx, d.Proposal.PieceRef, d.Proposal.Size.Uint64(), r)
 	if err != nil {
 		fail("failed to submit seal proof", fmt.Sprintf("failed to add piece: %s", err))
 		return
@@ -481,8 +476,12 @@ func (sm *Miner) processStorageDeal(proposalCid cid.Cid) {
 func (sm *Miner) loadDealsAwaitingSeal() error {
 	sm.dealsAwaitingSeal = newDealsAwaitingSeal()
 
-	key := datastore.KeyWithNamespaces([]string{dealsAwatingSealDatastorePrefix})
-	result, notFound := sm.dealsAwaitingSealDs.Get(key)
+	result, notFound := sm.dealsAwaitingSealDs.Get(sm.dealsAwaitingSealKey())
+	if notFound == datastore.ErrNotFound && sm.ownsLegacyDeals {
+		// Nodes used to run a single miner, mining.minerAddress, and stored
+		// its deals under a key shared by all miners.
+		result, notFound = sm.dealsAwaitingSealDs.Get(legacyDealsAwaitingSealKey)
+	}
 	if notFound == nil {
 		if err := json.Unmarshal(result, &sm.dealsAwaitingSeal); err != nil {
 			return errors.Wrap(err, "failed to unmarshal deals awaiting seal from datastore")
@@ -497,15 +496,25 @@ func (sm *Miner) saveDealsAwaitingSeal() error {
 	if err != nil {
 		return errors.Wrap(err, "Could not marshal dealsAwaitingSeal")
 	}
-	key := datastore.KeyWithNamespaces([]string{dealsAwatingSealDatastorePrefix})
-	err = sm.dealsAwaitingSealDs.Put(key, marshalledDealsAwaitingSeal)
+	err = sm.dealsAwaitingSealDs.Put(sm.dealsAwaitingSealKey(), marshalledDealsAwaitingSeal)
 	if err != nil {
 		return errors.Wrap(err, "could not save deal awaiting seal record to disk, in-memory deals differ from persisted deals!")
 	}
+	if sm.ownsLegacyDeals {
+		if err := sm.dealsAwaitingSealDs.Delete(legacyDealsAwaitingSealKey); err != nil && err != datastore.ErrNotFound {
+			return errors.Wrap(err, "could not remove legacy deals awaiting seal record")
+		}
+	}
 
 	return nil
 }
 
+// dealsAwaitingSealKey is the datastore key under which the deals awaiting
+// seal of this miner are persisted.
+func (sm *Miner) dealsAwaitingSealKey() datastore.Key {
+	return datastore.KeyWithNamespaces([]string{dealsAwatingSealDatastorePrefix, sm.minerAddr.String()})
+}
+
 // OnCommitmentSent is a callback, called when a sector seal message was posted to the chain.
 func (sm *Miner) OnCommitmentSent(sector *sectorbuilder.SealedSectorMetadata, msgCid cid.Cid, err error) {
 	ctx := context.Background()
@@ -780,7 +789,7 @@ func (sm *Miner) generatePoSt(sortedCommRs proofs.SortedCommRs, seed types.PoStC
 		SortedCommRs:  sortedCommRs,
 		ChallengeSeed: seed,
 	}
-	res, err := sm.node.SectorBuilder().GeneratePoSt(req)
+	res, err := sm.sectorBuilder.GeneratePoSt(req)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to generate PoSt")
 	}
@@ -828,9 +837,13 @@ func (sm *Miner) submitPoSt(start, end *types.BlockHeight, seed types.PoStChalle
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	// TODO: algorithmically determine appropriate values for these
+	// TODO: algorithmically determine an appropriate gas price
 	gasPrice := types.NewGasPrice(submitPostGasPrice)
-	gasLimit := types.NewGasUnits(submitPostGasLimit)
+	gasLimit, err := sm.porcelainAPI.MessageEstimateGasLimit(ctx, sm.minerOwnerAddr, sm.minerAddr, "submitPoSt", proofs)
+	if err != nil {
+		log.Errorf("failed to estimate the gas limit of PoSt: %s", err)
+		return
+	}
 
 	_, err = sm.porcelainAPI.MessageSend(ctx, sm.minerOwnerAddr, sm.minerAddr, types.ZeroAttoFIL, gasPrice, gasLimit, "submitPoSt", proofs)
 	if err != nil {
@@ -853,21 +866,3 @@ func (sm *Miner) Query(ctx context.Context, c cid.Cid) *storagedeal.Response {
 
 	return storageDeal.Response
 }
-
-func (sm *Miner) handleQueryDeal(s inet.Stream) {
-	defer s.Close() // nolint: errcheck
-
-	ctx := context.Background()
-
-	var q storagedeal.QueryRequest
-	if err := cbu.NewMsgReader(s).ReadMsg(&q); err != nil {
-		log.Errorf("received invalid query: %s", err)
-		return
-	}
-
-	resp := sm.Query(ctx, q.Cid)
-
-	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
-		log.Errorf("failed to write query response: %s", err)
-	}
-}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/protocol/storage/storagedeal"
+)
+
+// MinerSet serves the storage protocols for all the storage miners of a node.
+// A node has a single host, so the set routes each proposal to the miner it
+// is addressed to.
+type MinerSet struct {
+	lk     sync.Mutex
+	miners []*Miner
+}
+
+// NewMinerSet creates an empty MinerSet and registers its protocol handlers
+// on h.
+func NewMinerSet(h host.Host) *MinerSet {
+	ms := &MinerSet{}
+	h.SetStreamHandler(makeDealProtocol, ms.handleMakeDeal)
+	h.SetStreamHandler(queryDealProtocol, ms.handleQueryDeal)
+	return ms
+}
+
+// Add starts serving the storage protocols for sm, replacing any miner with
+// the same address.
+func (ms *MinerSet) Add(sm *Miner) {
+	ms.lk.Lock()
+	defer ms.lk.Unlock()
+
+	for i, m := range ms.miners {
+		if m.minerAddr == sm.minerAddr {
+			ms.miners[i] = sm
+			return
+		}
+	}
+	ms.miners = append(ms.miners, sm)
+}
+
+// Get returns the miner with address addr, or nil if the set has none.
+func (ms *MinerSet) Get(addr address.Address) *Miner {
+	ms.lk.Lock()
+	defer ms.lk.Unlock()
+
+	for _, m := range ms.miners {
+		if m.minerAddr == addr {
+			return m
+		}
+	}
+	return nil
+}
+
+// Miners returns the miners in the set in the order they were added.
+func (ms *MinerSet) Miners() []*Miner {
+	ms.lk.Lock()
+	defer ms.lk.Unlock()
+
+	return append([]*Miner{}, ms.miners...)
+}
+
+func (ms *MinerSet) handleMakeDeal(s inet.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	var signedProposal storagedeal.SignedDealProposal
+	if err := cbu.NewMsgReader(s).ReadMsg(&signedProposal); err != nil {
+		log.Errorf("received invalid proposal: %s", err)
+		return
+	}
+
+	minerAddr := signedProposal.Proposal.MinerAddress
+	sm := ms.Get(minerAddr)
+	if sm == nil {
+		log.Errorf("received proposal for miner %s which this node does not run", minerAddr)
+		resp := &storagedeal.Response{
+			State:   storagedeal.Rejected,
+			Message: fmt.Sprintf("this node does not run miner %s", minerAddr),
+		}
+		if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
+			log.Errorf("failed to write proposal response: %s", err)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	resp, err := sm.receiveStorageProposal(ctx, &signedProposal)
+	if err != nil {
+		log.Errorf("failed to process proposal: %s", err)
+		return
+	}
+
+	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
+		log.Errorf("failed to write proposal response: %s", err)
+	}
+}
+
+// Query responds to a query for the proposal referenced by the given cid,
+// made to any of the miners in the set.
+func (ms *MinerSet) Query(ctx context.Context, c cid.Cid) *storagedeal.Response {
+	for _, sm := range ms.Miners() {
+		if resp := sm.Query(ctx, c); resp.State != storagedeal.Unknown {
+			return resp
+		}
+	}
+	return &storagedeal.Response{
+		State:   storagedeal.Unknown,
+		Message: "no such deal",
+	}
+}
+
+func (ms *MinerSet) handleQueryDeal(s inet.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	ctx := context.Background()
+
+	var q storagedeal.QueryRequest
+	if err := cbu.NewMsgReader(s).ReadMsg(&q); err != nil {
+		log.Errorf("received invalid query: %s", err)
+		return
+	}
+
+	resp := ms.Query(ctx, q.Cid)
+
+	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
+		log.Errorf("failed to write query response: %s", err)
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"testing"
@@ -253,6 +254,58 @@ func TestDealsAwaitingSealPersistence(t *testing.T) {
 
 		assert.Equal(t, dealCid, miner.dealsAwaitingSeal.SectorsToDeals[42][0])
 	})
+
+	t.Run("miners sharing a datastore keep their deals apart", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().DealsDatastore()
+		addrGetter := address.NewForTestGetter()
+		minerA := &Miner{minerAddr: addrGetter(), dealsAwaitingSeal: newDealsAwaitingSeal(), dealsAwaitingSealDs: ds}
+		minerB := &Miner{minerAddr: addrGetter(), dealsAwaitingSeal: newDealsAwaitingSeal(), dealsAwaitingSealDs: ds}
+
+		minerA.dealsAwaitingSeal.attachDealToSector(context.Background(), wantSectorID, dealCid)
+		require.NoError(t, minerA.saveDealsAwaitingSeal())
+		require.NoError(t, minerB.saveDealsAwaitingSeal())
+
+		require.NoError(t, minerA.loadDealsAwaitingSeal())
+		require.NoError(t, minerB.loadDealsAwaitingSeal())
+		assert.Equal(t, dealCid, minerA.dealsAwaitingSeal.SectorsToDeals[42][0])
+		assert.Empty(t, minerB.dealsAwaitingSeal.SectorsToDeals)
+	})
+
+	t.Run("adopts deals persisted before miners had their own key", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().DealsDatastore()
+		legacy := newDealsAwaitingSeal()
+		legacy.attachDealToSector(context.Background(), wantSectorID, dealCid)
+		data, err := json.Marshal(legacy)
+		require.NoError(t, err)
+		require.NoError(t, ds.Put(legacyDealsAwaitingSealKey, data))
+
+		miner := &Miner{minerAddr: address.NewForTestGetter()(), dealsAwaitingSealDs: ds, ownsLegacyDeals: true}
+		require.NoError(t, miner.loadDealsAwaitingSeal())
+		assert.Equal(t, dealCid, miner.dealsAwaitingSeal.SectorsToDeals[42][0])
+
+		require.NoError(t, miner.saveDealsAwaitingSeal())
+		has, err := ds.Has(legacyDealsAwaitingSealKey)
+		require.NoError(t, err)
+		assert.False(t, has)
+	})
+
+	t.Run("only the original miner adopts legacy deals", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().DealsDatastore()
+		legacy := newDealsAwaitingSeal()
+		legacy.attachDealToSector(context.Background(), wantSectorID, dealCid)
+		data, err := json.Marshal(legacy)
+		require.NoError(t, err)
+		require.NoError(t, ds.Put(legacyDealsAwaitingSealKey, data))
+
+		miner := &Miner{minerAddr: address.NewForTestGetter()(), dealsAwaitingSealDs: ds}
+		require.NoError(t, miner.loadDealsAwaitingSeal())
+		assert.Empty(t, miner.dealsAwaitingSeal.SectorsToDeals)
+
+		require.NoError(t, miner.saveDealsAwaitingSeal())
+		has, err := ds.Has(legacyDealsAwaitingSealKey)
+		require.NoError(t, err)
+		assert.True(t, has)
+	})
 }
 
 func TestOnCommitmentAddedToChain(t *testing.T) {
@@ -360,6 +413,10 @@ func (mtp *minerTestPorcelain) ActorGetSignature(ctx context.Context, actorAddr
 	return nil, nil
 }
 
+func (mtp *minerTestPorcelain) MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	return types.NewGasUnits(300), nil
+}
+
 func (mtp *minerTestPorcelain) MessageSend(ctx context.Context, from, to address.Address, val types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error) {
 	return cid.Cid{}, nil
 }
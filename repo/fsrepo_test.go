@@ -40,6 +40,9 @@ const (
 		"minPeerThreshold": 0,
 		"period": "1m"
 	},
+	"consensus": {
+		"protocol": "expected"
+	},
 	"datastore": {
 		"type": "badgerds",
 		"path": "badger"
@@ -52,12 +55,20 @@ const (
 	},
 	"mining": {
 		"minerAddress": "empty",
+		"additionalMinerAddresses": [],
 		"autoSealIntervalSeconds": 120,
-		"storagePrice": "0"
+		"storagePrice": "0",
+		"reportConsensusFaults": false
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": "100"
+		"maxNonceGap": "100",
+		"replaceByFeePercent": 10,
+		"maxPendingPerSender": 100,
+		"minGasPrice": "0"
+	},
+	"msgIndex": {
+		"enabled": false
 	},
 	"net": "",
 	"observability": {
@@ -78,8 +89,12 @@ const (
 	"swarm": {
 		"address": "/ip4/0.0.0.0/tcp/6000"
 	},
+	"sync": {
+		"checkpoints": []
+	},
 	"wallet": {
-		"defaultAddress": "empty"
+		"defaultAddress": "empty",
+		"remoteBackends": []
 	}
 }`
 )
@@ -105,13 +105,20 @@ func MkFakeChildCore(parent types.TipSet,
 
 	pIDs := parent.ToSortedCidSet()
 
-	newBlock := NewValidTestBlockFromTipSet(parent, stateRoot, height, minerAddr, minerPubKey, signer)
+	newBlock, err := NewValidTestBlockFromTipSet(parent, stateRoot, height, minerAddr, minerPubKey, signer)
+	if err != nil {
+		return nil, err
+	}
 
 	// Override fake values with our values
 	newBlock.Parents = pIDs
 	newBlock.ParentWeight = types.Uint64(w)
 	newBlock.Nonce = types.Uint64(nonce)
 	newBlock.StateRoot = stateRoot
+	// Sign again over the overridden values.
+	if err := consensus.SignBlock(newBlock, minerPubKey, signer); err != nil {
+		return nil, err
+	}
 
 	return newBlock, nil
 }
@@ -105,6 +105,7 @@ type TestDaemon struct {
 	keyFiles         []string
 	withMiner        string
 	autoSealInterval string
+	passphraseFile   string
 	isRelay          bool
 
 	firstRun bool
@@ -733,6 +734,14 @@ func AutoSealInterval(autoSealInterval string) func(*TestDaemon) {
 	}
 }
 
+// WalletPassphraseFile specifies a file containing the passphrase to encrypt the daemon's wallet
+// with during init
+func WalletPassphraseFile(pf string) func(*TestDaemon) {
+	return func(td *TestDaemon) {
+		td.passphraseFile = pf
+	}
+}
+
 // GenesisFile allows setting the `genesisFile` config option on the daemon.
 func GenesisFile(a string) func(*TestDaemon) {
 	return func(td *TestDaemon) {
@@ -802,6 +811,10 @@ func NewDaemon(t *testing.T, options ...func(*TestDaemon)) *TestDaemon {
 		initopts = append(initopts, fmt.Sprintf("--auto-seal-interval-seconds=%s", td.autoSealInterval))
 	}
 
+	if td.passphraseFile != "" {
+		initopts = append(initopts, fmt.Sprintf("--wallet-passphrase-file=%s", td.passphraseFile))
+	}
+
 	if td.init {
 		t.Logf("run: go-filecoin init %s", initopts)
 		out, err := RunInit(td, initopts...)
@@ -71,12 +71,12 @@ func (tv *TestPowerTableView) HasPower(ctx context.Context, st state.Tree, bstor
 }
 
 // NewValidTestBlockFromTipSet creates a block for when proofs & power table don't need
-// to be correct
-func NewValidTestBlockFromTipSet(baseTipSet types.TipSet, stateRootCid cid.Cid, height uint64, minerAddr address.Address, minerPubKey []byte, signer consensus.TicketSigner) *types.Block {
+// to be correct.  The block is signed with minerPubKey, which signer must hold.
+func NewValidTestBlockFromTipSet(baseTipSet types.TipSet, stateRootCid cid.Cid, height uint64, minerAddr address.Address, minerPubKey []byte, signer consensus.TicketSigner) (*types.Block, error) {
 	poStProof := MakeRandomPoStProofForTest()
 	ticket, _ := consensus.CreateTicket(poStProof, minerPubKey, signer)
 
-	return &types.Block{
+	blk := &types.Block{
 		Miner:        minerAddr,
 		Ticket:       ticket,
 		Parents:      baseTipSet.ToSortedCidSet(),
@@ -86,6 +86,24 @@ func NewValidTestBlockFromTipSet(baseTipSet types.TipSet, stateRootCid cid.Cid,
 		StateRoot:    stateRootCid,
 		Proof:        poStProof,
 	}
+	if err := consensus.SignBlock(blk, minerPubKey, signer); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// RequireNewValidTestBlockFromTipSet wraps NewValidTestBlockFromTipSet with a
+// requirement that it does not error.
+func RequireNewValidTestBlockFromTipSet(t *testing.T, baseTipSet types.TipSet, stateRootCid cid.Cid, height uint64, minerAddr address.Address, minerPubKey []byte, signer consensus.TicketSigner) *types.Block {
+	blk, err := NewValidTestBlockFromTipSet(baseTipSet, stateRootCid, height, minerAddr, minerPubKey, signer)
+	require.NoError(t, err)
+	return blk
+}
+
+// RequireSignBlock signs blk with the key of minerPubKey, which must be held
+// by signer.  Blocks must be signed again after any of their fields change.
+func RequireSignBlock(t *testing.T, blk *types.Block, minerPubKey []byte, signer consensus.TicketSigner) {
+	require.NoError(t, consensus.SignBlock(blk, minerPubKey, signer))
 }
 
 // MakeRandomPoStProofForTest creates a random proof.
@@ -170,7 +188,7 @@ func ApplyTestMessageWithGas(st state.Tree, store vm.StorageMap, msg *types.Mess
 	if err != nil {
 		panic(err)
 	}
-	applier := consensus.NewConfiguredProcessor(consensus.NewDefaultMessageValidator(), consensus.NewDefaultBlockRewarder())
+	applier := consensus.NewConfiguredProcessor(consensus.NewBlockMessageValidator(), consensus.NewDefaultBlockRewarder())
 	return newMessageApplier(smsg, applier, st, store, bh, minerOwner, nil)
 }
 
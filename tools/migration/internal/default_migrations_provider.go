@@ -11,7 +11,10 @@ package internal
 // See runner_test for examples.
 
 import (
+	"os"
+
 	migration12 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-1-2"
+	migration23 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-2-3"
 )
 
 // DefaultMigrationsProvider is the migrations provider dependency used in production.
@@ -19,5 +22,6 @@ import (
 func DefaultMigrationsProvider() []Migration {
 	return []Migration{
 		&migration12.MetadataFormatJSONtoCBOR{},
+		&migration23.WalletEncryption{Passphrase: []byte(os.Getenv("FIL_WALLET_PASSPHRASE"))},
 	}
 }
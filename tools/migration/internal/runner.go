@@ -123,10 +123,12 @@ func (m *MigrationRunner) Run() RunResult {
 		}
 	}
 	err = m.runCommand(mig)
+	// Only one migration runs at a time, so the repo may still be behind the target version.
+	_, newVersion := mig.Versions()
 	return RunResult{
 		Err:         err,
 		OldVersion:  repoVersion,
-		NewVersion:  targetVersion,
+		NewVersion:  newVersion,
 		NewRepoPath: m.newRepoPath,
 	}
 }
@@ -44,6 +44,10 @@ OPTIONS
 	-v --verbose   Print diagnostic messages to stdout
 	--log-file     The path of the file for writing detailed log output
 
+ENVIRONMENT
+	FIL_WALLET_PASSPHRASE	the passphrase to encrypt the wallet with when migrating from
+		version 2 to 3. If it is unset the passphrase is empty, and the wallet is never locked.
+
 EXAMPLES
 	for a migration from version 1 to 2:
 	go-filecoin-migrate migrate --old-repo=~/.filecoin
@@ -0,0 +1,255 @@
+package migration23
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+// ==============  IMPORTANT ================
+// PLEASE SEE THE README IF YOU ARE HERE BECAUSE YOUR CHANGES BROKE A MIGRATION TEST
+// ==========================================
+// duplicate the wallet's keystore format here to protect against future changes
+var keystoreKey = datastore.NewKey("keystore")
+
+var keystoreCheck = []byte("filecoin wallet keystore")
+
+const (
+	scryptN   = 1 << 15
+	scryptR   = 8
+	scryptP   = 1
+	keyLength = 32
+	saltBytes = 32
+)
+
+func init() {
+	cbor.RegisterCborType(keystoreParams{})
+}
+
+// keystoreParams are the parameters of the encryption of the wallet's keys, as stored by
+// the wallet's DSBackend.
+type keystoreParams struct {
+	Salt  []byte
+	Check []byte
+}
+
+// WalletEncryption is the migration from version 2 to 3.
+type WalletEncryption struct {
+	// Passphrase is the passphrase to encrypt the wallet with.
+	Passphrase []byte
+}
+
+// Describe describes the steps this migration will take.
+func (m *WalletEncryption) Describe() string {
+	return `WalletEncryption migrates the storage repo from version 2 to 3.
+
+    This migration encrypts the private keys in the wallet datastore, which were
+    stored in plaintext. Each key is sealed with AES-GCM with a key derived with
+    scrypt from the passphrase in FIL_WALLET_PASSPHRASE, which is then needed to
+    unlock the wallet. If it is unset the passphrase is empty, and the wallet
+    stays unlocked. No other repo data is changed.
+`
+}
+
+// Migrate performs the migration steps
+func (m *WalletEncryption) Migrate(newRepoPath string) error {
+	oldVer, _ := m.Versions()
+
+	// This call performs some checks on the repo before we start.
+	fsrepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(fsrepo)
+
+	return encryptWallet(fsrepo.WalletDatastore(), m.Passphrase)
+}
+
+// Versions returns the old and new versions that are valid for this migration
+func (m *WalletEncryption) Versions() (from, to uint) {
+	return 2, 3
+}
+
+// Validate performs validation tests for the migration steps:
+// Reads in the old and new wallet keys, and returns an error unless the new
+// keys decrypt to the old ones with the passphrase.
+func (m *WalletEncryption) Validate(oldRepoPath, newRepoPath string) error {
+	oldVer, _ := m.Versions()
+
+	// This call performs some checks on the repo before we start.
+	oldFsRepo, err := repo.OpenFSRepo(oldRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(oldFsRepo)
+
+	// Version hasn't been updated yet.
+	newFsRepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(newFsRepo)
+
+	oldKeys, err := loadEntries(oldFsRepo.WalletDatastore())
+	if err != nil {
+		return err
+	}
+	newKeys, err := loadEntries(newFsRepo.WalletDatastore())
+	if err != nil {
+		return err
+	}
+
+	paramsBytes, ok := newKeys[keystoreKey]
+	if !ok {
+		return errors.New("new wallet has no keystore parameters")
+	}
+	delete(newKeys, keystoreKey)
+
+	var params keystoreParams
+	if err := cbor.DecodeInto(paramsBytes, &params); err != nil {
+		return errors.Wrap(err, "failed to unmarshal keystore parameters")
+	}
+	key, err := scrypt.Key(m.Passphrase, params.Salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return errors.Wrap(err, "failed to derive key")
+	}
+	check, err := unseal(key, params.Check)
+	if err != nil || !bytes.Equal(check, keystoreCheck) {
+		return errors.New("keystore parameters don't match the passphrase")
+	}
+
+	if len(oldKeys) != len(newKeys) {
+		return errors.Errorf("old wallet has %d keys, new wallet %d", len(oldKeys), len(newKeys))
+	}
+	for k, kib := range oldKeys {
+		sealed, ok := newKeys[k]
+		if !ok {
+			return errors.Errorf("key %s missing from new wallet", k)
+		}
+		newKib, err := unseal(key, sealed)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decrypt key %s", k)
+		}
+		if !bytes.Equal(kib, newKib) {
+			return errors.Errorf("key %s differs in new wallet", k)
+		}
+	}
+	return nil
+}
+
+// encryptWallet seals every key in the wallet datastore with a key derived from the
+// passphrase, and stores the parameters the wallet needs to derive the key again.
+func encryptWallet(ds repo.Datastore, passphrase []byte) error {
+	keys, err := loadEntries(ds)
+	if err != nil {
+		return err
+	}
+	if _, ok := keys[keystoreKey]; ok {
+		return errors.New("wallet is already encrypted")
+	}
+
+	salt := make([]byte, saltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "failed to generate salt")
+	}
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return errors.Wrap(err, "failed to derive key")
+	}
+	check, err := seal(key, keystoreCheck)
+	if err != nil {
+		return err
+	}
+	paramsBytes, err := cbor.DumpObject(keystoreParams{Salt: salt, Check: check})
+	if err != nil {
+		return err
+	}
+
+	batch, err := ds.Batch()
+	if err != nil {
+		return err
+	}
+	for k, kib := range keys {
+		sealed, err := seal(key, kib)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(k, sealed); err != nil {
+			return err
+		}
+	}
+	if err := batch.Put(keystoreKey, paramsBytes); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// loadEntries reads all entries of the wallet datastore.
+func loadEntries(ds repo.Datastore) (map[datastore.Key][]byte, error) {
+	res, err := ds.Query(dsq.Query{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query wallet datastore")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read wallet datastore")
+	}
+
+	out := make(map[datastore.Key][]byte)
+	for _, e := range entries {
+		out[datastore.NewKey(e.Key)] = e.Value
+	}
+	return out, nil
+}
+
+// seal is taken from the wallet's seal.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// unseal is taken from the wallet's unseal.
+func unseal(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func mustCloseRepo(fsRepo *repo.FSRepo) {
+	err := fsRepo.Close()
+	if err != nil {
+		panic(err)
+	}
+}
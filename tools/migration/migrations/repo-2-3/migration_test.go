@@ -0,0 +1,110 @@
+package migration23_test
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/tools/migration/internal"
+	migration23 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-2-3"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
+)
+
+// ==============  IMPORTANT ================
+// PLEASE SEE THE README IF YOU ARE HERE BECAUSE YOUR CHANGES BROKE A MIGRATION TEST
+// ==========================================
+func TestDescribe(t *testing.T) {
+	tf.UnitTest(t)
+
+	mig := migration23.WalletEncryption{}
+
+	expected := `WalletEncryption migrates the storage repo from version 2 to 3.
+
+    This migration encrypts the private keys in the wallet datastore, which were
+    stored in plaintext. Each key is sealed with AES-GCM with a key derived with
+    scrypt from the passphrase in FIL_WALLET_PASSPHRASE, which is then needed to
+    unlock the wallet. If it is unset the passphrase is empty, and the wallet
+    stays unlocked. No other repo data is changed.
+`
+	assert.Equal(t, expected, mig.Describe())
+}
+
+func TestMigrateWallet(t *testing.T) {
+	tf.UnitTest(t)
+
+	passphrase := []byte("passphrase")
+	mig := migration23.WalletEncryption{Passphrase: passphrase}
+	oldVer, newVer := mig.Versions()
+
+	container, repoLink := internal.RequireInitRepo(t, oldVer)
+	defer repo.RequireRemoveAll(t, container)
+
+	// store keys in plaintext, as version 2 wallets did
+	kis := types.MustGenerateKeyInfo(2, 42)
+	fsrepo, err := repo.OpenFSRepo(repoLink, oldVer)
+	require.NoError(t, err)
+	for _, ki := range kis {
+		addr, err := ki.Address()
+		require.NoError(t, err)
+		kib, err := ki.Marshal()
+		require.NoError(t, err)
+		require.NoError(t, fsrepo.WalletDatastore().Put(datastore.NewKey(addr.String()), kib))
+	}
+	require.NoError(t, fsrepo.Close())
+
+	t.Run("Happy path: valid migration passes validation", func(t *testing.T) {
+		newRepoPath, err := internal.CloneRepo(repoLink, newVer)
+		require.NoError(t, err)
+
+		require.NoError(t, mig.Migrate(newRepoPath))
+		require.NoError(t, mig.Validate(repoLink, newRepoPath))
+
+		newRepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, newRepo.Close())
+		}()
+
+		backend, err := wallet.NewDSBackend(newRepo.WalletDatastore())
+		require.NoError(t, err)
+		assert.True(t, backend.IsLocked())
+		require.NoError(t, backend.Unlock(passphrase, 0))
+
+		for _, ki := range kis {
+			addr, err := ki.Address()
+			require.NoError(t, err)
+			migrated, err := backend.GetKeyInfo(addr)
+			require.NoError(t, err)
+			assert.True(t, ki.Equals(migrated))
+		}
+	})
+
+	t.Run("Validation before migration is run fails validation", func(t *testing.T) {
+		newRepoPath, err := internal.CloneRepo(repoLink, newVer)
+		require.NoError(t, err)
+
+		assert.Error(t, mig.Validate(repoLink, newRepoPath))
+	})
+
+	t.Run("Validation with another passphrase fails validation", func(t *testing.T) {
+		newRepoPath, err := internal.CloneRepo(repoLink, newVer)
+		require.NoError(t, err)
+
+		require.NoError(t, mig.Migrate(newRepoPath))
+		other := migration23.WalletEncryption{Passphrase: []byte("other")}
+		assert.Error(t, other.Validate(repoLink, newRepoPath))
+	})
+
+	t.Run("Migrating an encrypted wallet fails", func(t *testing.T) {
+		newRepoPath, err := internal.CloneRepo(repoLink, newVer)
+		require.NoError(t, err)
+
+		require.NoError(t, mig.Migrate(newRepoPath))
+		assert.Error(t, mig.Migrate(newRepoPath))
+	})
+}
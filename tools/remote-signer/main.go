@@ -0,0 +1,233 @@
+// remote-signer is a reference external signer for the wallet's RemoteBackend. It holds the
+// keys of a wallet export file in memory and signs with them for the node, which never sees
+// them. See wallet.RemoteBackend for the protocol.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
+)
+
+var log = logging.Logger("remote-signer")
+
+func init() {
+	// Info level
+	logging.SetAllLoggers(4)
+}
+
+func main() {
+	listen := flag.String("listen", "unix:///tmp/filecoin-signer.sock", "endpoint to serve on, unix:///path/to/socket or http://host:port")
+	keyFile := flag.String("key-file", "", "(required) file of keys to sign with, as written by `go-filecoin wallet export --enc=json`")
+	tokenFile := flag.String("token-file", "", "file of the token clients must send, created if missing; required to listen on http")
+	allowRemote := flag.Bool("allow-remote", false, "allow listening on http on an address other than loopback")
+	flag.Parse()
+
+	if *keyFile == "" {
+		fmt.Println("ERROR: must provide a key file")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	backend, err := loadKeys(*keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var token string
+	if *tokenFile != "" {
+		token, err = loadToken(*tokenFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ln, err := listenOn(*listen, token != "", *allowRemote)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("signing for %s on %s", backend.Addresses(), ln.Addr())
+
+	log.Fatal(http.Serve(ln, newHandler(backend, token)))
+}
+
+// loadToken reads the token clients must send from the file, first writing a random one
+// only accessible to the user if the file doesn't exist.
+func loadToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", errors.Errorf("token file %s is empty", path)
+		}
+		return token, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	log.Infof("wrote a new token to %s", path)
+	return token, nil
+}
+
+// loadKeys imports the keys of the file into an in-memory wallet backend.
+func loadKeys(path string) (*wallet.DSBackend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var export struct {
+		KeyInfo []*types.KeyInfo
+	}
+	if err := json.NewDecoder(f).Decode(&export); err != nil {
+		return nil, errors.Wrap(err, "failed to read key file")
+	}
+	if len(export.KeyInfo) == 0 {
+		return nil, errors.New("no keys in key file")
+	}
+
+	backend, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	if err != nil {
+		return nil, err
+	}
+	for _, ki := range export.KeyInfo {
+		if err := backend.ImportKey(ki); err != nil {
+			return nil, err
+		}
+	}
+	return backend, nil
+}
+
+// listenOn listens on the endpoint, replacing a stale socket file, which is only accessible
+// to the user. Any local user can reach a TCP address, so it requires a token, and it must
+// be a loopback address unless allowRemote. An http endpoint without a host listens on
+// 127.0.0.1.
+func listenOn(endpoint string, hasToken, allowRemote bool) (net.Listener, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "unix":
+		if err := os.Remove(u.Path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		ln, err := net.Listen("unix", u.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(u.Path, 0600); err != nil {
+			ln.Close() // nolint: errcheck
+			return nil, err
+		}
+		return ln, nil
+	case "http":
+		if !hasToken {
+			return nil, errors.Errorf("invalid endpoint %s: listening on http requires a token file", endpoint)
+		}
+		host := u.Hostname()
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		if !allowRemote && !isLoopback(host) {
+			return nil, errors.Errorf("invalid endpoint %s: %s is not a loopback address, see -allow-remote", endpoint, host)
+		}
+		return net.Listen("tcp", net.JoinHostPort(host, u.Port()))
+	default:
+		return nil, errors.Errorf("invalid endpoint %s: scheme must be unix or http", endpoint)
+	}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newHandler serves the remote signer protocol for the backend, to clients sending the
+// token as a bearer token, if not empty.
+func newHandler(backend wallet.Backend, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/addresses", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method must be GET", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, &wallet.RemoteAddressesResponse{Addresses: backend.Addresses()})
+	})
+
+	mux.HandleFunc("/sign", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method must be POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req wallet.RemoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if !backend.HasAddress(req.Address) {
+			http.Error(w, fmt.Sprintf("no key for address %s", req.Address), http.StatusNotFound)
+			return
+		}
+
+		sig, err := backend.SignBytes(req.Data, req.Address)
+		if err != nil {
+			log.Errorf("failed to sign for %s: %s", req.Address, err)
+			http.Error(w, "failed to sign", http.StatusInternalServerError)
+			return
+		}
+		log.Infof("signed %d bytes for %s", len(req.Data), req.Address)
+		writeJSON(w, &wallet.RemoteSignResponse{Signature: sig})
+	})
+
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(auth, []byte("Bearer "+token)) != 1 {
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("failed to write response: %s", err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	tf.UnitTest(t)
+
+	kis := types.MustGenerateKeyInfo(2, 42)
+	var addrs []address.Address
+	export := struct{ KeyInfo []*types.KeyInfo }{}
+	for i := range kis {
+		addr, err := kis[i].Address()
+		require.NoError(t, err)
+		addrs = append(addrs, addr)
+		export.KeyInfo = append(export.KeyInfo, &kis[i])
+	}
+
+	f, err := ioutil.TempFile("", "keys")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Remove(f.Name()))
+	}()
+	require.NoError(t, json.NewEncoder(f).Encode(&export))
+	require.NoError(t, f.Close())
+
+	signer, err := loadKeys(f.Name())
+	require.NoError(t, err)
+	server := httptest.NewServer(newHandler(signer, ""))
+	defer server.Close()
+
+	backend, err := wallet.NewRemoteBackend(server.URL)
+	require.NoError(t, err)
+
+	t.Run("serves the addresses of the key file", func(t *testing.T) {
+		assert.ElementsMatch(t, addrs, backend.Addresses())
+	})
+
+	t.Run("signs with the keys of the key file", func(t *testing.T) {
+		data := []byte("data")
+		for _, addr := range addrs {
+			sig, err := backend.SignBytes(data, addr)
+			require.NoError(t, err)
+			assert.True(t, types.IsValidSignature(data, addr, sig))
+		}
+
+		_, err := backend.SignBytes(data, address.NewForTestGetter()())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no key for address")
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/sign")
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+func TestRemoteSignerToken(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "remote-signer")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	tokenFile := filepath.Join(dir, "token")
+	token, err := loadToken(tokenFile)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	info, err := os.Stat(tokenFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	again, err := loadToken(tokenFile)
+	require.NoError(t, err)
+	assert.Equal(t, token, again)
+
+	kis := types.MustGenerateKeyInfo(1, 42)
+	addr, err := kis[0].Address()
+	require.NoError(t, err)
+	signer, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	require.NoError(t, signer.ImportKey(&kis[0]))
+
+	server := httptest.NewServer(newHandler(signer, token))
+	defer server.Close()
+
+	t.Run("rejects requests without the token", func(t *testing.T) {
+		backend, err := wallet.NewRemoteBackend(server.URL)
+		require.NoError(t, err)
+		assert.Empty(t, backend.Addresses())
+
+		_, err = backend.SignBytes([]byte("data"), addr)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid token")
+	})
+
+	t.Run("serves requests with the token", func(t *testing.T) {
+		u, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		u.User = url.User(token)
+		backend, err := wallet.NewRemoteBackend(u.String())
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{addr}, backend.Addresses())
+
+		_, err = backend.SignBytes([]byte("data"), addr)
+		assert.NoError(t, err)
+	})
+}
+
+func TestListenOn(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("requires a token on http", func(t *testing.T) {
+		_, err := listenOn("http://127.0.0.1:0", false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires a token")
+	})
+
+	t.Run("listens on loopback by default", func(t *testing.T) {
+		ln, err := listenOn("http://:0", true, false)
+		require.NoError(t, err)
+		defer ln.Close() // nolint: errcheck
+		assert.True(t, strings.HasPrefix(ln.Addr().String(), "127.0.0.1:"))
+	})
+
+	t.Run("listens on other addresses only if allowed", func(t *testing.T) {
+		_, err := listenOn("http://0.0.0.0:0", true, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not a loopback address")
+
+		ln, err := listenOn("http://0.0.0.0:0", true, true)
+		require.NoError(t, err)
+		require.NoError(t, ln.Close())
+	})
+}
@@ -41,6 +41,10 @@ type Block struct {
 	// TODO: should be a merkletree-ish thing
 	Messages []*SignedMessage `json:"messages"`
 
+	// BLSAggregateSig is the aggregate of the signatures of the messages from
+	// BLS addresses, which are included in Messages without their own.
+	BLSAggregateSig Signature `json:"blsAggregateSig" refmt:",omitempty"`
+
 	// StateRoot is a cid pointer to the state tree after application of the
 	// transactions state transitions.
 	StateRoot cid.Cid `json:"stateRoot,omitempty" refmt:",omitempty"`
@@ -55,6 +59,10 @@ type Block struct {
 	// The timestamp, in seconds since the Unix epoch, at which this block was created.
 	Timestamp Uint64 `json:"timestamp"`
 
+	// BlockSig is the signature of the miner's worker key over the block's
+	// SignatureData.
+	BlockSig Signature `json:"blockSig" refmt:",omitempty"`
+
 	cachedCid cid.Cid
 
 	cachedBytes []byte
@@ -97,6 +105,21 @@ func (b *Block) Cid() cid.Cid {
 	return b.cachedCid
 }
 
+// SignatureData returns the bytes a miner signs to produce the block's
+// BlockSig: the block's cbor encoding without the signature.
+func (b *Block) SignatureData() []byte {
+	unsigned := *b
+	unsigned.BlockSig = nil
+	unsigned.cachedCid = cid.Undef
+	unsigned.cachedBytes = nil
+
+	bytes, err := cbor.DumpObject(&unsigned)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
 // IsParentOf returns true if the argument is a parent of the receiver.
 func (b Block) IsParentOf(c Block) bool {
 	return c.Parents.Has(b.Cid())
@@ -67,17 +67,19 @@ func TestTriangleEncoding(t *testing.T) {
 			Height:          Uint64(2),
 			Nonce:           3,
 			Messages:        []*SignedMessage{newSignedMessage()},
+			BLSAggregateSig: []byte{0x04, 0x05, 0x06},
 			MessageReceipts: []*MessageReceipt{{ExitCode: 1}},
 			Parents:         NewSortedCidSet(SomeCid()),
 			ParentWeight:    Uint64(1000),
 			Proof:           NewTestPoSt(),
 			StateRoot:       SomeCid(),
 			Timestamp:       Uint64(1),
+			BlockSig:        []byte{0x07, 0x08, 0x09},
 		}
 		s := reflect.TypeOf(*b)
 		// This check is here to request that you add a non-zero value for new fields
 		// to the above (and update the field count below).
-		require.Equal(t, 13, s.NumField()) // Note: this also counts private fields
+		require.Equal(t, 15, s.NumField()) // Note: this also counts private fields
 		testRoundTrip(t, b)
 	})
 }
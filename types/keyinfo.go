@@ -6,6 +6,7 @@ import (
 	cbor "github.com/ipfs/go-ipld-cbor"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 )
 
@@ -58,11 +59,20 @@ func (ki *KeyInfo) Equals(other *KeyInfo) bool {
 
 // Address returns the address for this keyinfo
 func (ki *KeyInfo) Address() (address.Address, error) {
-	// TODO: Use the address type we are running on from the config.
+	if ki.Curve == BLS {
+		return address.NewBLSAddress(ki.PublicKey())
+	}
 	return address.NewSecp256k1Address(ki.PublicKey())
 }
 
-// PublicKey returns the public key part as uncompressed bytes.
+// PublicKey returns the public key part, as uncompressed bytes for secp256k1 keys and
+// compressed bytes for BLS keys.
 func (ki *KeyInfo) PublicKey() []byte {
+	if ki.Curve == BLS {
+		var key bls.PrivateKey
+		copy(key[:], ki.PrivateKey)
+		pub := bls.PrivateKeyPublicKey(key)
+		return pub[:]
+	}
 	return crypto.PublicKey(ki.PrivateKey)
 }
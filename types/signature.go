@@ -4,6 +4,7 @@ import (
 	logging "github.com/ipfs/go-log"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
 
@@ -15,6 +16,11 @@ type Signature []byte
 // IsValidSignature cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key belonging to `addr`.
 func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
+	if !addr.Empty() && addr.Protocol() == address.BLS {
+		// BLS addresses are public keys, no recovery needed.
+		return wutil.VerifyBLS(addr.Payload(), data, sig)
+	}
+
 	maybePk, err := wutil.Ecrecover(data, sig)
 	if err != nil {
 		// Any error returned from Ecrecover means this signature is not valid.
@@ -29,3 +35,12 @@ func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
 
 	return maybeAddr == addr
 }
+
+// PublicKeyAddress returns the address of the public key `pk`, a BLS key if
+// it has the length of one and a secp256k1 key otherwise.
+func PublicKeyAddress(pk []byte) (address.Address, error) {
+	if len(pk) == bls.PublicKeyBytes {
+		return address.NewBLSAddress(pk)
+	}
+	return address.NewSecp256k1Address(pk)
+}
@@ -63,10 +63,16 @@ func (smsg *SignedMessage) Marshal() ([]byte, error) {
 	return cbor.DumpObject(smsg)
 }
 
-// Cid returns the canonical CID for the SignedMessage.
+// Cid returns the canonical CID for the SignedMessage. The CID of a message from
+// a BLS address does not cover its signature, so it is the same once the signature
+// has been aggregated into a block.
 // TODO: can we avoid returning an error?
 func (smsg *SignedMessage) Cid() (cid.Cid, error) {
-	obj, err := cbor.WrapObject(smsg, DefaultHashFunction, -1)
+	toHash := smsg
+	if smsg.IsFromBLS() {
+		toHash = smsg.WithoutSignature()
+	}
+	obj, err := cbor.WrapObject(toHash, DefaultHashFunction, -1)
 	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to marshal to cbor")
 	}
@@ -74,6 +80,17 @@ func (smsg *SignedMessage) Cid() (cid.Cid, error) {
 	return obj.Cid(), nil
 }
 
+// IsFromBLS returns true if the message is from a BLS address. Blocks include such
+// messages without a signature, aggregating their signatures into one.
+func (smsg *SignedMessage) IsFromBLS() bool {
+	return !smsg.From.Empty() && smsg.From.Protocol() == address.BLS
+}
+
+// WithoutSignature returns a copy of the message with an empty signature.
+func (smsg *SignedMessage) WithoutSignature() *SignedMessage {
+	return &SignedMessage{MeteredMessage: smsg.MeteredMessage}
+}
+
 // RecoverAddress returns the address derived from the signature and message encapsulated in `SignedMessage`
 func (smsg *SignedMessage) RecoverAddress(r Recoverer) (address.Address, error) {
 	if len(smsg.Signature) < 1 {
@@ -118,8 +135,9 @@ func (smsg *SignedMessage) String() string {
 	return fmt.Sprintf("SignedMessage cid=[%v]: %s", cid, string(js))
 }
 
-// Equals tests whether two signed messages are equal.
+// Equals tests whether two signed messages are equal. Like their CIDs, messages
+// from BLS addresses are compared without their signatures.
 func (smsg *SignedMessage) Equals(other *SignedMessage) bool {
 	return smsg.MeteredMessage.Equals(&other.MeteredMessage) &&
-		bytes.Equal(smsg.Signature, other.Signature)
+		(smsg.IsFromBLS() || bytes.Equal(smsg.Signature, other.Signature))
 }
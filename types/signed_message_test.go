@@ -75,6 +75,25 @@ func TestSignedMessageCid(t *testing.T) {
 	assert.NotEqual(t, c1.String(), c2.String())
 }
 
+func TestSignedMessageCidFromBLS(t *testing.T) {
+	tf.UnitTest(t)
+
+	blsSigner := NewMockSigner(MustGenerateBLSKeyInfo(1))
+	smsg := makeMessage(t, blsSigner, 42)
+	require.True(t, smsg.IsFromBLS())
+	assert.False(t, makeMessage(t, mockSigner, 42).IsFromBLS())
+
+	signedCid, err := smsg.Cid()
+	require.NoError(t, err)
+	unsigned := smsg.WithoutSignature()
+	assert.Empty(t, unsigned.Signature)
+	unsignedCid, err := unsigned.Cid()
+	require.NoError(t, err)
+
+	assert.True(t, signedCid.Equals(unsignedCid))
+	assert.True(t, smsg.Equals(unsigned))
+}
+
 func makeMessage(t *testing.T, signer MockSigner, nonce uint64) *SignedMessage {
 	newAddr, err := address.NewActorAddress([]byte("receiver"))
 	require.NoError(t, err)
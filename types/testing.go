@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
@@ -46,7 +47,7 @@ func NewMockSigner(kis []KeyInfo) MockSigner {
 	for _, k := range kis {
 		// extract public key
 		pub := k.PublicKey()
-		newAddr, err := address.NewSecp256k1Address(pub)
+		newAddr, err := k.Address()
 		if err != nil {
 			panic(err)
 		}
@@ -86,6 +87,19 @@ func MustGenerateKeyInfo(n int, seed byte) []KeyInfo {
 	return keyinfos
 }
 
+// MustGenerateBLSKeyInfo generates `n` random BLS keyinfos.
+func MustGenerateBLSKeyInfo(n int) []KeyInfo {
+	var keyinfos []KeyInfo
+	for i := 0; i < n; i++ {
+		prv := bls.PrivateKeyGenerate()
+		keyinfos = append(keyinfos, KeyInfo{
+			PrivateKey: prv[:],
+			Curve:      BLS,
+		})
+	}
+	return keyinfos
+}
+
 // SignBytes cryptographically signs `data` using the Address `addr`.
 func (ms MockSigner) SignBytes(data []byte, addr address.Address) (Signature, error) {
 	ki, ok := ms.AddrKeyInfo[addr]
@@ -93,6 +107,10 @@ func (ms MockSigner) SignBytes(data []byte, addr address.Address) (Signature, er
 		panic("unknown address")
 	}
 
+	if ki.Type() == BLS {
+		return wutil.SignBLS(ki.Key(), data)
+	}
+
 	hash := blake2b.Sum256(data)
 	return crypto.Sign(ki.Key(), hash[:])
 }
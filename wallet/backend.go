@@ -1,6 +1,8 @@
 package wallet
 
 import (
+	"time"
+
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -34,3 +36,21 @@ type Importer interface {
 	// into the backend
 	ImportKey(ki *types.KeyInfo) error
 }
+
+// Locker is a specialization of a wallet backend that encrypts its keys,
+// and can only use them while unlocked.
+type Locker interface {
+	// Unlock decrypts the keys with the passphrase, until the backend is
+	// locked again or the timeout passes. A zero timeout never passes.
+	Unlock(passphrase []byte, timeout time.Duration) error
+
+	// Lock prevents the use of the keys until the backend is unlocked.
+	Lock()
+
+	// IsLocked returns true if the backend is locked.
+	IsLocked() bool
+
+	// ReplacePassphrase re-encrypts the keys with newPassphrase, if passphrase is
+	// the current one.
+	ReplacePassphrase(passphrase, newPassphrase []byte) error
+}
@@ -1,45 +1,76 @@
 package wallet
 
 import (
+	"bytes"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	ds "github.com/ipfs/go-datastore"
 	dsq "github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet/hd"
 	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
 
 const (
 	// SECP256K1 is a curve used to computer private keys
 	SECP256K1 = "secp256k1"
+	// BLS is the curve of BLS private keys
+	BLS = "bls"
+)
+
+var (
+	// hdSeedKey is the key of the wallet's HD seed, sealed like the private keys.
+	hdSeedKey = ds.NewKey("hdseed")
+	// hdIndexKey is the key of the index of the next key derived from the HD seed.
+	hdIndexKey = ds.NewKey("hdindex")
 )
 
 // DSBackendType is the reflect type of the DSBackend.
 var DSBackendType = reflect.TypeOf(&DSBackend{})
 
 // DSBackend is a wallet backend implementation for storing addresses in a datastore.
+// The private keys are stored encrypted with a key derived from the wallet's passphrase,
+// and can only be used while the backend is unlocked.
+// Once the backend has an HD seed, new secp256k1 keys are derived from it in order, so
+// that they can all be recovered from the seed's mnemonic.
 type DSBackend struct {
 	lk sync.RWMutex
 
-	// TODO: use a better interface that supports time locks, encryption, etc.
 	ds repo.Datastore
 
 	// TODO: proper cache
 	cache map[address.Address]struct{}
+
+	params *keystoreParams
+
+	// key is the key derived from the passphrase while unlocked, nil while locked.
+	key []byte
+	// lockTimer locks the backend when an unlock times out.
+	lockTimer *time.Timer
+
+	hasSeed bool
+	// nextIndex is the index of the next key derived from the HD seed.
+	nextIndex uint32
 }
 
 var _ Backend = (*DSBackend)(nil)
+var _ Locker = (*DSBackend)(nil)
 
-// NewDSBackend constructs a new backend using the passed in datastore.
-func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
-	result, err := ds.Query(dsq.Query{
+// NewDSBackend constructs a new backend using the passed in datastore. A new wallet
+// is encrypted with an empty passphrase. A wallet with an empty passphrase starts
+// unlocked, as its passphrase protects nothing; others start locked.
+func NewDSBackend(store repo.Datastore) (*DSBackend, error) {
+	result, err := store.Query(dsq.Query{
 		KeysOnly: true,
 	})
 	if err != nil {
@@ -53,6 +84,9 @@ func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
 
 	cache := make(map[address.Address]struct{})
 	for _, el := range list {
+		if k := ds.NewKey(el.Key); k == keystoreKey || k == hdSeedKey || k == hdIndexKey {
+			continue
+		}
 		parsedAddr, err := address.NewFromString(strings.Trim(el.Key, "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", el.Key)
@@ -60,10 +94,197 @@ func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
 		cache[parsedAddr] = struct{}{}
 	}
 
-	return &DSBackend{
-		ds:    ds,
+	backend := &DSBackend{
+		ds:    store,
 		cache: cache,
-	}, nil
+	}
+
+	paramsBytes, err := store.Get(keystoreKey)
+	if err == ds.ErrNotFound {
+		if len(cache) > 0 {
+			return nil, errors.New("wallet keys are not encrypted, migrate the repo with tools/migration/go-filecoin-migrate")
+		}
+		if err := backend.setPassphrase(nil); err != nil {
+			return nil, err
+		}
+		return backend, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read keystore parameters")
+	}
+
+	backend.params = &keystoreParams{}
+	if err := cbor.DecodeInto(paramsBytes, backend.params); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal keystore parameters")
+	}
+
+	indexBytes, err := store.Get(hdIndexKey)
+	if err == nil {
+		backend.hasSeed = true
+		if err := cbor.DecodeInto(indexBytes, &backend.nextIndex); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal HD key index")
+		}
+	} else if err != ds.ErrNotFound {
+		return nil, errors.Wrap(err, "failed to read HD key index")
+	}
+
+	if err := backend.Unlock(nil, 0); err != nil && err != ErrBadPassphrase {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// Unlock unlocks the backend with its passphrase, allowing its keys to be used until it
+// is locked again, or the timeout passes. A zero timeout never passes.
+// Safe for concurrent access.
+func (backend *DSBackend) Unlock(passphrase []byte, timeout time.Duration) error {
+	backend.lk.RLock()
+	params := backend.params
+	backend.lk.RUnlock()
+
+	key, err := params.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	backend.lock()
+	backend.key = key
+	if timeout > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(timeout, func() {
+			backend.lk.Lock()
+			defer backend.lk.Unlock()
+
+			// Don't lock if the backend was locked and unlocked again meanwhile.
+			if backend.lockTimer == timer {
+				backend.lock()
+			}
+		})
+		backend.lockTimer = timer
+	}
+	return nil
+}
+
+// Lock locks the backend, so that its keys can't be used until it is unlocked.
+// Safe for concurrent access.
+func (backend *DSBackend) Lock() {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	backend.lock()
+}
+
+// IsLocked returns true if the backend is locked.
+// Safe for concurrent access.
+func (backend *DSBackend) IsLocked() bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	return backend.key == nil
+}
+
+// ChangePassphrase re-encrypts the keys of the unlocked backend with a new passphrase.
+// Safe for concurrent access.
+func (backend *DSBackend) ChangePassphrase(passphrase []byte) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if backend.key == nil {
+		return ErrWalletLocked
+	}
+	return backend.setPassphrase(passphrase)
+}
+
+// ReplacePassphrase re-encrypts the keys of the backend with newPassphrase, after checking
+// that passphrase is the current one. The backend stays locked, or unlocked, as it was.
+// Safe for concurrent access.
+func (backend *DSBackend) ReplacePassphrase(passphrase, newPassphrase []byte) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	key, err := backend.params.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	wasLocked := backend.key == nil
+	if wasLocked {
+		backend.key = key
+	}
+	err = backend.setPassphrase(newPassphrase)
+	if wasLocked {
+		backend.lock()
+	}
+	return err
+}
+
+// setPassphrase encrypts the backend's keys, and any later ones, with a key derived from the
+// passphrase, decrypting them with the current key if there is one. The caller must hold the
+// write lock, if others might access the backend.
+func (backend *DSBackend) setPassphrase(passphrase []byte) error {
+	params, key, err := newKeystoreParams(passphrase)
+	if err != nil {
+		return err
+	}
+	paramsBytes, err := cbor.DumpObject(params)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal keystore parameters")
+	}
+
+	batch, err := backend.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for addr := range backend.cache {
+		kib, err := backend.getKeyInfoBytes(addr)
+		if err != nil {
+			return err
+		}
+		sealed, err := seal(key, kib)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt key")
+		}
+		if err := batch.Put(ds.NewKey(addr.String()), sealed); err != nil {
+			return err
+		}
+	}
+	if backend.hasSeed {
+		seed, err := backend.getSeed()
+		if err != nil {
+			return err
+		}
+		sealed, err := seal(key, seed)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt HD seed")
+		}
+		if err := batch.Put(hdSeedKey, sealed); err != nil {
+			return err
+		}
+	}
+	if err := batch.Put(keystoreKey, paramsBytes); err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return errors.Wrap(err, "failed to store re-encrypted keys")
+	}
+
+	backend.params = params
+	backend.key = key
+	return nil
+}
+
+// lock forgets the key and stops any lock timer. The caller must hold the write lock.
+func (backend *DSBackend) lock() {
+	if backend.lockTimer != nil {
+		backend.lockTimer.Stop()
+		backend.lockTimer = nil
+	}
+	for i := range backend.key {
+		backend.key[i] = 0
+	}
+	backend.key = nil
 }
 
 // ImportKey loads the address in `ai` and KeyInfo `ki` into the backend
@@ -93,25 +314,198 @@ func (backend *DSBackend) HasAddress(addr address.Address) bool {
 	return ok
 }
 
-// NewAddress creates a new address and stores it.
+// NewAddress creates a new address using the given protocol, SECP256K1 or BLS, and stores it.
 // Safe for concurrent access.
-func (backend *DSBackend) NewAddress() (address.Address, error) {
-	prv, err := crypto.GenerateKey()
+func (backend *DSBackend) NewAddress(protocol address.Protocol) (address.Address, error) {
+	var ki *types.KeyInfo
+	switch protocol {
+	case address.SECP256K1:
+		if backend.HasSeed() {
+			return backend.newHDAddress()
+		}
+
+		prv, err := crypto.GenerateKey()
+		if err != nil {
+			return address.Undef, err
+		}
+
+		// TODO: maybe the above call should just return a keyinfo?
+		ki = &types.KeyInfo{
+			PrivateKey: prv,
+			Curve:      SECP256K1,
+		}
+	case address.BLS:
+		prv := bls.PrivateKeyGenerate()
+		ki = &types.KeyInfo{
+			PrivateKey: prv[:],
+			Curve:      BLS,
+		}
+	default:
+		return address.Undef, errors.Errorf("cannot create addresses of protocol %d", protocol)
+	}
+
+	if err := backend.putKeyInfo(ki); err != nil {
+		return address.Undef, err
+	}
+
+	return ki.Address()
+}
+
+// SetSeed sets the HD seed new secp256k1 keys are derived from. A backend's seed can't be
+// replaced by another, but setting the same seed again does nothing, so that a wallet can
+// be recovered into again. The backend must be unlocked.
+// Safe for concurrent access.
+func (backend *DSBackend) SetSeed(seed []byte) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if backend.key == nil {
+		return ErrWalletLocked
+	}
+	if backend.hasSeed {
+		current, err := backend.getSeed()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(current, seed) {
+			return errors.New("wallet already has an HD seed")
+		}
+		return nil
+	}
+
+	sealed, err := seal(backend.key, seed)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt HD seed")
+	}
+	indexBytes, err := cbor.DumpObject(uint32(0))
+	if err != nil {
+		return err
+	}
+
+	batch, err := backend.ds.Batch()
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(hdSeedKey, sealed); err != nil {
+		return err
+	}
+	if err := batch.Put(hdIndexKey, indexBytes); err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return errors.Wrap(err, "failed to store HD seed")
+	}
+
+	backend.hasSeed = true
+	backend.nextIndex = 0
+	return nil
+}
+
+// HasSeed returns true if the backend derives new secp256k1 keys from an HD seed.
+// Safe for concurrent access.
+func (backend *DSBackend) HasSeed() bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	return backend.hasSeed
+}
+
+// DeriveAddress returns the address of the secp256k1 key derived from the HD seed with the
+// index, without storing the key.
+// Safe for concurrent access.
+func (backend *DSBackend) DeriveAddress(index uint32) (address.Address, error) {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	ki, err := backend.deriveKeyInfo(index)
 	if err != nil {
 		return address.Undef, err
 	}
+	return ki.Address()
+}
 
-	// TODO: maybe the above call should just return a keyinfo?
-	ki := &types.KeyInfo{
-		PrivateKey: prv,
-		Curve:      SECP256K1,
+// newHDAddress stores the next key derived from the HD seed.
+func (backend *DSBackend) newHDAddress() (address.Address, error) {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	ki, err := backend.deriveKeyInfo(backend.nextIndex)
+	if err != nil {
+		return address.Undef, err
+	}
+	addr, err := ki.Address()
+	if err != nil {
+		return address.Undef, err
 	}
 
-	if err := backend.putKeyInfo(ki); err != nil {
+	kib, err := ki.Marshal()
+	if err != nil {
+		return address.Undef, err
+	}
+	sealed, err := seal(backend.key, kib)
+	if err != nil {
+		return address.Undef, errors.Wrap(err, "failed to encrypt key")
+	}
+	indexBytes, err := cbor.DumpObject(backend.nextIndex + 1)
+	if err != nil {
 		return address.Undef, err
 	}
 
-	return ki.Address()
+	batch, err := backend.ds.Batch()
+	if err != nil {
+		return address.Undef, err
+	}
+	if err := batch.Put(ds.NewKey(addr.String()), sealed); err != nil {
+		return address.Undef, err
+	}
+	if err := batch.Put(hdIndexKey, indexBytes); err != nil {
+		return address.Undef, err
+	}
+	if err := batch.Commit(); err != nil {
+		return address.Undef, errors.Wrap(err, "failed to store new address")
+	}
+
+	backend.cache[addr] = struct{}{}
+	backend.nextIndex++
+	return addr, nil
+}
+
+// deriveKeyInfo returns the secp256k1 key derived from the HD seed with the index. The
+// caller must hold the lock.
+func (backend *DSBackend) deriveKeyInfo(index uint32) (*types.KeyInfo, error) {
+	if !backend.hasSeed {
+		return nil, errors.New("wallet has no HD seed")
+	}
+	seed, err := backend.getSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := hd.DerivePath(seed, hd.FilecoinPath(index))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to derive key %d", index)
+	}
+	return &types.KeyInfo{
+		PrivateKey: k.Key,
+		Curve:      SECP256K1,
+	}, nil
+}
+
+// getSeed returns the HD seed, decrypted with the current key. The caller must hold the lock.
+func (backend *DSBackend) getSeed() ([]byte, error) {
+	if backend.key == nil {
+		return nil, ErrWalletLocked
+	}
+
+	sealed, err := backend.ds.Get(hdSeedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch HD seed")
+	}
+	seed, err := unseal(backend.key, sealed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt HD seed")
+	}
+	return seed, nil
 }
 
 func (backend *DSBackend) putKeyInfo(ki *types.KeyInfo) error {
@@ -123,12 +517,21 @@ func (backend *DSBackend) putKeyInfo(ki *types.KeyInfo) error {
 	backend.lk.Lock()
 	defer backend.lk.Unlock()
 
+	if backend.key == nil {
+		return ErrWalletLocked
+	}
+
 	kib, err := ki.Marshal()
 	if err != nil {
 		return err
 	}
 
-	if err := backend.ds.Put(ds.NewKey(a.String()), kib); err != nil {
+	sealed, err := seal(backend.key, kib)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt key")
+	}
+
+	if err := backend.ds.Put(ds.NewKey(a.String()), sealed); err != nil {
 		return errors.Wrap(err, "failed to store new address")
 	}
 
@@ -143,26 +546,31 @@ func (backend *DSBackend) SignBytes(data []byte, addr address.Address) (types.Si
 		return nil, err
 	}
 
+	if ki.Type() == BLS {
+		return wutil.SignBLS(ki.Key(), data)
+	}
 	return wutil.Sign(ki.Key(), data)
 }
 
 // Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key `pk`.
 func (backend *DSBackend) Verify(data, pk []byte, sig types.Signature) bool {
+	if len(pk) == bls.PublicKeyBytes {
+		return wutil.VerifyBLS(pk, data, sig)
+	}
 	return crypto.Verify(pk, data, sig)
 }
 
 // GetKeyInfo will return the private & public keys associated with address `addr`
-// iff backend contains the addr.
+// iff backend contains the addr. It fails with ErrWalletLocked while the backend is
+// locked.
 func (backend *DSBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, error) {
-	if !backend.HasAddress(addr) {
-		return nil, errors.New("backend does not contain address")
-	}
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
 
-	// kib is a cbor of types.KeyInfo
-	kib, err := backend.ds.Get(ds.NewKey(addr.String()))
+	kib, err := backend.getKeyInfoBytes(addr)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to fetch private key from backend")
+		return nil, err
 	}
 
 	ki := &types.KeyInfo{}
@@ -172,3 +580,25 @@ func (backend *DSBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, erro
 
 	return ki, nil
 }
+
+// getKeyInfoBytes returns the cbor of the types.KeyInfo of the address, decrypted with the
+// current key. The caller must hold the lock.
+func (backend *DSBackend) getKeyInfoBytes(addr address.Address) ([]byte, error) {
+	if _, ok := backend.cache[addr]; !ok {
+		return nil, errors.New("backend does not contain address")
+	}
+	if backend.key == nil {
+		return nil, ErrWalletLocked
+	}
+
+	sealed, err := backend.ds.Get(ds.NewKey(addr.String()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch private key from backend")
+	}
+
+	kib, err := unseal(backend.key, sealed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt private key")
+	}
+	return kib, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 )
 
@@ -26,7 +27,7 @@ func TestDSBackendSimple(t *testing.T) {
 	assert.Len(t, fs.Addresses(), 0)
 
 	t.Log("can create new address")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("address is stored")
@@ -51,7 +52,7 @@ func TestDSBackendKeyPairMatchAddress(t *testing.T) {
 	assert.NoError(t, err)
 
 	t.Log("can create new address")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("address is stored")
@@ -68,6 +69,49 @@ func TestDSBackendKeyPairMatchAddress(t *testing.T) {
 	assert.Equal(t, addr, dAddr)
 }
 
+func TestDSBackendBLSKeyPairMatchAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	defer func() {
+		require.NoError(t, ds.Close())
+	}()
+
+	fs, err := NewDSBackend(ds)
+	assert.NoError(t, err)
+
+	t.Log("can create new BLS address")
+	addr, err := fs.NewAddress(address.BLS)
+	assert.NoError(t, err)
+	assert.Equal(t, address.BLS, addr.Protocol())
+
+	t.Log("address references to a BLS secret key")
+	ki, err := fs.GetKeyInfo(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, BLS, ki.Type())
+
+	dAddr, err := ki.Address()
+	assert.NoError(t, err)
+
+	t.Log("generated address and stored address should match")
+	assert.Equal(t, addr, dAddr)
+
+	t.Log("address is restored with a new backend")
+	fs2, err := NewDSBackend(ds)
+	assert.NoError(t, err)
+	assert.True(t, fs2.HasAddress(addr))
+}
+
+func TestDSBackendRejectsUnknownProtocol(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, err := NewDSBackend(datastore.NewMapDatastore())
+	assert.NoError(t, err)
+
+	_, err = fs.NewAddress(address.Actor)
+	assert.Error(t, err)
+}
+
 func TestDSBackendErrorsForUnknownAddress(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -87,7 +131,7 @@ func TestDSBackendErrorsForUnknownAddress(t *testing.T) {
 	assert.NoError(t, err)
 
 	t.Log("can create new address in fs1")
-	addr, err := fs1.NewAddress()
+	addr, err := fs1.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("address is stored fs1")
@@ -123,7 +167,7 @@ func TestDSBackendParallel(t *testing.T) {
 	wg.Add(count)
 	for i := 0; i < count; i++ {
 		go func() {
-			_, err := fs.NewAddress()
+			_, err := fs.NewAddress(address.SECP256K1)
 			assert.NoError(t, err)
 			wg.Done()
 		}()
@@ -132,3 +176,75 @@ func TestDSBackendParallel(t *testing.T) {
 	wg.Wait()
 	assert.Len(t, fs.Addresses(), 10)
 }
+
+func TestDSBackendHD(t *testing.T) {
+	tf.UnitTest(t)
+
+	seed := []byte("a seed of the wallet, usually the seed of a mnemonic")
+
+	newBackend := func() (*DSBackend, *datastore.MapDatastore) {
+		ds := datastore.NewMapDatastore()
+		fs, err := NewDSBackend(ds)
+		require.NoError(t, err)
+		return fs, ds
+	}
+
+	t.Log("derived addresses depend only on the seed")
+	fs1, ds1 := newBackend()
+	defer func() {
+		require.NoError(t, ds1.Close())
+	}()
+	fs2, ds2 := newBackend()
+	defer func() {
+		require.NoError(t, ds2.Close())
+	}()
+
+	assert.False(t, fs1.HasSeed())
+	_, err := fs1.DeriveAddress(0)
+	assert.Error(t, err)
+
+	require.NoError(t, fs1.SetSeed(seed))
+	require.NoError(t, fs2.SetSeed(seed))
+	assert.True(t, fs1.HasSeed())
+	assert.Error(t, fs1.SetSeed([]byte("another seed")))
+	// setting the same seed again does nothing
+	require.NoError(t, fs1.SetSeed(seed))
+
+	var addrs []address.Address
+	for i := 0; i < 3; i++ {
+		addr1, err := fs1.NewAddress(address.SECP256K1)
+		require.NoError(t, err)
+		addr2, err := fs2.NewAddress(address.SECP256K1)
+		require.NoError(t, err)
+		assert.Equal(t, addr1, addr2)
+
+		derived, err := fs1.DeriveAddress(uint32(i))
+		require.NoError(t, err)
+		assert.Equal(t, addr1, derived)
+
+		addrs = append(addrs, addr1)
+	}
+	assert.Len(t, fs1.Addresses(), 3)
+
+	t.Log("BLS keys are not derived")
+	blsAddr, err := fs1.NewAddress(address.BLS)
+	require.NoError(t, err)
+	assert.NotEqual(t, addrs[0], blsAddr)
+
+	t.Log("the seed and index are kept across passphrase changes and restarts")
+	require.NoError(t, fs1.ChangePassphrase([]byte("passphrase")))
+	fs3, err := NewDSBackend(ds1)
+	require.NoError(t, err)
+	assert.True(t, fs3.HasSeed())
+	assert.Len(t, fs3.Addresses(), 4)
+
+	_, err = fs3.NewAddress(address.SECP256K1)
+	assert.Equal(t, ErrWalletLocked, err)
+	require.NoError(t, fs3.Unlock([]byte("passphrase"), 0))
+
+	next, err := fs3.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	derived, err := fs3.DeriveAddress(3)
+	require.NoError(t, err)
+	assert.Equal(t, derived, next)
+}
@@ -0,0 +1,167 @@
+// Package hd derives wallet keys deterministically from a BIP-39 mnemonic, following
+// BIP-32 along a BIP-44 path with Filecoin's coin type, so that one backed-up phrase
+// recovers all the keys derived from it.
+package hd
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+	"strconv"
+	"strings"
+
+	secp256k1 "github.com/ipsn/go-secp256k1"
+	"github.com/pkg/errors"
+	bip39 "github.com/tyler-smith/go-bip39"
+
+	"github.com/filecoin-project/go-filecoin/crypto"
+)
+
+// HardenedOffset is added to the index of a hardened child key.
+const HardenedOffset uint32 = 0x80000000
+
+// mnemonicEntropyBits is the entropy of new mnemonics, giving 24 words.
+const mnemonicEntropyBits = 256
+
+var masterKeySecret = []byte("Bitcoin seed")
+
+// NewMnemonic returns a new random BIP-39 mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate entropy")
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// SeedFromMnemonic returns the seed of the BIP-39 mnemonic, without a BIP-39 passphrase.
+// It fails if the mnemonic's checksum is wrong.
+func SeedFromMnemonic(mnemonic string) ([]byte, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(mnemonic), " "), "")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mnemonic")
+	}
+	return seed, nil
+}
+
+// FilecoinKeysPath is the derivation path of the parent of the wallet's secp256k1 keys,
+// with 461, Filecoin's coin type registered in SLIP-44.
+const FilecoinKeysPath = "m/44'/461'/0'/0"
+
+var filecoinKeysPath = mustParsePath(FilecoinKeysPath)
+
+// FilecoinPath returns the path of the index'th secp256k1 key of the wallet,
+// FilecoinKeysPath/index.
+func FilecoinPath(index uint32) []uint32 {
+	return append(append([]uint32{}, filecoinKeysPath...), index)
+}
+
+// ParsePath parses a derivation path such as m/44'/461'/0'/0/0.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, errors.Errorf("invalid derivation path %q: must start with m", path)
+	}
+
+	var out []uint32
+	for _, p := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(p, "'") {
+			offset = HardenedOffset
+			p = p[:len(p)-1]
+		}
+		i, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || uint32(i) >= HardenedOffset {
+			return nil, errors.Errorf("invalid derivation path %q: bad index %q", path, p)
+		}
+		out = append(out, uint32(i)+offset)
+	}
+	return out, nil
+}
+
+func mustParsePath(path string) []uint32 {
+	out, err := ParsePath(path)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// ExtendedKey is a BIP-32 extended secp256k1 private key.
+type ExtendedKey struct {
+	Key       []byte
+	ChainCode []byte
+}
+
+// NewMasterKey returns the master key of the seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, masterKeySecret)
+	mac.Write(seed) // nolint: errcheck
+	sum := mac.Sum(nil)
+
+	if !validKey(sum[:32]) {
+		return nil, errors.New("seed gives an invalid master key")
+	}
+	return &ExtendedKey{Key: sum[:32], ChainCode: sum[32:]}, nil
+}
+
+// DerivePath returns the key at the path from the master key of the seed.
+func DerivePath(seed []byte, path []uint32) (*ExtendedKey, error) {
+	k, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range path {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Child returns the child key of k with the index, a hardened key if the index is at
+// least HardenedOffset.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0}, k.Key...)
+	} else {
+		data = compressedPublicKey(k.Key)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data) // nolint: errcheck
+	sum := mac.Sum(nil)
+
+	if !validKey(sum[:32]) {
+		return nil, errors.Errorf("invalid child key %d, use the next index", index)
+	}
+	n := secp256k1.S256().Params().N
+	child := new(big.Int).SetBytes(sum[:32])
+	child.Add(child, new(big.Int).SetBytes(k.Key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, errors.Errorf("invalid child key %d, use the next index", index)
+	}
+
+	key := make([]byte, crypto.PrivateKeyBytes)
+	b := child.Bytes()
+	copy(key[len(key)-len(b):], b)
+	return &ExtendedKey{Key: key, ChainCode: sum[32:]}, nil
+}
+
+// validKey returns true if the bytes are a valid secp256k1 private key.
+func validKey(b []byte) bool {
+	k := new(big.Int).SetBytes(b)
+	return k.Sign() > 0 && k.Cmp(secp256k1.S256().Params().N) < 0
+}
+
+// compressedPublicKey returns the public key of the private key in compressed form.
+func compressedPublicKey(sk []byte) []byte {
+	pk := crypto.PublicKey(sk)
+	x, y := pk[1:33], pk[33:]
+	return append([]byte{2 + y[31]&1}, x...)
+}
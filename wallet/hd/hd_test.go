@@ -0,0 +1,90 @@
+package hd_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/wallet/hd"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestDerivePath(t *testing.T) {
+	tf.UnitTest(t)
+
+	// test vector 1 of BIP-32
+	seed := mustDecodeHex(t, "000102030405060708090a0b0c0d0e0f")
+
+	for _, tc := range []struct {
+		path      string
+		key       string
+		chainCode string
+	}{
+		{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508"},
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19"},
+	} {
+		path, err := hd.ParsePath(tc.path)
+		require.NoError(t, err)
+
+		k, err := hd.DerivePath(seed, path)
+		require.NoError(t, err)
+		assert.Equal(t, tc.key, hex.EncodeToString(k.Key), tc.path)
+		assert.Equal(t, tc.chainCode, hex.EncodeToString(k.ChainCode), tc.path)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tf.UnitTest(t)
+
+	path, err := hd.ParsePath("m/44'/461'/0'/0/7")
+	require.NoError(t, err)
+	assert.Equal(t, hd.FilecoinPath(7), path)
+
+	for _, bad := range []string{"", "44'/461'", "m/", "m/x", "m/-1", "m/2147483648"} {
+		_, err := hd.ParsePath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMnemonic(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("seed of a mnemonic", func(t *testing.T) {
+		seed, err := hd.SeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about")
+		require.NoError(t, err)
+		assert.Equal(t, "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4", hex.EncodeToString(seed))
+
+		// extra whitespace is ignored
+		seed2, err := hd.SeedFromMnemonic(" abandon abandon abandon abandon abandon abandon\nabandon abandon abandon abandon abandon  about\n")
+		require.NoError(t, err)
+		assert.Equal(t, seed, seed2)
+	})
+
+	t.Run("bad checksum", func(t *testing.T) {
+		_, err := hd.SeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+		assert.Error(t, err)
+	})
+
+	t.Run("new mnemonics", func(t *testing.T) {
+		m1, err := hd.NewMnemonic()
+		require.NoError(t, err)
+		m2, err := hd.NewMnemonic()
+		require.NoError(t, err)
+
+		assert.Len(t, strings.Fields(m1), 24)
+		assert.NotEqual(t, m1, m2)
+
+		_, err = hd.SeedFromMnemonic(m1)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	ds "github.com/ipfs/go-datastore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	// ErrWalletLocked is returned when using the private keys of a locked wallet.
+	ErrWalletLocked = errors.New("wallet is locked")
+	// ErrBadPassphrase is returned when unlocking a wallet with the wrong passphrase.
+	ErrBadPassphrase = errors.New("incorrect wallet passphrase")
+)
+
+// keystoreKey is the key of the keystoreParams in the wallet datastore. It can't be
+// mistaken for the key of an address.
+var keystoreKey = ds.NewKey("keystore")
+
+// keystoreCheck is sealed into the keystoreParams to check passphrases, so that a
+// wallet without keys can be unlocked too.
+var keystoreCheck = []byte("filecoin wallet keystore")
+
+// scrypt parameters deriving the encryption key from the passphrase.
+const (
+	scryptN   = 1 << 15
+	scryptR   = 8
+	scryptP   = 1
+	keyLength = 32
+	saltBytes = 32
+)
+
+func init() {
+	cbor.RegisterCborType(keystoreParams{})
+}
+
+// keystoreParams are the parameters of the encryption of a wallet's keys, which are
+// sealed with AES-GCM with a key derived from the wallet's passphrase with scrypt.
+type keystoreParams struct {
+	Salt  []byte
+	Check []byte
+}
+
+// newKeystoreParams returns new parameters for the passphrase, with the key derived
+// from it.
+func newKeystoreParams(passphrase []byte) (*keystoreParams, []byte, error) {
+	salt := make([]byte, saltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate salt")
+	}
+
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to derive key")
+	}
+
+	check, err := seal(key, keystoreCheck)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &keystoreParams{Salt: salt, Check: check}, key, nil
+}
+
+// deriveKey returns the key derived from the passphrase, or ErrBadPassphrase if
+// it is not the passphrase the parameters were created with.
+func (p *keystoreParams) deriveKey(passphrase []byte) ([]byte, error) {
+	key, err := scrypt.Key(passphrase, p.Salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive key")
+	}
+
+	check, err := unseal(key, p.Check)
+	if err != nil || !bytes.Equal(check, keystoreCheck) {
+		return nil, ErrBadPassphrase
+	}
+	return key, nil
+}
+
+// seal encrypts and authenticates plaintext with the key, prefixing the result
+// with the random nonce used.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// unseal decrypts data sealed with the key.
+func unseal(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid key")
+	}
+	return cipher.NewGCM(block)
+}
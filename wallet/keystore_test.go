@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestDSBackendLockUnlock(t *testing.T) {
+	tf.UnitTest(t)
+
+	passphrase := []byte("correct horse battery staple")
+
+	ds := datastore.NewMapDatastore()
+	defer func() {
+		require.NoError(t, ds.Close())
+	}()
+
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	t.Log("a new wallet starts unlocked")
+	assert.False(t, fs.IsLocked())
+	addr, err := fs.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	ki, err := fs.GetKeyInfo(addr)
+	require.NoError(t, err)
+
+	t.Log("keys are not stored in plaintext")
+	kib, err := ki.Marshal()
+	require.NoError(t, err)
+	stored, err := ds.Get(datastore.NewKey(addr.String()))
+	require.NoError(t, err)
+	assert.NotEqual(t, kib, stored)
+
+	require.NoError(t, fs.ChangePassphrase(passphrase))
+
+	t.Log("a wallet with a passphrase starts locked")
+	fs2, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	assert.True(t, fs2.IsLocked())
+	assert.True(t, fs2.HasAddress(addr))
+
+	_, err = fs2.GetKeyInfo(addr)
+	assert.Equal(t, ErrWalletLocked, err)
+	_, err = fs2.SignBytes([]byte("data"), addr)
+	assert.Equal(t, ErrWalletLocked, err)
+	_, err = fs2.NewAddress(address.SECP256K1)
+	assert.Equal(t, ErrWalletLocked, err)
+	assert.Equal(t, ErrWalletLocked, fs2.ChangePassphrase(nil))
+
+	t.Log("unlocking fails with the wrong passphrase")
+	assert.Equal(t, ErrBadPassphrase, fs2.Unlock([]byte("wrong"), 0))
+	assert.True(t, fs2.IsLocked())
+
+	t.Log("keys can be used once unlocked")
+	require.NoError(t, fs2.Unlock(passphrase, 0))
+	assert.False(t, fs2.IsLocked())
+	ki2, err := fs2.GetKeyInfo(addr)
+	require.NoError(t, err)
+	assert.True(t, ki.Equals(ki2))
+
+	t.Log("and not once locked again")
+	fs2.Lock()
+	assert.True(t, fs2.IsLocked())
+	_, err = fs2.GetKeyInfo(addr)
+	assert.Equal(t, ErrWalletLocked, err)
+}
+
+func TestDSBackendUnlockTimeout(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	defer func() {
+		require.NoError(t, ds.Close())
+	}()
+
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	require.NoError(t, fs.ChangePassphrase([]byte("passphrase")))
+
+	require.NoError(t, fs.Unlock([]byte("passphrase"), 50*time.Millisecond))
+	assert.False(t, fs.IsLocked())
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, fs.IsLocked())
+
+	t.Log("unlocking again without a timeout cancels an earlier timeout")
+	require.NoError(t, fs.Unlock([]byte("passphrase"), 50*time.Millisecond))
+	require.NoError(t, fs.Unlock([]byte("passphrase"), 0))
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, fs.IsLocked())
+}
+
+func TestDSBackendRejectsPlaintextKeys(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	defer func() {
+		require.NoError(t, ds.Close())
+	}()
+
+	ki := types.MustGenerateKeyInfo(1, 42)[0]
+	addr, err := ki.Address()
+	require.NoError(t, err)
+	kib, err := ki.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, ds.Put(datastore.NewKey(addr.String()), kib))
+
+	_, err = NewDSBackend(ds)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not encrypted")
+}
+
+func TestDSBackendReplacePassphrase(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	defer func() {
+		require.NoError(t, ds.Close())
+	}()
+
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	addr, err := fs.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	require.NoError(t, fs.ChangePassphrase([]byte("old")))
+	fs.Lock()
+
+	t.Log("fails with the wrong passphrase")
+	assert.Equal(t, ErrBadPassphrase, fs.ReplacePassphrase([]byte("wrong"), []byte("new")))
+
+	t.Log("a locked backend stays locked")
+	require.NoError(t, fs.ReplacePassphrase([]byte("old"), []byte("new")))
+	assert.True(t, fs.IsLocked())
+	assert.Equal(t, ErrBadPassphrase, fs.Unlock([]byte("old"), 0))
+
+	t.Log("an unlocked backend stays unlocked")
+	require.NoError(t, fs.Unlock([]byte("new"), 0))
+	require.NoError(t, fs.ReplacePassphrase([]byte("new"), []byte("newer")))
+	assert.False(t, fs.IsLocked())
+	_, err = fs.GetKeyInfo(addr)
+	require.NoError(t, err)
+
+	t.Log("the keys are encrypted with the new passphrase")
+	fs2, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	require.NoError(t, fs2.Unlock([]byte("newer"), 0))
+	_, err = fs2.GetKeyInfo(addr)
+	require.NoError(t, err)
+}
@@ -0,0 +1,252 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
+	"github.com/filecoin-project/go-filecoin/crypto"
+	"github.com/filecoin-project/go-filecoin/types"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+var log = logging.Logger("wallet")
+
+// RemoteBackendType is the reflect type of the RemoteBackend.
+var RemoteBackendType = reflect.TypeOf(&RemoteBackend{})
+
+const (
+	// remoteTimeout bounds requests to a remote signer, which may wait for an operator or an HSM.
+	remoteTimeout = time.Minute
+	// listTimeout bounds requests for the addresses of a remote signer, which shouldn't wait.
+	listTimeout = 5 * time.Second
+	// addressesTTL is how long the addresses a remote signer listed are used before they are
+	// listed again, whether or not that succeeded.
+	addressesTTL = 30 * time.Second
+)
+
+// RemoteBackend is a wallet backend whose keys are kept by an external signer process,
+// reached over HTTP on a Unix socket or a TCP address. The node never sees the private
+// keys: GetKeyInfo fails for the backend's addresses. The signer's addresses are listed at
+// most every addressesTTL, so keys added to it are used after up to that long.
+//
+// The signer serves JSON over HTTP:
+//
+//	GET /addresses
+//	  200 {"addresses": ["<address>", ...]}
+//	POST /sign {"address": "<address>", "data": "<base64>"}
+//	  200 {"signature": "<base64>"}
+//
+// Any other status is an error, described by the plain text body. The signature is of the
+// data itself, as SignBytes makes it. If the endpoint has a user, as in
+// http://<token>@host:port, it is sent to the signer as a bearer token in the
+// Authorization header of every request. tools/remote-signer is a reference signer.
+type RemoteBackend struct {
+	lk sync.Mutex
+	// refreshLk serializes listing the addresses, so that concurrent callers wait for one
+	// request rather than each making their own.
+	refreshLk sync.Mutex
+
+	// endpoint is the endpoint without its token, for messages.
+	endpoint string
+	baseURL  string
+	token    string
+	client   *http.Client
+
+	// addrs are the addresses the signer last listed, at refreshed.
+	addrs     []address.Address
+	refreshed time.Time
+}
+
+var _ Backend = (*RemoteBackend)(nil)
+
+// RemoteAddressesResponse is the body of the response of a remote signer to GET /addresses.
+type RemoteAddressesResponse struct {
+	Addresses []address.Address `json:"addresses"`
+}
+
+// RemoteSignRequest is the body of a request to a remote signer to POST /sign.
+type RemoteSignRequest struct {
+	Address address.Address `json:"address"`
+	Data    []byte          `json:"data"`
+}
+
+// RemoteSignResponse is the body of the response of a remote signer to POST /sign.
+type RemoteSignResponse struct {
+	Signature types.Signature `json:"signature"`
+}
+
+// NewRemoteBackend constructs a backend forwarding to the signer at the endpoint, either
+// unix:///path/to/socket or an http or https URL, optionally with a token as its user. The
+// signer is first contacted when the backend is used.
+func NewRemoteBackend(endpoint string) (*RemoteBackend, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		// The error quotes the endpoint, which may hold a token.
+		return nil, errors.New("invalid remote signer endpoint")
+	}
+
+	backend := &RemoteBackend{
+		client: &http.Client{Timeout: remoteTimeout},
+	}
+	if u.User != nil {
+		backend.token = u.User.Username()
+		u.User = nil
+	}
+	endpoint = u.String()
+	backend.endpoint = endpoint
+
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return nil, errors.Errorf("invalid remote signer endpoint %s: missing socket path", endpoint)
+		}
+		backend.baseURL = "http://unix"
+		backend.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", u.Path)
+			},
+		}
+	case "http", "https":
+		backend.baseURL = strings.TrimRight(endpoint, "/")
+	default:
+		return nil, errors.Errorf("invalid remote signer endpoint %s: scheme must be unix, http or https", endpoint)
+	}
+	return backend, nil
+}
+
+// Addresses returns the addresses of the keys the signer holds, as it last listed them. If
+// the signer can't be reached, it returns those it listed before.
+// Safe for concurrent access.
+func (backend *RemoteBackend) Addresses() []address.Address {
+	backend.refresh()
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	cpy := make([]address.Address, len(backend.addrs))
+	copy(cpy, backend.addrs)
+	return cpy
+}
+
+// HasAddress checks if the signer holds the key of the address, as it last listed them.
+// Safe for concurrent access.
+func (backend *RemoteBackend) HasAddress(addr address.Address) bool {
+	backend.refresh()
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	for _, a := range backend.addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh lists the addresses of the signer again if they are older than addressesTTL.
+func (backend *RemoteBackend) refresh() {
+	backend.refreshLk.Lock()
+	defer backend.refreshLk.Unlock()
+
+	backend.lk.Lock()
+	fresh := !backend.refreshed.IsZero() && time.Since(backend.refreshed) < addressesTTL
+	backend.lk.Unlock()
+	if fresh {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+	var res RemoteAddressesResponse
+	err := backend.call(ctx, http.MethodGet, "/addresses", nil, &res)
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	// An unreachable signer is only retried after addressesTTL too, so that it doesn't hold
+	// up every lookup.
+	backend.refreshed = time.Now()
+	if err != nil {
+		log.Warningf("failed to list addresses of remote signer %s: %s", backend.endpoint, err)
+		return
+	}
+	backend.addrs = res.Addresses
+}
+
+// SignBytes has the signer sign `data` with the key of the address, and checks the signature.
+// Safe for concurrent access.
+func (backend *RemoteBackend) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+	var res RemoteSignResponse
+	if err := backend.call(context.Background(), http.MethodPost, "/sign", &RemoteSignRequest{Address: addr, Data: data}, &res); err != nil {
+		return nil, errors.Wrapf(err, "remote signer %s failed to sign", backend.endpoint)
+	}
+
+	if !types.IsValidSignature(data, addr, res.Signature) {
+		return nil, errors.Errorf("remote signer %s returned an invalid signature for %s", backend.endpoint, addr)
+	}
+	return res.Signature, nil
+}
+
+// Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
+// the public key `pk`.
+func (backend *RemoteBackend) Verify(data, pk []byte, sig types.Signature) bool {
+	if len(pk) == bls.PublicKeyBytes {
+		return wutil.VerifyBLS(pk, data, sig)
+	}
+	return crypto.Verify(pk, data, sig)
+}
+
+// GetKeyInfo fails, as the private keys never leave the signer.
+func (backend *RemoteBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, error) {
+	return nil, errors.Errorf("the key of %s is held by remote signer %s and can't be read", addr, backend.endpoint)
+}
+
+// call sends a request to the signer with the JSON of in as body, if not nil, and decodes
+// the JSON response into out.
+func (backend *RemoteBackend) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, backend.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if backend.token != "" {
+		req.Header.Set("Authorization", "Bearer "+backend.token)
+	}
+
+	resp, err := backend.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,241 @@
+package wallet
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// stubSigner serves the remote signer protocol for the keys of a backend, optionally
+// tampering with the signatures or requiring a bearer token.
+type stubSigner struct {
+	backend *DSBackend
+	tamper  bool
+	token   string
+	// listed counts the requests for the addresses.
+	listed int32
+}
+
+func (s *stubSigner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/addresses":
+		atomic.AddInt32(&s.listed, 1)
+		json.NewEncoder(w).Encode(&RemoteAddressesResponse{Addresses: s.backend.Addresses()}) // nolint: errcheck
+	case "/sign":
+		var req RemoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := s.backend.SignBytes(req.Data, req.Address)
+		if err != nil {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		if s.tamper {
+			sig[0]++
+		}
+		json.NewEncoder(w).Encode(&RemoteSignResponse{Signature: sig}) // nolint: errcheck
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newStubSigner(t *testing.T, protocols ...address.Protocol) (*stubSigner, []address.Address) {
+	backend, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+
+	var addrs []address.Address
+	for _, p := range protocols {
+		addr, err := backend.NewAddress(p)
+		require.NoError(t, err)
+		addrs = append(addrs, addr)
+	}
+	return &stubSigner{backend: backend}, addrs
+}
+
+func TestNewRemoteBackend(t *testing.T) {
+	tf.UnitTest(t)
+
+	for _, endpoint := range []string{"unix:///tmp/signer.sock", "http://127.0.0.1:5678", "https://signer.example.com/", "http://token@127.0.0.1:5678"} {
+		_, err := NewRemoteBackend(endpoint)
+		assert.NoError(t, err, endpoint)
+	}
+	for _, endpoint := range []string{"/tmp/signer.sock", "unix://", "ftp://signer.example.com", "%"} {
+		_, err := NewRemoteBackend(endpoint)
+		assert.Error(t, err, endpoint)
+	}
+}
+
+func TestRemoteBackend(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer, addrs := newStubSigner(t, address.SECP256K1, address.BLS)
+	server := httptest.NewServer(signer)
+	defer server.Close()
+
+	backend, err := NewRemoteBackend(server.URL)
+	require.NoError(t, err)
+
+	data := []byte("data to sign")
+
+	t.Run("lists the addresses of the signer", func(t *testing.T) {
+		assert.ElementsMatch(t, addrs, backend.Addresses())
+		assert.True(t, backend.HasAddress(addrs[0]))
+		assert.False(t, backend.HasAddress(address.NewForTestGetter()()))
+	})
+
+	t.Run("lists the addresses again only after they expire", func(t *testing.T) {
+		listed := atomic.LoadInt32(&signer.listed)
+		backend.Addresses()
+		backend.HasAddress(address.NewForTestGetter()())
+		assert.Equal(t, listed, atomic.LoadInt32(&signer.listed))
+
+		backend.lk.Lock()
+		backend.refreshed = backend.refreshed.Add(-addressesTTL)
+		backend.lk.Unlock()
+		backend.HasAddress(addrs[0])
+		assert.Equal(t, listed+1, atomic.LoadInt32(&signer.listed))
+	})
+
+	t.Run("signs with the keys of the signer", func(t *testing.T) {
+		for _, addr := range addrs {
+			sig, err := backend.SignBytes(data, addr)
+			require.NoError(t, err)
+			assert.True(t, types.IsValidSignature(data, addr, sig))
+		}
+
+		_, err := backend.SignBytes(data, address.NewForTestGetter()())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no such key")
+	})
+
+	t.Run("rejects invalid signatures", func(t *testing.T) {
+		tampering, addrs := newStubSigner(t, address.SECP256K1)
+		tampering.tamper = true
+		server := httptest.NewServer(tampering)
+		defer server.Close()
+
+		backend, err := NewRemoteBackend(server.URL)
+		require.NoError(t, err)
+
+		_, err = backend.SignBytes(data, addrs[0])
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid signature")
+	})
+
+	t.Run("never reveals the keys", func(t *testing.T) {
+		_, err := backend.GetKeyInfo(addrs[0])
+		assert.Error(t, err)
+	})
+
+	t.Run("sends the token of the endpoint", func(t *testing.T) {
+		guarded, addrs := newStubSigner(t, address.SECP256K1)
+		guarded.token = "secret"
+		server := httptest.NewServer(guarded)
+		defer server.Close()
+
+		unauthorized, err := NewRemoteBackend(server.URL)
+		require.NoError(t, err)
+		assert.Empty(t, unauthorized.Addresses())
+
+		u, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		u.User = url.User("secret")
+		authorized, err := NewRemoteBackend(u.String())
+		require.NoError(t, err)
+		assert.Equal(t, addrs, authorized.Addresses())
+		assert.NotContains(t, authorized.endpoint, "secret")
+
+		_, err = authorized.SignBytes(data, addrs[0])
+		assert.NoError(t, err)
+	})
+
+	t.Run("keeps the last addresses while the signer is unreachable", func(t *testing.T) {
+		server.Close()
+
+		backend.lk.Lock()
+		backend.refreshed = backend.refreshed.Add(-addressesTTL)
+		backend.lk.Unlock()
+		assert.ElementsMatch(t, addrs, backend.Addresses())
+		_, err := backend.SignBytes(data, addrs[0])
+		assert.Error(t, err)
+	})
+}
+
+func TestRemoteBackendUnixSocket(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "remote-signer")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	socket := filepath.Join(dir, "signer.sock")
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	signer, addrs := newStubSigner(t, address.SECP256K1)
+	server := &http.Server{Handler: signer}
+	go server.Serve(ln) // nolint: errcheck
+	defer func() {
+		require.NoError(t, server.Close())
+	}()
+
+	backend, err := NewRemoteBackend("unix://" + socket)
+	require.NoError(t, err)
+
+	t.Log("a wallet signs with the remote keys")
+	w := New(backend)
+	assert.Equal(t, addrs, w.Addresses())
+
+	sig, err := w.SignBytes([]byte("data"), addrs[0])
+	require.NoError(t, err)
+	assert.True(t, types.IsValidSignature([]byte("data"), addrs[0], sig))
+}
+
+func TestWalletFindsLocalAddressesFirst(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer, remoteAddrs := newStubSigner(t, address.SECP256K1)
+	server := httptest.NewServer(signer)
+	defer server.Close()
+
+	remote, err := NewRemoteBackend(server.URL)
+	require.NoError(t, err)
+	local, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	localAddr, err := local.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+
+	w := New(remote, local)
+
+	backend, err := w.Find(localAddr)
+	require.NoError(t, err)
+	assert.Equal(t, local, backend)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&signer.listed))
+
+	backend, err = w.Find(remoteAddrs[0])
+	require.NoError(t, err)
+	assert.Equal(t, remote, backend)
+}
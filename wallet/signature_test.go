@@ -28,7 +28,7 @@ func requireSignerAddr(t *testing.T) (*DSBackend, address.Address) {
 	fs, err := NewDSBackend(ds)
 	require.NoError(t, err)
 
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	require.NoError(t, err)
 	return fs, addr
 }
@@ -82,7 +82,7 @@ func TestInvalidAddress(t *testing.T) {
 	sig, err := fs.SignBytes(data, addr)
 	require.NoError(t, err)
 
-	badAddr, err := fs.NewAddress()
+	badAddr, err := fs.NewAddress(address.SECP256K1)
 	require.NoError(t, err)
 
 	assert.False(t, types.IsValidSignature(data, badAddr, sig))
@@ -122,7 +122,7 @@ func TestBadFrom(t *testing.T) {
 	tf.UnitTest(t)
 
 	fs, addr := requireSignerAddr(t)
-	addr2, err := fs.NewAddress()
+	addr2, err := fs.NewAddress(address.SECP256K1)
 	require.NoError(t, err)
 
 	msg := types.NewMessage(addr, addr, 1, types.ZeroAttoFIL, "", nil)
@@ -166,3 +166,86 @@ func TestSignedMessageCorrupted(t *testing.T) {
 	smsg.Message.Nonce = types.Uint64(uint64(42))
 	assert.False(t, smsg.VerifySignature())
 }
+
+/* Test BLS signatures */
+
+func requireBLSSignerAddr(t *testing.T) (*DSBackend, address.Address) {
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	addr, err := fs.NewAddress(address.BLS)
+	require.NoError(t, err)
+	return fs, addr
+}
+
+// BLS signature is over the data being verified and was signed by the verifying address.
+func TestBLSSignatureOk(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, addr := requireBLSSignerAddr(t)
+
+	data := []byte("THESE BYTES WILL BE SIGNED")
+	sig, err := fs.SignBytes(data, addr)
+	require.NoError(t, err)
+
+	assert.True(t, types.IsValidSignature(data, addr, sig))
+
+	ki, err := fs.GetKeyInfo(addr)
+	require.NoError(t, err)
+	assert.True(t, fs.Verify(data, ki.PublicKey(), sig))
+}
+
+// BLS signature is over different data, or by a different address.
+func TestBLSSignatureInvalid(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, addr := requireBLSSignerAddr(t)
+
+	data := []byte("THESE BYTES ARE SIGNED")
+	sig, err := fs.SignBytes(data, addr)
+	require.NoError(t, err)
+
+	assert.False(t, types.IsValidSignature([]byte("THESE BYTEZ ARE SIGNED"), addr, sig))
+
+	badAddr, err := fs.NewAddress(address.BLS)
+	require.NoError(t, err)
+	assert.False(t, types.IsValidSignature(data, badAddr, sig))
+
+	_, secpAddr := requireSignerAddr(t)
+	assert.False(t, types.IsValidSignature(data, secpAddr, sig))
+
+	assert.False(t, types.IsValidSignature(data, addr, nil))
+}
+
+// Valid SignedMessage from a BLS address verifies correctly.
+func TestBLSSignMessageOk(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, addr := requireBLSSignerAddr(t)
+
+	msg := types.NewMessage(addr, addr, 1, types.ZeroAttoFIL, "", nil)
+	smsg, err := types.NewSignedMessage(*msg, fs, types.NewGasPrice(0), types.NewGasUnits(0))
+	require.NoError(t, err)
+
+	assert.True(t, smsg.VerifySignature())
+
+	smsg.Message.Nonce = types.Uint64(uint64(42))
+	assert.False(t, smsg.VerifySignature())
+}
+
+/* Test types.PublicKeyAddress */
+
+func TestPublicKeyAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	for _, newSigner := range []func(*testing.T) (*DSBackend, address.Address){requireSignerAddr, requireBLSSignerAddr} {
+		fs, addr := newSigner(t)
+		ki, err := fs.GetKeyInfo(addr)
+		require.NoError(t, err)
+
+		pkAddr, err := types.PublicKeyAddress(ki.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, addr, pkAddr)
+	}
+}
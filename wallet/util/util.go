@@ -4,6 +4,7 @@ import (
 	"github.com/minio/blake2b-simd"
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 )
 
@@ -36,3 +37,61 @@ func Ecrecover(data, signature []byte) ([]byte, error) {
 	hash := blake2b.Sum256(data)
 	return crypto.EcRecover(hash[:], signature)
 }
+
+// SignBLS signs `data` using the BLS private key `priv`.
+func SignBLS(priv, data []byte) ([]byte, error) {
+	if len(priv) != bls.PrivateKeyBytes {
+		return nil, errors.Errorf("invalid BLS private key length %d", len(priv))
+	}
+	var key bls.PrivateKey
+	copy(key[:], priv)
+	sig := bls.PrivateKeySign(key, data)
+	return sig[:], nil
+}
+
+// VerifyBLS cryptographically verifies that 'signature' is the BLS signature of 'data' with
+// the public key `pk`.
+func VerifyBLS(pk []byte, data, signature []byte) bool {
+	if len(pk) != bls.PublicKeyBytes || len(signature) != bls.SignatureBytes {
+		return false
+	}
+	var key bls.PublicKey
+	copy(key[:], pk)
+	var sig bls.Signature
+	copy(sig[:], signature)
+	return bls.Verify(sig, []bls.Digest{bls.Hash(data)}, []bls.PublicKey{key})
+}
+
+// AggregateBLS aggregates BLS signatures into one signature, which VerifyBLSAggregate verifies
+// over all the data signed.
+func AggregateBLS(signatures [][]byte) ([]byte, error) {
+	sigs := make([]bls.Signature, len(signatures))
+	for i, signature := range signatures {
+		if len(signature) != bls.SignatureBytes {
+			return nil, errors.Errorf("invalid BLS signature length %d", len(signature))
+		}
+		copy(sigs[i][:], signature)
+	}
+	agg := bls.Aggregate(sigs)
+	return agg[:], nil
+}
+
+// VerifyBLSAggregate cryptographically verifies that 'signature' is the aggregate of the BLS
+// signatures of each of 'data' with the public key of the same index in `pks`.
+func VerifyBLSAggregate(pks [][]byte, data [][]byte, signature []byte) bool {
+	if len(pks) != len(data) || len(signature) != bls.SignatureBytes {
+		return false
+	}
+	keys := make([]bls.PublicKey, len(pks))
+	digests := make([]bls.Digest, len(data))
+	for i, pk := range pks {
+		if len(pk) != bls.PublicKeyBytes {
+			return false
+		}
+		copy(keys[i][:], pk)
+		digests[i] = bls.Hash(data[i])
+	}
+	var sig bls.Signature
+	copy(sig[:], signature)
+	return bls.Verify(sig, digests, keys)
+}
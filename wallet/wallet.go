@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/types"
 	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
@@ -49,17 +52,13 @@ func (w *Wallet) HasAddress(a address.Address) bool {
 }
 
 // Find searches through all backends and returns the one storing the passed
-// in address.
+// in address. The local backends are searched first, so that finding their
+// addresses never waits for a remote signer.
 // Safe for concurrent access.
 func (w *Wallet) Find(addr address.Address) (Backend, error) {
-	w.lk.Lock()
-	defer w.lk.Unlock()
-
-	for _, backends := range w.backends {
-		for _, backend := range backends {
-			if backend.HasAddress(addr) {
-				return backend, nil
-			}
+	for _, backend := range w.allBackends() {
+		if backend.HasAddress(addr) {
+			return backend, nil
 		}
 	}
 
@@ -70,14 +69,9 @@ func (w *Wallet) Find(addr address.Address) (Backend, error) {
 // Safe for concurrent access.
 // Always sorted in the same order.
 func (w *Wallet) Addresses() []address.Address {
-	w.lk.Lock()
-	defer w.lk.Unlock()
-
 	var out []address.Address
-	for _, backends := range w.backends {
-		for _, backend := range backends {
-			out = append(out, backend.Addresses()...)
-		}
+	for _, backend := range w.allBackends() {
+		out = append(out, backend.Addresses()...)
 	}
 	sort.Slice(out, func(i, j int) bool {
 		return bytes.Compare(out[i].Bytes(), out[j].Bytes()) < 0
@@ -96,6 +90,92 @@ func (w *Wallet) Backends(kind reflect.Type) []Backend {
 	return cpy
 }
 
+// Lock locks all backends of the wallet that can be locked.
+func (w *Wallet) Lock() {
+	for _, locker := range w.lockers() {
+		locker.Lock()
+	}
+}
+
+// Unlock unlocks all backends of the wallet that can be locked with the passphrase, until
+// they are locked again or the timeout passes. A zero timeout never passes.
+func (w *Wallet) Unlock(passphrase []byte, timeout time.Duration) error {
+	for _, locker := range w.lockers() {
+		if err := locker.Unlock(passphrase, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangePassphrase re-encrypts all backends of the wallet that can be locked with
+// newPassphrase, if passphrase is their current one. The backends stay locked, or
+// unlocked, as they were.
+func (w *Wallet) ChangePassphrase(passphrase, newPassphrase []byte) error {
+	for _, locker := range w.lockers() {
+		if err := locker.ReplacePassphrase(passphrase, newPassphrase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsLocked returns true if any backend of the wallet is locked.
+func (w *Wallet) IsLocked() bool {
+	for _, locker := range w.lockers() {
+		if locker.IsLocked() {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Wallet) lockers() []Locker {
+	var out []Locker
+	for _, backend := range w.allBackends() {
+		if locker, ok := backend.(Locker); ok {
+			out = append(out, locker)
+		}
+	}
+	return out
+}
+
+// allBackends returns the backends of the wallet, the datastore backends first and remote
+// ones last. They are used without holding the wallet's lock, as a remote backend may wait
+// for its signer.
+func (w *Wallet) allBackends() []Backend {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	kinds := make([]reflect.Type, 0, len(w.backends))
+	for kind := range w.backends {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		return backendRank(kinds[i]) < backendRank(kinds[j]) ||
+			(backendRank(kinds[i]) == backendRank(kinds[j]) && kinds[i].String() < kinds[j].String())
+	})
+
+	var out []Backend
+	for _, kind := range kinds {
+		out = append(out, w.backends[kind]...)
+	}
+	return out
+}
+
+// backendRank orders the kinds of backends: local datastores, other kinds, then remote
+// signers.
+func backendRank(kind reflect.Type) int {
+	switch kind {
+	case DSBackendType:
+		return 0
+	case RemoteBackendType:
+		return 2
+	default:
+		return 1
+	}
+}
+
 // SignBytes cryptographically signs `data` using the private key corresponding to
 // address `addr`
 func (w *Wallet) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
@@ -107,6 +187,33 @@ func (w *Wallet) SignBytes(data []byte, addr address.Address) (types.Signature,
 	return backend.SignBytes(data, addr)
 }
 
+// signedDataPrefix starts the data signed by SignData. 0x19 is a valid CBOR head, of a
+// 16-bit unsigned integer, but no CBOR object the node signs starts with it: messages,
+// blocks and deal proposals are CBOR maps. The other data the node signs, tickets and
+// payment channel vouchers, start with a PoSt proof and a sequential channel ID respectively,
+// neither of which is chosen by whoever asks for data to be signed.
+const signedDataPrefix = "\x19Filecoin Signed Data:\n"
+
+// PrefixSignedData returns the bytes SignData signs for the data: a prefix separating them
+// from messages and the other data the node signs, the length of the data and the data.
+func PrefixSignedData(data []byte) []byte {
+	prefix := signedDataPrefix + strconv.Itoa(len(data))
+	return append([]byte(prefix), data...)
+}
+
+// SignData signs arbitrary data, such as a challenge proving ownership of the address,
+// with the key of the address. The signature is of the data with PrefixSignedData, so
+// that it can't be replayed as the signature of a message.
+func (w *Wallet) SignData(data []byte, addr address.Address) (types.Signature, error) {
+	return w.SignBytes(PrefixSignedData(data), addr)
+}
+
+// VerifyData returns true if sig is a signature of the data made by SignData with the key
+// of the address.
+func VerifyData(data []byte, addr address.Address, sig types.Signature) bool {
+	return types.IsValidSignature(PrefixSignedData(data), addr, sig)
+}
+
 // GetAddressForPubKey looks up a KeyInfo address associated with a given PublicKey
 func (w *Wallet) GetAddressForPubKey(pk []byte) (address.Address, error) {
 	var addr address.Address
@@ -125,8 +232,11 @@ func (w *Wallet) GetAddressForPubKey(pk []byte) (address.Address, error) {
 }
 
 // Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
-// the public key `pk`.
+// the public key `pk`, a secp256k1 or BLS key.
 func (w *Wallet) Verify(data []byte, pk []byte, sig types.Signature) (bool, error) {
+	if len(pk) == bls.PublicKeyBytes {
+		return wutil.VerifyBLS(pk, data, sig), nil
+	}
 	return wutil.Verify(pk, data, sig)
 }
 
@@ -138,15 +248,41 @@ func (w *Wallet) Ecrecover(data []byte, sig types.Signature) ([]byte, error) {
 	return wutil.Ecrecover(data, sig)
 }
 
-// NewAddress creates a new account address on the default wallet backend.
-func NewAddress(w *Wallet) (address.Address, error) {
+// NewAddress creates a new account address using the given protocol on the default wallet
+// backend.
+func NewAddress(w *Wallet, protocol address.Protocol) (address.Address, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return address.Undef, err
+	}
+	return backend.NewAddress(protocol)
+}
+
+// SetSeed sets the HD seed the default wallet backend derives new secp256k1 keys from.
+func SetSeed(w *Wallet, seed []byte) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
+	}
+	return backend.SetSeed(seed)
+}
+
+// DeriveAddress returns the address of the secp256k1 key the default wallet backend derives
+// from its HD seed with the index, without storing the key.
+func DeriveAddress(w *Wallet, index uint32) (address.Address, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return address.Undef, err
+	}
+	return backend.DeriveAddress(index)
+}
+
+func defaultBackend(w *Wallet) (*DSBackend, error) {
 	backends := w.Backends(DSBackendType)
 	if len(backends) == 0 {
-		return address.Undef, fmt.Errorf("missing default ds backend")
+		return nil, fmt.Errorf("missing default ds backend")
 	}
-
-	backend := (backends[0]).(*DSBackend)
-	return backend.NewAddress()
+	return (backends[0]).(*DSBackend), nil
 }
 
 // GetPubKeyForAddress returns the public key in the keystore associated with
@@ -162,7 +298,7 @@ func (w *Wallet) GetPubKeyForAddress(addr address.Address) ([]byte, error) {
 
 // NewKeyInfo creates a new KeyInfo struct in the wallet backend and returns it
 func (w *Wallet) NewKeyInfo() (*types.KeyInfo, error) {
-	newAddr, err := NewAddress(w)
+	newAddr, err := NewAddress(w, address.SECP256K1)
 	if err != nil {
 		return &types.KeyInfo{}, err
 	}
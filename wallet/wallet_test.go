@@ -30,7 +30,7 @@ func TestWalletSimple(t *testing.T) {
 	assert.Len(t, w.Backends(wallet.DSBackendType), 1)
 
 	t.Log("create a new address in the backend")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("test HasAddress")
@@ -52,7 +52,7 @@ func TestWalletSimple(t *testing.T) {
 	assert.Equal(t, list[0], addr)
 
 	t.Log("addresses are sorted")
-	addr2, err := fs.NewAddress()
+	addr2, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	if bytes.Compare(addr2.Bytes(), addr.Bytes()) < 0 {
@@ -81,7 +81,7 @@ func TestSimpleSignAndVerify(t *testing.T) {
 	assert.Len(t, w.Backends(wallet.DSBackendType), 1)
 
 	t.Log("create a new address in the backend")
-	addr, err := fs.NewAddress()
+	addr, err := fs.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("test HasAddress")
@@ -149,9 +149,9 @@ func TestSignErrorCases(t *testing.T) {
 	assert.Len(t, w2.Backends(wallet.DSBackendType), 1)
 
 	t.Log("create a new address each backend")
-	addr1, err := fs1.NewAddress()
+	addr1, err := fs1.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
-	addr2, err := fs2.NewAddress()
+	addr2, err := fs2.NewAddress(address.SECP256K1)
 	assert.NoError(t, err)
 
 	t.Log("test HasAddress")
@@ -199,6 +199,43 @@ func TestGetAddressForPubKeyy(t *testing.T) {
 
 }
 
+func TestSignAndVerifyData(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := wallet.NewDSBackend(ds)
+	require.NoError(t, err)
+	w := wallet.New(fs)
+
+	secpAddr, err := fs.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	blsAddr, err := fs.NewAddress(address.BLS)
+	require.NoError(t, err)
+	data := []byte("I own this address")
+
+	for _, addr := range []address.Address{secpAddr, blsAddr} {
+		sig, err := w.SignData(data, addr)
+		require.NoError(t, err)
+
+		assert.True(t, wallet.VerifyData(data, addr, sig))
+		assert.False(t, wallet.VerifyData([]byte("other data"), addr, sig))
+		assert.False(t, wallet.VerifyData(data, address.NewForTestGetter()(), sig))
+
+		t.Log("the signature is not one of the data itself")
+		assert.False(t, types.IsValidSignature(data, addr, sig))
+	}
+
+	t.Log("signed data is separated from messages")
+	assert.Equal(t, []byte("\x19Filecoin Signed Data:\n3abc"), wallet.PrefixSignedData([]byte("abc")))
+	msg := types.NewMessage(secpAddr, secpAddr, 0, types.ZeroAttoFIL, "", nil)
+	smsg, err := types.NewSignedMessage(*msg, w, types.NewGasPrice(0), types.NewGasUnits(0))
+	require.NoError(t, err)
+	bmsg, err := smsg.MeteredMessage.Marshal()
+	require.NoError(t, err)
+	assert.NotEqual(t, wallet.PrefixSignedData(nil)[0], bmsg[0])
+	assert.False(t, wallet.VerifyData(bmsg, secpAddr, smsg.Signature))
+}
+
 func TestWallet_CreateTicket(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -206,7 +243,7 @@ func TestWallet_CreateTicket(t *testing.T) {
 	fs, err := wallet.NewDSBackend(ds)
 	assert.NoError(t, err)
 	w := wallet.New(fs)
-	addr, err := wallet.NewAddress(w)
+	addr, err := wallet.NewAddress(w, address.SECP256K1)
 	require.NoError(t, err)
 	pubKey, err := w.GetPubKeyForAddress(addr)
 	require.NoError(t, err)